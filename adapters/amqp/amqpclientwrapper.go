@@ -0,0 +1,111 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	amqp091 "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	// Kind of exchanges created for topics. Existing exchanges are used with their own kind.
+	exchangeKind = amqp091.ExchangeTopic
+
+	// Queues are bound to topic exchanges with a key matching every routing key
+	bindingKey = "#"
+)
+
+// AMQP connection wrapper which implements client interface.
+// A failed passive declaration closes its channel, so every provisioning operation uses its own channel.
+type amqpClientWrapper struct {
+	connection *amqp091.Connection
+}
+
+func (a *amqpClientWrapper) ExchangeExists(name string) (bool, error) {
+	channel, err := a.connection.Channel()
+	if err != nil {
+		return false, err
+	}
+	defer channel.Close()
+
+	err = channel.ExchangeDeclarePassive(name, exchangeKind, true, false, false, false, nil)
+	var amqpError *amqp091.Error
+	if errors.As(err, &amqpError) && amqpError.Code == amqp091.NotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (a *amqpClientWrapper) DeclareExchange(name string) error {
+	channel, err := a.connection.Channel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	return channel.ExchangeDeclare(name, exchangeKind, true, false, false, false, nil)
+}
+
+func (a *amqpClientWrapper) DeclareQueue(name string, args amqp091.Table) error {
+	channel, err := a.connection.Channel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	_, err = channel.QueueDeclare(name, true, false, false, false, args)
+	return err
+}
+
+func (a *amqpClientWrapper) BindQueue(queue string, exchange string) error {
+	channel, err := a.connection.Channel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	return channel.QueueBind(queue, bindingKey, exchange, false, nil)
+}
+
+// Starts consuming queue with manual acknowledgements on a dedicated channel.
+// At most prefetch deliveries are unacknowledged at the same time.
+func (a *amqpClientWrapper) Consume(queue string, prefetch int) (<-chan amqp091.Delivery, error) {
+	channel, err := a.connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+
+	err = channel.Qos(prefetch, 0, false)
+	if err != nil {
+		channel.Close()
+		return nil, err
+	}
+
+	return channel.Consume(queue, "", false, false, false, false, nil)
+}
+
+// Publishes message to queue through default exchange on its own channel in confirm mode, so message is not lost when
+// caller acknowledges the original delivery afterwards
+func (a *amqpClientWrapper) Requeue(queue string, message amqp091.Publishing) error {
+	channel, err := a.connection.Channel()
+	if err != nil {
+		return err
+	}
+	defer channel.Close()
+
+	err = channel.Confirm(false)
+	if err != nil {
+		return err
+	}
+
+	confirmation, err := channel.PublishWithDeferredConfirmWithContext(context.Background(), "", queue, true, false, message)
+	if err != nil {
+		return err
+	}
+	if !confirmation.Wait() {
+		return fmt.Errorf("requeued message of queue %s is not confirmed by broker", queue)
+	}
+
+	return nil
+}
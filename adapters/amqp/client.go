@@ -0,0 +1,17 @@
+package amqp
+
+import (
+	amqp091 "github.com/rabbitmq/amqp091-go"
+)
+
+// AMQP 0-9-1 broker operations used while provisioning and running subscriptions
+type client interface {
+	ExchangeExists(name string) (bool, error)
+	DeclareExchange(name string) error
+	DeclareQueue(name string, args amqp091.Table) error
+	BindQueue(queue string, exchange string) error
+	Consume(queue string, prefetch int) (<-chan amqp091.Delivery, error)
+
+	// Publishes message to queue through default exchange and waits for broker to confirm it
+	Requeue(queue string, message amqp091.Publishing) error
+}
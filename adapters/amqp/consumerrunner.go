@@ -0,0 +1,146 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/metglobal-compass/pusu"
+	amqp091 "github.com/rabbitmq/amqp091-go"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// Messages are dead-lettered after this many deliveries
+	defaultMaxDeliveries = 5
+
+	// Header of redelivered messages of quorum queues which holds number of their previous deliveries
+	deliveryCountHeader = "x-delivery-count"
+
+	// Maximum number of unacknowledged deliveries, which are handled concurrently
+	defaultPrefetch = 10
+
+	// Waiting duration before requeueing a message rejected by subscription (e.g. circuit is open)
+	defaultRejectionBackoff = time.Second
+)
+
+// Queue consumer runner which implements pusu.Runner interface
+type consumerRunner struct {
+	client client
+
+	prefetch         int
+	rejectionBackoff time.Duration
+
+	// Counts outcomes of handled messages. Optional.
+	metrics *pusu.Metrics
+}
+
+// Consumes queue of subscription until delivery channel is closed. Up to prefetch deliveries are handled concurrently.
+// A delivery is acked after successful handling, otherwise it is requeued and dead-lettered by broker after
+// maximum deliveries. Deliveries which handler requires to dead letter are dead-lettered right away.
+func (c *consumerRunner) Run(subscription pusu.Subscription) error {
+	deliveries, err := c.client.Consume(subscription.Name(), c.prefetch)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	for delivery := range deliveries {
+		go c.handle(ctx, subscription, delivery)
+	}
+
+	return fmt.Errorf("delivery channel of subscription %s is closed", subscription.Name())
+}
+
+// Handles delivery with subscription and acknowledges result to broker
+func (c *consumerRunner) handle(ctx context.Context, subscription pusu.Subscription, delivery amqp091.Delivery) {
+	message := pusu.NewMessage(string(delivery.Body)).WithAttributes(attributes(delivery)).WithContext(ctx)
+	err := pusu.HandleBlocking(ctx, subscription, message)
+	if c.metrics != nil {
+		c.metrics.Observe(subscription, err)
+	}
+
+	if err == nil {
+		err = delivery.Ack(false)
+	} else if pusu.IsRejection(err) {
+		// Handler was not called at all. Nacked deliveries count to delivery limit of quorum queue, so publish same
+		// message again as a new one after a while and ack this delivery instead.
+		time.Sleep(c.rejectionBackoff)
+		err = c.requeue(subscription, delivery)
+	} else if errors.Is(err, pusu.ErrDeadLetter) {
+		log.Printf("Handler of subscription %s requires dead lettering: %s", subscription.Name(), err)
+		err = delivery.Nack(false, false)
+	} else {
+		log.Printf("Handler of subscription %s failed: %s", subscription.Name(), err)
+		err = delivery.Nack(false, true)
+	}
+
+	if err != nil {
+		log.Printf("Acknowledgement of subscription %s failed: %s", subscription.Name(), err)
+	}
+}
+
+// Publishes delivery to queue of subscription again and acks it. Delivery is nacked to be requeued by broker if it
+// can not be published.
+func (c *consumerRunner) requeue(subscription pusu.Subscription, delivery amqp091.Delivery) error {
+	// Delivery count of broker is left out, so message starts over as a new one
+	headers := amqp091.Table{}
+	for key, value := range delivery.Headers {
+		if key != deliveryCountHeader {
+			headers[key] = value
+		}
+	}
+
+	err := c.client.Requeue(subscription.Name(), amqp091.Publishing{
+		Headers:         headers,
+		ContentType:     delivery.ContentType,
+		ContentEncoding: delivery.ContentEncoding,
+		DeliveryMode:    amqp091.Persistent,
+		Priority:        delivery.Priority,
+		CorrelationId:   delivery.CorrelationId,
+		ReplyTo:         delivery.ReplyTo,
+		Expiration:      delivery.Expiration,
+		MessageId:       delivery.MessageId,
+		Timestamp:       delivery.Timestamp,
+		Type:            delivery.Type,
+		UserId:          delivery.UserId,
+		AppId:           delivery.AppId,
+		Body:            delivery.Body,
+	})
+	if err != nil {
+		log.Printf("Rejected message of subscription %s could not be published again: %s", subscription.Name(), err)
+		return delivery.Nack(false, true)
+	}
+
+	return delivery.Ack(false)
+}
+
+// Returns attributes of delivery, which are its headers of scalar values except headers of broker (x-...). Byte
+// arrays are taken as strings and other scalars in their printed form. Returns nil if there are none.
+func attributes(delivery amqp091.Delivery) map[string]string {
+	var attributes map[string]string
+	for key, value := range delivery.Headers {
+		if strings.HasPrefix(key, "x-") {
+			continue
+		}
+
+		var attribute string
+		switch v := value.(type) {
+		case string:
+			attribute = v
+		case []byte:
+			attribute = string(v)
+		case bool, int8, int16, int32, int64, uint8, uint16, uint32, float32, float64, amqp091.Decimal, time.Time:
+			attribute = fmt.Sprint(v)
+		default:
+			continue
+		}
+		if attributes == nil {
+			attributes = make(map[string]string, len(delivery.Headers))
+		}
+		attributes[key] = attribute
+	}
+
+	return attributes
+}
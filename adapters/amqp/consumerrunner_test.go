@@ -0,0 +1,145 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	"github.com/metglobal-compass/pusu/pusutest"
+	amqp091 "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+func TestConsumerRunner_Run(t *testing.T) {
+	// Create fake acknowledger which signals acknowledgement
+	acked := make(chan struct{})
+	fakeAcknowledger := new(fakeAcknowledger)
+	fakeAcknowledger.On("Ack", uint64(1), false).Return(nil).Run(func(args mock.Arguments) {
+		close(acked)
+	})
+
+	// Create fake client with a single delivery
+	deliveries := make(chan amqp091.Delivery, 1)
+	deliveries <- amqp091.Delivery{Acknowledger: fakeAcknowledger, DeliveryTag: 1, Body: []byte("payload"),
+		Headers: amqp091.Table{"type": "created", "version": int32(2), "x-delivery-count": int64(1), "tags": []interface{}{"a"}}}
+	fakeClient := new(fakeClient)
+	fakeClient.On("Consume", "testing", 10).Return((<-chan amqp091.Delivery)(deliveries), nil)
+
+	// Call real method with a successful subscription
+	runner := &consumerRunner{client: fakeClient, prefetch: 10}
+	subscription := new(pusutest.Subscription).WillHaveProperFields()
+	result := make(chan error)
+	go func() {
+		result <- runner.Run(subscription)
+	}()
+
+	// Delivery must be acked and runner must stop when delivery channel is closed
+	<-acked
+	close(deliveries)
+	assert.Error(t, <-result)
+
+	// Delivery must be handled with its scalar headers other than headers of broker
+	subscription.(*pusutest.Subscription).AssertCalled(t, "Handle", mock.MatchedBy(func(m *pusu.Message) bool {
+		return m.Message() == "payload" && assert.ObjectsAreEqual(map[string]string{"type": "created", "version": "2"}, m.Attributes())
+	}))
+}
+
+func TestConsumerRunner_RunErrorOnConsume(t *testing.T) {
+	// Create fake client which can not consume queue
+	fakeClient := new(fakeClient)
+	fakeClient.On("Consume", "testing", 10).Return(nil, errors.New("error"))
+
+	// Runner must return error
+	runner := &consumerRunner{client: fakeClient, prefetch: 10}
+	assert.Error(t, runner.Run(new(pusutest.Subscription).WillHaveProperFields()))
+}
+
+func TestConsumerRunner_HandleFailure(t *testing.T) {
+	// Failed delivery must be requeued
+	fakeAcknowledger := new(fakeAcknowledger)
+	fakeAcknowledger.On("Nack", uint64(1), false, true).Return(nil)
+
+	runner := &consumerRunner{metrics: new(pusu.Metrics)}
+	subscription := new(pusutest.Subscription).WillReturnError()
+	runner.handle(context.Background(), subscription, amqp091.Delivery{Acknowledger: fakeAcknowledger, DeliveryTag: 1})
+
+	fakeAcknowledger.AssertNumberOfCalls(t, "Nack", 1)
+	fakeAcknowledger.AssertNotCalled(t, "Ack", mock.Anything, mock.Anything)
+	assert.Equal(t, int64(1), runner.metrics.Count(subscription, pusu.OutcomeFailure))
+}
+
+func TestConsumerRunner_HandleRejection(t *testing.T) {
+	// Rejected delivery must be published again as a new message after backoff and acked, so it does not count to
+	// delivery limit
+	fakeAcknowledger := new(fakeAcknowledger)
+	fakeAcknowledger.On("Ack", uint64(1), false).Return(nil)
+	fakeClient := new(fakeClient)
+	fakeClient.On("Requeue", "testing", mock.MatchedBy(func(message amqp091.Publishing) bool {
+		_, counted := message.Headers[deliveryCountHeader]
+		return string(message.Body) == "payload" && message.Headers["type"] == "created" && !counted
+	})).Return(nil)
+
+	runner := &consumerRunner{client: fakeClient, rejectionBackoff: 10 * time.Millisecond}
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(pusu.ErrRateLimited)
+	start := time.Now()
+	delivery := amqp091.Delivery{
+		Acknowledger: fakeAcknowledger,
+		DeliveryTag:  1,
+		Headers:      amqp091.Table{"type": "created", deliveryCountHeader: int64(4)},
+		Body:         []byte("payload"),
+	}
+	runner.handle(context.Background(), subscription, delivery)
+
+	fakeClient.AssertNumberOfCalls(t, "Requeue", 1)
+	fakeAcknowledger.AssertNumberOfCalls(t, "Ack", 1)
+	fakeAcknowledger.AssertNotCalled(t, "Nack", mock.Anything, mock.Anything, mock.Anything)
+	assert.True(t, time.Since(start) >= 10*time.Millisecond, "Rejected delivery must be requeued after backoff")
+}
+
+func TestConsumerRunner_HandleRejectionErrorOnRequeue(t *testing.T) {
+	// Rejected delivery must be requeued by broker when it can not be published again
+	fakeAcknowledger := new(fakeAcknowledger)
+	fakeAcknowledger.On("Nack", uint64(1), false, true).Return(nil)
+	fakeClient := new(fakeClient)
+	fakeClient.On("Requeue", "testing", mock.Anything).Return(errors.New("error"))
+
+	runner := &consumerRunner{client: fakeClient}
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(pusu.ErrCircuitOpen)
+	runner.handle(context.Background(), subscription, amqp091.Delivery{Acknowledger: fakeAcknowledger, DeliveryTag: 1})
+
+	fakeAcknowledger.AssertNumberOfCalls(t, "Nack", 1)
+	fakeAcknowledger.AssertNotCalled(t, "Ack", mock.Anything, mock.Anything)
+}
+
+func TestConsumerRunner_HandleDeadLetter(t *testing.T) {
+	// Delivery must be dead-lettered without requeueing when handler requires it
+	fakeAcknowledger := new(fakeAcknowledger)
+	fakeAcknowledger.On("Nack", uint64(1), false, false).Return(nil)
+
+	runner := &consumerRunner{}
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(pusu.ErrDeadLetter)
+	runner.handle(context.Background(), subscription, amqp091.Delivery{Acknowledger: fakeAcknowledger, DeliveryTag: 1})
+
+	fakeAcknowledger.AssertCalled(t, "Nack", uint64(1), false, false)
+}
+
+type fakeAcknowledger struct {
+	mock.Mock
+}
+
+func (f *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	args := f.Called(tag, multiple)
+	return args.Error(0)
+}
+
+func (f *fakeAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	args := f.Called(tag, multiple, requeue)
+	return args.Error(0)
+}
+
+func (f *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	args := f.Called(tag, requeue)
+	return args.Error(0)
+}
@@ -0,0 +1,61 @@
+package amqp
+
+import (
+	"fmt"
+	"github.com/metglobal-compass/pusu"
+	amqp091 "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	// Messages failed on every delivery are dead-lettered to this queue of subscription
+	deadLetterQueuePattern = "%s.dlq"
+)
+
+type queueAdder struct {
+	client client
+
+	// Maximum delivery attempts of a message before it is dead-lettered
+	maxDeliveries int
+}
+
+// Implementation of internal Creator interface for AMQP Adapter
+func (q *queueAdder) CreateSubscription(subscription pusu.Subscription) error {
+	// Check if exchange exists
+	exists, err := q.client.ExchangeExists(subscription.Topic())
+	if err != nil {
+		return err
+	}
+
+	// If exchange does not exists, create it
+	if !exists {
+		err = q.client.DeclareExchange(subscription.Topic())
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create dead letter queue of subscription
+	err = q.client.DeclareQueue(deadLetterQueue(subscription), nil)
+	if err != nil {
+		return err
+	}
+
+	// Create durable quorum queue which dead-letters messages to dead letter queue through default exchange
+	// after maximum deliveries
+	err = q.client.DeclareQueue(subscription.Name(), amqp091.Table{
+		"x-queue-type":              amqp091.QueueTypeQuorum,
+		"x-delivery-limit":          q.maxDeliveries,
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": deadLetterQueue(subscription),
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.client.BindQueue(subscription.Name(), subscription.Topic())
+}
+
+// Returns name of dead letter queue of subscription
+func deadLetterQueue(subscription pusu.Subscription) string {
+	return fmt.Sprintf(deadLetterQueuePattern, subscription.Name())
+}
@@ -0,0 +1,120 @@
+package amqp
+
+import (
+	"errors"
+	"github.com/metglobal-compass/pusu/pusutest"
+	amqp091 "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+func TestQueueAdder_CreateSubscription(t *testing.T) {
+	// Create fake mocked client. In this case, exchange does not exist
+	fakeClient := new(fakeClient)
+	fakeClient.On("ExchangeExists", "test").Return(false, nil)
+	fakeClient.On("DeclareExchange", "test").Return(nil)
+	fakeClient.On("DeclareQueue", mock.Anything, mock.Anything).Return(nil)
+	fakeClient.On("BindQueue", "testing", "test").Return(nil)
+
+	// Call real method
+	queueAdder := &queueAdder{client: fakeClient, maxDeliveries: 3}
+	err := queueAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Everything is fine, so real method must return nil as error
+	assert.Nil(t, err)
+
+	// Check queues are declared with proper arguments
+	fakeClient.AssertCalled(t, "DeclareQueue", "testing.dlq", amqp091.Table(nil))
+	fakeClient.AssertCalled(t, "DeclareQueue", "testing", amqp091.Table{
+		"x-queue-type":              amqp091.QueueTypeQuorum,
+		"x-delivery-limit":          3,
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": "testing.dlq",
+	})
+	fakeClient.AssertExpectations(t)
+}
+
+func TestQueueAdder_CreateSubscriptionExistingExchange(t *testing.T) {
+	// Create fake mocked client. In this case, exchange of a legacy service exists
+	fakeClient := new(fakeClient)
+	fakeClient.On("ExchangeExists", "test").Return(true, nil)
+	fakeClient.On("DeclareQueue", mock.Anything, mock.Anything).Return(nil)
+	fakeClient.On("BindQueue", "testing", "test").Return(nil)
+
+	// Call real method
+	queueAdder := &queueAdder{client: fakeClient, maxDeliveries: 3}
+	err := queueAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Existing exchange must not be declared again
+	assert.Nil(t, err)
+	fakeClient.AssertNotCalled(t, "DeclareExchange", mock.Anything)
+	fakeClient.AssertNumberOfCalls(t, "BindQueue", 1)
+}
+
+func TestQueueAdder_CreateSubscriptionErrorOnExchangeExists(t *testing.T) {
+	// Create fake mocked client. In this case, we get an error while checking exchange's existence
+	fakeClient := new(fakeClient)
+	fakeClient.On("ExchangeExists", "test").Return(false, errors.New("error"))
+
+	// Call real method
+	queueAdder := &queueAdder{client: fakeClient, maxDeliveries: 3}
+	err := queueAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Got an error and following methods must not be called
+	assert.Error(t, err)
+	fakeClient.AssertNotCalled(t, "DeclareExchange", mock.Anything)
+	fakeClient.AssertNotCalled(t, "DeclareQueue", mock.Anything, mock.Anything)
+	fakeClient.AssertNotCalled(t, "BindQueue", mock.Anything, mock.Anything)
+}
+
+func TestQueueAdder_CreateSubscriptionErrorOnDeclareQueue(t *testing.T) {
+	// Create fake mocked client. In this case, we get an error while declaring queue
+	fakeClient := new(fakeClient)
+	fakeClient.On("ExchangeExists", "test").Return(true, nil)
+	fakeClient.On("DeclareQueue", "testing.dlq", mock.Anything).Return(nil)
+	fakeClient.On("DeclareQueue", "testing", mock.Anything).Return(errors.New("error"))
+
+	// Call real method
+	queueAdder := &queueAdder{client: fakeClient, maxDeliveries: 3}
+	err := queueAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Got an error and queue must not be bound
+	assert.Error(t, err)
+	fakeClient.AssertNotCalled(t, "BindQueue", mock.Anything, mock.Anything)
+}
+
+type fakeClient struct {
+	mock.Mock
+}
+
+func (f *fakeClient) ExchangeExists(name string) (bool, error) {
+	args := f.Called(name)
+	return args.Bool(0), args.Error(1)
+}
+
+func (f *fakeClient) DeclareExchange(name string) error {
+	args := f.Called(name)
+	return args.Error(0)
+}
+
+func (f *fakeClient) DeclareQueue(name string, table amqp091.Table) error {
+	args := f.Called(name, table)
+	return args.Error(0)
+}
+
+func (f *fakeClient) BindQueue(queue string, exchange string) error {
+	args := f.Called(queue, exchange)
+	return args.Error(0)
+}
+
+func (f *fakeClient) Consume(queue string, prefetch int) (<-chan amqp091.Delivery, error) {
+	args := f.Called(queue, prefetch)
+	deliveries, _ := args.Get(0).(<-chan amqp091.Delivery)
+	return deliveries, args.Error(1)
+}
+
+func (f *fakeClient) Requeue(queue string, message amqp091.Publishing) error {
+	args := f.Called(queue, message)
+	return args.Error(0)
+}
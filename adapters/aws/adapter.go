@@ -0,0 +1,79 @@
+// Package aws provides implementation of pub/sub workflow on Amazon SNS and SQS.
+// Topic of a subscription is an SNS topic and name of a subscription is an SQS queue subscribed to it.
+// Messages are unwrapped from SNS envelope, deleted after successful handling and made visible again later on
+// failure. Messages are moved to dead letter queue of subscription after maximum receive count. Receives rejected by
+// subscription wrappers (e.g. circuit is open) do not count to maximum receive count. Attributes of messages are
+// string and number SQS message attributes and SNS message attributes of envelope. Visibility timeout of received
+// messages is extended until they are handled.
+// Endpoints of local stand-ins like LocalStack or ElasticMQ may be set with AWS_ENDPOINT_URL environment variable.
+package aws
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/metglobal-compass/pusu"
+	"net/url"
+)
+
+type Adapter struct {
+	// Adds topic, queue and dead letter queue of subscription to AWS
+	queueAdder pusu.Creator
+
+	// Runs subscription as queue poller
+	runner pusu.Runner
+
+	// Validates and provisions subscriptions, tracking their health and metrics
+	pusu.Provisioner
+}
+
+// Implementation of pusu.Creator interface as part of pusu.Adapter interface
+func (a *Adapter) CreateSubscription(subscription pusu.Subscription) error {
+	return a.Provision(subscription, a.queueAdder)
+}
+
+// Implementation of pusu.Runner interface as part of pusu.Adapter interface
+func (a *Adapter) Run(subscription pusu.Subscription) error {
+	err := a.runner.Run(subscription)
+	return err
+}
+
+// Creates AWS Adapter. Credentials are loaded from default credential chain.
+// region: AWS region of topics and queues. (Ex: eu-west-1)
+func CreateAdapter(region string) (*Adapter, error) {
+	// Validate parameters
+	if region == "" {
+		return nil, errors.New("region must not be empty")
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	client := &awsClientWrapper{sns: sns.NewFromConfig(awsConfig), sqs: sqs.NewFromConfig(awsConfig)}
+
+	awsAdapter := new(Adapter)
+	awsAdapter.queueAdder = &queueAdder{client: client, maxReceiveCount: defaultMaxReceiveCount}
+	awsAdapter.runner = &pollRunner{
+		client:             client,
+		maxReceiveCount:    defaultMaxReceiveCount,
+		failureVisibility:  defaultFailureVisibility,
+		rejectionDelay:     defaultRejectionDelay,
+		inFlightVisibility: defaultInFlightVisibility,
+		heartbeatInterval:  defaultHeartbeatInterval,
+		metrics:            awsAdapter.Metrics(),
+	}
+
+	return awsAdapter, nil
+}
+
+func init() {
+	pusu.RegisterScheme("aws", open)
+}
+
+// Opens AWS Adapter of url in form of aws://<region>
+func open(u *url.URL) (pusu.Adapter, error) {
+	return CreateAdapter(u.Host)
+}
@@ -0,0 +1,43 @@
+package aws
+
+import (
+	"github.com/metglobal-compass/pusu"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAdapter_CreateAdapter(t *testing.T) {
+	// Call real method and check each interfaces have proper types
+	adapter, err := CreateAdapter("eu-west-1")
+
+	assert.Nil(t, err)
+
+	queueAdder, queueAdderProper := adapter.queueAdder.(*queueAdder)
+	assert.True(t, queueAdderProper, "Queue adder is not proper")
+	assert.Equal(t, defaultMaxReceiveCount, queueAdder.maxReceiveCount)
+
+	_, awsClientProper := queueAdder.client.(*awsClientWrapper)
+	assert.True(t, awsClientProper, "AWS client is not proper")
+
+	pollRunner, pollRunnerProper := adapter.runner.(*pollRunner)
+	assert.True(t, pollRunnerProper, "Runner is not proper")
+	assert.True(t, pollRunner.metrics == adapter.Metrics(), "Runner metrics is not proper")
+}
+
+func TestAdapter_CreateAdapterErrorWithEmptyRegion(t *testing.T) {
+	// Call real method without region
+	_, err := CreateAdapter("")
+	assert.Error(t, err)
+}
+
+func TestAdapter_Open(t *testing.T) {
+	// Open adapter by region
+	adapter, err := pusu.Open("aws://eu-west-1")
+	assert.Nil(t, err)
+	_, proper := adapter.(*Adapter)
+	assert.True(t, proper, "Adapter is not proper")
+
+	// Region is required
+	_, err = pusu.Open("aws://")
+	assert.Error(t, err)
+}
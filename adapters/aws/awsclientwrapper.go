@@ -0,0 +1,129 @@
+package aws
+
+import (
+	"context"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	// Maximum number of messages received at once
+	receiveBatchSize = 10
+
+	// Long polling duration of a receive request in seconds
+	receiveWaitSeconds = 20
+)
+
+// SNS and SQS clients wrapper which implements client interface
+type awsClientWrapper struct {
+	sns *sns.Client
+	sqs *sqs.Client
+}
+
+// Creates topic or returns arn of existing topic with the same name
+func (a *awsClientWrapper) CreateTopic(ctx context.Context, name string) (string, error) {
+	output, err := a.sns.CreateTopic(ctx, &sns.CreateTopicInput{Name: awssdk.String(name)})
+	if err != nil {
+		return "", err
+	}
+
+	return awssdk.ToString(output.TopicArn), nil
+}
+
+// Creates queue or returns url of existing queue with the same name
+func (a *awsClientWrapper) CreateQueue(ctx context.Context, name string) (string, error) {
+	output, err := a.sqs.CreateQueue(ctx, &sqs.CreateQueueInput{QueueName: awssdk.String(name)})
+	if err != nil {
+		return "", err
+	}
+
+	return awssdk.ToString(output.QueueUrl), nil
+}
+
+// Returns url of existing queue
+func (a *awsClientWrapper) QueueUrl(ctx context.Context, name string) (string, error) {
+	output, err := a.sqs.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: awssdk.String(name)})
+	if err != nil {
+		return "", err
+	}
+
+	return awssdk.ToString(output.QueueUrl), nil
+}
+
+func (a *awsClientWrapper) QueueArn(ctx context.Context, queueUrl string) (string, error) {
+	output, err := a.sqs.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       awssdk.String(queueUrl),
+		AttributeNames: []sqstypes.QueueAttributeName{sqstypes.QueueAttributeNameQueueArn},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return output.Attributes[string(sqstypes.QueueAttributeNameQueueArn)], nil
+}
+
+func (a *awsClientWrapper) SetQueueAttributes(ctx context.Context, queueUrl string, attributes map[string]string) error {
+	_, err := a.sqs.SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{
+		QueueUrl:   awssdk.String(queueUrl),
+		Attributes: attributes,
+	})
+	return err
+}
+
+// Subscribes queue to topic. Subscribing an already subscribed queue returns existing subscription.
+func (a *awsClientWrapper) Subscribe(ctx context.Context, topicArn string, queueArn string) error {
+	_, err := a.sns.Subscribe(ctx, &sns.SubscribeInput{
+		TopicArn: awssdk.String(topicArn),
+		Protocol: awssdk.String("sqs"),
+		Endpoint: awssdk.String(queueArn),
+	})
+	return err
+}
+
+// Long polls queue for messages together with their receive counts and message attributes
+func (a *awsClientWrapper) ReceiveMessages(ctx context.Context, queueUrl string) ([]sqstypes.Message, error) {
+	output, err := a.sqs.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:              awssdk.String(queueUrl),
+		MaxNumberOfMessages:   receiveBatchSize,
+		WaitTimeSeconds:       receiveWaitSeconds,
+		MessageAttributeNames: []string{"All"},
+		MessageSystemAttributeNames: []sqstypes.MessageSystemAttributeName{
+			sqstypes.MessageSystemAttributeNameApproximateReceiveCount,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return output.Messages, nil
+}
+
+func (a *awsClientWrapper) DeleteMessage(ctx context.Context, queueUrl string, receiptHandle string) error {
+	_, err := a.sqs.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      awssdk.String(queueUrl),
+		ReceiptHandle: awssdk.String(receiptHandle),
+	})
+	return err
+}
+
+func (a *awsClientWrapper) ChangeVisibility(ctx context.Context, queueUrl string, receiptHandle string, timeout int32) error {
+	_, err := a.sqs.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          awssdk.String(queueUrl),
+		ReceiptHandle:     awssdk.String(receiptHandle),
+		VisibilityTimeout: timeout,
+	})
+	return err
+}
+
+// Sends message to queue, which becomes visible after delay
+func (a *awsClientWrapper) SendMessage(ctx context.Context, queueUrl string, body string, attributes map[string]sqstypes.MessageAttributeValue, delaySeconds int32) error {
+	_, err := a.sqs.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:          awssdk.String(queueUrl),
+		MessageBody:       awssdk.String(body),
+		MessageAttributes: attributes,
+		DelaySeconds:      delaySeconds,
+	})
+	return err
+}
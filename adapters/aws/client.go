@@ -0,0 +1,20 @@
+package aws
+
+import (
+	"context"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// SNS and SQS operations used while provisioning and running subscriptions
+type client interface {
+	CreateTopic(ctx context.Context, name string) (string, error)
+	CreateQueue(ctx context.Context, name string) (string, error)
+	QueueUrl(ctx context.Context, name string) (string, error)
+	QueueArn(ctx context.Context, queueUrl string) (string, error)
+	SetQueueAttributes(ctx context.Context, queueUrl string, attributes map[string]string) error
+	Subscribe(ctx context.Context, topicArn string, queueArn string) error
+	ReceiveMessages(ctx context.Context, queueUrl string) ([]sqstypes.Message, error)
+	DeleteMessage(ctx context.Context, queueUrl string, receiptHandle string) error
+	ChangeVisibility(ctx context.Context, queueUrl string, receiptHandle string, timeout int32) error
+	SendMessage(ctx context.Context, queueUrl string, body string, attributes map[string]sqstypes.MessageAttributeValue, delaySeconds int32) error
+}
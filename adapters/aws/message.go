@@ -0,0 +1,63 @@
+package aws
+
+import (
+	"encoding/json"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"strings"
+)
+
+// Amazon SNS notification envelope of messages delivered to SQS queues
+type notification struct {
+	Type              string                           `json:"Type"`
+	MessageId         string                           `json:"MessageId"`
+	TopicArn          string                           `json:"TopicArn"`
+	Message           string                           `json:"Message"`
+	MessageAttributes map[string]notificationAttribute `json:"MessageAttributes"`
+}
+
+// Message attribute of SNS notification envelope
+type notificationAttribute struct {
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+// Returns payload and attributes of SQS message body. Body is unwrapped if it is an SNS notification envelope,
+// otherwise it is returned as is (e.g. raw message delivery or messages sent to queue directly) without attributes.
+func unwrap(body string) (string, map[string]string) {
+	var n notification
+	err := json.Unmarshal([]byte(body), &n)
+	if err != nil || n.Type != "Notification" || n.TopicArn == "" {
+		return body, nil
+	}
+
+	var attributes map[string]string
+	for name, attribute := range n.MessageAttributes {
+		if attribute.Type == "Binary" {
+			continue
+		}
+		if attributes == nil {
+			attributes = make(map[string]string, len(n.MessageAttributes))
+		}
+		attributes[name] = attribute.Value
+	}
+
+	return n.Message, attributes
+}
+
+// Returns attributes of SQS message, which are its string and number message attributes on top of attributes of
+// SNS notification envelope. Binary attributes and attributes of pusu are left out. Returns nil if there are none.
+func attributes(m sqstypes.Message, envelope map[string]string) map[string]string {
+	attributes := envelope
+	for name, value := range m.MessageAttributes {
+		if value.StringValue == nil || strings.HasPrefix(name, "pusu-") {
+			continue
+		}
+		if attributes == nil {
+			attributes = make(map[string]string, len(m.MessageAttributes))
+		}
+		attributes[name] = awssdk.ToString(value.StringValue)
+	}
+
+	return attributes
+}
@@ -0,0 +1,44 @@
+package aws
+
+import (
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUnwrap(t *testing.T) {
+	// SNS notification must be unwrapped
+	body := `{"Type":"Notification","MessageId":"1","TopicArn":"arn:aws:sns:eu-west-1:000000000000:test","Message":"hello"}`
+	payload, attributes := unwrap(body)
+	assert.Equal(t, "hello", payload)
+	assert.Nil(t, attributes)
+}
+
+func TestUnwrapAttributes(t *testing.T) {
+	// String and number attributes of SNS notification must be kept
+	body := `{"Type":"Notification","MessageId":"1","TopicArn":"arn:aws:sns:eu-west-1:000000000000:test","Message":"hello",` +
+		`"MessageAttributes":{"type":{"Type":"String","Value":"created"},"version":{"Type":"Number","Value":"2"},` +
+		`"image":{"Type":"Binary","Value":"aGVsbG8="}}}`
+	payload, envelope := unwrap(body)
+	assert.Equal(t, "hello", payload)
+	assert.Equal(t, map[string]string{"type": "created", "version": "2"}, envelope)
+
+	// SQS message attributes must be added, leaving out binary ones and attributes of pusu
+	m := sqstypes.Message{MessageAttributes: map[string]sqstypes.MessageAttributeValue{
+		"region":          {DataType: awssdk.String("String"), StringValue: awssdk.String("eu")},
+		"image":           {DataType: awssdk.String("Binary"), BinaryValue: []byte("hello")},
+		failuresAttribute: {DataType: awssdk.String("Number"), StringValue: awssdk.String("1")},
+	}}
+	assert.Equal(t, map[string]string{"type": "created", "version": "2", "region": "eu"}, attributes(m, envelope))
+	assert.Nil(t, attributes(sqstypes.Message{}, nil))
+}
+
+func TestUnwrapRawBody(t *testing.T) {
+	// Raw bodies and json which is not an SNS notification must be returned as is
+	for _, body := range []string{"hello", `{"Type":"Order","Message":"hello"}`} {
+		payload, attributes := unwrap(body)
+		assert.Equal(t, body, payload)
+		assert.Nil(t, attributes)
+	}
+}
@@ -0,0 +1,278 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/metglobal-compass/pusu"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// Messages are moved to dead letter queue after this many receives
+	defaultMaxReceiveCount = 5
+
+	// Visibility timeout of a failed message in seconds. It is multiplied by receive count of message.
+	defaultFailureVisibility = 30
+
+	// Delay of a message rejected by subscription (e.g. circuit is open) in seconds
+	defaultRejectionDelay = 1
+
+	// Maximum visibility timeout allowed by SQS in seconds
+	maxVisibility = 12 * 60 * 60
+
+	// Message attribute which holds number of failed receives of a message before it was sent again
+	failuresAttribute = "pusu-failures"
+
+	// Maximum number of message attributes allowed by SQS
+	maxMessageAttributes = 10
+
+	// Visibility timeout of a received message which is not handled yet in seconds, same as SQS default
+	defaultInFlightVisibility = 30
+
+	// Interval of extending visibility timeout of received messages which are not handled yet
+	defaultHeartbeatInterval = 10 * time.Second
+)
+
+// SQS long polling runner which implements pusu.Runner interface
+type pollRunner struct {
+	client client
+
+	// Maximum failed receives of a message, which must be same as maximum receive count of redrive policy
+	maxReceiveCount int
+
+	// Visibility timeout of a failed message in seconds
+	failureVisibility int32
+
+	// Delay of a rejected message in seconds
+	rejectionDelay int32
+
+	// Visibility timeout of a received message which is not handled yet in seconds
+	inFlightVisibility int32
+
+	// Interval of extending visibility timeout of received messages. Visibility is not extended if it is zero.
+	heartbeatInterval time.Duration
+
+	// Counts outcomes of handled messages. Optional.
+	metrics *pusu.Metrics
+}
+
+// Long polls queue of subscription until client fails. A message is deleted after successful handling,
+// otherwise its visibility timeout is extended so it is received again later. Rejected messages are sent to queue
+// again as new messages, so receives which did not call the handler do not count to redrive policy. Number of failed
+// receives is carried in an attribute and message is moved to dead letter queue by runner once it reaches maximum.
+// Messages of a batch are handled one by one, so visibility timeout of received messages is extended periodically
+// until each of them is handled.
+func (p *pollRunner) Run(subscription pusu.Subscription) error {
+	ctx := context.Background()
+	queueUrl, err := p.client.QueueUrl(ctx, subscription.Name())
+	if err != nil {
+		return err
+	}
+	deadLetterQueueUrl, err := p.client.QueueUrl(ctx, deadLetterQueue(subscription))
+	if err != nil {
+		return err
+	}
+
+	for {
+		messages, err := p.client.ReceiveMessages(ctx, queueUrl)
+		if err != nil {
+			return err
+		}
+
+		err = p.handleBatch(ctx, subscription, queueUrl, deadLetterQueueUrl, messages)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Handles received messages one by one while extending visibility timeout of the ones not handled yet
+func (p *pollRunner) handleBatch(ctx context.Context, subscription pusu.Subscription, queueUrl string, deadLetterQueueUrl string, messages []sqstypes.Message) error {
+	heartbeat := p.startHeartbeat(ctx, queueUrl, messages)
+	defer heartbeat.stop()
+
+	for _, m := range messages {
+		err := p.process(ctx, subscription, m)
+		heartbeat.release(m)
+
+		err = p.acknowledge(ctx, subscription, queueUrl, deadLetterQueueUrl, m, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Handles SQS message with subscription and deletes it on success
+func (p *pollRunner) handle(ctx context.Context, subscription pusu.Subscription, queueUrl string, deadLetterQueueUrl string, m sqstypes.Message) error {
+	return p.acknowledge(ctx, subscription, queueUrl, deadLetterQueueUrl, m, p.process(ctx, subscription, m))
+}
+
+// Calls handler of subscription with SQS message
+func (p *pollRunner) process(ctx context.Context, subscription pusu.Subscription, m sqstypes.Message) error {
+	payload, envelope := unwrap(awssdk.ToString(m.Body))
+	message := pusu.NewMessage(payload).WithAttributes(attributes(m, envelope)).WithContext(ctx)
+	err := pusu.HandleBlocking(ctx, subscription, message)
+	if p.metrics != nil {
+		p.metrics.Observe(subscription, err)
+	}
+
+	return err
+}
+
+// Deletes SQS message on success, otherwise sends it again, moves it to dead letter queue or extends its visibility
+// timeout by result of its handling
+func (p *pollRunner) acknowledge(ctx context.Context, subscription pusu.Subscription, queueUrl string, deadLetterQueueUrl string, m sqstypes.Message, err error) error {
+	if err == nil {
+		return p.client.DeleteMessage(ctx, queueUrl, awssdk.ToString(m.ReceiptHandle))
+	}
+
+	// Handler was not called at all, so this receive is not a failure
+	if pusu.IsRejection(err) {
+		return p.move(ctx, queueUrl, queueUrl, m, failures(m)+receiveCount(m)-1, p.rejectionDelay)
+	}
+
+	attempts := failures(m) + receiveCount(m)
+	if attempts >= p.maxReceiveCount || errors.Is(err, pusu.ErrDeadLetter) {
+		log.Printf("Handler of subscription %s failed on last attempt, message is dead lettered: %s", subscription.Name(), err)
+		return p.move(ctx, queueUrl, deadLetterQueueUrl, m, attempts, 0)
+	}
+
+	log.Printf("Handler of subscription %s failed: %s", subscription.Name(), err)
+	return p.client.ChangeVisibility(ctx, queueUrl, awssdk.ToString(m.ReceiptHandle), p.visibility(attempts))
+}
+
+// Sends message to given queue as a new message with its failed receives, then deletes it from its queue. Failed
+// receives are not carried if message already has maximum number of attributes, so its retries start over.
+func (p *pollRunner) move(ctx context.Context, queueUrl string, targetUrl string, m sqstypes.Message, failures int, delay int32) error {
+	attributes := make(map[string]sqstypes.MessageAttributeValue, len(m.MessageAttributes)+1)
+	for name, value := range m.MessageAttributes {
+		attributes[name] = value
+	}
+
+	_, carried := attributes[failuresAttribute]
+	if carried || len(attributes) < maxMessageAttributes {
+		attributes[failuresAttribute] = sqstypes.MessageAttributeValue{
+			DataType:    awssdk.String("Number"),
+			StringValue: awssdk.String(strconv.Itoa(failures)),
+		}
+	} else {
+		log.Printf("Message has %d attributes, its failed receives are not carried", len(attributes))
+	}
+
+	err := p.client.SendMessage(ctx, targetUrl, awssdk.ToString(m.Body), attributes, delay)
+	if err != nil {
+		return err
+	}
+
+	return p.client.DeleteMessage(ctx, queueUrl, awssdk.ToString(m.ReceiptHandle))
+}
+
+// Starts extending visibility timeout of received messages periodically until they are released
+func (p *pollRunner) startHeartbeat(ctx context.Context, queueUrl string, messages []sqstypes.Message) *heartbeat {
+	h := &heartbeat{receipts: make(map[string]bool, len(messages)), stopped: make(chan struct{}), done: make(chan struct{})}
+	if p.heartbeatInterval <= 0 {
+		close(h.done)
+		return h
+	}
+
+	for _, m := range messages {
+		h.receipts[awssdk.ToString(m.ReceiptHandle)] = true
+	}
+
+	go func() {
+		defer close(h.done)
+
+		ticker := time.NewTicker(p.heartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-h.stopped:
+				return
+			case <-ticker.C:
+				h.extend(func(receipt string) {
+					err := p.client.ChangeVisibility(ctx, queueUrl, receipt, p.inFlightVisibility)
+					if err != nil {
+						log.Printf("Visibility timeout of received message can not be extended: %s", err)
+					}
+				})
+			}
+		}
+	}()
+
+	return h
+}
+
+// Returns visibility timeout of a failed message, growing with its failed attempts
+func (p *pollRunner) visibility(attempts int) int32 {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	visibility := int64(p.failureVisibility) * int64(attempts)
+	if visibility > maxVisibility {
+		visibility = maxVisibility
+	}
+
+	return int32(visibility)
+}
+
+// Returns number of times message is received from its queue, at least 1
+func receiveCount(m sqstypes.Message) int {
+	count, err := strconv.Atoi(m.Attributes[string(sqstypes.MessageSystemAttributeNameApproximateReceiveCount)])
+	if err != nil || count < 1 {
+		return 1
+	}
+
+	return count
+}
+
+// Returns number of failed receives of message before it was sent to its queue again
+func failures(m sqstypes.Message) int {
+	value, ok := m.MessageAttributes[failuresAttribute]
+	if !ok {
+		return 0
+	}
+
+	count, _ := strconv.Atoi(awssdk.ToString(value.StringValue))
+	return count
+}
+
+// Extends visibility timeout of received messages which are not released yet
+type heartbeat struct {
+	mutex    sync.Mutex
+	receipts map[string]bool
+	stopped  chan struct{}
+	done     chan struct{}
+}
+
+// Calls given function with receipt handle of each message which is not released yet
+func (h *heartbeat) extend(f func(receipt string)) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for receipt := range h.receipts {
+		f(receipt)
+	}
+}
+
+// Stops extending visibility timeout of message, waiting for an ongoing extension of it to finish
+func (h *heartbeat) release(m sqstypes.Message) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.receipts, awssdk.ToString(m.ReceiptHandle))
+}
+
+// Stops extending visibility timeout of all messages and waits until extension ends
+func (h *heartbeat) stop() {
+	close(h.stopped)
+	<-h.done
+}
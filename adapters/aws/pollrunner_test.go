@@ -0,0 +1,228 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/metglobal-compass/pusu"
+	"github.com/metglobal-compass/pusu/pusutest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPollRunner_Run(t *testing.T) {
+	// Create fake client which receives a notification, then fails on next receive
+	fakeClient := new(fakeClient)
+	fakeClient.On("QueueUrl", mock.Anything, "testing").Return("queue-url", nil)
+	fakeClient.On("QueueUrl", mock.Anything, "testing-dlq").Return("dlq-url", nil)
+	fakeClient.On("ReceiveMessages", mock.Anything, "queue-url").
+		Return([]sqstypes.Message{newTestMessage(`{"Type":"Notification","TopicArn":"topic-arn","Message":"hello",`+
+			`"MessageAttributes":{"type":{"Type":"String","Value":"created"}}}`, "1")}, nil).Once()
+	fakeClient.On("ReceiveMessages", mock.Anything, "queue-url").Return(nil, errors.New("error"))
+	fakeClient.On("DeleteMessage", mock.Anything, "queue-url", "receipt").Return(nil)
+
+	// Call real method with a successful subscription
+	runner := newTestRunner(fakeClient)
+	subscription := new(pusutest.Subscription).WillHaveProperFields()
+	err := runner.Run(subscription)
+
+	// Runner must return error of client
+	assert.Error(t, err)
+
+	// Unwrapped notification must be handled with its attributes and deleted
+	subscription.(*pusutest.Subscription).AssertCalled(t, "Handle", mock.MatchedBy(func(m *pusu.Message) bool {
+		return m.Message() == "hello" && m.Attribute("type") == "created"
+	}))
+	fakeClient.AssertCalled(t, "DeleteMessage", mock.Anything, "queue-url", "receipt")
+	assert.Equal(t, int64(1), runner.metrics.Count(subscription, pusu.OutcomeSuccess))
+}
+
+func TestPollRunner_RunFailure(t *testing.T) {
+	// Create fake client which receives a message received twice before, then fails on next receive
+	fakeClient := new(fakeClient)
+	fakeClient.On("QueueUrl", mock.Anything, "testing").Return("queue-url", nil)
+	fakeClient.On("QueueUrl", mock.Anything, "testing-dlq").Return("dlq-url", nil)
+	fakeClient.On("ReceiveMessages", mock.Anything, "queue-url").
+		Return([]sqstypes.Message{newTestMessage("hello", "3")}, nil).Once()
+	fakeClient.On("ReceiveMessages", mock.Anything, "queue-url").Return(nil, errors.New("error"))
+	fakeClient.On("ChangeVisibility", mock.Anything, "queue-url", "receipt", int32(90)).Return(nil)
+
+	// Call real method with a failing subscription
+	runner := newTestRunner(fakeClient)
+	runner.Run(new(pusutest.Subscription).WillReturnError())
+
+	// Failed message must not be deleted and must become visible again later by its receive count
+	fakeClient.AssertNotCalled(t, "DeleteMessage", mock.Anything, mock.Anything, mock.Anything)
+	fakeClient.AssertCalled(t, "ChangeVisibility", mock.Anything, "queue-url", "receipt", int32(90))
+}
+
+func TestPollRunner_HandleBatchExtendsVisibility(t *testing.T) {
+	// Create fake client which accepts visibility extensions and deletions of two messages
+	fakeClient := new(fakeClient)
+	fakeClient.On("ChangeVisibility", mock.Anything, "queue-url", mock.Anything, int32(defaultInFlightVisibility)).Return(nil)
+	fakeClient.On("DeleteMessage", mock.Anything, "queue-url", mock.Anything).Return(nil)
+	first := newTestMessage("first", "1")
+	first.ReceiptHandle = awssdk.String("receipt-1")
+	second := newTestMessage("second", "1")
+	second.ReceiptHandle = awssdk.String("receipt-2")
+
+	// Handle messages slower than heartbeat interval
+	runner := newTestRunner(fakeClient)
+	runner.inFlightVisibility = defaultInFlightVisibility
+	runner.heartbeatInterval = 10 * time.Millisecond
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing")
+	subscription.On("Handle", mock.Anything).Run(func(mock.Arguments) { time.Sleep(50 * time.Millisecond) }).Return(nil)
+	assert.Nil(t, runner.handleBatch(context.Background(), subscription, "queue-url", "dlq-url", []sqstypes.Message{first, second}))
+
+	// Visibility of second message must be extended while first one is handled and both must be deleted
+	fakeClient.AssertCalled(t, "ChangeVisibility", mock.Anything, "queue-url", "receipt-2", int32(defaultInFlightVisibility))
+	fakeClient.AssertCalled(t, "DeleteMessage", mock.Anything, "queue-url", "receipt-1")
+	fakeClient.AssertCalled(t, "DeleteMessage", mock.Anything, "queue-url", "receipt-2")
+
+	// Visibility must not be extended after messages are handled
+	calls := len(fakeClient.Calls)
+	time.Sleep(30 * time.Millisecond)
+	assert.Len(t, fakeClient.Calls, calls)
+}
+
+func TestPollRunner_HandleRejectionWithMaximumAttributes(t *testing.T) {
+	// Create fake client which accepts messages sent again
+	fakeClient := new(fakeClient)
+	fakeClient.On("SendMessage", mock.Anything, "queue-url", "hello", mock.Anything, int32(defaultRejectionDelay)).Return(nil)
+	fakeClient.On("DeleteMessage", mock.Anything, "queue-url", "receipt").Return(nil)
+
+	// Reject a message which already has maximum number of attributes
+	m := newTestMessage("hello", "2")
+	m.MessageAttributes = make(map[string]sqstypes.MessageAttributeValue, maxMessageAttributes)
+	for i := 0; i < maxMessageAttributes; i++ {
+		m.MessageAttributes["attribute-"+strconv.Itoa(i)] = sqstypes.MessageAttributeValue{
+			DataType: awssdk.String("String"), StringValue: awssdk.String("value"),
+		}
+	}
+	runner := newTestRunner(fakeClient)
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(pusu.ErrCircuitOpen)
+	assert.Nil(t, runner.handle(context.Background(), subscription, "queue-url", "dlq-url", m))
+
+	// Message must be sent again with its own attributes only, since SQS rejects more attributes
+	fakeClient.AssertCalled(t, "SendMessage", mock.Anything, "queue-url", "hello",
+		mock.MatchedBy(func(attributes map[string]sqstypes.MessageAttributeValue) bool {
+			_, ok := attributes[failuresAttribute]
+			return len(attributes) == maxMessageAttributes && !ok
+		}), int32(defaultRejectionDelay))
+}
+
+func TestPollRunner_RunErrorOnQueueUrl(t *testing.T) {
+	// Create fake client which fails while getting queue url
+	fakeClient := new(fakeClient)
+	fakeClient.On("QueueUrl", mock.Anything, "testing").Return("", errors.New("error"))
+
+	// Runner must return error
+	runner := &pollRunner{client: fakeClient}
+	assert.Error(t, runner.Run(new(pusutest.Subscription).WillHaveProperFields()))
+}
+
+func TestPollRunner_HandleRejection(t *testing.T) {
+	// Create fake client which accepts messages sent again
+	fakeClient := new(fakeClient)
+	fakeClient.On("SendMessage", mock.Anything, "queue-url", "hello", mock.Anything, int32(defaultRejectionDelay)).Return(nil)
+	fakeClient.On("DeleteMessage", mock.Anything, "queue-url", "receipt").Return(nil)
+	runner := newTestRunner(fakeClient)
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(pusu.ErrCircuitOpen)
+
+	// Reject a message more times than maximum receive count, each time as a new message received once
+	m := newTestMessage("hello", "1")
+	m.MessageAttributes = map[string]sqstypes.MessageAttributeValue{
+		"type": {DataType: awssdk.String("String"), StringValue: awssdk.String("created")},
+	}
+	for i := 0; i < 2*defaultMaxReceiveCount; i++ {
+		assert.Nil(t, runner.handle(context.Background(), subscription, "queue-url", "dlq-url", m))
+	}
+
+	// Rejected messages must be sent to queue again with their attributes and without failures, never to dead
+	// letter queue
+	fakeClient.AssertNumberOfCalls(t, "SendMessage", 2*defaultMaxReceiveCount)
+	fakeClient.AssertCalled(t, "SendMessage", mock.Anything, "queue-url", "hello",
+		mock.MatchedBy(func(attributes map[string]sqstypes.MessageAttributeValue) bool {
+			return awssdk.ToString(attributes["type"].StringValue) == "created" &&
+				awssdk.ToString(attributes[failuresAttribute].StringValue) == "0"
+		}), int32(defaultRejectionDelay))
+	fakeClient.AssertNotCalled(t, "SendMessage", mock.Anything, "dlq-url", mock.Anything, mock.Anything, mock.Anything)
+	fakeClient.AssertNotCalled(t, "ChangeVisibility", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPollRunner_HandleRejectionKeepsFailures(t *testing.T) {
+	// Message failed twice and rejected on its third receive must be sent again with two failures
+	fakeClient := new(fakeClient)
+	fakeClient.On("SendMessage", mock.Anything, "queue-url", "hello", mock.MatchedBy(func(attributes map[string]sqstypes.MessageAttributeValue) bool {
+		return awssdk.ToString(attributes[failuresAttribute].StringValue) == "2"
+	}), mock.Anything).Return(nil)
+	fakeClient.On("DeleteMessage", mock.Anything, "queue-url", "receipt").Return(nil)
+
+	runner := newTestRunner(fakeClient)
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(pusu.ErrRateLimited)
+	assert.Nil(t, runner.handle(context.Background(), subscription, "queue-url", "dlq-url", newTestMessage("hello", "3")))
+	fakeClient.AssertNumberOfCalls(t, "SendMessage", 1)
+}
+
+func TestPollRunner_HandleFailureOnLastAttempt(t *testing.T) {
+	// Message sent again after four failures must be moved to dead letter queue when it fails once more
+	fakeClient := new(fakeClient)
+	fakeClient.On("SendMessage", mock.Anything, "dlq-url", "hello", mock.Anything, int32(0)).Return(nil)
+	fakeClient.On("DeleteMessage", mock.Anything, "queue-url", "receipt").Return(nil)
+	m := newTestMessage("hello", "1")
+	m.MessageAttributes = map[string]sqstypes.MessageAttributeValue{
+		failuresAttribute: {DataType: awssdk.String("Number"), StringValue: awssdk.String("4")},
+	}
+
+	runner := newTestRunner(fakeClient)
+	assert.Nil(t, runner.handle(context.Background(), new(pusutest.Subscription).WillReturnError(), "queue-url", "dlq-url", m))
+	fakeClient.AssertNumberOfCalls(t, "SendMessage", 1)
+	fakeClient.AssertNotCalled(t, "ChangeVisibility", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPollRunner_HandleDeadLetter(t *testing.T) {
+	// Message must be moved to dead letter queue on its first receive when handler requires it
+	fakeClient := new(fakeClient)
+	fakeClient.On("SendMessage", mock.Anything, "dlq-url", "hello", mock.Anything, int32(0)).Return(nil)
+	fakeClient.On("DeleteMessage", mock.Anything, "queue-url", "receipt").Return(nil)
+
+	runner := newTestRunner(fakeClient)
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(pusu.ErrDeadLetter)
+	assert.Nil(t, runner.handle(context.Background(), subscription, "queue-url", "dlq-url", newTestMessage("hello", "1")))
+	fakeClient.AssertNumberOfCalls(t, "SendMessage", 1)
+}
+
+func TestPollRunner_Visibility(t *testing.T) {
+	runner := &pollRunner{failureVisibility: defaultFailureVisibility}
+
+	// Visibility must be at least failure visibility and must not exceed limit of SQS
+	assert.Equal(t, int32(30), runner.visibility(0))
+	assert.Equal(t, int32(60), runner.visibility(2))
+	assert.Equal(t, int32(maxVisibility), runner.visibility(100000))
+}
+
+// Creates a poll runner with given fake client
+func newTestRunner(c client) *pollRunner {
+	return &pollRunner{
+		client:            c,
+		maxReceiveCount:   defaultMaxReceiveCount,
+		failureVisibility: defaultFailureVisibility,
+		rejectionDelay:    defaultRejectionDelay,
+		metrics:           new(pusu.Metrics),
+	}
+}
+
+// Creates an SQS message with given body and receive count
+func newTestMessage(body string, receiveCount string) sqstypes.Message {
+	return sqstypes.Message{
+		Body:          awssdk.String(body),
+		ReceiptHandle: awssdk.String("receipt"),
+		Attributes: map[string]string{
+			string(sqstypes.MessageSystemAttributeNameApproximateReceiveCount): receiveCount,
+		},
+	}
+}
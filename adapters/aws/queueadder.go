@@ -0,0 +1,95 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/metglobal-compass/pusu"
+	"strconv"
+)
+
+const (
+	// Messages failed on every receive are moved to this queue of subscription by redrive policy
+	deadLetterQueuePattern = "%s-dlq"
+)
+
+type queueAdder struct {
+	client client
+
+	// Maximum receive count of a message before it is moved to dead letter queue
+	maxReceiveCount int
+}
+
+// Implementation of internal Creator interface for AWS Adapter.
+// Creates SNS topic, SQS queue and its dead letter queue, allows topic to send messages to queue
+// and subscribes queue to topic. Every step is idempotent.
+func (q *queueAdder) CreateSubscription(subscription pusu.Subscription) error {
+	// Use single context
+	ctx := context.Background()
+
+	topicArn, err := q.client.CreateTopic(ctx, subscription.Topic())
+	if err != nil {
+		return err
+	}
+
+	deadLetterQueueUrl, err := q.client.CreateQueue(ctx, deadLetterQueue(subscription))
+	if err != nil {
+		return err
+	}
+	deadLetterQueueArn, err := q.client.QueueArn(ctx, deadLetterQueueUrl)
+	if err != nil {
+		return err
+	}
+
+	queueUrl, err := q.client.CreateQueue(ctx, subscription.Name())
+	if err != nil {
+		return err
+	}
+	queueArn, err := q.client.QueueArn(ctx, queueUrl)
+	if err != nil {
+		return err
+	}
+
+	err = q.client.SetQueueAttributes(ctx, queueUrl, map[string]string{
+		"Policy":        queuePolicy(queueArn, topicArn),
+		"RedrivePolicy": redrivePolicy(deadLetterQueueArn, q.maxReceiveCount),
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.client.Subscribe(ctx, topicArn, queueArn)
+}
+
+// Returns queue policy which allows topic to send messages to queue
+func queuePolicy(queueArn string, topicArn string) string {
+	policy, _ := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Service": "sns.amazonaws.com"},
+			"Action":    "sqs:SendMessage",
+			"Resource":  queueArn,
+			"Condition": map[string]interface{}{
+				"ArnEquals": map[string]string{"aws:SourceArn": topicArn},
+			},
+		}},
+	})
+
+	return string(policy)
+}
+
+// Returns redrive policy which moves messages to dead letter queue after maximum receive count
+func redrivePolicy(deadLetterQueueArn string, maxReceiveCount int) string {
+	policy, _ := json.Marshal(map[string]string{
+		"deadLetterTargetArn": deadLetterQueueArn,
+		"maxReceiveCount":     strconv.Itoa(maxReceiveCount),
+	})
+
+	return string(policy)
+}
+
+// Returns name of dead letter queue of subscription
+func deadLetterQueue(subscription pusu.Subscription) string {
+	return fmt.Sprintf(deadLetterQueuePattern, subscription.Name())
+}
@@ -0,0 +1,135 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/metglobal-compass/pusu/pusutest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+func TestQueueAdder_CreateSubscription(t *testing.T) {
+	// Create fake mocked client
+	fakeClient := new(fakeClient)
+	fakeClient.On("CreateTopic", context.Background(), "test").Return("topic-arn", nil)
+	fakeClient.On("CreateQueue", context.Background(), "testing-dlq").Return("dlq-url", nil)
+	fakeClient.On("QueueArn", context.Background(), "dlq-url").Return("dlq-arn", nil)
+	fakeClient.On("CreateQueue", context.Background(), "testing").Return("queue-url", nil)
+	fakeClient.On("QueueArn", context.Background(), "queue-url").Return("queue-arn", nil)
+	fakeClient.On("SetQueueAttributes", context.Background(), "queue-url", mock.Anything).Return(nil)
+	fakeClient.On("Subscribe", context.Background(), "topic-arn", "queue-arn").Return(nil)
+
+	// Call real method
+	queueAdder := &queueAdder{client: fakeClient, maxReceiveCount: 3}
+	err := queueAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Queue must be subscribed to topic with policy and redrive policy to dead letter queue
+	assert.Nil(t, err)
+	fakeClient.AssertExpectations(t)
+	fakeClient.AssertCalled(t, "SetQueueAttributes", context.Background(), "queue-url", map[string]string{
+		"Policy":        queuePolicy("queue-arn", "topic-arn"),
+		"RedrivePolicy": `{"deadLetterTargetArn":"dlq-arn","maxReceiveCount":"3"}`,
+	})
+}
+
+func TestQueueAdder_CreateSubscriptionErrorOnTopic(t *testing.T) {
+	// Create fake mocked client. In this case, we get an error while creating topic
+	fakeClient := new(fakeClient)
+	fakeClient.On("CreateTopic", context.Background(), "test").Return("", errors.New("error"))
+
+	// Call real method
+	queueAdder := &queueAdder{client: fakeClient}
+	err := queueAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Got an error and no queue is created
+	assert.Error(t, err)
+	fakeClient.AssertNotCalled(t, "CreateQueue", mock.Anything, mock.Anything)
+}
+
+func TestQueueAdder_CreateSubscriptionErrorOnSubscribe(t *testing.T) {
+	// Create fake mocked client. In this case, we get an error while subscribing queue to topic
+	fakeClient := new(fakeClient)
+	fakeClient.On("CreateTopic", mock.Anything, mock.Anything).Return("topic-arn", nil)
+	fakeClient.On("CreateQueue", mock.Anything, mock.Anything).Return("queue-url", nil)
+	fakeClient.On("QueueArn", mock.Anything, mock.Anything).Return("queue-arn", nil)
+	fakeClient.On("SetQueueAttributes", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	fakeClient.On("Subscribe", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("error"))
+
+	// Call real method
+	queueAdder := &queueAdder{client: fakeClient}
+	err := queueAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Got an error
+	assert.Error(t, err)
+}
+
+func TestQueuePolicy(t *testing.T) {
+	// Policy must allow only topic to send messages to queue
+	assert.JSONEq(t, `{
+		"Version": "2012-10-17",
+		"Statement": [{
+			"Effect": "Allow",
+			"Principal": {"Service": "sns.amazonaws.com"},
+			"Action": "sqs:SendMessage",
+			"Resource": "queue-arn",
+			"Condition": {"ArnEquals": {"aws:SourceArn": "topic-arn"}}
+		}]
+	}`, queuePolicy("queue-arn", "topic-arn"))
+}
+
+type fakeClient struct {
+	mock.Mock
+}
+
+func (f *fakeClient) CreateTopic(ctx context.Context, name string) (string, error) {
+	args := f.Called(ctx, name)
+	return args.String(0), args.Error(1)
+}
+
+func (f *fakeClient) CreateQueue(ctx context.Context, name string) (string, error) {
+	args := f.Called(ctx, name)
+	return args.String(0), args.Error(1)
+}
+
+func (f *fakeClient) QueueUrl(ctx context.Context, name string) (string, error) {
+	args := f.Called(ctx, name)
+	return args.String(0), args.Error(1)
+}
+
+func (f *fakeClient) QueueArn(ctx context.Context, queueUrl string) (string, error) {
+	args := f.Called(ctx, queueUrl)
+	return args.String(0), args.Error(1)
+}
+
+func (f *fakeClient) SetQueueAttributes(ctx context.Context, queueUrl string, attributes map[string]string) error {
+	args := f.Called(ctx, queueUrl, attributes)
+	return args.Error(0)
+}
+
+func (f *fakeClient) Subscribe(ctx context.Context, topicArn string, queueArn string) error {
+	args := f.Called(ctx, topicArn, queueArn)
+	return args.Error(0)
+}
+
+func (f *fakeClient) ReceiveMessages(ctx context.Context, queueUrl string) ([]sqstypes.Message, error) {
+	args := f.Called(ctx, queueUrl)
+	messages, _ := args.Get(0).([]sqstypes.Message)
+	return messages, args.Error(1)
+}
+
+func (f *fakeClient) DeleteMessage(ctx context.Context, queueUrl string, receiptHandle string) error {
+	args := f.Called(ctx, queueUrl, receiptHandle)
+	return args.Error(0)
+}
+
+func (f *fakeClient) ChangeVisibility(ctx context.Context, queueUrl string, receiptHandle string, timeout int32) error {
+	args := f.Called(ctx, queueUrl, receiptHandle, timeout)
+	return args.Error(0)
+}
+
+func (f *fakeClient) SendMessage(ctx context.Context, queueUrl string, body string, attributes map[string]sqstypes.MessageAttributeValue, delaySeconds int32) error {
+	args := f.Called(ctx, queueUrl, body, attributes, delaySeconds)
+	return args.Error(0)
+}
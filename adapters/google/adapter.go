@@ -4,13 +4,16 @@
 // Google Cloud Pub/Sub pushes triggered messages to those App Engine services.
 // If message processing is successful, pusu returns a 200 OK response code and Pub/Sub acknowledges the message
 // If it is unsuccessful, pusu returns 400 or 500 response code and Pub/Sub tries later until gets a success message.
+// Existing subscriptions whose push endpoint or payload wrapper drifted are pointed to subscriber again when it starts.
 package google
 
 import (
 	"cloud.google.com/go/pubsub"
 	"context"
 	"errors"
+	"fmt"
 	"github.com/metglobal-compass/pusu"
+	"net/url"
 )
 
 type Adapter struct {
@@ -22,34 +25,106 @@ type Adapter struct {
 
 	// Runs subscription as HTTP App Engine service
 	runner pusu.Runner
+
+	// Validates and provisions subscriptions, tracking their health and metrics
+	pusu.Provisioner
+
+	// Names subscriptions in Pub/Sub and routes their push endpoints, shared by cloud adder and http handler adder
+	routing routing
+
+	// Publishes messages
+	client client
 }
 
 // Implementation of pusu.Creator interface as part of pusu.Adapter interface
 func (g *Adapter) CreateSubscription(subscription pusu.Subscription) error {
-	// Validate subscription
-	if subscription.Name() == "" {
-		return errors.New("Subscription name must not be empty. ")
+	// Validate naming and filter of subscription before any API call
+	err := pusu.ValidateSubscription(subscription)
+	if err != nil {
+		return err
+	}
+	err = g.routing.naming.validateSubscription(subscription)
+	if err != nil {
+		return err
 	}
-	if subscription.Topic() == "" {
-		return errors.New("Subscription topic must not be empty. ")
+	filter, _ := pusu.FilterOf(subscription)
+	_, err = pusu.ParseFilter(filter)
+	if err != nil {
+		return err
 	}
 
-	err := g.cloudAdder.CreateSubscription(subscription)
+	return g.Provision(subscription, g.cloudAdder, g.httpHandlerAdder)
+}
+
+// Implementation of pusu.Runner interface as part of pusu.Adapter interface
+func (g *Adapter) Run(subscription pusu.Subscription) error {
+	err := g.runner.Run(subscription)
+	return err
+}
+
+// Sets naming policy of topics and subscriptions in Pub/Sub. Must be set before subscriptions are created.
+func (g *Adapter) SetNaming(naming Naming) error {
+	err := naming.Validate()
 	if err != nil {
 		return err
 	}
+	g.routing.naming = naming
+
+	return nil
+}
 
-	err = g.httpHandlerAdder.CreateSubscription(subscription)
+// Sets path template of push endpoints with {topic} and {subscription} placeholders, e.g.
+// /events/{topic}/{subscription}. Defaults to DefaultEndpointPath. Must be set before subscriptions are created.
+// Subscriptions whose paths are same, e.g. /events, are told apart by subscription of push envelope.
+func (g *Adapter) SetEndpointPath(template string) error {
+	err := validateEndpointPath(template)
 	if err != nil {
 		return err
 	}
+	g.routing.path = template
 
 	return nil
 }
 
-// Implementation of pusu.Runner interface as part of pusu.Adapter interface
-func (g *Adapter) Run(subscription pusu.Subscription) error {
-	err := g.runner.Run(subscription)
+// Requests Pub/Sub to push raw payloads instead of JSON envelopes to subscriptions created afterwards. If write
+// metadata is set, message id, subscription and attributes are pushed as headers too, otherwise messages have no
+// attributes. Subscriptions sharing an endpoint path need metadata, since they are told apart by it. Http handlers
+// decode both formats.
+func (g *Adapter) SetNoWrapper(writeMetadata bool) {
+	g.routing.noWrapper = &pubsub.NoWrapper{WriteMetadata: writeMetadata}
+}
+
+// Sends messages which are being published and releases Pub/Sub client. Adapter can't publish or create subscriptions
+// afterwards.
+func (g *Adapter) Close() error {
+	return g.client.Close()
+}
+
+// Publishes message to topic, named by naming policy of adapter
+func (g *Adapter) Publish(topic string, payload string) error {
+	return g.PublishMessage(topic, pusu.NewMessage(payload))
+}
+
+// Publishes message with its attributes to topic, named by naming policy of adapter. Payload of message must be string
+// or []byte. CloudEvents are published in Pub/Sub protocol binding, e.g. PublishMessage(topic, event.Message()).
+func (g *Adapter) PublishMessage(topic string, m *pusu.Message) error {
+	var data []byte
+	switch payload := m.Message().(type) {
+	case string:
+		data = []byte(payload)
+	case []byte:
+		data = payload
+	default:
+		return fmt.Errorf("payload of message must be string or []byte, not %T", m.Message())
+	}
+
+	name := g.routing.naming.Topic(topic)
+	err := validateResourceName(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = g.client.Publish(context.Background(), name, data, m.Attributes())
 	return err
 }
 
@@ -67,17 +142,68 @@ func CreateAdapter(projectId string, host string) (*Adapter, error) {
 	}
 
 	googleAdapter := new(Adapter)
-	googleAdapter.httpHandlerAdder = new(httpHandlerAdder)
+	googleAdapter.httpHandlerAdder = &httpHandlerAdder{
+		projectId: projectId,
+		metrics:   googleAdapter.Metrics(),
+		routing:   &googleAdapter.routing,
+	}
 
 	// Add pub/sub client
 	client, err := pubsub.NewClient(context.Background(), projectId)
 	if err != nil {
 		return nil, err
 	}
-	googleAdapter.cloudAdder = &cloudAdder{client: &pubSubClientWrapper{client: client}, host: host}
+	googleAdapter.client = &pubSubClientWrapper{client: client}
+	googleAdapter.cloudAdder = &cloudAdder{client: googleAdapter.client, host: host, routing: &googleAdapter.routing}
 
 	// Add appengine runner
-	googleAdapter.runner = new(appEngineRunner)
+	googleAdapter.runner = &appEngineRunner{health: googleAdapter.Health(), metrics: googleAdapter.Metrics()}
 
 	return googleAdapter, nil
 }
+
+func init() {
+	pusu.RegisterScheme("gcp", open)
+}
+
+// Opens Google Adapter of url in form of gcp://<project id>?host=<base host of subscriber http handlers>.
+// Naming policy is set by env, topic_template, subscription_template, prefix and suffix query parameters and endpoint
+// path template by path query parameter. Unwrapped payloads are requested by no_wrapper query parameter, which is
+// either raw or metadata to write metadata headers too.
+func open(u *url.URL) (pusu.Adapter, error) {
+	query := u.Query()
+	adapter, err := CreateAdapter(u.Host, query.Get("host"))
+	if err != nil {
+		return nil, err
+	}
+
+	err = adapter.SetNaming(Naming{
+		Env:                  query.Get("env"),
+		TopicTemplate:        query.Get("topic_template"),
+		SubscriptionTemplate: query.Get("subscription_template"),
+		Prefix:               query.Get("prefix"),
+		Suffix:               query.Get("suffix"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch query.Get("no_wrapper") {
+	case "":
+	case "raw":
+		adapter.SetNoWrapper(false)
+	case "metadata":
+		adapter.SetNoWrapper(true)
+	default:
+		return nil, fmt.Errorf("no_wrapper must be raw or metadata, not %q", query.Get("no_wrapper"))
+	}
+
+	if query.Get("path") != "" {
+		err = adapter.SetEndpointPath(query.Get("path"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return adapter, nil
+}
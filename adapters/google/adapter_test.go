@@ -1,6 +1,7 @@
 package google
 
 import (
+	"cloud.google.com/go/pubsub"
 	"errors"
 	"github.com/metglobal-compass/pusu"
 	"github.com/stretchr/testify/assert"
@@ -25,6 +26,105 @@ func TestAdapter_CreateSubscriptionErrorOnEmptyName(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestAdapter_CreateSubscriptionErrorOnInvalidName(t *testing.T) {
+	// Create adapter whose naming policy makes names invalid
+	creator := new(fakeCreator)
+	adapter := new(Adapter)
+	adapter.cloudAdder = creator
+	adapter.httpHandlerAdder = creator
+	assert.Nil(t, adapter.SetNaming(Naming{Prefix: "goog-"}))
+
+	// Names must be validated before any API call
+	err := adapter.CreateSubscription(new(fakeSubscription).WillHaveProperFields())
+	assert.Error(t, err)
+	creator.AssertNotCalled(t, "CreateSubscription", mock.Anything)
+}
+
+func TestAdapter_CreateSubscriptionErrorOnInvalidFilter(t *testing.T) {
+	creator := new(fakeCreator)
+	adapter := new(Adapter)
+	adapter.cloudAdder = creator
+	adapter.httpHandlerAdder = creator
+
+	// Filter must be validated before any API call
+	subscription := pusu.WithFilter(new(fakeSubscription).WillHaveProperFields(), `attributes.type = created`)
+	assert.Error(t, adapter.CreateSubscription(subscription))
+	creator.AssertNotCalled(t, "CreateSubscription", mock.Anything)
+}
+
+func TestAdapter_SetNamingErrorOnInvalidTemplate(t *testing.T) {
+	// Templates must be validated
+	adapter := new(Adapter)
+	assert.Error(t, adapter.SetNaming(Naming{TopicTemplate: "{env}-{topic}"}))
+	assert.Equal(t, Naming{}, adapter.routing.naming)
+}
+
+func TestAdapter_SetEndpointPath(t *testing.T) {
+	withoutCredentials(t)
+
+	// Path template must be shared by cloud adder and http handler adder
+	adapter, err := CreateAdapter("my-project", "http://localhost")
+	assert.Nil(t, err)
+	assert.Nil(t, adapter.SetEndpointPath("/events/{topic}/{subscription}"))
+	subscription := new(fakeSubscription).WillHaveProperFields()
+	assert.Equal(t, "/events/test/testing", adapter.httpHandlerAdder.(*httpHandlerAdder).UrlPath(subscription))
+	cloudAdder := adapter.cloudAdder.(*cloudAdder)
+	assert.Equal(t, "http://localhost/events/test/testing", cloudAdder.routing.endpoint(cloudAdder.host, subscription))
+
+	// Invalid templates must be rejected
+	assert.Error(t, adapter.SetEndpointPath("events/{subscription}"))
+	assert.Error(t, adapter.SetEndpointPath("/events/{env}/{subscription}"))
+	assert.Equal(t, "/events/{topic}/{subscription}", adapter.routing.path)
+
+	// Subscriptions may share a path
+	assert.Nil(t, adapter.SetEndpointPath("/events"))
+}
+
+func TestAdapter_Publish(t *testing.T) {
+	// Create fake client
+	fakeClient := new(fakeClient)
+	fakeClient.On("Publish", mock.Anything, "prod-test", []byte("hello"), map[string]string(nil)).Return("1", nil)
+
+	// Message must be published to topic named by naming policy
+	adapter := new(Adapter)
+	adapter.client = fakeClient
+	assert.Nil(t, adapter.SetNaming(Naming{Env: "prod", TopicTemplate: "{env}-{topic}"}))
+	assert.Nil(t, adapter.Publish("test", "hello"))
+	fakeClient.AssertExpectations(t)
+
+	// Invalid names must be rejected before publishing
+	assert.Error(t, adapter.Publish("a/b", "hello"))
+	fakeClient.AssertNumberOfCalls(t, "Publish", 1)
+}
+
+func TestAdapter_PublishMessage(t *testing.T) {
+	// Create fake client
+	fakeClient := new(fakeClient)
+	attributes := map[string]string{"ce-id": "1", "ce-source": "//orders", "ce-specversion": "1.0", "ce-type": "created"}
+	fakeClient.On("Publish", mock.Anything, "test", []byte("hello"), attributes).Return("1", nil)
+
+	// CloudEvent must be published with its context attributes
+	adapter := new(Adapter)
+	adapter.client = fakeClient
+	event := pusu.CloudEvent{ID: "1", Source: "//orders", SpecVersion: "1.0", Type: "created", Data: []byte("hello")}
+	assert.Nil(t, adapter.PublishMessage("test", event.Message()))
+	fakeClient.AssertExpectations(t)
+
+	// Payloads other than string or []byte must be rejected
+	assert.Error(t, adapter.PublishMessage("test", pusu.NewMessage(1)))
+	fakeClient.AssertNumberOfCalls(t, "Publish", 1)
+}
+
+func TestAdapter_Close(t *testing.T) {
+	// Client of adapter must be closed
+	fakeClient := new(fakeClient)
+	fakeClient.On("Close").Return(nil)
+	adapter := new(Adapter)
+	adapter.client = fakeClient
+	assert.Nil(t, adapter.Close())
+	fakeClient.AssertExpectations(t)
+}
+
 func TestAdapter_CreateSubscription(t *testing.T) {
 	// Create mocked object
 	successCreator := new(fakeCreator)
@@ -43,6 +143,33 @@ func TestAdapter_CreateSubscription(t *testing.T) {
 	successCreator.AssertNumberOfCalls(t, "CreateSubscription", 2)
 }
 
+func TestAdapter_CreateSubscriptionReadiness(t *testing.T) {
+	// Create mocked objects
+	successCreator := new(fakeCreator)
+	successCreator.On("CreateSubscription", mock.Anything).Return(nil)
+	failCreator := new(fakeCreator)
+	failCreator.On("CreateSubscription", mock.Anything).Return(errors.New("error... "))
+
+	// Subscriber must not be ready when provisioning fails
+	adapter := new(Adapter)
+	adapter.cloudAdder = failCreator
+	adapter.httpHandlerAdder = successCreator
+	adapter.CreateSubscription(new(fakeSubscription).WillHaveProperFields())
+	assert.Error(t, adapter.Health().Ready())
+
+	// Subscriber must be ready after successful provisioning
+	adapter.cloudAdder = successCreator
+	err := adapter.CreateSubscription(new(fakeSubscription).WillHaveProperFields())
+	assert.Nil(t, err)
+	assert.Nil(t, adapter.Health().Ready())
+
+	// User-registered readiness check must be taken into account
+	adapter.AddReadinessCheck("database", func() error {
+		return errors.New("connection refused")
+	})
+	assert.Error(t, adapter.Health().Ready())
+}
+
 func TestAdapter_CreateSubscriptionOnCloudFailure(t *testing.T) {
 	// Create successor mocked object
 	successCreator := new(fakeCreator)
@@ -110,6 +237,8 @@ func TestAdapter_RunError(t *testing.T) {
 }
 
 func TestAdapter_CreateAdapter(t *testing.T) {
+	withoutCredentials(t)
+
 	// Call real method and check each interfaces have proper types
 	adapter, err := CreateAdapter("my-project", "http://localhost")
 
@@ -118,9 +247,12 @@ func TestAdapter_CreateAdapter(t *testing.T) {
 	assert.NotNil(t, adapter.cloudAdder)
 	assert.NotNil(t, adapter.runner)
 
-	_, httpHandlerProper := adapter.httpHandlerAdder.(*httpHandlerAdder)
+	httpHandlerAdder, httpHandlerProper := adapter.httpHandlerAdder.(*httpHandlerAdder)
 	assert.True(t, httpHandlerProper, "Http handler is not proper")
 
+	// Http handler must count handled messages in metrics of the adapter
+	assert.True(t, httpHandlerAdder.metrics == adapter.Metrics(), "Http handler metrics is not proper")
+
 	cloudAdder, cloudAdderProper := adapter.cloudAdder.(*cloudAdder)
 	assert.True(t, cloudAdderProper, "Cloud adder is not proper")
 
@@ -130,8 +262,12 @@ func TestAdapter_CreateAdapter(t *testing.T) {
 	_, pubSubClientProper := cloudAdder.client.(*pubSubClientWrapper)
 	assert.True(t, pubSubClientProper, "Pubsub client is not proper")
 
-	_, appEngineRunnerProper := adapter.runner.(*appEngineRunner)
+	appEngineRunner, appEngineRunnerProper := adapter.runner.(*appEngineRunner)
 	assert.True(t, appEngineRunnerProper, "Runner  is not proper")
+
+	// Runner must serve health of the adapter
+	assert.True(t, appEngineRunner.health == adapter.Health(), "Runner health is not proper")
+	assert.True(t, appEngineRunner.metrics == adapter.Metrics(), "Runner metrics is not proper")
 }
 
 func TestAdapter_CreateAdapterErrorWithEmptyProject(t *testing.T) {
@@ -146,6 +282,43 @@ func TestAdapter_CreateAdapterErrorWithEmptyHost(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestAdapter_Open(t *testing.T) {
+	withoutCredentials(t)
+
+	// Open adapter by dsn
+	adapter, err := pusu.Open("gcp://my-project?host=http://localhost")
+	assert.Nil(t, err)
+
+	googleAdapter, proper := adapter.(*Adapter)
+	assert.True(t, proper, "Adapter is not proper")
+	assert.Equal(t, "http://localhost", googleAdapter.cloudAdder.(*cloudAdder).host)
+
+	// Host is required
+	_, err = pusu.Open("gcp://my-project")
+	assert.Error(t, err)
+
+	// Naming policy must be set by query parameters
+	adapter, err = pusu.Open("gcp://my-project?host=http://localhost&env=staging&topic_template={env}-{topic}&suffix=-v2")
+	assert.Nil(t, err)
+	assert.Equal(t, Naming{Env: "staging", TopicTemplate: "{env}-{topic}", Suffix: "-v2"}, adapter.(*Adapter).routing.naming)
+	_, err = pusu.Open("gcp://my-project?host=http://localhost&topic_template={env}-{topic}")
+	assert.Error(t, err)
+
+	// Endpoint path template must be set by query parameter
+	adapter, err = pusu.Open("gcp://my-project?host=http://localhost&path=/events/{topic}/{subscription}")
+	assert.Nil(t, err)
+	assert.Equal(t, "/events/{topic}/{subscription}", adapter.(*Adapter).routing.path)
+	_, err = pusu.Open("gcp://my-project?host=http://localhost&path=events")
+	assert.Error(t, err)
+
+	// Unwrapped payloads must be requested by query parameter
+	adapter, err = pusu.Open("gcp://my-project?host=http://localhost&no_wrapper=metadata")
+	assert.Nil(t, err)
+	assert.Equal(t, &pubsub.NoWrapper{WriteMetadata: true}, adapter.(*Adapter).routing.noWrapper)
+	_, err = pusu.Open("gcp://my-project?host=http://localhost&no_wrapper=yes")
+	assert.Error(t, err)
+}
+
 // A fake creator definition
 type fakeCreator struct {
 	mock.Mock
@@ -207,3 +380,9 @@ func (f *fakeSubscription) WillHaveProperFields() pusu.Subscription {
 func (f *fakeSubscription) WillReturnError() *fakeSubscription {
 	return f.WithTopic("test").WithName("testing").WithReturning(errors.New("error"))
 }
+
+// Points Pub/Sub clients to an emulator host, so they are created without Google Cloud credentials. Nothing listens
+// there, so tests using it must not call Pub/Sub.
+func withoutCredentials(t *testing.T) {
+	t.Setenv("PUBSUB_EMULATOR_HOST", "localhost:8085")
+}
@@ -3,13 +3,50 @@ package google
 import (
 	"github.com/metglobal-compass/pusu"
 	"google.golang.org/appengine"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+const (
+	livenessPath  = "/healthz"
+	readinessPath = "/readyz"
+	metricsPath   = "/metrics"
 )
 
 // App engine http handler which implements pusu.Runner interface
 type appEngineRunner struct {
+	health  *pusu.Health
+	metrics *pusu.Metrics
+
+	// Registers health and metrics handlers only once, since Run may be called for every subscription
+	once sync.Once
 }
 
 func (a *appEngineRunner) Run(subscription pusu.Subscription) error {
+	a.once.Do(func() {
+		a.handleHealth(http.DefaultServeMux)
+		a.handleMetrics(http.DefaultServeMux)
+	})
 	appengine.Main()
 	return nil
 }
+
+// Adds liveness and readiness probe handlers to given mux, unless application already serves their paths
+func (a *appEngineRunner) handleHealth(mux *http.ServeMux) {
+	handle(mux, livenessPath, http.HandlerFunc(a.health.ServeLiveness))
+	handle(mux, readinessPath, http.HandlerFunc(a.health.ServeReadiness))
+}
+
+// Adds metrics handler to given mux, unless application already serves its path
+func (a *appEngineRunner) handleMetrics(mux *http.ServeMux) {
+	handle(mux, metricsPath, a.metrics)
+}
+
+// Adds handler of path to mux if path is not registered yet, since registering a path twice panics
+func handle(mux *http.ServeMux, path string, handler http.Handler) {
+	if _, pattern := mux.Handler(&http.Request{Method: http.MethodGet, URL: &url.URL{Path: path}}); pattern == path {
+		return
+	}
+	mux.Handle(path, handler)
+}
@@ -0,0 +1,73 @@
+package google
+
+import (
+	"github.com/metglobal-compass/pusu"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAppEngineRunner_HandleHealth(t *testing.T) {
+	// Register probe handlers to a fresh mux
+	health := new(pusu.Health)
+	runner := &appEngineRunner{health: health}
+	mux := http.NewServeMux()
+	runner.handleHealth(mux)
+
+	// Liveness probe must return 200 regardless of provisioning
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, livenessPath, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Readiness probe must return 503 until subscription is provisioned
+	subscription := new(fakeSubscription).WillHaveProperFields()
+	health.Register(subscription)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, readinessPath, nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	health.Provisioned(subscription)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, readinessPath, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAppEngineRunner_HandleMetrics(t *testing.T) {
+	// Register metrics handler to a fresh mux
+	metrics := new(pusu.Metrics)
+	metrics.Observe(new(fakeSubscription).WillHaveProperFields(), nil)
+	runner := &appEngineRunner{metrics: metrics}
+	mux := http.NewServeMux()
+	runner.handleMetrics(mux)
+
+	// Metrics endpoint must expose observed messages
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, metricsPath, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `pusu_messages_total{subscription="testing",outcome="success"} 1`)
+}
+
+func TestAppEngineRunner_HandleTwice(t *testing.T) {
+	// Application may serve metrics itself
+	mux := http.NewServeMux()
+	mux.HandleFunc(metricsPath, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	// Registering handlers again must not panic nor replace handlers of application
+	runner := &appEngineRunner{health: new(pusu.Health), metrics: new(pusu.Metrics)}
+	assert.NotPanics(t, func() {
+		runner.handleHealth(mux)
+		runner.handleMetrics(mux)
+		runner.handleHealth(mux)
+		runner.handleMetrics(mux)
+	})
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, metricsPath, nil))
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, livenessPath, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
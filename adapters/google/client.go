@@ -3,6 +3,7 @@ package google
 import (
 	"cloud.google.com/go/pubsub"
 	"context"
+	"time"
 )
 
 type client interface {
@@ -12,4 +13,16 @@ type client interface {
 	Subscription(name string) *pubsub.Subscription
 	SubscriptionExists(ctx context.Context, subscription *pubsub.Subscription) (bool, error)
 	CreateSubscription(ctx context.Context, name string, config pubsub.SubscriptionConfig) (*pubsub.Subscription, error)
+	TopicNames(ctx context.Context) ([]string, error)
+	SubscriptionNames(ctx context.Context) ([]string, error)
+	DescribeSubscription(ctx context.Context, name string) (SubscriptionInfo, error)
+	UpdatePushConfig(ctx context.Context, name string, config pubsub.PushConfig, ackDeadline time.Duration) error
+	UpdateSubscription(ctx context.Context, name string, config pubsub.SubscriptionConfigToUpdate) error
+	TopicLabels(ctx context.Context, name string) (map[string]string, error)
+	UpdateTopicLabels(ctx context.Context, name string, labels map[string]string) error
+	DeleteTopic(ctx context.Context, name string) error
+	DeleteSubscription(ctx context.Context, name string) error
+	Publish(ctx context.Context, topic string, data []byte, attributes map[string]string) (string, error)
+	Receive(ctx context.Context, subscription string, handle func(m ReceivedMessage)) error
+	Close() error
 }
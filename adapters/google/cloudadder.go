@@ -3,18 +3,25 @@ package google
 import (
 	"cloud.google.com/go/pubsub"
 	"context"
-	"fmt"
 	"github.com/metglobal-compass/pusu"
+	"log"
 	"time"
 )
 
 const (
-	endpointPattern = "%s/_handlers/topics/%s/subscribers/%s"
+	// Pub/Sub redelivers a message if it is not acknowledged within ack deadline
+	ackDeadline = 10 * time.Second
+
+	// Handlers are given up this much before ack deadline, so failure response reaches Pub/Sub in time
+	ackDeadlineMargin = 2 * time.Second
 )
 
 type cloudAdder struct {
 	client client
 	host   string
+
+	// Names subscriptions in Pub/Sub and routes their push endpoints. Optional.
+	routing *routing
 }
 
 // Implementation of internal Creator interface for Google Adapter
@@ -22,8 +29,11 @@ func (t *cloudAdder) CreateSubscription(subscription pusu.Subscription) error {
 	// Use single context
 	ctx := context.Background()
 
+	// Resolve names in Pub/Sub
+	topicName, name := t.routing.names(subscription)
+
 	// Get topic instance
-	topic := t.client.Topic(subscription.Topic())
+	topic := t.client.Topic(topicName)
 
 	// Check if topic existss
 	topicExists, err := t.client.TopicExists(ctx, topic)
@@ -33,14 +43,14 @@ func (t *cloudAdder) CreateSubscription(subscription pusu.Subscription) error {
 
 	// If topic does not exists, create in cloud
 	if !topicExists {
-		topic, err = t.client.CreateTopic(ctx, subscription.Topic())
+		topic, err = t.client.CreateTopic(ctx, topicName)
 		if err != nil {
 			return err
 		}
 	}
 
 	// Create subscription instance
-	clientSubscription := t.client.Subscription(subscription.Name())
+	clientSubscription := t.client.Subscription(name)
 
 	// Check if subscription exists
 	exists, err := t.client.SubscriptionExists(ctx, clientSubscription)
@@ -48,20 +58,44 @@ func (t *cloudAdder) CreateSubscription(subscription pusu.Subscription) error {
 		return err
 	}
 
-	// If subscription does not exists, create it in cloud
+	// If subscription does not exists, create it in cloud with its filter, which can't be changed afterwards
+	filter, _ := pusu.FilterOf(subscription)
 	if !exists {
 		subscriptionConfig := pubsub.SubscriptionConfig{
 			Topic:       topic,
-			AckDeadline: 10 * time.Second,
-			PushConfig: pubsub.PushConfig{
-				Endpoint: fmt.Sprintf(endpointPattern, t.host, subscription.Topic(), subscription.Name()),
-			},
+			AckDeadline: ackDeadline,
+			PushConfig:  t.routing.pushConfig(t.host, subscription),
+			Filter:      filter,
 		}
-		_, err := t.client.CreateSubscription(ctx, subscription.Name(), subscriptionConfig)
+		_, err := t.client.CreateSubscription(ctx, name, subscriptionConfig)
 		if err != nil {
 			return err
 		}
+		return nil
+	}
+
+	// Existing subscription keeps its filter, so tell if it is not the declared one
+	info, err := t.client.DescribeSubscription(ctx, name)
+	if err != nil {
+		return err
+	}
+	if info.Filter != filter {
+		log.Printf("Filter of subscription %s is %q instead of %q, subscription must be recreated to change it",
+			name, info.Filter, filter)
+	}
+
+	// Point subscription to this subscriber again if its push endpoint or payload wrapper drifted (e.g. host or path
+	// template changed), otherwise messages would be pushed where nothing handles them
+	pushConfig := t.routing.pushConfigKeeping(t.routing.endpoint(t.host, subscription), info)
+	if pushConfigDiffers(info, pushConfig) {
+		log.Printf("Push endpoint of subscription %s is %q instead of %q, it is updated", name, info.Endpoint, pushConfig.Endpoint)
+		return t.client.UpdatePushConfig(ctx, name, pushConfig, ackDeadline)
 	}
 
 	return nil
 }
+
+// Implementation of pusu.FilterEnforcer interface, since filters are set on Pub/Sub subscriptions
+func (t *cloudAdder) EnforcesFilters() bool {
+	return true
+}
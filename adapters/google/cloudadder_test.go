@@ -4,7 +4,7 @@ import (
 	"cloud.google.com/go/pubsub"
 	"context"
 	"errors"
-	"fmt"
+	"github.com/metglobal-compass/pusu"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"testing"
@@ -37,7 +37,7 @@ func TestCloudAdder_CreateSubscription(t *testing.T) {
 		Topic:       &pubsub.Topic{},
 		AckDeadline: 10 * time.Second,
 		PushConfig: pubsub.PushConfig{
-			Endpoint: fmt.Sprintf(endpointPattern, cloudAdder.host, "test", "testing"),
+			Endpoint: "http://localhost/_handlers/topics/test/subscribers/testing",
 		},
 	}
 	fakeClient.AssertCalled(t, "CreateSubscription", mock.Anything, "testing", expectedPushConfiguration)
@@ -52,6 +52,161 @@ func TestCloudAdder_CreateSubscription(t *testing.T) {
 	fakeClient.AssertExpectations(t)
 }
 
+func TestCloudAdder_CreateSubscriptionWithNaming(t *testing.T) {
+	// Create fake mocked client. In this case, topic and subscription exist under names of naming policy
+	fakeClient := new(fakeClient)
+	fakeClient.On("Topic", "staging-test").Return(&pubsub.Topic{})
+	fakeClient.On("TopicExists", context.Background(), &pubsub.Topic{}).Return(false, nil)
+	fakeClient.On("CreateTopic", context.Background(), "staging-test").Return(&pubsub.Topic{}, nil)
+	fakeClient.On("Subscription", "staging-testing").Return(&pubsub.Subscription{})
+	fakeClient.On("SubscriptionExists", context.Background(), &pubsub.Subscription{}).Return(false, nil)
+	fakeClient.On("CreateSubscription", context.Background(), "staging-testing", mock.Anything).
+		Return(&pubsub.Subscription{}, nil)
+
+	// Call real method
+	cloudAdder := new(cloudAdder)
+	cloudAdder.client = fakeClient
+	cloudAdder.host = "http://localhost"
+	cloudAdder.routing = &routing{naming: Naming{Prefix: "staging-"}}
+	err := cloudAdder.CreateSubscription(new(fakeSubscription).WillHaveProperFields())
+	assert.Nil(t, err)
+
+	// Push endpoint must be named after names in Pub/Sub
+	fakeClient.AssertCalled(t, "CreateSubscription", mock.Anything, "staging-testing", pubsub.SubscriptionConfig{
+		Topic:       &pubsub.Topic{},
+		AckDeadline: ackDeadline,
+		PushConfig: pubsub.PushConfig{
+			Endpoint: "http://localhost/_handlers/topics/staging-test/subscribers/staging-testing",
+		},
+	})
+}
+
+func TestCloudAdder_CreateSubscriptionWithRouting(t *testing.T) {
+	// Create fake mocked client. In this case, we try to create a subscription which does not exist in cloud
+	fakeClient := new(fakeClient)
+	fakeClient.On("Topic", "test").Return(&pubsub.Topic{})
+	fakeClient.On("TopicExists", context.Background(), &pubsub.Topic{}).Return(true, nil)
+	fakeClient.On("Subscription", "testing").Return(&pubsub.Subscription{})
+	fakeClient.On("SubscriptionExists", context.Background(), &pubsub.Subscription{}).Return(false, nil)
+	fakeClient.On("CreateSubscription", context.Background(), "testing", mock.Anything).
+		Return(&pubsub.Subscription{}, nil)
+
+	// Call real method with a subscription served on another host
+	cloudAdder := new(cloudAdder)
+	cloudAdder.client = fakeClient
+	cloudAdder.host = "http://localhost"
+	cloudAdder.routing = &routing{path: "/events/{topic}/{subscription}", noWrapper: &pubsub.NoWrapper{WriteMetadata: true}}
+	err := cloudAdder.CreateSubscription(WithHost(new(fakeSubscription).WillHaveProperFields(), "https://other"))
+	assert.Nil(t, err)
+
+	// Push endpoint must point to path template on host of subscription and request unwrapped payloads
+	fakeClient.AssertCalled(t, "CreateSubscription", mock.Anything, "testing", pubsub.SubscriptionConfig{
+		Topic:       &pubsub.Topic{},
+		AckDeadline: ackDeadline,
+		PushConfig: pubsub.PushConfig{
+			Endpoint: "https://other/events/test/testing",
+			Wrapper:  &pubsub.NoWrapper{WriteMetadata: true},
+		},
+	})
+}
+
+func TestCloudAdder_CreateSubscriptionWithFilter(t *testing.T) {
+	// Create fake mocked client. In this case, subscription is created on first call and exists on second call.
+	fakeClient := new(fakeClient)
+	fakeClient.On("Topic", "test").Return(&pubsub.Topic{})
+	fakeClient.On("TopicExists", context.Background(), &pubsub.Topic{}).Return(true, nil)
+	fakeClient.On("Subscription", "testing").Return(&pubsub.Subscription{})
+	fakeClient.On("SubscriptionExists", context.Background(), &pubsub.Subscription{}).Return(false, nil).Once()
+	fakeClient.On("SubscriptionExists", context.Background(), &pubsub.Subscription{}).Return(true, nil)
+	fakeClient.On("CreateSubscription", context.Background(), "testing", mock.Anything).
+		Return(&pubsub.Subscription{}, nil)
+	fakeClient.On("DescribeSubscription", context.Background(), "testing").
+		Return(SubscriptionInfo{Name: "testing", Topic: "test", Filter: `attributes.type = "deleted"`,
+			Endpoint: "http://localhost/_handlers/topics/test/subscribers/testing"}, nil)
+
+	// Call real method
+	cloudAdder := new(cloudAdder)
+	cloudAdder.client = fakeClient
+	cloudAdder.host = "http://localhost"
+	subscription := pusu.WithFilter(new(fakeSubscription).WillHaveProperFields(), `attributes.type = "created"`)
+	assert.Nil(t, cloudAdder.CreateSubscription(subscription))
+
+	// Subscription must be created with its filter
+	fakeClient.AssertCalled(t, "CreateSubscription", mock.Anything, "testing", pubsub.SubscriptionConfig{
+		Topic:       &pubsub.Topic{},
+		AckDeadline: ackDeadline,
+		PushConfig:  pubsub.PushConfig{Endpoint: "http://localhost/_handlers/topics/test/subscribers/testing"},
+		Filter:      `attributes.type = "created"`,
+	})
+	fakeClient.AssertNotCalled(t, "DescribeSubscription", mock.Anything, mock.Anything)
+
+	// Filter of existing subscription can't be changed, it is only checked
+	assert.Nil(t, cloudAdder.CreateSubscription(subscription))
+	fakeClient.AssertNumberOfCalls(t, "CreateSubscription", 1)
+	fakeClient.AssertNumberOfCalls(t, "DescribeSubscription", 1)
+	fakeClient.AssertNotCalled(t, "UpdatePushConfig", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCloudAdder_CreateSubscriptionReconcilesPushConfig(t *testing.T) {
+	// Create fake mocked client. In this case, subscription exists with endpoint of an earlier host.
+	fakeClient := new(fakeClient)
+	fakeClient.On("Topic", "test").Return(&pubsub.Topic{})
+	fakeClient.On("TopicExists", context.Background(), &pubsub.Topic{}).Return(true, nil)
+	fakeClient.On("Subscription", "testing").Return(&pubsub.Subscription{})
+	fakeClient.On("SubscriptionExists", context.Background(), &pubsub.Subscription{}).Return(true, nil)
+	fakeClient.On("DescribeSubscription", context.Background(), "testing").Return(SubscriptionInfo{
+		Name: "testing", Topic: "test", Endpoint: "http://old/_handlers/topics/test/subscribers/testing",
+		Unwrapped: true, WriteMetadata: true,
+	}, nil)
+	fakeClient.On("UpdatePushConfig", context.Background(), "testing", pubsub.PushConfig{
+		Endpoint: "http://localhost/_handlers/topics/test/subscribers/testing",
+		Wrapper:  &pubsub.NoWrapper{WriteMetadata: true},
+	}, ackDeadline).Return(nil)
+
+	// Subscription must be pointed to this subscriber again, keeping its payload wrapper
+	cloudAdder := &cloudAdder{client: fakeClient, host: "http://localhost"}
+	assert.Nil(t, cloudAdder.CreateSubscription(new(fakeSubscription).WillHaveProperFields()))
+	fakeClient.AssertExpectations(t)
+	fakeClient.AssertNotCalled(t, "CreateSubscription", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCloudAdder_CreateSubscriptionReplacesWrapper(t *testing.T) {
+	// Create fake mocked client. In this case, subscription exists with metadata headers which subscriber doesn't request.
+	fakeClient := new(fakeClient)
+	fakeClient.On("Topic", "test").Return(&pubsub.Topic{})
+	fakeClient.On("TopicExists", context.Background(), &pubsub.Topic{}).Return(true, nil)
+	fakeClient.On("Subscription", "testing").Return(&pubsub.Subscription{})
+	fakeClient.On("SubscriptionExists", context.Background(), &pubsub.Subscription{}).Return(true, nil)
+	fakeClient.On("DescribeSubscription", context.Background(), "testing").Return(SubscriptionInfo{
+		Name: "testing", Topic: "test", Endpoint: "http://localhost/_handlers/topics/test/subscribers/testing",
+		Unwrapped: true, WriteMetadata: true,
+	}, nil)
+	fakeClient.On("UpdatePushConfig", context.Background(), "testing", pubsub.PushConfig{
+		Endpoint: "http://localhost/_handlers/topics/test/subscribers/testing",
+		Wrapper:  &pubsub.NoWrapper{},
+	}, ackDeadline).Return(nil)
+
+	// Wrapper requested by subscriber must replace the existing one
+	cloudAdder := &cloudAdder{client: fakeClient, host: "http://localhost", routing: &routing{noWrapper: &pubsub.NoWrapper{}}}
+	assert.Nil(t, cloudAdder.CreateSubscription(new(fakeSubscription).WillHaveProperFields()))
+	fakeClient.AssertExpectations(t)
+}
+
+func TestCloudAdder_CreateSubscriptionErrorOnDescribe(t *testing.T) {
+	// Create fake mocked client which fails while describing existing subscription
+	fakeClient := new(fakeClient)
+	fakeClient.On("Topic", "test").Return(&pubsub.Topic{})
+	fakeClient.On("TopicExists", context.Background(), &pubsub.Topic{}).Return(true, nil)
+	fakeClient.On("Subscription", "testing").Return(&pubsub.Subscription{})
+	fakeClient.On("SubscriptionExists", context.Background(), &pubsub.Subscription{}).Return(true, nil)
+	fakeClient.On("DescribeSubscription", context.Background(), "testing").Return(SubscriptionInfo{}, errors.New("error"))
+
+	// Got an error without updating subscription
+	cloudAdder := &cloudAdder{client: fakeClient, host: "http://localhost"}
+	assert.Error(t, cloudAdder.CreateSubscription(new(fakeSubscription).WillHaveProperFields()))
+	fakeClient.AssertNotCalled(t, "UpdatePushConfig", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestCloudAdder_CreateSubscriptionErrorOnTopicExists(t *testing.T) {
 	// Create fake mocked client In this case, we get an error while checking a topic's existence
 	fakeClient := new(fakeClient)
@@ -208,3 +363,66 @@ func (f *fakeClient) CreateSubscription(ctx context.Context, name string, config
 	args := f.Called(ctx, name, config)
 	return args.Get(0).(*pubsub.Subscription), args.Error(1)
 }
+
+func (f *fakeClient) TopicNames(ctx context.Context) ([]string, error) {
+	args := f.Called(ctx)
+	names, _ := args.Get(0).([]string)
+	return names, args.Error(1)
+}
+
+func (f *fakeClient) SubscriptionNames(ctx context.Context) ([]string, error) {
+	args := f.Called(ctx)
+	names, _ := args.Get(0).([]string)
+	return names, args.Error(1)
+}
+
+func (f *fakeClient) DescribeSubscription(ctx context.Context, name string) (SubscriptionInfo, error) {
+	args := f.Called(ctx, name)
+	return args.Get(0).(SubscriptionInfo), args.Error(1)
+}
+
+func (f *fakeClient) UpdatePushConfig(ctx context.Context, name string, config pubsub.PushConfig, ackDeadline time.Duration) error {
+	args := f.Called(ctx, name, config, ackDeadline)
+	return args.Error(0)
+}
+
+func (f *fakeClient) DeleteTopic(ctx context.Context, name string) error {
+	args := f.Called(ctx, name)
+	return args.Error(0)
+}
+
+func (f *fakeClient) DeleteSubscription(ctx context.Context, name string) error {
+	args := f.Called(ctx, name)
+	return args.Error(0)
+}
+
+func (f *fakeClient) Publish(ctx context.Context, topic string, data []byte, attributes map[string]string) (string, error) {
+	args := f.Called(ctx, topic, data, attributes)
+	return args.String(0), args.Error(1)
+}
+
+func (f *fakeClient) Close() error {
+	args := f.Called()
+	return args.Error(0)
+}
+
+func (f *fakeClient) Receive(ctx context.Context, subscription string, handle func(m ReceivedMessage)) error {
+	args := f.Called(ctx, subscription, handle)
+	return args.Error(0)
+}
+
+func (f *fakeClient) UpdateSubscription(ctx context.Context, name string, config pubsub.SubscriptionConfigToUpdate) error {
+	args := f.Called(ctx, name, config)
+	return args.Error(0)
+}
+
+func (f *fakeClient) TopicLabels(ctx context.Context, name string) (map[string]string, error) {
+	args := f.Called(ctx, name)
+	labels, _ := args.Get(0).(map[string]string)
+	return labels, args.Error(1)
+}
+
+func (f *fakeClient) UpdateTopicLabels(ctx context.Context, name string, labels map[string]string) error {
+	args := f.Called(ctx, name, labels)
+	return args.Error(0)
+}
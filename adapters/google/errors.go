@@ -1,7 +1,14 @@
 package google
 
 const (
-	ErrorJsonSyntax          string = "Fatal error while decoding http request json payload."
-	ErrorBase64MessageSyntax string = "Fatal error while decoding base64 message data."
-	ErrorMessageExecution    string = "Message execution unsuccessful."
+	ErrorJsonSyntax           string = "Fatal error while decoding http request json payload."
+	ErrorBase64MessageSyntax  string = "Fatal error while decoding base64 message data."
+	ErrorCloudEventSyntax     string = "Fatal error while decoding CloudEvent."
+	ErrorPayloadRead          string = "Fatal error while reading http request payload."
+	ErrorMessageExecution     string = "Message execution unsuccessful."
+	ErrorTooManyInFlight      string = "Too many messages in flight, try again later."
+	ErrorRateLimited          string = "Rate limit exceeded, try again later."
+	ErrorCircuitOpen          string = "Circuit is open, try again later."
+	ErrorHandlerTimeout       string = "Message execution timed out."
+	ErrorSubscriptionMismatch string = "Message is not pushed by a subscription of this endpoint."
 )
@@ -1,62 +1,141 @@
 package google
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/metglobal-compass/pusu"
+	"io"
+	"log"
 	"net/http"
+	"strings"
 )
 
 type httpHandlerAdder struct {
-	subscription pusu.Subscription
+	// Subscriptions by path of their push endpoints. Subscriptions sharing a path are told apart by push envelope.
+	subscriptions map[string][]pusu.Subscription
+
+	// Project which push envelopes must come from. Not checked if empty.
+	projectId string
+
+	// Counts outcomes of handled messages. Optional.
+	metrics *pusu.Metrics
+
+	// Routes push endpoints to paths, same as cloud adder. Optional.
+	routing *routing
 }
 
 // Implementation of internal Creator interface for Google Adapter
 func (h *httpHandlerAdder) CreateSubscription(subscription pusu.Subscription) error {
-	h.subscription = subscription
-	http.Handle(h.UrlPath(subscription), h)
+	// Path is handled once, even if it is shared by subscriptions
+	if h.add(subscription) {
+		http.Handle(h.UrlPath(subscription), h)
+	}
 	return nil
 }
 
+// Adds subscription to its path. Reports whether path is new.
+func (h *httpHandlerAdder) add(subscription pusu.Subscription) bool {
+	if h.subscriptions == nil {
+		h.subscriptions = make(map[string][]pusu.Subscription)
+	}
+
+	path := h.UrlPath(subscription)
+	h.subscriptions[path] = append(h.subscriptions[path], subscription)
+
+	return len(h.subscriptions[path]) == 1
+}
+
 // Implementation of handler interface of net/http Handler interface
 func (h *httpHandlerAdder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Check path in case of anything
-	if r.URL.Path != h.UrlPath(h.subscription) || r.Method != http.MethodPost {
+	candidates := h.subscriptions[r.URL.Path]
+	if len(candidates) == 0 || r.Method != http.MethodPost {
 		http.Error(w, ErrorMessageExecution, http.StatusNotFound)
 		return
 	}
 
-	// Convert pubsubmessage structure to pusu.Message
-	var m message
-	err := json.NewDecoder(r.Body).Decode(&m)
-	if err != nil || m.Message.Data == "" {
-		http.Error(w, ErrorJsonSyntax, http.StatusInternalServerError)
+	// Convert pubsubmessage structure, unwrapped payload or CloudEvent to pusu.Message
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPushSize))
+	if err != nil {
+		http.Error(w, ErrorPayloadRead, http.StatusInternalServerError)
+		return
+	}
+	m, err := decodePush(r.Header, body, h.routing.unwrapped())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Decode base64 encoded pub/sub data to string
-	s, err := base64.StdEncoding.DecodeString(m.Message.Data)
+	// Find subscription which message is pushed to
+	subscription, err := h.subscriptionOf(m.subscription, candidates)
 	if err != nil {
-		http.Error(w, ErrorBase64MessageSyntax, http.StatusInternalServerError)
+		log.Printf("Rejected message pushed to %s, possible misrouting or spoofing: %s", r.URL.Path, err)
+		http.Error(w, ErrorSubscriptionMismatch, http.StatusForbidden)
 		return
 	}
 
-	// Create message
-	pusuMessage := pusu.NewMessage(string(s))
+	// Create message with its attributes. Its context is cancelled when Pub/Sub drops the request.
+	pusuMessage := pusu.NewMessage(string(m.data)).WithAttributes(m.attributes).WithContext(r.Context())
 
-	// Execute real method of subscription
-	err = h.subscription.Handle(pusuMessage)
+	// Execute real method of subscription. Unless subscription has its own timeout, give up before ack deadline
+	// since Pub/Sub redelivers the message afterwards anyway.
+	err = pusu.HandleWithTimeout(subscription, pusuMessage, ackDeadline-ackDeadlineMargin)
+	if h.metrics != nil {
+		h.metrics.Observe(subscription, err)
+	}
+	if errors.Is(err, pusu.ErrHandlerTimeout) {
+		log.Printf("Handler of subscription %s timed out, message will be retried", subscription.Name())
+	} else if err != nil && !pusu.IsRejection(err) {
+		log.Printf("Handler of subscription %s failed, message will be retried: %s", subscription.Name(), err)
+	}
 
-	// Return 500 status code in case of any error, otherwise do nothing
-	if err != nil {
+	// Return 429 status code when subscription is over its concurrency or rate limit and 503 status code while its
+	// circuit is open, so Pub/Sub push backoff kicks in.
+	// Return 500 status code in case of any other error, otherwise do nothing
+	if errors.Is(err, pusu.ErrTooManyInFlight) {
+		http.Error(w, ErrorTooManyInFlight, http.StatusTooManyRequests)
+	} else if errors.Is(err, pusu.ErrRateLimited) {
+		http.Error(w, ErrorRateLimited, http.StatusTooManyRequests)
+	} else if errors.Is(err, pusu.ErrCircuitOpen) {
+		http.Error(w, ErrorCircuitOpen, http.StatusServiceUnavailable)
+	} else if errors.Is(err, pusu.ErrHandlerTimeout) {
+		http.Error(w, ErrorHandlerTimeout, http.StatusInternalServerError)
+	} else if err != nil {
 		http.Error(w, ErrorMessageExecution, http.StatusInternalServerError)
 	} else {
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
+// Returns subscription of candidates which push envelope is addressed to, in form of
+// projects/<project id>/subscriptions/<subscription name in Pub/Sub>. Envelopes without subscription are accepted
+// only if there is a single candidate.
+func (h *httpHandlerAdder) subscriptionOf(address string, candidates []pusu.Subscription) (pusu.Subscription, error) {
+	if address == "" {
+		if len(candidates) == 1 {
+			return candidates[0], nil
+		}
+		return nil, errors.New("subscription of push envelope is missing")
+	}
+
+	parts := strings.Split(address, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != "subscriptions" {
+		return nil, fmt.Errorf("subscription %q of push envelope is malformed", address)
+	}
+	if h.projectId != "" && parts[1] != h.projectId {
+		return nil, fmt.Errorf("subscription %q of push envelope belongs to another project than %s", address, h.projectId)
+	}
+
+	for _, candidate := range candidates {
+		if _, name := h.routing.names(candidate); name == parts[3] {
+			return candidate, nil
+		}
+	}
+
+	return nil, fmt.Errorf("subscription %q of push envelope is not served on this path", address)
+}
+
 // Get url path of subscriber
 func (h *httpHandlerAdder) UrlPath(subscription pusu.Subscription) string {
-	return fmt.Sprintf("/_handlers/topics/%s/subscribers/%s", subscription.Topic(), subscription.Name())
+	return h.routing.endpointPath(subscription)
 }
@@ -2,17 +2,23 @@ package google
 
 import (
 	"bytes"
+	"cloud.google.com/go/pubsub"
+	"errors"
 	"fmt"
+	"github.com/metglobal-compass/pusu"
+	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestHttpHandlerAdder_CreateSubscription(t *testing.T) {
 	// Create subscription via httpdhandleradder
 	handler := new(httpHandlerAdder)
-	handler.CreateSubscription(new(fakeSubscription).WillHaveProperFields())
+	subscription := new(fakeSubscription).WillHaveProperFields()
+	handler.CreateSubscription(subscription)
 
 	// Create test server
 	server := httptest.NewServer(handler)
@@ -21,7 +27,7 @@ func TestHttpHandlerAdder_CreateSubscription(t *testing.T) {
 	// Create request body and make request
 	requestBody := []byte(`{"message": {"data": "W3sib2JqZWN0X2lkIjoxLCJvYmplY3RfbmFtZSI6IkFsbG90bWVudFBsYW4iLCJjaGlsZF9vYmplY3RfbmFtZSI6bnVsbCwib2JqZWN0X2RlZmluaXRpb24iOnsiaWQiOjEsImNsYXNzIjoiQWxsb3RtZW50UGxhbiJ9LCJhY3Rpb25fbmFtZSI6InVwZGF0ZSIsImxvZ190aW1lIjoiMjAxOC0wMi0xNiAxNTozNTowOSIsImNoYW5nZV9zZXQiOnsibmFtZSI6eyJvbGQiOiJCQVIiLCJuZXciOiJ0ZXN0cyJ9fSwiY29uc3VtZXJfbmFtZSI6IkNvbXBhc3MiLCJjb25zdW1lcl9pZCI6MSwiaXBfYWRkcmVzcyI6IjEwLjQuNC4xIiwidXNlcl9pZCI6MywidXNlcm5hbWUiOiJzZXlmaSIsImNsaWVudF9uYW1lIjoiSG90ZWxzcHJvIERNQ0MifV0="}}`)
 	resp, _ := http.Post(
-		fmt.Sprintf("%s%s", server.URL, handler.UrlPath(handler.subscription)),
+		fmt.Sprintf("%s%s", server.URL, handler.UrlPath(subscription)),
 		"application/json",
 		bytes.NewReader(requestBody),
 	)
@@ -45,6 +51,185 @@ func TestHttpHandlerAdder_UrlPath(t *testing.T) {
 	}
 }
 
+func TestHttpHandlerAdder_UrlPathWithNaming(t *testing.T) {
+	// Create http handler adder with naming policy
+	handler := new(httpHandlerAdder)
+	handler.routing = &routing{naming: Naming{Env: "staging", TopicTemplate: "{env}-{topic}", SubscriptionTemplate: "{env}-{subscription}"}}
+
+	// Path must be named after names in Pub/Sub, same as push endpoint of cloud adder
+	path := handler.UrlPath(new(fakeSubscription).WillHaveProperFields())
+	if path != "/_handlers/topics/staging-test/subscribers/staging-testing" {
+		t.Errorf("Url path must be named by naming policy. \nActual:\n%s", path)
+	}
+}
+
+func TestHttpHandlerAdder_ServeHTTPWithEndpointPath(t *testing.T) {
+	// Create http handler adder with custom path template
+	handler := new(httpHandlerAdder)
+	handler.routing = &routing{path: "/events/{subscription}"}
+	handler.add(new(fakeSubscription).WillHaveProperFields())
+
+	// Message must be handled on custom path
+	body := `{"message": {"data": "aGVsbG8="}}`
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/events/testing", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Errorf("Status code is not valid. \nExcepted: 200\n Actual:%d", w.Code)
+	}
+
+	// Default path must not be served anymore
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/_handlers/topics/test/subscribers/testing", strings.NewReader(body)))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Status code is not valid. \nExcepted: 404\n Actual:%d", w.Code)
+	}
+}
+
+func TestHttpHandlerAdder_ServeHTTPSharedPath(t *testing.T) {
+	// Create http handler adder whose subscriptions share a path
+	handler := new(httpHandlerAdder)
+	handler.projectId = "my-project"
+	handler.routing = &routing{naming: Naming{Prefix: "dev-"}, path: "/events"}
+	first := new(fakeSubscription).WithTopic("test").WithName("testing").WithReturning(nil)
+	second := new(fakeSubscription).WithTopic("test").WithName("other").WithReturning(errors.New("error"))
+	handler.add(first)
+	handler.add(second)
+
+	push := func(address string) int {
+		body := fmt.Sprintf(`{"message": {"data": "aGVsbG8="}, "subscription": %q}`, address)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body)))
+		return w.Code
+	}
+
+	// Messages must be routed by subscription of push envelope, named by naming policy
+	if code := push("projects/my-project/subscriptions/dev-testing"); code != http.StatusOK {
+		t.Errorf("Status code is not valid. \nExcepted: 200\n Actual:%d", code)
+	}
+	first.AssertNumberOfCalls(t, "Handle", 1)
+	if code := push("projects/my-project/subscriptions/dev-other"); code != http.StatusInternalServerError {
+		t.Errorf("Status code is not valid. \nExcepted: 500\n Actual:%d", code)
+	}
+	second.AssertNumberOfCalls(t, "Handle", 1)
+
+	// Envelopes of other projects, unknown subscriptions or without subscription must be rejected
+	for _, address := range []string{
+		"projects/other-project/subscriptions/dev-testing",
+		"projects/my-project/subscriptions/testing",
+		"projects/my-project/topics/dev-testing",
+		"",
+	} {
+		if code := push(address); code != http.StatusForbidden {
+			t.Errorf("Status code is not valid for %q. \nExcepted: 403\n Actual:%d", address, code)
+		}
+	}
+	first.AssertNumberOfCalls(t, "Handle", 1)
+	second.AssertNumberOfCalls(t, "Handle", 1)
+}
+
+func TestHttpHandlerAdder_ServeHTTPErrorOnSubscriptionMismatch(t *testing.T) {
+	// Create http handler adder of a single subscription
+	handler := new(httpHandlerAdder)
+	handler.projectId = "my-project"
+	subscription := new(fakeSubscription).WillHaveProperFields()
+	handler.add(subscription)
+
+	// Message pushed by another subscription to this path must be rejected
+	body := `{"message": {"data": "aGVsbG8="}, "subscription": "projects/my-project/subscriptions/other"}`
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/_handlers/topics/test/subscribers/testing", strings.NewReader(body)))
+	if w.Code != http.StatusForbidden || strings.TrimSpace(w.Body.String()) != ErrorSubscriptionMismatch {
+		t.Errorf("Status code is not valid. \nExcepted: 403\n Actual:%d", w.Code)
+	}
+	subscription.(*fakeSubscription).AssertNotCalled(t, "Handle", mock.Anything)
+
+	// Message pushed by registered subscription must be handled
+	body = `{"message": {"data": "aGVsbG8="}, "subscription": "projects/my-project/subscriptions/testing"}`
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/_handlers/topics/test/subscribers/testing", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Errorf("Status code is not valid. \nExcepted: 200\n Actual:%d", w.Code)
+	}
+}
+
+func TestHttpHandlerAdder_ServeHTTPUnwrapped(t *testing.T) {
+	// Create http handler adder whose subscriptions share a path and receive unwrapped payloads
+	handler := new(httpHandlerAdder)
+	handler.projectId = "my-project"
+	handler.routing = &routing{path: "/events", noWrapper: &pubsub.NoWrapper{WriteMetadata: true}}
+	first := new(fakeSubscription).WithTopic("test").WithName("first").WithReturning(nil)
+	second := new(fakeSubscription).WithTopic("test").WithName("second").WithReturning(nil)
+	handler.add(first)
+	handler.add(second)
+
+	// Raw payload must be handled by subscription of metadata headers, with attributes of its headers
+	req := httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"id": 1}`))
+	req.Header.Set("X-Goog-Pubsub-Message-Id", "1")
+	req.Header.Set("X-Goog-Pubsub-Subscription-Name", "projects/my-project/subscriptions/second")
+	req.Header.Set("event", "created")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Status code is not valid. \nExcepted: 200\n Actual:%d", w.Code)
+	}
+	second.AssertCalled(t, "Handle", mock.MatchedBy(func(m *pusu.Message) bool {
+		return m.Message() == `{"id": 1}` && m.Attributes()["event"] == "created"
+	}))
+	first.AssertNotCalled(t, "Handle", mock.Anything)
+
+	// Envelopes must still be decoded
+	body := `{"message": {"data": "aGVsbG8="}, "subscription": "projects/my-project/subscriptions/first"}`
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Errorf("Status code is not valid. \nExcepted: 200\n Actual:%d", w.Code)
+	}
+	first.AssertCalled(t, "Handle", mock.MatchedBy(func(m *pusu.Message) bool {
+		return m.Message() == "hello"
+	}))
+
+	// Raw payload without metadata can't be routed to one of subscriptions sharing a path
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(`{"id": 1}`)))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Status code is not valid. \nExcepted: 403\n Actual:%d", w.Code)
+	}
+}
+
+func TestHttpHandlerAdder_ServeHTTPCloudEvent(t *testing.T) {
+	// Create http handler adder
+	handler := new(httpHandlerAdder)
+	subscription := new(fakeSubscription).WithTopic("test").WithName("testing").WithReturning(nil)
+	handler.add(subscription)
+
+	// CloudEvent in binary content mode must be handled with its context attributes
+	req := httptest.NewRequest(http.MethodPost, "/_handlers/topics/test/subscribers/testing", strings.NewReader(`{"id":1}`))
+	req.Header.Set("Ce-Id", "1")
+	req.Header.Set("Ce-Source", "//orders")
+	req.Header.Set("Ce-Specversion", "1.0")
+	req.Header.Set("Ce-Type", "created")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Status code is not valid. \nExcepted: 200\n Actual:%d", w.Code)
+	}
+	subscription.AssertCalled(t, "Handle", mock.MatchedBy(func(m *pusu.Message) bool {
+		e, ok := pusu.CloudEventOf(m)
+		return ok && e.Type == "created" && e.Source == "//orders" && string(e.Data) == `{"id":1}`
+	}))
+
+	// Invalid CloudEvent must be rejected
+	req = httptest.NewRequest(http.MethodPost, "/_handlers/topics/test/subscribers/testing", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", pusu.CloudEventContentType)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Status code is not valid. \nExcepted: 500\n Actual:%d", w.Code)
+	}
+	subscription.AssertNumberOfCalls(t, "Handle", 1)
+}
+
 func TestHttpHandlerAdder_ServeHTTP(t *testing.T) {
 	// Create test request data
 	body := []byte(`{"message": {"data": "W3sib2JqZWN0X2lkIjoxLCJvYmplY3RfbmFtZSI6IkFsbG90bWVudFBsYW4iLCJjaGlsZF9vYmplY3RfbmFtZSI6bnVsbCwib2JqZWN0X2RlZmluaXRpb24iOnsiaWQiOjEsImNsYXNzIjoiQWxsb3RtZW50UGxhbiJ9LCJhY3Rpb25fbmFtZSI6InVwZGF0ZSIsImxvZ190aW1lIjoiMjAxOC0wMi0xNiAxNTozNTowOSIsImNoYW5nZV9zZXQiOnsibmFtZSI6eyJvbGQiOiJCQVIiLCJuZXciOiJ0ZXN0cyJ9fSwiY29uc3VtZXJfbmFtZSI6IkNvbXBhc3MiLCJjb25zdW1lcl9pZCI6MSwiaXBfYWRkcmVzcyI6IjEwLjQuNC4xIiwidXNlcl9pZCI6MywidXNlcm5hbWUiOiJzZXlmaSIsImNsaWVudF9uYW1lIjoiSG90ZWxzcHJvIERNQ0MifV0="}}`)
@@ -56,13 +241,20 @@ func TestHttpHandlerAdder_ServeHTTP(t *testing.T) {
 
 	// Create http handler and call real method
 	handler := new(httpHandlerAdder)
-	handler.subscription = new(fakeSubscription).WillHaveProperFields()
+	subscription := new(fakeSubscription).WillHaveProperFields()
+	handler.add(subscription)
+	handler.metrics = new(pusu.Metrics)
 	handler.ServeHTTP(w, req)
 
 	// Check status code
 	if w.Code != http.StatusOK {
 		t.Errorf("Status code is not valid. \nExcepted: 200\n Actual:%d", w.Code)
 	}
+
+	// Check handled message is counted
+	if handler.metrics.Count(subscription, pusu.OutcomeSuccess) != 1 {
+		t.Errorf("Handled message is not counted in metrics")
+	}
 }
 
 func TestHttpHandlerAdder_ServeHTTPErrorJson(t *testing.T) {
@@ -76,7 +268,7 @@ func TestHttpHandlerAdder_ServeHTTPErrorJson(t *testing.T) {
 
 	// Create http handler and call real method
 	handler := new(httpHandlerAdder)
-	handler.subscription = new(fakeSubscription).WillHaveProperFields()
+	handler.add(new(fakeSubscription).WillHaveProperFields())
 	handler.ServeHTTP(w, req)
 
 	// Check status code
@@ -100,7 +292,7 @@ func TestHttpHandlerAdder_ServeHTTPErrorBase64(t *testing.T) {
 
 	// Create http handler and call real method
 	handler := new(httpHandlerAdder)
-	handler.subscription = new(fakeSubscription).WillHaveProperFields()
+	handler.add(new(fakeSubscription).WillHaveProperFields())
 	handler.ServeHTTP(w, req)
 
 	// Check status code
@@ -124,7 +316,7 @@ func TestHttpHandlerAdder_ServeHTTPSubscriberError(t *testing.T) {
 
 	// Create http handler and call real method. Subscriber must return error
 	handler := new(httpHandlerAdder)
-	handler.subscription = new(fakeSubscription).WillReturnError()
+	handler.add(new(fakeSubscription).WillReturnError())
 	handler.ServeHTTP(w, req)
 
 	// Check status code
@@ -137,6 +329,113 @@ func TestHttpHandlerAdder_ServeHTTPSubscriberError(t *testing.T) {
 	}
 }
 
+func TestHttpHandlerAdder_ServeHTTPCircuitOpen(t *testing.T) {
+	// Create test request data
+	body := []byte(`{"message": {"data": "dGVzdA=="}}`)
+	path := fmt.Sprintf("/_handlers/topics/%s/subscribers/%s", "test", "testing")
+	req, _ := http.NewRequest("POST", path, bytes.NewReader(body))
+
+	// Create fakeResponseWriter to hold response data
+	w := httptest.NewRecorder()
+
+	// Create http handler and call real method. Subscriber must reject message because of open circuit
+	handler := new(httpHandlerAdder)
+	handler.add(new(fakeSubscription).WithTopic("test").WithName("testing").
+		WithReturning(pusu.ErrCircuitOpen))
+	handler.ServeHTTP(w, req)
+
+	// Check status code
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Status code is not valid. \nExcepted: 503\n Actual:%d", w.Code)
+	}
+
+	if strings.TrimSpace(w.Body.String()) != ErrorCircuitOpen {
+		t.Errorf("\nExcepted Error Message: \n%s\nActual:\n%s", ErrorCircuitOpen, w.Body.String())
+	}
+}
+
+func TestHttpHandlerAdder_ServeHTTPTooManyInFlight(t *testing.T) {
+	// Create test request data
+	body := []byte(`{"message": {"data": "dGVzdA=="}}`)
+	path := fmt.Sprintf("/_handlers/topics/%s/subscribers/%s", "test", "testing")
+	req, _ := http.NewRequest("POST", path, bytes.NewReader(body))
+
+	// Create fakeResponseWriter to hold response data
+	w := httptest.NewRecorder()
+
+	// Create http handler and call real method. Subscriber must reject message because of concurrency limit
+	handler := new(httpHandlerAdder)
+	handler.add(new(fakeSubscription).WithTopic("test").WithName("testing").
+		WithReturning(pusu.ErrTooManyInFlight))
+	handler.ServeHTTP(w, req)
+
+	// Check status code
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Status code is not valid. \nExcepted: 429\n Actual:%d", w.Code)
+	}
+
+	if strings.TrimSpace(w.Body.String()) != ErrorTooManyInFlight {
+		t.Errorf("\nExcepted Error Message: \n%s\nActual:\n%s", ErrorTooManyInFlight, w.Body.String())
+	}
+}
+
+func TestHttpHandlerAdder_ServeHTTPRateLimited(t *testing.T) {
+	// Create test request data
+	body := []byte(`{"message": {"data": "dGVzdA=="}}`)
+	path := fmt.Sprintf("/_handlers/topics/%s/subscribers/%s", "test", "testing")
+	req, _ := http.NewRequest("POST", path, bytes.NewReader(body))
+
+	// Create fakeResponseWriter to hold response data
+	w := httptest.NewRecorder()
+
+	// Create http handler and call real method. Subscriber must reject message because of rate limit
+	handler := new(httpHandlerAdder)
+	handler.add(new(fakeSubscription).WithTopic("test").WithName("testing").
+		WithReturning(pusu.ErrRateLimited))
+	handler.ServeHTTP(w, req)
+
+	// Check status code
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Status code is not valid. \nExcepted: 429\n Actual:%d", w.Code)
+	}
+
+	if strings.TrimSpace(w.Body.String()) != ErrorRateLimited {
+		t.Errorf("\nExcepted Error Message: \n%s\nActual:\n%s", ErrorRateLimited, w.Body.String())
+	}
+}
+
+func TestHttpHandlerAdder_ServeHTTPHandlerTimeout(t *testing.T) {
+	// Create test request data
+	body := []byte(`{"message": {"data": "dGVzdA=="}}`)
+	path := fmt.Sprintf("/_handlers/topics/%s/subscribers/%s", "test", "testing")
+	req, _ := http.NewRequest("POST", path, bytes.NewReader(body))
+
+	// Create fakeResponseWriter to hold response data
+	w := httptest.NewRecorder()
+
+	// Create http handler and call real method. Subscriber takes longer than its timeout.
+	subscription := new(fakeSubscription).WithTopic("test").WithName("testing")
+	subscription.On("Handle", mock.Anything).After(100 * time.Millisecond).Return(nil)
+	handler := new(httpHandlerAdder)
+	handler.add(pusu.WithTimeout(subscription, 10*time.Millisecond))
+	handler.metrics = new(pusu.Metrics)
+	handler.ServeHTTP(w, req)
+
+	// Check status code
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Status code is not valid. \nExcepted: 500\n Actual:%d", w.Code)
+	}
+
+	if strings.TrimSpace(w.Body.String()) != ErrorHandlerTimeout {
+		t.Errorf("\nExcepted Error Message: \n%s\nActual:\n%s", ErrorHandlerTimeout, w.Body.String())
+	}
+
+	// Check timeout is counted distinctly
+	if handler.metrics.Count(subscription, pusu.OutcomeTimeout) != 1 {
+		t.Errorf("Timed out message is not counted in metrics")
+	}
+}
+
 func TestHttpHandlerAdder_ServeHTTPSubscriberPathError(t *testing.T) {
 	// Create test request data with wrong url path
 	body := []byte(`{"message": {"data": "W3sib2JqZWN0X2lkIjoxLCJvYmplY3RfbmFtZSI6IkFsbG90bWVudFBsYW4iLCJjaGlsZF9vYmplY3RfbmFtZSI6bnVsbCwib2JqZWN0X2RlZmluaXRpb24iOnsiaWQiOjEsImNsYXNzIjoiQWxsb3RtZW50UGxhbiJ9LCJhY3Rpb25fbmFtZSI6InVwZGF0ZSIsImxvZ190aW1lIjoiMjAxOC0wMi0xNiAxNTozNTowOSIsImNoYW5nZV9zZXQiOnsibmFtZSI6eyJvbGQiOiJCQVIiLCJuZXciOiJ0ZXN0cyJ9fSwiY29uc3VtZXJfbmFtZSI6IkNvbXBhc3MiLCJjb25zdW1lcl9pZCI6MSwiaXBfYWRkcmVzcyI6IjEwLjQuNC4xIiwidXNlcl9pZCI6MywidXNlcm5hbWUiOiJzZXlmaSIsImNsaWVudF9uYW1lIjoiSG90ZWxzcHJvIERNQ0MifV0="}}`)
@@ -148,7 +447,7 @@ func TestHttpHandlerAdder_ServeHTTPSubscriberPathError(t *testing.T) {
 
 	// Create http handler and call real method
 	handler := new(httpHandlerAdder)
-	handler.subscription = new(fakeSubscription).WillHaveProperFields()
+	handler.add(new(fakeSubscription).WillHaveProperFields())
 	handler.ServeHTTP(w, req)
 
 	// Check status code
@@ -168,7 +467,7 @@ func TestHttpHandlerAdder_ServeHTTPSubscriberMethodTypeError(t *testing.T) {
 
 	// Create http handler and call real method
 	handler := new(httpHandlerAdder)
-	handler.subscription = new(fakeSubscription).WillHaveProperFields()
+	handler.add(new(fakeSubscription).WillHaveProperFields())
 	handler.ServeHTTP(w, req)
 
 	// Check status code
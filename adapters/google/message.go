@@ -1,8 +1,110 @@
 package google
 
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	"net/http"
+	"strings"
+)
+
+const (
+	// Metadata headers of unwrapped push requests, written if no wrapper config has write metadata set
+	messageIdHeader    = "X-Goog-Pubsub-Message-Id"
+	subscriptionHeader = "X-Goog-Pubsub-Subscription-Name"
+
+	// Metadata headers. Attributes are written to headers of their own keys.
+	metadataHeaders = "X-Goog-Pubsub-*"
+
+	// Header which marks push requests of CloudEvents in binary content mode
+	cloudEventSpecVersionHeader = "Ce-Specversion"
+
+	// Maximum size of Pub/Sub messages, envelopes add base64 overhead on top of it
+	maxPushSize = 16 << 20
+)
+
 // Google Cloud Pub/Sub message structure
 type message struct {
 	Message struct {
-		Data string `json:"data"`
+		Data       string            `json:"data"`
+		Attributes map[string]string `json:"attributes"`
 	} `json:"message"`
+
+	// Subscription which message is pushed by, in form of projects/<project id>/subscriptions/<subscription name>
+	Subscription string `json:"subscription"`
+}
+
+// Message pushed by Pub/Sub, decoded from either JSON envelope or unwrapped payload
+type pushedMessage struct {
+	data []byte
+
+	// Attributes of message, including CloudEvents context attributes with ce- prefix. Optional.
+	attributes map[string]string
+
+	// Subscription which message is pushed by. Empty if push request does not tell it.
+	subscription string
+}
+
+// Decodes push request body. Requests of CloudEvents in structured or binary content mode are decoded to messages in
+// binary content mode. Requests with metadata headers are unwrapped payloads, with attributes of their headers.
+// Otherwise body is decoded as JSON envelope, unless unwrapped payloads are expected and body is not an envelope.
+// Unwrapped payloads without metadata headers have no attributes, Pub/Sub doesn't send them unless no wrapper config
+// has write metadata set.
+func decodePush(header http.Header, body []byte, unwrapped bool) (pushedMessage, error) {
+	if strings.HasPrefix(header.Get("Content-Type"), pusu.CloudEventContentType) {
+		e, err := pusu.ParseCloudEvent(body)
+		if err != nil {
+			return pushedMessage{}, errors.New(ErrorCloudEventSyntax)
+		}
+		return pushedMessage{data: e.Data, attributes: e.Message().Attributes(), subscription: header.Get(subscriptionHeader)}, nil
+	}
+
+	if header.Get(cloudEventSpecVersionHeader) != "" {
+		m := pushedMessage{data: body, attributes: cloudEventAttributes(header), subscription: header.Get(subscriptionHeader)}
+		if _, ok := pusu.CloudEventOf(pusu.NewMessage(body).WithAttributes(m.attributes)); !ok {
+			return pushedMessage{}, errors.New(ErrorCloudEventSyntax)
+		}
+		return m, nil
+	}
+
+	if header.Get(messageIdHeader) != "" {
+		return pushedMessage{data: body, attributes: pusu.HeaderAttributes(header, metadataHeaders, "Content-Type"), subscription: header.Get(subscriptionHeader)}, nil
+	}
+
+	var m message
+	err := json.Unmarshal(body, &m)
+	if err != nil || m.Message.Data == "" {
+		if unwrapped {
+			return pushedMessage{data: body}, nil
+		}
+		return pushedMessage{}, errors.New(ErrorJsonSyntax)
+	}
+
+	// Decode base64 encoded pub/sub data
+	data, err := base64.StdEncoding.DecodeString(m.Message.Data)
+	if err != nil {
+		if unwrapped {
+			return pushedMessage{data: body}, nil
+		}
+		return pushedMessage{}, errors.New(ErrorBase64MessageSyntax)
+	}
+
+	return pushedMessage{data: data, attributes: m.Message.Attributes, subscription: m.Subscription}, nil
+}
+
+// Returns CloudEvents context attributes of binary content mode headers, in form of Pub/Sub attributes
+func cloudEventAttributes(header http.Header) map[string]string {
+	attributes := make(map[string]string)
+	for name := range header {
+		key := strings.ToLower(name)
+		if strings.HasPrefix(key, pusu.CloudEventAttributePrefix) {
+			attributes[key] = header.Get(name)
+		}
+	}
+	if contentType := header.Get("Content-Type"); contentType != "" {
+		attributes[pusu.ContentTypeAttribute] = contentType
+	}
+
+	return attributes
 }
@@ -0,0 +1,105 @@
+package google
+
+import (
+	"github.com/metglobal-compass/pusu"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestDecodePush(t *testing.T) {
+	envelope := []byte(`{"message": {"data": "aGVsbG8="}, "subscription": "projects/my-project/subscriptions/testing"}`)
+
+	// Envelopes must be decoded whether unwrapped payloads are expected or not
+	for _, unwrapped := range []bool{false, true} {
+		m, err := decodePush(http.Header{}, envelope, unwrapped)
+		assert.Nil(t, err)
+		assert.Equal(t, pushedMessage{data: []byte("hello"), subscription: "projects/my-project/subscriptions/testing"}, m)
+	}
+
+	// Payloads with metadata headers must be taken as they are
+	header := http.Header{}
+	header.Set(messageIdHeader, "1")
+	header.Set(subscriptionHeader, "projects/my-project/subscriptions/testing")
+	m, err := decodePush(header, envelope, false)
+	assert.Nil(t, err)
+	assert.Equal(t, pushedMessage{data: envelope, subscription: "projects/my-project/subscriptions/testing"}, m)
+
+	// Payloads without metadata must be taken as they are only if unwrapped payloads are expected
+	for _, body := range []string{`{"id": 1}`, `plain text`, `{"message": {"data": "not base64"}}`} {
+		m, err = decodePush(http.Header{}, []byte(body), true)
+		assert.Nil(t, err)
+		assert.Equal(t, pushedMessage{data: []byte(body)}, m)
+
+		_, err = decodePush(http.Header{}, []byte(body), false)
+		assert.Error(t, err)
+	}
+}
+
+func TestDecodePush_Attributes(t *testing.T) {
+	// Attributes of envelopes must be kept
+	envelope := []byte(`{"message": {"data": "aGVsbG8=", "attributes": {"ce-type": "created"}}}`)
+	m, err := decodePush(http.Header{}, envelope, false)
+	assert.Nil(t, err)
+	assert.Equal(t, pushedMessage{data: []byte("hello"), attributes: map[string]string{"ce-type": "created"}}, m)
+
+	// Attributes of unwrapped payloads must be taken from headers other than metadata and transport ones
+	header := http.Header{}
+	header.Set(messageIdHeader, "1")
+	header.Set("X-Goog-Pubsub-Publish-Time", "2024-01-01T00:00:00Z")
+	header.Set("Content-Type", "application/json")
+	header.Set("User-Agent", "APIs-Google")
+	header.Set("Authorization", "Bearer token")
+	header.Set("Cookie", "session=1")
+	header.Set("X-Request-Id", "1")
+	header.Set("X-Appengine-Country", "TR")
+	header.Set("event", "created")
+	header.Set("Region", "eu")
+	m, err = decodePush(header, []byte("hello"), true)
+	assert.Nil(t, err)
+	assert.Equal(t, pushedMessage{data: []byte("hello"), attributes: map[string]string{"event": "created", "region": "eu"}}, m)
+}
+
+func TestDecodePush_CloudEvents(t *testing.T) {
+	expected := map[string]string{
+		"ce-id":          "1",
+		"ce-source":      "//orders",
+		"ce-specversion": "1.0",
+		"ce-type":        "created",
+		"content-type":   "application/json",
+	}
+
+	// Events in binary content mode must be taken from headers
+	header := http.Header{}
+	header.Set("Ce-Id", "1")
+	header.Set("Ce-Source", "//orders")
+	header.Set("Ce-Specversion", "1.0")
+	header.Set("Ce-Type", "created")
+	header.Set("Content-Type", "application/json")
+	header.Set(subscriptionHeader, "projects/my-project/subscriptions/testing")
+	m, err := decodePush(header, []byte(`{"id":1}`), false)
+	assert.Nil(t, err)
+	assert.Equal(t, pushedMessage{
+		data:         []byte(`{"id":1}`),
+		attributes:   expected,
+		subscription: "projects/my-project/subscriptions/testing",
+	}, m)
+
+	// Events in structured content mode must be taken from body
+	header = http.Header{}
+	header.Set("Content-Type", pusu.CloudEventContentType)
+	body := `{"specversion": "1.0", "id": "1", "source": "//orders", "type": "created", "datacontenttype": "application/json", "data": {"id":1}}`
+	m, err = decodePush(header, []byte(body), false)
+	assert.Nil(t, err)
+	assert.Equal(t, pushedMessage{data: []byte(`{"id":1}`), attributes: expected}, m)
+
+	// Invalid events must be rejected
+	_, err = decodePush(header, []byte(`{"specversion": "1.0", "id": "1"}`), true)
+	assert.EqualError(t, err, ErrorCloudEventSyntax)
+
+	header = http.Header{}
+	header.Set("Ce-Specversion", "1.0")
+	header.Set("Ce-Id", "1")
+	_, err = decodePush(header, []byte("hello"), true)
+	assert.EqualError(t, err, ErrorCloudEventSyntax)
+}
@@ -0,0 +1,184 @@
+package google
+
+import (
+	"fmt"
+	"github.com/metglobal-compass/pusu"
+	"regexp"
+	"strings"
+)
+
+const (
+	envPlaceholder          = "{env}"
+	topicPlaceholder        = "{topic}"
+	subscriptionPlaceholder = "{subscription}"
+)
+
+var (
+	// Pub/Sub resource names start with a letter and have 3 to 255 letters, numbers or -_.~+% characters
+	resourceNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9\-_.~+%]{2,254}$`)
+
+	placeholderPattern = regexp.MustCompile(`\{[^}]*\}`)
+)
+
+// Naming policy which maps topic and subscription names of pusu subscriptions to names of Pub/Sub resources, so
+// environments sharing naming conventions (e.g. staging and production) don't hard-code prefixes in subscriptions.
+// Zero value uses names as they are.
+type Naming struct {
+	// Replaces {env} placeholder of templates. Names are scoped to env by default templates if it is set.
+	Env string
+
+	// Template of topic names with {env} and {topic} placeholders, e.g. {env}-{topic}. Defaults to {env}-{topic} if
+	// env is set, {topic} otherwise. Template may leave out {env} to share topics between environments.
+	TopicTemplate string
+
+	// Template of subscription names with {env}, {topic} and {subscription} placeholders. Defaults to
+	// {env}-{subscription} if env is set, {subscription} otherwise. Template must have {env} if env is set, so
+	// environments never consume messages of each other through a shared subscription.
+	SubscriptionTemplate string
+
+	// Added to both topic and subscription names generated by templates
+	Prefix string
+	Suffix string
+}
+
+// Returns name of topic in Pub/Sub
+func (n Naming) Topic(topic string) string {
+	return n.Prefix + strings.NewReplacer(envPlaceholder, n.Env, topicPlaceholder, topic).Replace(n.topicTemplate()) + n.Suffix
+}
+
+// Returns name of subscription of topic in Pub/Sub
+func (n Naming) Subscription(topic string, subscription string) string {
+	return n.Prefix + strings.NewReplacer(
+		envPlaceholder, n.Env,
+		topicPlaceholder, topic,
+		subscriptionPlaceholder, subscription,
+	).Replace(n.subscriptionTemplate()) + n.Suffix
+}
+
+// Returns topic of pusu which is named as given topic in Pub/Sub. Reports false if name doesn't follow naming policy.
+func (n Naming) parseTopic(name string) (string, bool) {
+	topic, ok := n.parse(n.topicTemplate(), topicPlaceholder, name, map[string]string{envPlaceholder: n.Env})
+	return topic, ok && n.Topic(topic) == name
+}
+
+// Returns name of pusu subscription of given topic which is named as given subscription in Pub/Sub. Reports false if
+// name doesn't follow naming policy.
+func (n Naming) parseSubscription(topic string, name string) (string, bool) {
+	subscription, ok := n.parse(n.subscriptionTemplate(), subscriptionPlaceholder, name,
+		map[string]string{envPlaceholder: n.Env, topicPlaceholder: topic})
+	return subscription, ok && n.Subscription(topic, subscription) == name
+}
+
+// Matches name against template whose known placeholders are replaced by their values and returns value of
+// placeholder which is looked for
+func (n Naming) parse(template string, placeholder string, name string, known map[string]string) (string, bool) {
+	pattern := regexp.QuoteMeta(n.Prefix)
+	last := 0
+	for _, bounds := range placeholderPattern.FindAllStringIndex(template, -1) {
+		pattern += regexp.QuoteMeta(template[last:bounds[0]])
+		if template[bounds[0]:bounds[1]] == placeholder {
+			pattern += "(.+)"
+		} else {
+			pattern += regexp.QuoteMeta(known[template[bounds[0]:bounds[1]]])
+		}
+		last = bounds[1]
+	}
+	pattern += regexp.QuoteMeta(template[last:]) + regexp.QuoteMeta(n.Suffix)
+
+	match := regexp.MustCompile("^" + pattern + "$").FindStringSubmatch(name)
+	if len(match) < 2 {
+		return "", false
+	}
+
+	return match[1], true
+}
+
+func (n Naming) topicTemplate() string {
+	if n.TopicTemplate != "" {
+		return n.TopicTemplate
+	}
+	if n.Env != "" {
+		return envPlaceholder + "-" + topicPlaceholder
+	}
+
+	return topicPlaceholder
+}
+
+func (n Naming) subscriptionTemplate() string {
+	if n.SubscriptionTemplate != "" {
+		return n.SubscriptionTemplate
+	}
+	if n.Env != "" {
+		return envPlaceholder + "-" + subscriptionPlaceholder
+	}
+
+	return subscriptionPlaceholder
+}
+
+// Checks that templates have only known placeholders, keep names of different topics and subscriptions apart, have
+// an environment if they refer to it and scope subscriptions to environment if it is set
+func (n Naming) Validate() error {
+	err := validateTemplate("topic naming", n.TopicTemplate, topicPlaceholder, envPlaceholder, topicPlaceholder)
+	if err != nil {
+		return err
+	}
+	err = validateTemplate("subscription naming", n.SubscriptionTemplate, subscriptionPlaceholder,
+		envPlaceholder, topicPlaceholder, subscriptionPlaceholder)
+	if err != nil {
+		return err
+	}
+
+	if n.Env == "" && (strings.Contains(n.TopicTemplate, envPlaceholder) || strings.Contains(n.SubscriptionTemplate, envPlaceholder)) {
+		return fmt.Errorf("env is required by %s placeholder of naming templates", envPlaceholder)
+	}
+	if n.Env != "" && !strings.Contains(n.subscriptionTemplate(), envPlaceholder) {
+		return fmt.Errorf("subscription naming template %q must have %s placeholder, so subscriptions of env %s "+
+			"are not shared with other environments", n.SubscriptionTemplate, envPlaceholder, n.Env)
+	}
+
+	return nil
+}
+
+// Checks that names of subscription and its topic in Pub/Sub are valid
+func (n Naming) validateSubscription(subscription pusu.Subscription) error {
+	err := validateResourceName(n.Topic(subscription.Topic()))
+	if err != nil {
+		return err
+	}
+
+	return validateResourceName(n.Subscription(subscription.Topic(), subscription.Name()))
+}
+
+// Checks name of a topic or subscription against Pub/Sub naming rules
+func validateResourceName(name string) error {
+	if !resourceNamePattern.MatchString(name) {
+		return fmt.Errorf("%q is not a valid Pub/Sub name: it must start with a letter and have 3 to 255 letters, "+
+			"numbers or -_.~+%% characters", name)
+	}
+	if strings.HasPrefix(name, "goog") {
+		return fmt.Errorf("%q is not a valid Pub/Sub name: it must not start with goog", name)
+	}
+
+	return nil
+}
+
+func validateTemplate(kind string, template string, required string, allowed ...string) error {
+	if template == "" {
+		return nil
+	}
+	if required != "" && !strings.Contains(template, required) {
+		return fmt.Errorf("%s template %q must have %s placeholder", kind, template, required)
+	}
+
+	for _, placeholder := range placeholderPattern.FindAllString(template, -1) {
+		known := false
+		for _, a := range allowed {
+			known = known || placeholder == a
+		}
+		if !known {
+			return fmt.Errorf("%s template %q has unknown placeholder %s", kind, template, placeholder)
+		}
+	}
+
+	return nil
+}
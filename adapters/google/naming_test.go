@@ -0,0 +1,74 @@
+package google
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestNaming(t *testing.T) {
+	// Zero naming must use names as they are
+	assert.Equal(t, "test", Naming{}.Topic("test"))
+	assert.Equal(t, "testing", Naming{}.Subscription("test", "testing"))
+
+	// Templates, prefix and suffix must be applied
+	naming := Naming{Env: "staging", TopicTemplate: "{env}-{topic}", SubscriptionTemplate: "{env}-{topic}-{subscription}"}
+	assert.Equal(t, "staging-test", naming.Topic("test"))
+	assert.Equal(t, "staging-test-testing", naming.Subscription("test", "testing"))
+	naming = Naming{Prefix: "app.", Suffix: ".v2"}
+	assert.Equal(t, "app.test.v2", naming.Topic("test"))
+	assert.Equal(t, "app.testing.v2", naming.Subscription("test", "testing"))
+
+	// Names must be scoped to env by default templates
+	naming = Naming{Env: "staging"}
+	assert.Equal(t, "staging-test", naming.Topic("test"))
+	assert.Equal(t, "staging-testing", naming.Subscription("test", "testing"))
+}
+
+func TestNaming_Parse(t *testing.T) {
+	// Names must be parsed back to names of pusu
+	naming := Naming{Env: "staging", TopicTemplate: "{env}-{topic}", SubscriptionTemplate: "{env}-{topic}-{subscription}", Suffix: ".v2"}
+	topic, ok := naming.parseTopic("staging-order-events.v2")
+	assert.True(t, ok)
+	assert.Equal(t, "order-events", topic)
+	subscription, ok := naming.parseSubscription("order-events", "staging-order-events-billing.v2")
+	assert.True(t, ok)
+	assert.Equal(t, "billing", subscription)
+
+	// Names which are not named by naming policy must be reported
+	_, ok = naming.parseTopic("prod-order-events.v2")
+	assert.False(t, ok)
+	_, ok = naming.parseSubscription("order-events", "staging-payments-billing.v2")
+	assert.False(t, ok)
+
+	// Zero naming must parse every name as it is
+	topic, ok = Naming{}.parseTopic("test")
+	assert.True(t, ok)
+	assert.Equal(t, "test", topic)
+}
+
+func TestNaming_Validate(t *testing.T) {
+	assert.Nil(t, Naming{}.Validate())
+	assert.Nil(t, Naming{Env: "prod", TopicTemplate: "{env}-{topic}", SubscriptionTemplate: "{subscription}-{env}"}.Validate())
+
+	// Templates must keep names apart, have known placeholders and an environment if they refer to it
+	assert.Error(t, Naming{Env: "prod", TopicTemplate: "{env}-events"}.Validate())
+	assert.Error(t, Naming{SubscriptionTemplate: "{topic}"}.Validate())
+	assert.Error(t, Naming{TopicTemplate: "{region}-{topic}"}.Validate())
+	assert.Error(t, Naming{TopicTemplate: "{env}-{topic}"}.Validate())
+
+	// Subscriptions must be scoped to env if it is set, topics may be shared
+	assert.Nil(t, Naming{Env: "prod"}.Validate())
+	assert.Nil(t, Naming{Env: "prod", TopicTemplate: "{topic}"}.Validate())
+	assert.Error(t, Naming{Env: "prod", SubscriptionTemplate: "{topic}-{subscription}"}.Validate())
+}
+
+func TestValidateResourceName(t *testing.T) {
+	for _, name := range []string{"test", "Test-1_.~+%", strings.Repeat("a", 255)} {
+		assert.Nil(t, validateResourceName(name), name)
+	}
+
+	for _, name := range []string{"", "ab", "1test", "-test", "test/1", "test name", "goog-test", strings.Repeat("a", 256)} {
+		assert.Error(t, validateResourceName(name), name)
+	}
+}
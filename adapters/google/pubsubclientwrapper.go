@@ -3,11 +3,22 @@ package google
 import (
 	"cloud.google.com/go/pubsub"
 	"context"
+	"fmt"
+	"google.golang.org/api/iterator"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Google Cloud Pub/Sub Client wrapper which implements client interface
 type pubSubClientWrapper struct {
 	client *pubsub.Client
+
+	mutex sync.Mutex
+
+	// Topics which messages are published to, keyed by name. Each topic batches messages with its own goroutines, so
+	// it is kept until wrapper is closed.
+	publishers map[string]*pubsub.Topic
 }
 
 func (p *pubSubClientWrapper) Topic(name string) *pubsub.Topic {
@@ -33,3 +44,162 @@ func (p *pubSubClientWrapper) SubscriptionExists(ctx context.Context, subscripti
 func (p *pubSubClientWrapper) CreateSubscription(ctx context.Context, name string, config pubsub.SubscriptionConfig) (*pubsub.Subscription, error) {
 	return p.client.CreateSubscription(ctx, name, config)
 }
+
+// Returns names of every topic of project
+func (p *pubSubClientWrapper) TopicNames(ctx context.Context) ([]string, error) {
+	var names []string
+	topics := p.client.Topics(ctx)
+	for {
+		topic, err := topics.Next()
+		if err == iterator.Done {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, topic.ID())
+	}
+}
+
+// Returns names of every subscription of project
+func (p *pubSubClientWrapper) SubscriptionNames(ctx context.Context) ([]string, error) {
+	var names []string
+	subscriptions := p.client.Subscriptions(ctx)
+	for {
+		subscription, err := subscriptions.Next()
+		if err == iterator.Done {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, subscription.ID())
+	}
+}
+
+func (p *pubSubClientWrapper) DescribeSubscription(ctx context.Context, name string) (SubscriptionInfo, error) {
+	config, err := p.client.Subscription(name).Config(ctx)
+	if err != nil {
+		return SubscriptionInfo{}, err
+	}
+
+	info := SubscriptionInfo{
+		Name:        name,
+		Endpoint:    config.PushConfig.Endpoint,
+		AckDeadline: config.AckDeadline,
+		Filter:      config.Filter,
+	}
+	if noWrapper, ok := config.PushConfig.Wrapper.(*pubsub.NoWrapper); ok {
+		info.Unwrapped = true
+		info.WriteMetadata = noWrapper.WriteMetadata
+	}
+	if config.Topic != nil {
+		info.Topic = config.Topic.ID()
+	}
+	if len(config.Labels) > 0 {
+		info.Labels = config.Labels
+	}
+	if config.DeadLetterPolicy != nil && config.DeadLetterPolicy.DeadLetterTopic != "" {
+		// Dead letter topic is given as resource name, e.g. projects/my-project/topics/my-topic, which is kept as is
+		// only if topic belongs to another project
+		info.DeadLetterTopic = strings.TrimPrefix(config.DeadLetterPolicy.DeadLetterTopic,
+			fmt.Sprintf(topicResourcePattern, p.client.Project(), ""))
+		info.MaxDeliveryAttempts = config.DeadLetterPolicy.MaxDeliveryAttempts
+	}
+
+	return info, nil
+}
+
+func (p *pubSubClientWrapper) UpdatePushConfig(ctx context.Context, name string, config pubsub.PushConfig, ackDeadline time.Duration) error {
+	_, err := p.client.Subscription(name).Update(ctx, pubsub.SubscriptionConfigToUpdate{
+		PushConfig:  &config,
+		AckDeadline: ackDeadline,
+	})
+	return err
+}
+
+func (p *pubSubClientWrapper) UpdateSubscription(ctx context.Context, name string, config pubsub.SubscriptionConfigToUpdate) error {
+	_, err := p.client.Subscription(name).Update(ctx, config)
+	return err
+}
+
+func (p *pubSubClientWrapper) TopicLabels(ctx context.Context, name string) (map[string]string, error) {
+	config, err := p.client.Topic(name).Config(ctx)
+	if err != nil || len(config.Labels) == 0 {
+		return nil, err
+	}
+
+	return config.Labels, nil
+}
+
+// Replaces labels of topic. Empty labels remove existing ones.
+func (p *pubSubClientWrapper) UpdateTopicLabels(ctx context.Context, name string, labels map[string]string) error {
+	if labels == nil {
+		// Nil labels leave existing ones unchanged
+		labels = map[string]string{}
+	}
+
+	_, err := p.client.Topic(name).Update(ctx, pubsub.TopicConfigToUpdate{Labels: labels})
+	return err
+}
+
+func (p *pubSubClientWrapper) DeleteTopic(ctx context.Context, name string) error {
+	p.mutex.Lock()
+	if t, ok := p.publishers[name]; ok {
+		t.Stop()
+		delete(p.publishers, name)
+	}
+	p.mutex.Unlock()
+
+	return p.client.Topic(name).Delete(ctx)
+}
+
+func (p *pubSubClientWrapper) DeleteSubscription(ctx context.Context, name string) error {
+	return p.client.Subscription(name).Delete(ctx)
+}
+
+// Publishes message and waits until it is accepted by Pub/Sub. Returns id of message.
+func (p *pubSubClientWrapper) Publish(ctx context.Context, topic string, data []byte, attributes map[string]string) (string, error) {
+	return p.publisher(topic).Publish(ctx, &pubsub.Message{Data: data, Attributes: attributes}).Get(ctx)
+}
+
+// Returns topic which messages are published to, creating it on first publish
+func (p *pubSubClientWrapper) publisher(name string) *pubsub.Topic {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	t, ok := p.publishers[name]
+	if !ok {
+		if p.publishers == nil {
+			p.publishers = make(map[string]*pubsub.Topic)
+		}
+		t = p.client.Topic(name)
+		p.publishers[name] = t
+	}
+
+	return t
+}
+
+// Sends remaining messages of topics which messages are published to, then closes client
+func (p *pubSubClientWrapper) Close() error {
+	p.mutex.Lock()
+	for name, t := range p.publishers {
+		t.Stop()
+		delete(p.publishers, name)
+	}
+	p.mutex.Unlock()
+
+	return p.client.Close()
+}
+
+// Receives messages of pull subscription one by one until context is done. Messages are acked after handling.
+func (p *pubSubClientWrapper) Receive(ctx context.Context, subscription string, handle func(m ReceivedMessage)) error {
+	s := p.client.Subscription(subscription)
+	s.ReceiveSettings.NumGoroutines = 1
+	s.ReceiveSettings.MaxOutstandingMessages = 1
+
+	return s.Receive(ctx, func(ctx context.Context, m *pubsub.Message) {
+		handle(ReceivedMessage{ID: m.ID, PublishTime: m.PublishTime, Attributes: m.Attributes, Data: m.Data})
+		m.Ack()
+	})
+}
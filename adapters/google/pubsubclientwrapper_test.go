@@ -0,0 +1,120 @@
+package google
+
+import (
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"testing"
+	"time"
+)
+
+func TestPubSubClientWrapper(t *testing.T) {
+	wrapper := &pubSubClientWrapper{client: runServer(t)}
+	ctx := context.Background()
+
+	// Create topic and push subscription
+	topic, err := wrapper.CreateTopic(ctx, "test")
+	assert.Nil(t, err)
+	_, err = wrapper.CreateSubscription(ctx, "testing", pubsub.SubscriptionConfig{
+		Topic:       topic,
+		AckDeadline: 10 * time.Second,
+		PushConfig:  pubsub.PushConfig{Endpoint: "http://old"},
+	})
+	assert.Nil(t, err)
+
+	// Topic and subscription must be listed
+	topics, err := wrapper.TopicNames(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"test"}, topics)
+	subscriptions, err := wrapper.SubscriptionNames(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"testing"}, subscriptions)
+
+	// Subscription must be described
+	info, err := wrapper.DescribeSubscription(ctx, "testing")
+	assert.Nil(t, err)
+	assert.Equal(t, SubscriptionInfo{Name: "testing", Topic: "test", Endpoint: "http://old", AckDeadline: 10 * time.Second}, info)
+
+	// Push config must be updated
+	assert.Nil(t, wrapper.UpdatePushConfig(ctx, "testing", pubsub.PushConfig{Endpoint: "http://new"}, 20*time.Second))
+	info, err = wrapper.DescribeSubscription(ctx, "testing")
+	assert.Nil(t, err)
+	assert.Equal(t, "http://new", info.Endpoint)
+	assert.Equal(t, 20*time.Second, info.AckDeadline)
+
+	// Published message must be received by pull subscription
+	_, err = wrapper.CreateSubscription(ctx, "pulling", pubsub.SubscriptionConfig{Topic: topic})
+	assert.Nil(t, err)
+	id, err := wrapper.Publish(ctx, "test", []byte("hello"), map[string]string{"k": "v"})
+	assert.Nil(t, err)
+	receiveCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	var received ReceivedMessage
+	err = wrapper.Receive(receiveCtx, "pulling", func(m ReceivedMessage) {
+		received = m
+		cancel()
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, id, received.ID)
+	assert.Equal(t, "hello", string(received.Data))
+	assert.Equal(t, map[string]string{"k": "v"}, received.Attributes)
+	assert.Nil(t, wrapper.DeleteSubscription(ctx, "pulling"))
+
+	// Subscription and topic must be deleted
+	assert.Nil(t, wrapper.DeleteSubscription(ctx, "testing"))
+	assert.Nil(t, wrapper.DeleteTopic(ctx, "test"))
+	topics, err = wrapper.TopicNames(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, topics)
+}
+
+func TestPubSubClientWrapper_Publish(t *testing.T) {
+	wrapper := &pubSubClientWrapper{client: runServer(t)}
+	ctx := context.Background()
+	_, err := wrapper.CreateTopic(ctx, "test")
+	assert.Nil(t, err)
+
+	// Messages of a topic must be published through the same topic
+	_, err = wrapper.Publish(ctx, "test", []byte("hello"), nil)
+	assert.Nil(t, err)
+	publisher := wrapper.publishers["test"]
+	_, err = wrapper.Publish(ctx, "test", []byte("world"), nil)
+	assert.Nil(t, err)
+	assert.Len(t, wrapper.publishers, 1)
+	assert.True(t, publisher == wrapper.publishers["test"])
+
+	// Topic must be stopped when it is deleted
+	assert.Nil(t, wrapper.DeleteTopic(ctx, "test"))
+	assert.Empty(t, wrapper.publishers)
+
+	// Topics must be stopped when wrapper is closed
+	_, err = wrapper.CreateTopic(ctx, "test")
+	assert.Nil(t, err)
+	_, err = wrapper.Publish(ctx, "test", []byte("hello"), nil)
+	assert.Nil(t, err)
+	assert.Nil(t, wrapper.Close())
+	assert.Empty(t, wrapper.publishers)
+}
+
+// Runs an in-memory Pub/Sub server for the duration of test and returns a client of it
+func runServer(t *testing.T) *pubsub.Client {
+	server := pstest.NewServer()
+	t.Cleanup(func() { server.Close() })
+
+	connection, err := grpc.NewClient(server.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { connection.Close() })
+
+	client, err := pubsub.NewClient(context.Background(), "my-project", option.WithGRPCConn(connection))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
@@ -0,0 +1,147 @@
+package google
+
+import (
+	"cloud.google.com/go/pubsub"
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	"strings"
+)
+
+// Default path template of push endpoints
+const DefaultEndpointPath = "/_handlers/topics/{topic}/subscribers/{subscription}"
+
+// Routing of push endpoints, shared by provisioning side (cloud adder) and serving side (http handler adder) so push
+// endpoints of subscriptions always point to paths they are served on
+type routing struct {
+	// Maps names of subscriptions to names in Pub/Sub, which push endpoints are named after
+	naming Naming
+
+	// Path template of push endpoints with optional {topic} and {subscription} placeholders. Defaults to
+	// DefaultEndpointPath.
+	path string
+
+	// Requests unwrapped payloads instead of JSON envelopes. Optional.
+	noWrapper *pubsub.NoWrapper
+}
+
+// Returns names of subscription and its topic in Pub/Sub. Names are used as they are if routing is nil.
+func (r *routing) names(subscription pusu.Subscription) (topic string, name string) {
+	if r == nil {
+		return subscription.Topic(), subscription.Name()
+	}
+
+	return r.naming.Topic(subscription.Topic()), r.naming.Subscription(subscription.Topic(), subscription.Name())
+}
+
+// Returns path which push endpoint of subscription is served on
+func (r *routing) endpointPath(subscription pusu.Subscription) string {
+	return r.pathOf(r.names(subscription))
+}
+
+// Returns path which push endpoint of subscription is served on by names of subscription and its topic in Pub/Sub
+func (r *routing) pathOf(topic string, name string) string {
+	template := DefaultEndpointPath
+	if r != nil && r.path != "" {
+		template = r.path
+	}
+
+	return strings.NewReplacer(topicPlaceholder, topic, subscriptionPlaceholder, name).Replace(template)
+}
+
+// Returns push endpoint of subscription on given host, unless subscription is served on another host set by WithHost
+func (r *routing) endpoint(host string, subscription pusu.Subscription) string {
+	if override, ok := HostOf(subscription); ok {
+		host = override
+	}
+
+	return host + r.endpointPath(subscription)
+}
+
+// Returns push config of subscription on given host
+func (r *routing) pushConfig(host string, subscription pusu.Subscription) pubsub.PushConfig {
+	return r.pushConfigOf(r.endpoint(host, subscription))
+}
+
+// Returns push config of endpoint served by pusu subscriber
+func (r *routing) pushConfigOf(endpoint string) pubsub.PushConfig {
+	config := pubsub.PushConfig{Endpoint: endpoint}
+	if r != nil && r.noWrapper != nil {
+		config.Wrapper = r.noWrapper
+	}
+
+	return config
+}
+
+// Returns push config of endpoint served by pusu subscriber for an existing subscription, which keeps its payload
+// wrapper unless unwrapped payloads are requested
+func (r *routing) pushConfigKeeping(endpoint string, info SubscriptionInfo) pubsub.PushConfig {
+	config := r.pushConfigOf(endpoint)
+	if config.Wrapper == nil && info.Unwrapped {
+		config.Wrapper = &pubsub.NoWrapper{WriteMetadata: info.WriteMetadata}
+	}
+
+	return config
+}
+
+// Reports whether push endpoint or payload wrapper of subscription differs from given push config
+func pushConfigDiffers(info SubscriptionInfo, config pubsub.PushConfig) bool {
+	noWrapper, unwrapped := config.Wrapper.(*pubsub.NoWrapper)
+	if info.Endpoint != config.Endpoint || info.Unwrapped != unwrapped {
+		return true
+	}
+
+	return unwrapped && info.WriteMetadata != noWrapper.WriteMetadata
+}
+
+// Reports whether unwrapped payloads are requested
+func (r *routing) unwrapped() bool {
+	return r != nil && r.noWrapper != nil
+}
+
+// Reports whether metadata headers of unwrapped payloads are requested
+func (r *routing) writesMetadata() bool {
+	return r.unwrapped() && r.noWrapper.WriteMetadata
+}
+
+// Checks that path template is absolute and has known placeholders. Template may leave out {subscription}, then
+// subscriptions sharing a path are told apart by subscription of push envelope.
+func validateEndpointPath(template string) error {
+	if !strings.HasPrefix(template, "/") {
+		return errors.New("endpoint path template must start with /")
+	}
+
+	return validateTemplate("endpoint path", template, "", topicPlaceholder, subscriptionPlaceholder)
+}
+
+// Subscription wrapper which is served on another host than subscriber provisioning it
+type hostedSubscription struct {
+	pusu.Subscription
+	host string
+}
+
+// Wraps subscription so that its push endpoint points to given host instead of host of adapter, e.g. when it is served
+// by another service than the one provisioning it. Serving side must use the same endpoint path template.
+func WithHost(subscription pusu.Subscription, host string) pusu.Subscription {
+	h := new(hostedSubscription)
+	h.Subscription = subscription
+	h.host = host
+
+	return h
+}
+
+// Returns wrapped subscription
+func (h *hostedSubscription) Unwrap() pusu.Subscription {
+	return h.Subscription
+}
+
+// Returns host set on subscription with WithHost, looking through subscription wrappers.
+// Reports false if subscription has no host of its own.
+func HostOf(subscription pusu.Subscription) (string, bool) {
+	for ; subscription != nil; subscription = pusu.Unwrap(subscription) {
+		if hosted, ok := subscription.(*hostedSubscription); ok {
+			return hosted.host, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,36 @@
+package google
+
+import (
+	"github.com/metglobal-compass/pusu"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRouting_Endpoint(t *testing.T) {
+	subscription := new(fakeSubscription).WillHaveProperFields()
+
+	// Default path must be used without routing
+	var r *routing
+	assert.Equal(t, "http://localhost/_handlers/topics/test/subscribers/testing", r.endpoint("http://localhost", subscription))
+
+	// Path template must be filled with names in Pub/Sub
+	r = &routing{naming: Naming{Prefix: "dev-"}, path: "/events/{topic}/{subscription}"}
+	assert.Equal(t, "/events/dev-test/dev-testing", r.endpointPath(subscription))
+	assert.Equal(t, "http://localhost/events/dev-test/dev-testing", r.endpoint("http://localhost", subscription))
+
+	// Host of subscription must override given host, even through other subscription wrappers
+	hosted := pusu.WithTimeout(WithHost(subscription, "https://other"), time.Second)
+	assert.Equal(t, "https://other/events/dev-test/dev-testing", r.endpoint("http://localhost", hosted))
+}
+
+func TestHostOf(t *testing.T) {
+	subscription := new(fakeSubscription).WillHaveProperFields()
+
+	_, ok := HostOf(subscription)
+	assert.False(t, ok)
+
+	host, ok := HostOf(pusu.LimitConcurrency(WithHost(subscription, "https://other"), 1))
+	assert.True(t, ok)
+	assert.Equal(t, "https://other", host)
+}
@@ -0,0 +1,298 @@
+package google
+
+import (
+	"cloud.google.com/go/pubsub"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/metglobal-compass/pusu"
+	"sort"
+	"time"
+)
+
+const (
+	// Suffix of temporary pull subscriptions of Tail, which follows name of topic
+	tailSuffixPattern = "-tail-%d"
+
+	// Maximum length of Pub/Sub resource names
+	maxResourceNameLength = 255
+
+	// Label of temporary pull subscriptions of Tail, so they are never pruned
+	tailLabel = "pusu-tail"
+
+	// Minimum expiration of subscriptions allowed by Pub/Sub
+	tailExpiration = 24 * time.Hour
+)
+
+// Configuration of a subscription in Google Cloud Pub/Sub
+type SubscriptionInfo struct {
+	Name  string
+	Topic string
+
+	// Push endpoint of subscription. Empty for pull subscriptions.
+	Endpoint string
+
+	// Push endpoint which pusu subscriber of this subscription serves. Empty if Topology has no host.
+	ExpectedEndpoint string
+
+	// Pushes raw payloads instead of JSON envelopes, with metadata headers if write metadata is set
+	Unwrapped     bool
+	WriteMetadata bool
+
+	AckDeadline time.Duration
+
+	// Filter expression of subscription. Empty if subscription receives every message.
+	Filter string
+
+	Labels map[string]string
+
+	// Topic which undeliverable messages are forwarded to. Empty if dead lettering is disabled. Topics of other
+	// projects are given as full resource names, e.g. projects/my-project/topics/my-topic.
+	DeadLetterTopic     string
+	MaxDeliveryAttempts int
+}
+
+// Message received from a topic by Topology.Tail
+type ReceivedMessage struct {
+	ID          string
+	PublishTime time.Time
+	Attributes  map[string]string
+	Data        []byte
+}
+
+// Reports whether push endpoint of subscription differs from endpoint served by pusu subscriber
+func (s SubscriptionInfo) Outdated() bool {
+	return s.ExpectedEndpoint != "" && s.Endpoint != s.ExpectedEndpoint
+}
+
+// Topology manages topics and subscriptions of a Google Cloud Pub/Sub project. Subscriptions are created with the
+// same configuration as Adapter creates them, so topology may be provisioned ahead of deployment (e.g. by a CLI).
+//
+// Like Adapter, CreateSubscription, Publish, Tail and topology specs take names of pusu topics and subscriptions,
+// which are mapped to names in Pub/Sub by naming policy. Other methods take and return names in Pub/Sub.
+type Topology struct {
+	projectId  string
+	client     client
+	cloudAdder *cloudAdder
+
+	// Names subscriptions in Pub/Sub and routes their push endpoints, shared by cloud adder
+	routing routing
+}
+
+// Lists names of topics in alphabetical order
+func (t *Topology) Topics() ([]string, error) {
+	names, err := t.client.TopicNames(context.Background())
+	sort.Strings(names)
+	return names, err
+}
+
+// Creates topic if it does not exist
+func (t *Topology) CreateTopic(name string) error {
+	err := validateResourceName(name)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	exists, err := t.client.TopicExists(ctx, t.client.Topic(name))
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = t.client.CreateTopic(ctx, name)
+	return err
+}
+
+// Deletes topic. Its subscriptions are kept detached from topic until they are deleted.
+func (t *Topology) DeleteTopic(name string) error {
+	return t.client.DeleteTopic(context.Background(), name)
+}
+
+// Lists subscriptions in alphabetical order of their names
+func (t *Topology) Subscriptions() ([]SubscriptionInfo, error) {
+	names, err := t.client.SubscriptionNames(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	var subscriptions []SubscriptionInfo
+	for _, name := range names {
+		info, err := t.DescribeSubscription(name)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, info)
+	}
+
+	return subscriptions, nil
+}
+
+// Returns configuration of subscription together with push endpoint served by pusu subscriber
+func (t *Topology) DescribeSubscription(name string) (SubscriptionInfo, error) {
+	info, err := t.client.DescribeSubscription(context.Background(), name)
+	if err != nil {
+		return info, err
+	}
+	if t.cloudAdder.host != "" && info.Topic != "" {
+		info.ExpectedEndpoint = t.Endpoint(info.Topic, name)
+	}
+
+	return info, nil
+}
+
+// Creates topic and push subscription if they don't exist, exactly as Adapter does
+func (t *Topology) CreateSubscription(topic string, name string) error {
+	if t.cloudAdder.host == "" {
+		return errors.New("host for subscriber http handlers is required to create subscriptions")
+	}
+	subscription := &definition{topic: topic, name: name}
+	err := t.routing.naming.validateSubscription(subscription)
+	if err != nil {
+		return err
+	}
+
+	return t.cloudAdder.CreateSubscription(subscription)
+}
+
+// Points push endpoint, payload wrapper and ack deadline of an existing subscription to pusu subscriber. Payload
+// wrapper of subscription is kept unless unwrapped payloads are requested with SetNoWrapper.
+func (t *Topology) UpdateSubscription(name string) error {
+	if t.cloudAdder.host == "" {
+		return errors.New("host for subscriber http handlers is required to update subscriptions")
+	}
+
+	info, err := t.client.DescribeSubscription(context.Background(), name)
+	if err != nil {
+		return err
+	}
+	if info.Topic == "" {
+		return fmt.Errorf("topic of subscription %s is deleted", name)
+	}
+
+	return t.client.UpdatePushConfig(context.Background(), name, t.routing.pushConfigKeeping(t.Endpoint(info.Topic, name), info), ackDeadline)
+}
+
+func (t *Topology) DeleteSubscription(name string) error {
+	return t.client.DeleteSubscription(context.Background(), name)
+}
+
+// Publishes message with attributes to topic, named by naming policy. Returns id of message.
+func (t *Topology) Publish(topic string, data []byte, attributes map[string]string) (string, error) {
+	return t.client.Publish(context.Background(), t.routing.naming.Topic(topic), data, attributes)
+}
+
+// Receives messages published to topic, named by naming policy, from now on until context is done. A temporary pull
+// subscription is created for this and deleted on return. It expires in a day if it is left behind (e.g. process is
+// killed).
+func (t *Topology) Tail(ctx context.Context, topic string, handle func(m ReceivedMessage)) error {
+	topic = t.routing.naming.Topic(topic)
+	name, err := tailSubscriptionName(topic, time.Now())
+	if err != nil {
+		return err
+	}
+
+	_, err = t.client.CreateSubscription(ctx, name, pubsub.SubscriptionConfig{
+		Topic:            t.client.Topic(topic),
+		AckDeadline:      ackDeadline,
+		ExpirationPolicy: tailExpiration,
+		Labels:           map[string]string{tailLabel: "true"},
+	})
+	if err != nil {
+		return err
+	}
+	defer t.client.DeleteSubscription(context.Background(), name)
+
+	return t.client.Receive(ctx, name, handle)
+}
+
+// Releases Pub/Sub client. Topology can't be used afterwards.
+func (t *Topology) Close() error {
+	return t.client.Close()
+}
+
+// Returns name of temporary pull subscription of topic, which is named in Pub/Sub. Topic name is shortened if name
+// would be longer than Pub/Sub allows.
+func tailSubscriptionName(topic string, now time.Time) (string, error) {
+	suffix := fmt.Sprintf(tailSuffixPattern, now.UnixNano())
+	if len(topic)+len(suffix) > maxResourceNameLength {
+		topic = topic[:maxResourceNameLength-len(suffix)]
+	}
+
+	name := topic + suffix
+	return name, validateResourceName(name)
+}
+
+// Returns push endpoint served by pusu subscriber of given topic and subscription, named as they are in Pub/Sub
+func (t *Topology) Endpoint(topic string, name string) string {
+	return t.cloudAdder.host + t.routing.pathOf(topic, name)
+}
+
+// Sets naming policy of topics and subscriptions in Pub/Sub, which must be same as naming policy of subscribers
+func (t *Topology) SetNaming(naming Naming) error {
+	err := naming.Validate()
+	if err != nil {
+		return err
+	}
+	t.routing.naming = naming
+
+	return nil
+}
+
+// Requests Pub/Sub to push raw payloads instead of JSON envelopes to subscriptions created or updated afterwards,
+// which must be same as subscribers request. See Adapter.SetNoWrapper.
+func (t *Topology) SetNoWrapper(writeMetadata bool) {
+	t.routing.noWrapper = &pubsub.NoWrapper{WriteMetadata: writeMetadata}
+}
+
+// Sets path template of push endpoints, which must be same as path template of subscribers. Defaults to
+// DefaultEndpointPath.
+func (t *Topology) SetEndpointPath(template string) error {
+	err := validateEndpointPath(template)
+	if err != nil {
+		return err
+	}
+	t.routing.path = template
+
+	return nil
+}
+
+// Creates Topology of a Google Cloud project. Set PUBSUB_EMULATOR_HOST environment variable to use the emulator.
+// projectId: Google Cloud Project Id
+// host: Base host uri of subscriber http handlers. Optional for read-only and delete operations.
+func CreateTopology(projectId string, host string) (*Topology, error) {
+	if projectId == "" {
+		return nil, errors.New("projectId must not be empty")
+	}
+
+	client, err := pubsub.NewClient(context.Background(), projectId)
+	if err != nil {
+		return nil, err
+	}
+	wrapper := &pubSubClientWrapper{client: client}
+
+	topology := new(Topology)
+	topology.projectId = projectId
+	topology.client = wrapper
+	topology.cloudAdder = &cloudAdder{client: wrapper, host: host, routing: &topology.routing}
+
+	return topology, nil
+}
+
+// Subscription definition without handler, used to provision subscriptions outside of subscribers
+type definition struct {
+	topic string
+	name  string
+}
+
+func (d *definition) Topic() string {
+	return d.topic
+}
+
+func (d *definition) Name() string {
+	return d.name
+}
+
+func (d *definition) Handle(m *pusu.Message) error {
+	return errors.New("subscription definition can't handle messages")
+}
@@ -0,0 +1,338 @@
+package google
+
+import (
+	"cloud.google.com/go/pubsub"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTopology_Topics(t *testing.T) {
+	// Create fake client
+	fakeClient := new(fakeClient)
+	fakeClient.On("TopicNames", context.Background()).Return([]string{"b", "a"}, nil)
+
+	// Topics must be sorted
+	topics, err := newTestTopology(fakeClient, "").Topics()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, topics)
+}
+
+func TestTopology_CreateTopic(t *testing.T) {
+	// Create fake client. In this case, topic does not exist
+	fakeClient := new(fakeClient)
+	fakeClient.On("Topic", "test").Return(&pubsub.Topic{})
+	fakeClient.On("TopicExists", context.Background(), &pubsub.Topic{}).Return(false, nil)
+	fakeClient.On("CreateTopic", context.Background(), "test").Return(&pubsub.Topic{}, nil)
+
+	// Topic must be created
+	assert.Nil(t, newTestTopology(fakeClient, "").CreateTopic("test"))
+	fakeClient.AssertExpectations(t)
+}
+
+func TestTopology_CreateTopicExisting(t *testing.T) {
+	// Create fake client. In this case, topic already exists
+	fakeClient := new(fakeClient)
+	fakeClient.On("Topic", "test").Return(&pubsub.Topic{})
+	fakeClient.On("TopicExists", context.Background(), &pubsub.Topic{}).Return(true, nil)
+
+	// Existing topic must not be created again
+	assert.Nil(t, newTestTopology(fakeClient, "").CreateTopic("test"))
+	fakeClient.AssertNotCalled(t, "CreateTopic", mock.Anything, mock.Anything)
+}
+
+func TestTopology_Subscriptions(t *testing.T) {
+	// Create fake client with a push subscription of pusu and a pull subscription
+	fakeClient := new(fakeClient)
+	fakeClient.On("SubscriptionNames", context.Background()).Return([]string{"testing", "pulling"}, nil)
+	fakeClient.On("DescribeSubscription", context.Background(), "testing").Return(SubscriptionInfo{
+		Name: "testing", Topic: "test", Endpoint: "http://localhost/_handlers/topics/test/subscribers/testing",
+	}, nil)
+	fakeClient.On("DescribeSubscription", context.Background(), "pulling").Return(SubscriptionInfo{
+		Name: "pulling", Topic: "test",
+	}, nil)
+
+	// Subscriptions must be sorted and expected endpoints must be computed
+	subscriptions, err := newTestTopology(fakeClient, "http://localhost").Subscriptions()
+	assert.Nil(t, err)
+	assert.Len(t, subscriptions, 2)
+	assert.Equal(t, "pulling", subscriptions[0].Name)
+	assert.True(t, subscriptions[0].Outdated())
+	assert.Equal(t, "testing", subscriptions[1].Name)
+	assert.Equal(t, "http://localhost/_handlers/topics/test/subscribers/testing", subscriptions[1].ExpectedEndpoint)
+	assert.False(t, subscriptions[1].Outdated())
+}
+
+func TestTopology_DescribeSubscriptionWithoutHost(t *testing.T) {
+	// Create fake client
+	fakeClient := new(fakeClient)
+	fakeClient.On("DescribeSubscription", context.Background(), "testing").
+		Return(SubscriptionInfo{Name: "testing", Topic: "test"}, nil)
+
+	// Expected endpoint is unknown without host
+	info, err := newTestTopology(fakeClient, "").DescribeSubscription("testing")
+	assert.Nil(t, err)
+	assert.Empty(t, info.ExpectedEndpoint)
+	assert.False(t, info.Outdated())
+}
+
+func TestTopology_CreateSubscription(t *testing.T) {
+	// Create fake client. In this case, topic and subscription exist
+	fakeClient := new(fakeClient)
+	fakeClient.On("Topic", "test").Return(&pubsub.Topic{})
+	fakeClient.On("TopicExists", context.Background(), &pubsub.Topic{}).Return(true, nil)
+	fakeClient.On("Subscription", "testing").Return(&pubsub.Subscription{})
+	fakeClient.On("SubscriptionExists", context.Background(), &pubsub.Subscription{}).Return(true, nil)
+	fakeClient.On("DescribeSubscription", context.Background(), "testing").Return(SubscriptionInfo{
+		Name: "testing", Topic: "test", Endpoint: "http://localhost/_handlers/topics/test/subscribers/testing",
+	}, nil)
+
+	// Subscription must be provisioned like Adapter does
+	assert.Nil(t, newTestTopology(fakeClient, "http://localhost").CreateSubscription("test", "testing"))
+	fakeClient.AssertExpectations(t)
+}
+
+func TestTopology_CreateSubscriptionWithNaming(t *testing.T) {
+	// Create fake client. In this case, topic and subscription of env exist
+	fakeClient := new(fakeClient)
+	fakeClient.On("Topic", "staging-test").Return(&pubsub.Topic{})
+	fakeClient.On("TopicExists", context.Background(), &pubsub.Topic{}).Return(true, nil)
+	fakeClient.On("Subscription", "staging-testing").Return(&pubsub.Subscription{})
+	fakeClient.On("SubscriptionExists", context.Background(), &pubsub.Subscription{}).Return(true, nil)
+	fakeClient.On("DescribeSubscription", context.Background(), "staging-testing").Return(SubscriptionInfo{
+		Name:     "staging-testing",
+		Topic:    "staging-test",
+		Endpoint: "http://localhost/_handlers/topics/staging-test/subscribers/staging-testing",
+	}, nil)
+
+	// Subscription must be named by naming policy like Adapter does
+	topology := newTestTopology(fakeClient, "http://localhost")
+	assert.Nil(t, topology.SetNaming(Naming{Env: "staging"}))
+	assert.Nil(t, topology.CreateSubscription("test", "testing"))
+	fakeClient.AssertExpectations(t)
+
+	// Invalid naming must be rejected
+	assert.Error(t, topology.SetNaming(Naming{Env: "staging", SubscriptionTemplate: "{subscription}"}))
+}
+
+func TestTopology_CreateSubscriptionErrorWithoutHost(t *testing.T) {
+	// Push endpoint can't be computed without host
+	fakeClient := new(fakeClient)
+	assert.Error(t, newTestTopology(fakeClient, "").CreateSubscription("test", "testing"))
+	fakeClient.AssertNotCalled(t, "Topic", mock.Anything)
+}
+
+func TestTopology_UpdateSubscription(t *testing.T) {
+	// Create fake client with a subscription whose endpoint is outdated
+	fakeClient := new(fakeClient)
+	fakeClient.On("DescribeSubscription", context.Background(), "testing").
+		Return(SubscriptionInfo{Name: "testing", Topic: "test", Endpoint: "http://old"}, nil)
+	fakeClient.On("UpdatePushConfig", context.Background(), "testing",
+		pubsub.PushConfig{Endpoint: "http://localhost/_handlers/topics/test/subscribers/testing"}, 10*time.Second).Return(nil)
+
+	// Push endpoint and ack deadline must be updated
+	assert.Nil(t, newTestTopology(fakeClient, "http://localhost").UpdateSubscription("testing"))
+	fakeClient.AssertExpectations(t)
+}
+
+func TestTopology_UpdateSubscriptionWithNoWrapper(t *testing.T) {
+	// Create fake client with a subscription whose endpoint is outdated
+	fakeClient := new(fakeClient)
+	fakeClient.On("DescribeSubscription", context.Background(), "testing").
+		Return(SubscriptionInfo{Name: "testing", Topic: "test", Endpoint: "http://old", Unwrapped: true}, nil)
+	fakeClient.On("UpdatePushConfig", context.Background(), "testing", pubsub.PushConfig{
+		Endpoint: "http://localhost/_handlers/topics/test/subscribers/testing",
+		Wrapper:  &pubsub.NoWrapper{WriteMetadata: true},
+	}, 10*time.Second).Return(nil)
+
+	// Unwrapped payloads must be kept requested
+	topology := newTestTopology(fakeClient, "http://localhost")
+	topology.SetNoWrapper(true)
+	assert.Nil(t, topology.UpdateSubscription("testing"))
+	fakeClient.AssertExpectations(t)
+}
+
+func TestTopology_UpdateSubscriptionKeepsWrapper(t *testing.T) {
+	// Create fake client with an unwrapped subscription whose endpoint is outdated
+	fakeClient := new(fakeClient)
+	fakeClient.On("DescribeSubscription", context.Background(), "testing").Return(SubscriptionInfo{
+		Name: "testing", Topic: "test", Endpoint: "http://old", Unwrapped: true, WriteMetadata: true,
+	}, nil)
+	fakeClient.On("UpdatePushConfig", context.Background(), "testing", pubsub.PushConfig{
+		Endpoint: "http://localhost/_handlers/topics/test/subscribers/testing",
+		Wrapper:  &pubsub.NoWrapper{WriteMetadata: true},
+	}, 10*time.Second).Return(nil)
+
+	// Payload wrapper of subscription must be kept when topology requests none
+	assert.Nil(t, newTestTopology(fakeClient, "http://localhost").UpdateSubscription("testing"))
+	fakeClient.AssertExpectations(t)
+}
+
+func TestTopology_UpdateSubscriptionWithNaming(t *testing.T) {
+	// Create fake client with a subscription of env whose endpoint is outdated
+	fakeClient := new(fakeClient)
+	fakeClient.On("DescribeSubscription", context.Background(), "staging-testing").
+		Return(SubscriptionInfo{Name: "staging-testing", Topic: "staging-test", Endpoint: "http://old"}, nil)
+	fakeClient.On("UpdatePushConfig", context.Background(), "staging-testing",
+		pubsub.PushConfig{Endpoint: "http://localhost/_handlers/topics/staging-test/subscribers/staging-testing"}, 10*time.Second).Return(nil)
+
+	// Push endpoint must be named after names in Pub/Sub without naming them again
+	topology := newTestTopology(fakeClient, "http://localhost")
+	assert.Nil(t, topology.SetNaming(Naming{Env: "staging"}))
+	assert.Nil(t, topology.UpdateSubscription("staging-testing"))
+	fakeClient.AssertExpectations(t)
+}
+
+func TestTopology_UpdateSubscriptionErrorOnDeletedTopic(t *testing.T) {
+	// Create fake client with a subscription whose topic is deleted
+	fakeClient := new(fakeClient)
+	fakeClient.On("DescribeSubscription", context.Background(), "testing").
+		Return(SubscriptionInfo{Name: "testing"}, nil)
+
+	// Got an error without update
+	assert.Error(t, newTestTopology(fakeClient, "http://localhost").UpdateSubscription("testing"))
+	fakeClient.AssertNotCalled(t, "UpdatePushConfig", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTopology_Delete(t *testing.T) {
+	// Create fake client
+	fakeClient := new(fakeClient)
+	fakeClient.On("DeleteTopic", context.Background(), "test").Return(nil)
+	fakeClient.On("DeleteSubscription", context.Background(), "testing").Return(errors.New("error"))
+
+	// Errors of client must be returned
+	topology := newTestTopology(fakeClient, "")
+	assert.Nil(t, topology.DeleteTopic("test"))
+	assert.Error(t, topology.DeleteSubscription("testing"))
+}
+
+func TestTopology_CreateTopology(t *testing.T) {
+	withoutCredentials(t)
+
+	// Call real method and check each interfaces have proper types
+	topology, err := CreateTopology("my-project", "http://localhost")
+	assert.Nil(t, err)
+	_, clientProper := topology.client.(*pubSubClientWrapper)
+	assert.True(t, clientProper, "Pub/Sub client is not proper")
+	assert.Equal(t, "http://localhost", topology.cloudAdder.host)
+
+	// Project is required
+	_, err = CreateTopology("", "")
+	assert.Error(t, err)
+}
+
+func TestTopology_SetEndpointPath(t *testing.T) {
+	// Path template must be set without losing naming policy and unwrapped payloads
+	topology := newTestTopology(new(fakeClient), "http://localhost")
+	assert.Nil(t, topology.SetNaming(Naming{Env: "staging"}))
+	topology.SetNoWrapper(false)
+	assert.Nil(t, topology.SetEndpointPath("/events/{topic}/{subscription}"))
+	subscription := &definition{topic: "test", name: "testing"}
+	assert.Equal(t, pubsub.PushConfig{
+		Endpoint: "http://localhost/events/staging-test/staging-testing",
+		Wrapper:  &pubsub.NoWrapper{},
+	}, topology.cloudAdder.routing.pushConfig(topology.cloudAdder.host, subscription))
+
+	// Invalid templates must be rejected
+	assert.Error(t, topology.SetEndpointPath("events/{subscription}"))
+	assert.Equal(t, "/events/{topic}/{subscription}", topology.routing.path)
+}
+
+// Creates a topology with given fake client and host
+func newTestTopology(c client, host string) *Topology {
+	topology := &Topology{projectId: "my-project", client: c}
+	topology.cloudAdder = &cloudAdder{client: c, host: host, routing: &topology.routing}
+
+	return topology
+}
+
+func TestTopology_Publish(t *testing.T) {
+	// Create fake client
+	fakeClient := new(fakeClient)
+	fakeClient.On("Publish", context.Background(), "test", []byte("hello"), map[string]string{"k": "v"}).Return("1", nil)
+
+	// Id of published message must be returned
+	id, err := newTestTopology(fakeClient, "").Publish("test", []byte("hello"), map[string]string{"k": "v"})
+	assert.Nil(t, err)
+	assert.Equal(t, "1", id)
+
+	// Message must be published to topic named by naming policy
+	fakeClient.On("Publish", context.Background(), "staging-test", []byte("hello"), map[string]string(nil)).Return("2", nil)
+	topology := newTestTopology(fakeClient, "")
+	assert.Nil(t, topology.SetNaming(Naming{Env: "staging"}))
+	id, err = topology.Publish("test", []byte("hello"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "2", id)
+}
+
+func TestTopology_Tail(t *testing.T) {
+	// Create fake client which delivers a message to temporary subscription
+	fakeClient := new(fakeClient)
+	fakeClient.On("Topic", "test").Return(&pubsub.Topic{})
+	fakeClient.On("CreateSubscription", mock.Anything, mock.MatchedBy(func(name string) bool {
+		return strings.HasPrefix(name, "test-tail-")
+	}), mock.MatchedBy(func(config pubsub.SubscriptionConfig) bool {
+		return config.PushConfig.Endpoint == "" && config.ExpirationPolicy == tailExpiration && config.Labels[tailLabel] != ""
+	})).Return(&pubsub.Subscription{}, nil)
+	fakeClient.On("Receive", mock.Anything, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(2).(func(m ReceivedMessage))(ReceivedMessage{ID: "1", Data: []byte("hello")})
+	})
+	fakeClient.On("DeleteSubscription", mock.Anything, mock.Anything).Return(nil)
+
+	// Message must be received and temporary subscription must be deleted
+	var received []ReceivedMessage
+	err := newTestTopology(fakeClient, "").Tail(context.Background(), "test", func(m ReceivedMessage) {
+		received = append(received, m)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []ReceivedMessage{{ID: "1", Data: []byte("hello")}}, received)
+	name := fakeClient.Calls[1].Arguments.String(1)
+	fakeClient.AssertCalled(t, "Receive", mock.Anything, name, mock.Anything)
+	fakeClient.AssertCalled(t, "DeleteSubscription", mock.Anything, name)
+}
+
+func TestTailSubscriptionName(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	// Name must follow topic
+	name, err := tailSubscriptionName("test", now)
+	assert.Nil(t, err)
+	assert.Equal(t, "test-tail-1700000000000000000", name)
+
+	// Long topic names must be shortened to the length Pub/Sub allows
+	long := strings.Repeat("a", maxResourceNameLength)
+	name, err = tailSubscriptionName(long, now)
+	assert.Nil(t, err)
+	assert.Len(t, name, maxResourceNameLength)
+	assert.True(t, strings.HasSuffix(name, "-tail-1700000000000000000"))
+
+	// Invalid topic names must not be accepted
+	_, err = tailSubscriptionName("1test", now)
+	assert.Error(t, err)
+}
+
+func TestTopology_TailErrorOnInvalidTopic(t *testing.T) {
+	// Got an error without creating temporary subscription
+	fakeClient := new(fakeClient)
+	err := newTestTopology(fakeClient, "").Tail(context.Background(), "1test", func(m ReceivedMessage) {})
+	assert.Error(t, err)
+	fakeClient.AssertNotCalled(t, "CreateSubscription", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTopology_TailErrorOnSubscription(t *testing.T) {
+	// Create fake client which fails while creating temporary subscription
+	fakeClient := new(fakeClient)
+	fakeClient.On("Topic", "test").Return(&pubsub.Topic{})
+	fakeClient.On("CreateSubscription", mock.Anything, mock.Anything, mock.Anything).
+		Return(&pubsub.Subscription{}, errors.New("error"))
+
+	// Got an error without receiving
+	err := newTestTopology(fakeClient, "").Tail(context.Background(), "test", func(m ReceivedMessage) {})
+	assert.Error(t, err)
+	fakeClient.AssertNotCalled(t, "Receive", mock.Anything, mock.Anything, mock.Anything)
+	fakeClient.AssertNotCalled(t, "DeleteSubscription", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,656 @@
+package google
+
+import (
+	"cloud.google.com/go/pubsub"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/metglobal-compass/pusu"
+	"reflect"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// Resource name of dead letter topics
+	topicResourcePattern = "projects/%s/topics/%s"
+
+	// Prefix of resource names
+	projectsPrefix = "projects/"
+
+	// Delivery attempts before dead lettering if spec does not set it, same as Pub/Sub default
+	maxDeliveryAttempts = 5
+)
+
+// Full resource names of topics, e.g. projects/my-project/topics/my-topic
+var topicResourceNamePattern = regexp.MustCompile(`^projects/[^/]+/topics/([^/]+)$`)
+
+// Declarative topology of a Google Cloud Pub/Sub project. It is read from YAML or JSON files with ParseTopologySpec,
+// reconciled against the project with Topology.Apply and generated from an existing project with Topology.Export.
+type TopologySpec struct {
+	Topics []TopicSpec `json:"topics"`
+}
+
+type TopicSpec struct {
+	Name          string             `json:"name"`
+	Labels        map[string]string  `json:"labels,omitempty"`
+	Subscriptions []SubscriptionSpec `json:"subscriptions,omitempty"`
+}
+
+type SubscriptionSpec struct {
+	Name string `json:"name"`
+
+	// Push endpoint of subscription. Endpoint served by pusu subscriber is used if it is empty.
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Creates a pull subscription instead of a push subscription
+	Pull bool `json:"pull,omitempty"`
+
+	// Ack deadline of pusu subscribers is used if it is zero
+	AckDeadline Duration `json:"ack_deadline,omitempty"`
+
+	DeadLetter *DeadLetterSpec `json:"dead_letter,omitempty"`
+
+	// Filter expression of subscription. It can't be changed after subscription is created.
+	Filter string `json:"filter,omitempty"`
+
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Dead lettering of a subscription. Pub/Sub service account must be allowed to publish to dead letter topic and to
+// subscribe to subscription, otherwise messages are not forwarded.
+type DeadLetterSpec struct {
+	// Topic which undeliverable messages are forwarded to. It is created if it is not declared in spec. Topics of
+	// other projects are given as full resource names, e.g. projects/my-project/topics/my-topic, and must exist.
+	Topic string `json:"topic"`
+
+	// Between 5 and 100, 5 if it is zero
+	MaxDeliveryAttempts int `json:"max_delivery_attempts,omitempty"`
+}
+
+// Duration which is written as text like "10s" in topology files
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	err := json.Unmarshal(data, &text)
+	if err != nil {
+		return fmt.Errorf("duration must be a string like \"10s\": %s", data)
+	}
+
+	duration, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+
+	return nil
+}
+
+// Parses and validates a topology spec in YAML or JSON format. Unknown fields are rejected.
+func ParseTopologySpec(data []byte) (TopologySpec, error) {
+	var spec TopologySpec
+	err := yaml.UnmarshalStrict(data, &spec)
+	if err != nil {
+		return spec, err
+	}
+
+	return spec, spec.Validate()
+}
+
+// Encodes topology spec in given format, yaml or json
+func EncodeTopologySpec(spec TopologySpec, format string) ([]byte, error) {
+	switch format {
+	case "yaml":
+		return yaml.Marshal(spec)
+	case "json":
+		data, err := json.MarshalIndent(spec, "", "  ")
+		return append(data, '\n'), err
+	}
+
+	return nil, fmt.Errorf("unknown topology format %q", format)
+}
+
+// Checks that names are given and unique, and settings are within limits of Pub/Sub
+func (s TopologySpec) Validate() error {
+	topics := make(map[string]bool)
+	subscriptions := make(map[string]bool)
+	for _, topic := range s.Topics {
+		if topic.Name == "" {
+			return errors.New("topic name must not be empty")
+		}
+		err := validateResourceName(topic.Name)
+		if err != nil {
+			return err
+		}
+		if topics[topic.Name] {
+			return fmt.Errorf("topic %s is declared more than once", topic.Name)
+		}
+		topics[topic.Name] = true
+
+		for _, subscription := range topic.Subscriptions {
+			if subscription.Name == "" {
+				return fmt.Errorf("name of a subscription of topic %s is empty", topic.Name)
+			}
+			err := validateResourceName(subscription.Name)
+			if err != nil {
+				return err
+			}
+			if subscriptions[subscription.Name] {
+				return fmt.Errorf("subscription %s is declared more than once", subscription.Name)
+			}
+			subscriptions[subscription.Name] = true
+
+			err = subscription.validate()
+			if err != nil {
+				return fmt.Errorf("subscription %s: %w", subscription.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s SubscriptionSpec) validate() error {
+	if s.Pull && s.Endpoint != "" {
+		return errors.New("pull subscription must not have an endpoint")
+	}
+	if s.AckDeadline != 0 && (s.AckDeadline < Duration(10*time.Second) || s.AckDeadline > Duration(600*time.Second)) {
+		return errors.New("ack deadline must be between 10s and 10m")
+	}
+	_, err := pusu.ParseFilter(s.Filter)
+	if err != nil {
+		return err
+	}
+	if s.DeadLetter == nil {
+		return nil
+	}
+	if s.DeadLetter.Topic == "" {
+		return errors.New("dead letter topic must not be empty")
+	}
+	err = validateTopicName(s.DeadLetter.Topic)
+	if err != nil {
+		return err
+	}
+	attempts := s.DeadLetter.MaxDeliveryAttempts
+	if attempts != 0 && (attempts < 5 || attempts > 100) {
+		return errors.New("max delivery attempts must be between 5 and 100")
+	}
+
+	return nil
+}
+
+// Change to project which is needed to reconcile it against a topology spec
+type Change struct {
+	// create, update or delete
+	Action string
+
+	// topic or subscription
+	Kind string
+
+	Name string
+
+	// Changed settings of updated resources, e.g. endpoint, labels
+	Fields []string
+
+	apply func(ctx context.Context) error
+}
+
+func (c Change) String() string {
+	if len(c.Fields) == 0 {
+		return fmt.Sprintf("%s %s %s", c.Action, c.Kind, c.Name)
+	}
+
+	return fmt.Sprintf("%s %s %s: %s", c.Action, c.Kind, c.Name, strings.Join(c.Fields, ", "))
+}
+
+// Reconciles project against spec: creates missing topics and subscriptions and updates settings which differ.
+// If prune is set, topics and subscriptions which are not declared in spec are deleted. Nothing is changed if spec
+// can't be applied, e.g. filter of an existing subscription differs. Returns changes which are applied.
+func (t *Topology) Apply(spec TopologySpec, prune bool) ([]Change, error) {
+	changes, err := t.Plan(spec, prune)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	for i, change := range changes {
+		err := change.apply(ctx)
+		if err != nil {
+			return changes[:i], fmt.Errorf("%s: %w", change, err)
+		}
+	}
+
+	return changes, nil
+}
+
+// Returns changes which Apply would make, without changing anything
+func (t *Topology) Plan(spec TopologySpec, prune bool) ([]Change, error) {
+	err := spec.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	topics, err := t.client.TopicNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions, err := t.client.SubscriptionNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existingTopics := toSet(topics)
+	existingSubscriptions := toSet(subscriptions)
+
+	// Names of spec are mapped to names in Pub/Sub by naming policy
+	spec, err = t.resolve(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	// Topics are changed before their subscriptions, subscriptions are deleted before their topics
+	var topicChanges, subscriptionChanges, deletes []Change
+	declaredTopics := make(map[string]bool)
+	declaredSubscriptions := make(map[string]bool)
+	for _, topic := range spec.Topics {
+		declaredTopics[topic.Name] = true
+		change, err := t.planTopic(ctx, topic, existingTopics[topic.Name])
+		if err != nil {
+			return nil, err
+		}
+		if change != nil {
+			topicChanges = append(topicChanges, *change)
+		}
+	}
+
+	for _, topic := range spec.Topics {
+		for _, subscription := range topic.Subscriptions {
+			declaredSubscriptions[subscription.Name] = true
+			if subscription.DeadLetter != nil && !isResourceName(subscription.DeadLetter.Topic) && !declaredTopics[subscription.DeadLetter.Topic] {
+				// Undeclared dead letter topics of project are created without managing their settings
+				declaredTopics[subscription.DeadLetter.Topic] = true
+				if !existingTopics[subscription.DeadLetter.Topic] {
+					topicChanges = append(topicChanges, t.createTopic(TopicSpec{Name: subscription.DeadLetter.Topic}))
+				}
+			}
+
+			change, err := t.planSubscription(ctx, topic.Name, subscription, existingSubscriptions[subscription.Name])
+			if err != nil {
+				return nil, err
+			}
+			if change != nil {
+				subscriptionChanges = append(subscriptionChanges, *change)
+			}
+		}
+	}
+
+	if prune {
+		for _, name := range sortedKeys(existingSubscriptions) {
+			if declaredSubscriptions[name] {
+				continue
+			}
+			managed, err := t.managedSubscription(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			if managed {
+				deletes = append(deletes, t.deleteSubscription(name))
+			}
+		}
+		for _, name := range sortedKeys(existingTopics) {
+			if _, managed := t.routing.naming.parseTopic(name); managed && !declaredTopics[name] {
+				deletes = append(deletes, t.deleteTopic(name))
+			}
+		}
+	}
+
+	return append(append(topicChanges, subscriptionChanges...), deletes...), nil
+}
+
+// Reports whether subscription is named by naming policy and is not a temporary subscription of Tail, so it may be
+// pruned. Subscriptions of deleted topics are not pruned, since their names can't be told apart.
+func (t *Topology) managedSubscription(ctx context.Context, name string) (bool, error) {
+	info, err := t.client.DescribeSubscription(ctx, name)
+	if err != nil {
+		return false, err
+	}
+	if info.Labels[tailLabel] != "" {
+		return false, nil
+	}
+
+	topic, ok := t.routing.naming.parseTopic(info.Topic)
+	if !ok || info.Topic == "" {
+		return false, nil
+	}
+	_, ok = t.routing.naming.parseSubscription(topic, name)
+
+	return ok, nil
+}
+
+// Returns copy of spec whose topics and subscriptions are named as they are in Pub/Sub by naming policy. Endpoints
+// served by pusu subscribers are resolved beforehand, since they are named after names in Pub/Sub.
+func (t *Topology) resolve(spec TopologySpec) (TopologySpec, error) {
+	naming := t.routing.naming
+	resolved := TopologySpec{}
+	for _, topic := range spec.Topics {
+		resolvedTopic := TopicSpec{Name: naming.Topic(topic.Name), Labels: topic.Labels}
+		for _, subscription := range topic.Subscriptions {
+			err := naming.validateSubscription(&definition{topic: topic.Name, name: subscription.Name})
+			if err != nil {
+				return resolved, err
+			}
+
+			subscription.Name = naming.Subscription(topic.Name, subscription.Name)
+			if subscription.DeadLetter != nil && !isResourceName(subscription.DeadLetter.Topic) {
+				deadLetter := *subscription.DeadLetter
+				deadLetter.Topic = naming.Topic(deadLetter.Topic)
+				err = validateResourceName(deadLetter.Topic)
+				if err != nil {
+					return resolved, err
+				}
+				subscription.DeadLetter = &deadLetter
+			}
+			resolvedTopic.Subscriptions = append(resolvedTopic.Subscriptions, subscription)
+		}
+
+		err := validateResourceName(resolvedTopic.Name)
+		if err != nil {
+			return resolved, err
+		}
+		resolved.Topics = append(resolved.Topics, resolvedTopic)
+	}
+
+	return resolved, nil
+}
+
+// Returns change which creates topic or updates its labels, nil if topic is up to date
+func (t *Topology) planTopic(ctx context.Context, topic TopicSpec, exists bool) (*Change, error) {
+	if !exists {
+		change := t.createTopic(topic)
+		return &change, nil
+	}
+
+	labels, err := t.client.TopicLabels(ctx, topic.Name)
+	if err != nil {
+		return nil, err
+	}
+	if equalLabels(labels, topic.Labels) {
+		return nil, nil
+	}
+
+	return &Change{Action: "update", Kind: "topic", Name: topic.Name, Fields: []string{"labels"},
+		apply: func(ctx context.Context) error {
+			return t.client.UpdateTopicLabels(ctx, topic.Name, topic.Labels)
+		},
+	}, nil
+}
+
+// Returns change which creates subscription or updates its settings, nil if subscription is up to date
+func (t *Topology) planSubscription(ctx context.Context, topic string, subscription SubscriptionSpec, exists bool) (*Change, error) {
+	endpoint, err := t.endpointOf(topic, subscription)
+	if err != nil {
+		return nil, err
+	}
+
+	// Endpoints served by pusu subscribers get payload wrapper of subscribers
+	served := !subscription.Pull && subscription.Endpoint == ""
+	pushConfig := pubsub.PushConfig{Endpoint: endpoint}
+	if served {
+		pushConfig = t.routing.pushConfigOf(endpoint)
+	}
+	deadline := time.Duration(subscription.AckDeadline)
+	if deadline == 0 {
+		deadline = ackDeadline
+	}
+	deadLetter := t.deadLetterPolicyOf(subscription)
+
+	if !exists {
+		return &Change{Action: "create", Kind: "subscription", Name: subscription.Name,
+			apply: func(ctx context.Context) error {
+				_, err := t.client.CreateSubscription(ctx, subscription.Name, pubsub.SubscriptionConfig{
+					Topic:            t.client.Topic(topic),
+					AckDeadline:      deadline,
+					PushConfig:       pushConfig,
+					DeadLetterPolicy: deadLetter,
+					Filter:           subscription.Filter,
+					Labels:           subscription.Labels,
+				})
+				return err
+			},
+		}, nil
+	}
+
+	info, err := t.client.DescribeSubscription(ctx, subscription.Name)
+	if err != nil {
+		return nil, err
+	}
+	if info.Topic != topic {
+		return nil, fmt.Errorf("subscription %s belongs to topic %q, it can't be moved to topic %s", subscription.Name, info.Topic, topic)
+	}
+	if info.Filter != subscription.Filter {
+		return nil, fmt.Errorf("filter of subscription %s can't be changed, delete subscription to recreate it", subscription.Name)
+	}
+
+	var fields []string
+	var update pubsub.SubscriptionConfigToUpdate
+	if info.Endpoint != endpoint {
+		fields = append(fields, "endpoint")
+		update.PushConfig = &pushConfig
+	}
+	if served && (info.Unwrapped != t.routing.unwrapped() || info.WriteMetadata != t.routing.writesMetadata()) {
+		fields = append(fields, "wrapper")
+		update.PushConfig = &pushConfig
+	}
+	if info.AckDeadline != deadline {
+		fields = append(fields, "ack_deadline")
+		update.AckDeadline = deadline
+	}
+	currentDeadLetter := t.deadLetterPolicyOf(SubscriptionSpec{DeadLetter: deadLetterSpecOf(info)})
+	if !reflect.DeepEqual(currentDeadLetter, deadLetter) {
+		fields = append(fields, "dead_letter")
+		// Zero policy disables dead lettering
+		update.DeadLetterPolicy = &pubsub.DeadLetterPolicy{}
+		if deadLetter != nil {
+			update.DeadLetterPolicy = deadLetter
+		}
+	}
+	if !equalLabels(info.Labels, subscription.Labels) {
+		fields = append(fields, "labels")
+		// Empty labels remove existing ones, nil labels leave them unchanged
+		update.Labels = map[string]string{}
+		if subscription.Labels != nil {
+			update.Labels = subscription.Labels
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	return &Change{Action: "update", Kind: "subscription", Name: subscription.Name, Fields: fields,
+		apply: func(ctx context.Context) error {
+			return t.client.UpdateSubscription(ctx, subscription.Name, update)
+		},
+	}, nil
+}
+
+// Returns push endpoint of subscription, empty for pull subscriptions
+func (t *Topology) endpointOf(topic string, subscription SubscriptionSpec) (string, error) {
+	if subscription.Pull || subscription.Endpoint != "" {
+		return subscription.Endpoint, nil
+	}
+	if t.cloudAdder.host == "" {
+		return "", fmt.Errorf("host for subscriber http handlers is required for subscription %s without endpoint", subscription.Name)
+	}
+
+	return t.Endpoint(topic, subscription.Name), nil
+}
+
+func (t *Topology) deadLetterPolicyOf(subscription SubscriptionSpec) *pubsub.DeadLetterPolicy {
+	if subscription.DeadLetter == nil {
+		return nil
+	}
+
+	attempts := subscription.DeadLetter.MaxDeliveryAttempts
+	if attempts == 0 {
+		attempts = maxDeliveryAttempts
+	}
+
+	topic := subscription.DeadLetter.Topic
+	if !isResourceName(topic) {
+		topic = fmt.Sprintf(topicResourcePattern, t.projectId, topic)
+	}
+
+	return &pubsub.DeadLetterPolicy{
+		DeadLetterTopic:     topic,
+		MaxDeliveryAttempts: attempts,
+	}
+}
+
+func (t *Topology) createTopic(topic TopicSpec) Change {
+	return Change{Action: "create", Kind: "topic", Name: topic.Name, apply: func(ctx context.Context) error {
+		_, err := t.client.CreateTopic(ctx, topic.Name)
+		if err != nil || len(topic.Labels) == 0 {
+			return err
+		}
+
+		return t.client.UpdateTopicLabels(ctx, topic.Name, topic.Labels)
+	}}
+}
+
+func (t *Topology) deleteTopic(name string) Change {
+	return Change{Action: "delete", Kind: "topic", Name: name, apply: func(ctx context.Context) error {
+		return t.client.DeleteTopic(ctx, name)
+	}}
+}
+
+func (t *Topology) deleteSubscription(name string) Change {
+	return Change{Action: "delete", Kind: "subscription", Name: name, apply: func(ctx context.Context) error {
+		return t.client.DeleteSubscription(ctx, name)
+	}}
+}
+
+// Generates topology spec of project with names of pusu topics and subscriptions. Topics and subscriptions which
+// are not named by naming policy, subscriptions of deleted topics and temporary subscriptions of Tail are left out. Endpoints which are served by
+// pusu subscribers are left out too, so spec can be applied to projects with other hosts or environments.
+func (t *Topology) Export() (TopologySpec, error) {
+	var spec TopologySpec
+	topics, err := t.Topics()
+	if err != nil {
+		return spec, err
+	}
+	subscriptions, err := t.Subscriptions()
+	if err != nil {
+		return spec, err
+	}
+
+	for _, name := range topics {
+		labels, err := t.client.TopicLabels(context.Background(), name)
+		if err != nil {
+			return spec, err
+		}
+
+		topicName, ok := t.routing.naming.parseTopic(name)
+		if !ok {
+			continue
+		}
+
+		topic := TopicSpec{Name: topicName, Labels: labels}
+		for _, info := range subscriptions {
+			if info.Topic != name || info.Labels[tailLabel] != "" {
+				continue
+			}
+			subscriptionName, ok := t.routing.naming.parseSubscription(topicName, info.Name)
+			if !ok {
+				continue
+			}
+
+			subscription := SubscriptionSpec{
+				Name:        subscriptionName,
+				Endpoint:    info.Endpoint,
+				AckDeadline: Duration(info.AckDeadline),
+				DeadLetter:  deadLetterSpecOf(info),
+				Filter:      info.Filter,
+				Labels:      info.Labels,
+			}
+			if subscription.DeadLetter != nil {
+				// Dead letter topics which are named by naming policy are exported by their names of pusu
+				if deadLetterTopic, ok := t.routing.naming.parseTopic(subscription.DeadLetter.Topic); ok {
+					subscription.DeadLetter.Topic = deadLetterTopic
+				}
+			}
+			if info.Endpoint == "" {
+				subscription.Pull = true
+			} else if info.Endpoint == info.ExpectedEndpoint {
+				subscription.Endpoint = ""
+			}
+			topic.Subscriptions = append(topic.Subscriptions, subscription)
+		}
+		spec.Topics = append(spec.Topics, topic)
+	}
+
+	return spec, nil
+}
+
+func deadLetterSpecOf(info SubscriptionInfo) *DeadLetterSpec {
+	if info.DeadLetterTopic == "" {
+		return nil
+	}
+
+	return &DeadLetterSpec{Topic: info.DeadLetterTopic, MaxDeliveryAttempts: info.MaxDeliveryAttempts}
+}
+
+// Reports whether topic is given as a full resource name, e.g. projects/my-project/topics/my-topic
+func isResourceName(topic string) bool {
+	return strings.HasPrefix(topic, projectsPrefix)
+}
+
+// Checks name of a topic, or its full resource name, against Pub/Sub naming rules
+func validateTopicName(topic string) error {
+	if !isResourceName(topic) {
+		return validateResourceName(topic)
+	}
+
+	match := topicResourceNamePattern.FindStringSubmatch(topic)
+	if match == nil {
+		return fmt.Errorf("%q is not a valid resource name of a topic: it must be in projects/<project>/topics/<topic> form", topic)
+	}
+
+	return validateResourceName(match[1])
+}
+
+// Reports whether labels are same, treating nil and empty labels as same
+func equalLabels(a map[string]string, b map[string]string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func toSet(names []string) map[string]bool {
+	set := make(map[string]bool)
+	for _, name := range names {
+		set[name] = true
+	}
+
+	return set
+}
+
+func sortedKeys(set map[string]bool) []string {
+	var keys []string
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
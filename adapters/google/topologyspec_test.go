@@ -0,0 +1,330 @@
+package google
+
+import (
+	"cloud.google.com/go/pubsub"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+const testTopologySpec = `
+topics:
+  - name: orders
+    labels:
+      team: checkout
+    subscriptions:
+      - name: archiving
+        pull: true
+      - name: auditing
+        endpoint: https://audit.example.com/orders
+      - name: invoicing
+        ack_deadline: 30s
+        dead_letter:
+          topic: orders-dead
+        filter: attributes.type = "created"
+        labels:
+          team: billing
+`
+
+func TestParseTopologySpec(t *testing.T) {
+	spec, err := ParseTopologySpec([]byte(testTopologySpec))
+	assert.Nil(t, err)
+	assert.Equal(t, TopologySpec{Topics: []TopicSpec{{
+		Name:   "orders",
+		Labels: map[string]string{"team": "checkout"},
+		Subscriptions: []SubscriptionSpec{
+			{Name: "archiving", Pull: true},
+			{Name: "auditing", Endpoint: "https://audit.example.com/orders"},
+			{
+				Name:        "invoicing",
+				AckDeadline: Duration(30 * time.Second),
+				DeadLetter:  &DeadLetterSpec{Topic: "orders-dead"},
+				Filter:      `attributes.type = "created"`,
+				Labels:      map[string]string{"team": "billing"},
+			},
+		},
+	}}}, spec)
+
+	// Spec must survive encoding in both formats
+	for _, format := range []string{"yaml", "json"} {
+		data, err := EncodeTopologySpec(spec, format)
+		assert.Nil(t, err)
+		decoded, err := ParseTopologySpec(data)
+		assert.Nil(t, err)
+		assert.Equal(t, spec, decoded, format)
+	}
+
+	// Json files must be parsed too
+	spec, err = ParseTopologySpec([]byte(`{"topics": [{"name": "orders", "subscriptions": [{"name": "archiving", "pull": true}]}]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, TopologySpec{Topics: []TopicSpec{{Name: "orders", Subscriptions: []SubscriptionSpec{{Name: "archiving", Pull: true}}}}}, spec)
+}
+
+func TestParseTopologySpecErrors(t *testing.T) {
+	specs := map[string]string{
+		"unknown field":       `{"topics": [{"name": "orders", "retention": "1d"}]}`,
+		"invalid duration":    `{"topics": [{"name": "orders", "subscriptions": [{"name": "sub", "ack_deadline": 30}]}]}`,
+		"empty topic name":    `{"topics": [{"name": ""}]}`,
+		"duplicate topic":     `{"topics": [{"name": "orders"}, {"name": "orders"}]}`,
+		"empty subscription":  `{"topics": [{"name": "orders", "subscriptions": [{"name": ""}]}]}`,
+		"duplicate subscript": `{"topics": [{"name": "sub", "subscriptions": [{"name": "sub"}]}, {"name": "second", "subscriptions": [{"name": "sub"}]}]}`,
+		"pull with endpoint":  `{"topics": [{"name": "orders", "subscriptions": [{"name": "sub", "pull": true, "endpoint": "http://x"}]}]}`,
+		"short ack deadline":  `{"topics": [{"name": "orders", "subscriptions": [{"name": "sub", "ack_deadline": "5s"}]}]}`,
+		"empty dead letter":   `{"topics": [{"name": "orders", "subscriptions": [{"name": "sub", "dead_letter": {"topic": ""}}]}]}`,
+		"invalid name":        `{"topics": [{"name": "goog-orders"}]}`,
+		"invalid filter":      `{"topics": [{"name": "orders", "subscriptions": [{"name": "sub", "filter": "attributes.type > 1"}]}]}`,
+		"few attempts":        `{"topics": [{"name": "orders", "subscriptions": [{"name": "sub", "dead_letter": {"topic": "dead", "max_delivery_attempts": 2}}]}]}`,
+	}
+
+	for name, spec := range specs {
+		_, err := ParseTopologySpec([]byte(spec))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestTopology_ApplyAndExport(t *testing.T) {
+	wrapper := &pubSubClientWrapper{client: runServer(t)}
+	topology := newTestTopology(wrapper, "http://localhost")
+	ctx := context.Background()
+
+	// Create resources which are not declared in spec
+	_, err := wrapper.CreateTopic(ctx, "legacy")
+	assert.Nil(t, err)
+	assert.Nil(t, topology.CreateSubscription("legacy", "legacying"))
+
+	// Missing resources must be created
+	spec, err := ParseTopologySpec([]byte(testTopologySpec))
+	assert.Nil(t, err)
+	changes, err := topology.Apply(spec, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"create topic orders",
+		"create topic orders-dead",
+		"create subscription archiving",
+		"create subscription auditing",
+		"create subscription invoicing",
+	}, changeStrings(changes))
+
+	info, err := topology.DescribeSubscription("invoicing")
+	assert.Nil(t, err)
+	assert.Equal(t, SubscriptionInfo{
+		Name:                "invoicing",
+		Topic:               "orders",
+		Endpoint:            "http://localhost/_handlers/topics/orders/subscribers/invoicing",
+		ExpectedEndpoint:    "http://localhost/_handlers/topics/orders/subscribers/invoicing",
+		AckDeadline:         30 * time.Second,
+		Filter:              `attributes.type = "created"`,
+		Labels:              map[string]string{"team": "billing"},
+		DeadLetterTopic:     "orders-dead",
+		MaxDeliveryAttempts: 5,
+	}, info)
+
+	// Applying same spec again must not change anything
+	changes, err = topology.Apply(spec, false)
+	assert.Nil(t, err)
+	assert.Empty(t, changes)
+
+	// Changed settings must be updated and undeclared resources must be deleted with prune
+	spec.Topics[0].Labels = nil
+	spec.Topics[0].Subscriptions[0].Pull = false
+	spec.Topics[0].Subscriptions[2].DeadLetter.MaxDeliveryAttempts = 10
+	spec.Topics[0].Subscriptions[2].Labels = map[string]string{"team": "finance"}
+	changes, err = topology.Apply(spec, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"update topic orders: labels",
+		"update subscription archiving: endpoint",
+		"update subscription invoicing: dead_letter, labels",
+		"delete subscription legacying",
+		"delete topic legacy",
+	}, changeStrings(changes))
+
+	// Project must be exported as applied spec, except dead letter topic which is not declared
+	exported, err := topology.Export()
+	assert.Nil(t, err)
+	spec.Topics[0].Subscriptions[0].AckDeadline = Duration(ackDeadline)
+	spec.Topics[0].Subscriptions[1].AckDeadline = Duration(ackDeadline)
+	assert.Equal(t, append(spec.Topics, TopicSpec{Name: "orders-dead"}), exported.Topics)
+
+	// Filters can't be changed, so nothing must be applied
+	spec.Topics[0].Labels = map[string]string{"team": "checkout"}
+	spec.Topics[0].Subscriptions[2].Filter = ""
+	changes, err = topology.Apply(spec, false)
+	assert.Error(t, err)
+	assert.Empty(t, changes)
+	labels, err := wrapper.TopicLabels(ctx, "orders")
+	assert.Nil(t, err)
+	assert.Empty(t, labels)
+}
+
+func TestTopology_ApplyAndExportWithNaming(t *testing.T) {
+	wrapper := &pubSubClientWrapper{client: runServer(t)}
+	topology := newTestTopology(wrapper, "http://localhost")
+	assert.Nil(t, topology.SetNaming(Naming{Env: "staging"}))
+	ctx := context.Background()
+
+	// Create resources of another environment
+	_, err := wrapper.CreateTopic(ctx, "prod-orders")
+	assert.Nil(t, err)
+
+	// Resources of spec must be named by naming policy
+	spec, err := ParseTopologySpec([]byte(testTopologySpec))
+	assert.Nil(t, err)
+	changes, err := topology.Apply(spec, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"create topic staging-orders",
+		"create topic staging-orders-dead",
+		"create subscription staging-archiving",
+		"create subscription staging-auditing",
+		"create subscription staging-invoicing",
+	}, changeStrings(changes))
+
+	info, err := topology.DescribeSubscription("staging-invoicing")
+	assert.Nil(t, err)
+	assert.Equal(t, "staging-orders", info.Topic)
+	assert.Equal(t, "staging-orders-dead", info.DeadLetterTopic)
+	assert.Equal(t, "http://localhost/_handlers/topics/staging-orders/subscribers/staging-invoicing", info.Endpoint)
+	assert.False(t, info.Outdated())
+
+	// Project must be exported with names of pusu, leaving resources of other environments out
+	exported, err := topology.Export()
+	assert.Nil(t, err)
+	spec.Topics[0].Subscriptions[0].AckDeadline = Duration(ackDeadline)
+	spec.Topics[0].Subscriptions[1].AckDeadline = Duration(ackDeadline)
+	spec.Topics[0].Subscriptions[2].DeadLetter.MaxDeliveryAttempts = maxDeliveryAttempts
+	assert.Equal(t, append(spec.Topics, TopicSpec{Name: "orders-dead"}), exported.Topics)
+}
+
+func TestTopology_ApplyPruneWithNaming(t *testing.T) {
+	wrapper := &pubSubClientWrapper{client: runServer(t)}
+	topology := newTestTopology(wrapper, "http://localhost")
+	assert.Nil(t, topology.SetNaming(Naming{Env: "staging"}))
+	ctx := context.Background()
+
+	// Create resources of another environment, a temporary subscription of tail and undeclared resources of env
+	for _, name := range []string{"prod-orders", "staging-orders", "staging-legacy"} {
+		_, err := wrapper.CreateTopic(ctx, name)
+		assert.Nil(t, err)
+	}
+	_, err := wrapper.CreateSubscription(ctx, "prod-archiving", pubsub.SubscriptionConfig{Topic: wrapper.Topic("prod-orders")})
+	assert.Nil(t, err)
+	_, err = wrapper.CreateSubscription(ctx, "staging-orders-tail-1", pubsub.SubscriptionConfig{
+		Topic: wrapper.Topic("staging-orders"), Labels: map[string]string{tailLabel: "true"},
+	})
+	assert.Nil(t, err)
+	_, err = wrapper.CreateSubscription(ctx, "staging-legacying", pubsub.SubscriptionConfig{Topic: wrapper.Topic("staging-legacy")})
+	assert.Nil(t, err)
+
+	// Only undeclared resources named by naming policy must be pruned
+	spec := TopologySpec{Topics: []TopicSpec{{Name: "orders", Subscriptions: []SubscriptionSpec{{Name: "archiving", Pull: true}}}}}
+	changes, err := topology.Apply(spec, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"create subscription staging-archiving",
+		"delete subscription staging-legacying",
+		"delete topic staging-legacy",
+	}, changeStrings(changes))
+
+	// Temporary subscription of tail must not be exported
+	exported, err := topology.Export()
+	assert.Nil(t, err)
+	spec.Topics[0].Subscriptions[0].AckDeadline = Duration(ackDeadline)
+	assert.Equal(t, spec.Topics, exported.Topics)
+}
+
+func TestTopology_ApplyWithNoWrapper(t *testing.T) {
+	wrapper := &pubSubClientWrapper{client: runServer(t)}
+	topology := newTestTopology(wrapper, "http://localhost")
+
+	// Subscription served by pusu must be created with wrapper of subscribers
+	topology.SetNoWrapper(true)
+	spec := TopologySpec{Topics: []TopicSpec{{Name: "orders", Subscriptions: []SubscriptionSpec{{Name: "invoicing"}}}}}
+	_, err := topology.Apply(spec, false)
+	assert.Nil(t, err)
+	info, err := topology.DescribeSubscription("invoicing")
+	assert.Nil(t, err)
+	assert.True(t, info.Unwrapped)
+	assert.True(t, info.WriteMetadata)
+
+	// Changed wrapper of subscribers must be applied
+	topology.SetNoWrapper(false)
+	changes, err := topology.Apply(spec, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"update subscription invoicing: wrapper"}, changeStrings(changes))
+	info, err = topology.DescribeSubscription("invoicing")
+	assert.Nil(t, err)
+	assert.True(t, info.Unwrapped)
+	assert.False(t, info.WriteMetadata)
+}
+
+func TestTopology_PlanDeadLetterOfAnotherProject(t *testing.T) {
+	// Create fake client with an empty project
+	fakeClient := new(fakeClient)
+	fakeClient.On("TopicNames", context.Background()).Return([]string{}, nil)
+	fakeClient.On("SubscriptionNames", context.Background()).Return([]string{}, nil)
+	fakeClient.On("Topic", "test").Return(&pubsub.Topic{})
+	fakeClient.On("CreateTopic", context.Background(), "test").Return(&pubsub.Topic{}, nil)
+	fakeClient.On("CreateSubscription", context.Background(), "testing", mock.MatchedBy(func(config pubsub.SubscriptionConfig) bool {
+		return config.DeadLetterPolicy.DeadLetterTopic == "projects/other/topics/dead"
+	})).Return(&pubsub.Subscription{}, nil)
+
+	// Dead letter topic of another project must be used as it is, without creating it
+	spec := TopologySpec{Topics: []TopicSpec{{Name: "test", Subscriptions: []SubscriptionSpec{
+		{Name: "testing", Pull: true, DeadLetter: &DeadLetterSpec{Topic: "projects/other/topics/dead"}},
+	}}}}
+	topology := newTestTopology(fakeClient, "")
+	changes, err := topology.Apply(spec, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"create topic test", "create subscription testing"}, changeStrings(changes))
+	fakeClient.AssertExpectations(t)
+
+	// Malformed resource names must be rejected
+	spec.Topics[0].Subscriptions[0].DeadLetter.Topic = "projects/other/dead"
+	assert.Error(t, spec.Validate())
+}
+
+func TestTopology_PlanErrorWithoutHost(t *testing.T) {
+	// Create fake client with an empty project
+	fakeClient := new(fakeClient)
+	fakeClient.On("TopicNames", context.Background()).Return([]string{}, nil)
+	fakeClient.On("SubscriptionNames", context.Background()).Return([]string{}, nil)
+
+	// Endpoint of push subscriptions can't be computed without host
+	spec := TopologySpec{Topics: []TopicSpec{{Name: "test", Subscriptions: []SubscriptionSpec{{Name: "testing"}}}}}
+	_, err := newTestTopology(fakeClient, "").Plan(spec, false)
+	assert.Error(t, err)
+
+	// Pull subscriptions don't need host
+	spec.Topics[0].Subscriptions[0].Pull = true
+	changes, err := newTestTopology(fakeClient, "").Plan(spec, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"create topic test", "create subscription testing"}, changeStrings(changes))
+}
+
+func TestTopology_ApplyErrorOnChange(t *testing.T) {
+	// Create fake client which fails to create topic
+	fakeClient := new(fakeClient)
+	fakeClient.On("TopicNames", context.Background()).Return([]string{}, nil)
+	fakeClient.On("SubscriptionNames", context.Background()).Return([]string{}, nil)
+	fakeClient.On("CreateTopic", context.Background(), "test").Return((*pubsub.Topic)(nil), errors.New("permission denied"))
+
+	// Failed change must be reported and following changes must not be applied
+	spec := TopologySpec{Topics: []TopicSpec{{Name: "test", Subscriptions: []SubscriptionSpec{{Name: "testing", Pull: true}}}}}
+	changes, err := newTestTopology(fakeClient, "").Apply(spec, false)
+	assert.EqualError(t, err, "create topic test: permission denied")
+	assert.Empty(t, changes)
+	fakeClient.AssertNotCalled(t, "CreateSubscription")
+}
+
+func changeStrings(changes []Change) []string {
+	var strings []string
+	for _, change := range changes {
+		strings = append(strings, change.String())
+	}
+
+	return strings
+}
@@ -0,0 +1,81 @@
+// Package kafka provides implementation of pub/sub workflow on Apache Kafka.
+// Topic of a subscription is a Kafka topic and name of a subscription is its consumer group.
+// Messages are committed after successful handling. Failed messages are sent to retry topic of the subscription,
+// which is consumed by the same consumer group after a retry delay doubling on every attempt, and to its dead letter
+// topic after maximum attempts. Messages of each partition are handled in order, so a message waiting for its retry
+// delay blocks its own partition only. Handling is given up after a minute unless subscription has its own timeout.
+// Kafka headers of messages are attributes of pusu messages.
+package kafka
+
+import (
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	kafkago "github.com/segmentio/kafka-go"
+	"net/url"
+	"strings"
+)
+
+type Adapter struct {
+	// Adds topic, retry topic and dead letter topic of subscription to Kafka cluster
+	topicAdder pusu.Creator
+
+	// Runs subscription as consumer group member
+	runner pusu.Runner
+
+	// Validates and provisions subscriptions, tracking their health and metrics
+	pusu.Provisioner
+}
+
+// Implementation of pusu.Creator interface as part of pusu.Adapter interface
+func (k *Adapter) CreateSubscription(subscription pusu.Subscription) error {
+	return k.Provision(subscription, k.topicAdder)
+}
+
+// Implementation of pusu.Runner interface as part of pusu.Adapter interface
+func (k *Adapter) Run(subscription pusu.Subscription) error {
+	err := k.runner.Run(subscription)
+	return err
+}
+
+// Creates Kafka Adapter
+// brokers: Addresses of Kafka brokers. (Ex: localhost:9092)
+func CreateAdapter(brokers []string) (*Adapter, error) {
+	// Validate parameters
+	if len(brokers) == 0 {
+		return nil, errors.New("brokers must not be empty")
+	}
+
+	kafkaAdapter := new(Adapter)
+	kafkaAdapter.topicAdder = &topicAdder{client: &kafkaClientWrapper{client: &kafkago.Client{Addr: kafkago.TCP(brokers...)}}}
+
+	// Add consumer group runner. Messages with same key are sent to same partition of retry topic to keep ordering.
+	kafkaAdapter.runner = &consumerRunner{
+		newReader: func(subscription pusu.Subscription, topic string) reader {
+			return kafkago.NewReader(kafkago.ReaderConfig{
+				Brokers: brokers,
+				GroupID: subscription.Name(),
+				Topic:   topic,
+			})
+		},
+		writer:           &kafkago.Writer{Addr: kafkago.TCP(brokers...), Balancer: new(kafkago.Hash)},
+		maxAttempts:      defaultMaxAttempts,
+		retryDelay:       defaultRetryDelay,
+		rejectionBackoff: defaultRejectionBackoff,
+		handlerTimeout:   defaultHandlerTimeout,
+		metrics:          kafkaAdapter.Metrics(),
+	}
+
+	return kafkaAdapter, nil
+}
+
+func init() {
+	pusu.RegisterScheme("kafka", open)
+}
+
+// Opens Kafka Adapter of url in form of kafka://<broker>[,<broker>...]
+func open(u *url.URL) (pusu.Adapter, error) {
+	if u.Host == "" {
+		return CreateAdapter(nil)
+	}
+	return CreateAdapter(strings.Split(u.Host, ","))
+}
@@ -0,0 +1,56 @@
+package kafka
+
+import (
+	"github.com/metglobal-compass/pusu"
+	"github.com/metglobal-compass/pusu/pusutest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+func TestAdapter_CreateAdapter(t *testing.T) {
+	// Call real method and check each interfaces have proper types
+	adapter, err := CreateAdapter([]string{"localhost:9092"})
+
+	assert.Nil(t, err)
+
+	topicAdder, topicAdderProper := adapter.topicAdder.(*topicAdder)
+	assert.True(t, topicAdderProper, "Topic adder is not proper")
+
+	_, kafkaClientProper := topicAdder.client.(*kafkaClientWrapper)
+	assert.True(t, kafkaClientProper, "Kafka client is not proper")
+
+	consumerRunner, consumerRunnerProper := adapter.runner.(*consumerRunner)
+	assert.True(t, consumerRunnerProper, "Runner is not proper")
+	assert.Equal(t, defaultMaxAttempts, consumerRunner.maxAttempts)
+	assert.True(t, consumerRunner.metrics == adapter.Metrics(), "Runner metrics is not proper")
+}
+
+func TestAdapter_CreateAdapterErrorWithEmptyBrokers(t *testing.T) {
+	// Call real method without brokers
+	_, err := CreateAdapter(nil)
+	assert.Error(t, err)
+}
+
+func TestAdapter_Open(t *testing.T) {
+	// Open adapter by dsn with several brokers
+	adapter, err := pusu.Open("kafka://localhost:9092,localhost:9093")
+	assert.Nil(t, err)
+	_, proper := adapter.(*Adapter)
+	assert.True(t, proper, "Adapter is not proper")
+
+	// Brokers are required
+	_, err = pusu.Open("kafka://")
+	assert.Error(t, err)
+}
+
+func TestAdapter_CreateSubscriptionErrorOnFilter(t *testing.T) {
+	// Kafka can't filter messages, so filtered subscriptions must be rejected before topics are created
+	fakeClient := new(fakeClient)
+	adapter := new(Adapter)
+	adapter.topicAdder = &topicAdder{client: fakeClient}
+	subscription := new(pusutest.Subscription).WillHaveProperFields()
+	assert.Error(t, adapter.CreateSubscription(pusu.WithFilter(subscription, `attributes.kind = "order"`)))
+	fakeClient.AssertNotCalled(t, "TopicExists", mock.Anything, mock.Anything)
+	assert.NotNil(t, adapter.Health().Ready(), "Subscriber must not be ready")
+}
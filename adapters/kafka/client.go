@@ -0,0 +1,24 @@
+package kafka
+
+import (
+	"context"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Kafka cluster operations used while provisioning subscriptions
+type client interface {
+	TopicExists(ctx context.Context, topic string) (bool, error)
+	CreateTopic(ctx context.Context, topic string) error
+}
+
+// Consumer group reader of a subscription
+type reader interface {
+	FetchMessage(ctx context.Context) (kafkago.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafkago.Message) error
+	Close() error
+}
+
+// Producer used for sending failed messages to retry and dead letter topics
+type writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafkago.Message) error
+}
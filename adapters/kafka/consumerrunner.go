@@ -0,0 +1,281 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	kafkago "github.com/segmentio/kafka-go"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Header which holds number of failed handling attempts of a message
+	attemptHeader = "pusu-attempt"
+
+	// Header of retried messages which holds the time, in unix milliseconds, before which they must not be handled
+	notBeforeHeader = "pusu-not-before"
+
+	// Messages are sent to dead letter topic after this many failed attempts
+	defaultMaxAttempts = 5
+
+	// Waiting duration before the first retry of a failed message. It doubles on every further attempt.
+	defaultRetryDelay = 10 * time.Second
+
+	// Waiting duration before handling a message again when subscription rejects it (e.g. circuit is open). It doubles
+	// on every further rejection of the same message up to maxRejectionBackoff.
+	defaultRejectionBackoff = time.Second
+	maxRejectionBackoff     = 30 * time.Second
+
+	// Handling is given up after this timeout unless subscription has its own
+	defaultHandlerTimeout = time.Minute
+
+	// Fetched messages queued for each partition. Fetching stops once queue of a partition waiting for its retry delay
+	// is full.
+	partitionQueueSize = 100
+)
+
+// Consumer group runner which implements pusu.Runner interface
+type consumerRunner struct {
+	// Creates consumer group reader of subscription for given topic
+	newReader func(subscription pusu.Subscription, topic string) reader
+
+	// Sends failed messages to retry and dead letter topics
+	writer writer
+
+	maxAttempts      int
+	retryDelay       time.Duration
+	rejectionBackoff time.Duration
+	handlerTimeout   time.Duration
+
+	// Counts outcomes of handled messages. Optional.
+	metrics *pusu.Metrics
+}
+
+// Consumes messages of topic and retry topic of subscription until a reader fails. A message is committed after it is
+// handled successfully, or after it is sent to retry or dead letter topic on failure. Messages of retry topic are
+// handled after their retry delay.
+func (c *consumerRunner) Run(subscription pusu.Subscription) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Retry topic is consumed by its own reader, so waiting for delayed messages does not block the topic
+	topics := []string{subscription.Topic(), retryTopic(subscription)}
+	result := make(chan error, len(topics))
+	for _, topic := range topics {
+		r := c.newReader(subscription, topic)
+		go func() {
+			defer r.Close()
+			result <- c.consume(ctx, subscription, r)
+		}()
+	}
+
+	// Stop the other reader when one fails
+	err := <-result
+	cancel()
+	<-result
+
+	return err
+}
+
+// Consumes messages of reader until it fails or context is done. Messages of each partition are handled in order by
+// their own goroutine, so a message waiting for its retry delay does not block other partitions. Messages already
+// fetched are handled before error of reader is returned.
+func (c *consumerRunner) consume(ctx context.Context, subscription pusu.Subscription, r reader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	var workers sync.WaitGroup
+	failed := make(chan error, 1)
+	partitions := make(map[int]chan kafkago.Message)
+	stop := func(err error) error {
+		for _, queue := range partitions {
+			close(queue)
+		}
+		workers.Wait()
+		cancel()
+		select {
+		case workerErr := <-failed:
+			return workerErr
+		default:
+			return err
+		}
+	}
+
+	for {
+		message, err := r.FetchMessage(ctx)
+		if err != nil {
+			return stop(err)
+		}
+
+		queue, ok := partitions[message.Partition]
+		if !ok {
+			queue = make(chan kafkago.Message, partitionQueueSize)
+			partitions[message.Partition] = queue
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				err := c.consumePartition(ctx, subscription, r, queue)
+				if err != nil {
+					select {
+					case failed <- err:
+					default:
+					}
+					cancel()
+				}
+			}()
+		}
+
+		select {
+		case queue <- message:
+		case <-ctx.Done():
+			return stop(ctx.Err())
+		}
+	}
+}
+
+// Handles and commits queued messages of a partition in order until queue is closed or context is done
+func (c *consumerRunner) consumePartition(ctx context.Context, subscription pusu.Subscription, r reader, queue <-chan kafkago.Message) error {
+	for message := range queue {
+		err := wait(ctx, time.Until(notBefore(message)))
+		if err != nil {
+			return err
+		}
+
+		err = c.handle(ctx, subscription, message)
+		if err != nil {
+			return err
+		}
+
+		err = r.CommitMessages(ctx, message)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Handles message with subscription, giving up after handler timeout. Returns error only if message could not be
+// sent to retry or dead letter topic.
+func (c *consumerRunner) handle(ctx context.Context, subscription pusu.Subscription, message kafkago.Message) error {
+	m := pusu.NewMessage(string(message.Value)).WithAttributes(attributes(message)).WithContext(ctx)
+	handler := subscription
+	if _, ok := pusu.HandlerTimeoutOf(subscription); !ok {
+		handler = pusu.WithTimeout(subscription, c.handlerTimeout)
+	}
+
+	backoff := c.rejectionBackoff
+	for {
+		err := pusu.HandleBlocking(ctx, handler, m)
+		if c.metrics != nil {
+			c.metrics.Observe(subscription, err)
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		// Handler was not called at all, try same message again later with a growing backoff
+		if pusu.IsRejection(err) {
+			err = wait(ctx, backoff)
+			if err != nil {
+				return err
+			}
+			backoff = min(2*backoff, maxRejectionBackoff)
+			continue
+		}
+
+		log.Printf("Handler of subscription %s failed: %s", subscription.Name(), err)
+		return c.forward(ctx, subscription, message, err)
+	}
+}
+
+// Sends failed message to retry topic, or to dead letter topic if maximum attempts are reached or handler requires it.
+// Messages sent to retry topic must not be handled before their retry delay, which doubles on every attempt.
+func (c *consumerRunner) forward(ctx context.Context, subscription pusu.Subscription, message kafkago.Message, err error) error {
+	attempt := attempts(message) + 1
+
+	topic := retryTopic(subscription)
+	headers := []kafkago.Header{{Key: attemptHeader, Value: []byte(strconv.Itoa(attempt))}}
+	if attempt >= c.maxAttempts || errors.Is(err, pusu.ErrDeadLetter) {
+		topic = deadLetterTopic(subscription)
+	} else if c.retryDelay > 0 {
+		delay := c.retryDelay << (attempt - 1)
+		notBefore := time.Now().Add(delay).UnixMilli()
+		headers = append(headers, kafkago.Header{Key: notBeforeHeader, Value: []byte(strconv.FormatInt(notBefore, 10))})
+	}
+
+	for _, header := range message.Headers {
+		if header.Key != attemptHeader && header.Key != notBeforeHeader {
+			headers = append(headers, header)
+		}
+	}
+
+	return c.writer.WriteMessages(ctx, kafkago.Message{
+		Topic:   topic,
+		Key:     message.Key,
+		Value:   message.Value,
+		Headers: headers,
+	})
+}
+
+// Returns number of failed attempts of message
+func attempts(message kafkago.Message) int {
+	for _, header := range message.Headers {
+		if header.Key == attemptHeader {
+			attempt, _ := strconv.Atoi(string(header.Value))
+			return attempt
+		}
+	}
+
+	return 0
+}
+
+// Returns the time before which message must not be handled. Returns zero time if message may be handled right away.
+func notBefore(message kafkago.Message) time.Time {
+	for _, header := range message.Headers {
+		if header.Key == notBeforeHeader {
+			millis, err := strconv.ParseInt(string(header.Value), 10, 64)
+			if err != nil {
+				return time.Time{}
+			}
+			return time.UnixMilli(millis)
+		}
+	}
+
+	return time.Time{}
+}
+
+// Returns headers of message as attributes, leaving out headers of pusu itself. Returns nil if there is none.
+func attributes(message kafkago.Message) map[string]string {
+	var attributes map[string]string
+	for _, header := range message.Headers {
+		if strings.HasPrefix(header.Key, "pusu-") {
+			continue
+		}
+		if attributes == nil {
+			attributes = make(map[string]string, len(message.Headers))
+		}
+		attributes[header.Key] = string(header.Value)
+	}
+
+	return attributes
+}
+
+// Waits for given duration unless context is done before
+func wait(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
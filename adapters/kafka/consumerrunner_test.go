@@ -0,0 +1,324 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	"github.com/metglobal-compass/pusu/pusutest"
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestConsumerRunner_Run(t *testing.T) {
+	// Create fake reader which returns a single message and then stops
+	message := kafkago.Message{Topic: "test", Value: []byte("payload"), Headers: []kafkago.Header{{Key: "type", Value: []byte("created")}}}
+	fakeReader := new(fakeReader)
+	fakeReader.On("FetchMessage", mock.Anything).Return(message, nil).Once()
+	fakeReader.On("FetchMessage", mock.Anything).Return(kafkago.Message{}, io.EOF)
+	fakeReader.On("CommitMessages", mock.Anything, []kafkago.Message{message}).Return(nil)
+	fakeReader.On("Close").Return(nil)
+
+	// Call real method with a successful subscription
+	fakeWriter := new(fakeWriter)
+	runner := newTestRunner(fakeReader, fakeWriter)
+	subscription := new(pusutest.Subscription).WillHaveProperFields()
+	err := runner.Run(subscription)
+
+	// Runner must return error of reader
+	assert.Equal(t, io.EOF, err)
+
+	// Message must be handled with its payload and headers and committed without sending to any other topic
+	subscription.(*pusutest.Subscription).AssertCalled(t, "Handle", mock.MatchedBy(func(m *pusu.Message) bool {
+		return m.Message() == "payload" && assert.ObjectsAreEqual(map[string]string{"type": "created"}, m.Attributes())
+	}))
+	fakeReader.AssertNumberOfCalls(t, "CommitMessages", 1)
+	fakeReader.AssertCalled(t, "Close")
+	fakeWriter.AssertNotCalled(t, "WriteMessages")
+	assert.Equal(t, int64(1), runner.metrics.Count(subscription, pusu.OutcomeSuccess))
+}
+
+func TestConsumerRunner_RunFailureToRetryTopic(t *testing.T) {
+	// Create fake reader which returns a single message and then stops
+	message := kafkago.Message{Topic: "test", Key: []byte("key"), Value: []byte("payload")}
+	fakeReader := new(fakeReader)
+	fakeReader.On("FetchMessage", mock.Anything).Return(message, nil).Once()
+	fakeReader.On("FetchMessage", mock.Anything).Return(kafkago.Message{}, io.EOF)
+	fakeReader.On("CommitMessages", mock.Anything, mock.Anything).Return(nil)
+	fakeReader.On("Close").Return(nil)
+	fakeWriter := new(fakeWriter)
+	fakeWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+
+	// Call real method with a failing subscription
+	runner := newTestRunner(fakeReader, fakeWriter)
+	runner.Run(new(pusutest.Subscription).WillReturnError())
+
+	// Failed message must be sent to retry topic with first attempt, delayed by retry delay, and then committed
+	fakeWriter.AssertCalled(t, "WriteMessages", mock.Anything, mock.MatchedBy(func(messages []kafkago.Message) bool {
+		delay := time.Until(notBefore(messages[0]))
+		return len(messages) == 1 && messages[0].Topic == "testing.retry" && string(messages[0].Key) == "key" &&
+			string(messages[0].Value) == "payload" && attempts(messages[0]) == 1 && delay > 0 && delay <= time.Minute
+	}))
+	fakeReader.AssertNumberOfCalls(t, "CommitMessages", 1)
+}
+
+func TestConsumerRunner_RunRetryDelay(t *testing.T) {
+	// Create fake retry topic reader which returns a message that must not be handled for 50ms
+	delayed := time.Now().Add(50 * time.Millisecond)
+	message := kafkago.Message{
+		Topic: "testing.retry",
+		Value: []byte("payload"),
+		Headers: []kafkago.Header{
+			{Key: attemptHeader, Value: []byte("1")},
+			{Key: notBeforeHeader, Value: []byte(strconv.FormatInt(delayed.UnixMilli(), 10))},
+		},
+	}
+	retryReader := new(fakeReader)
+	retryReader.On("FetchMessage", mock.Anything).Return(message, nil).Once()
+	retryReader.On("FetchMessage", mock.Anything).Return(kafkago.Message{}, io.EOF)
+	retryReader.On("CommitMessages", mock.Anything, mock.Anything).Return(nil)
+	retryReader.On("Close").Return(nil)
+
+	// Topic reader must not be blocked while retry topic reader waits
+	topicReader := new(fakeReader)
+	topicReader.On("FetchMessage", mock.Anything).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	}).Return(kafkago.Message{}, context.Canceled)
+	topicReader.On("Close").Return(nil)
+
+	var handled time.Time
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing")
+	subscription.On("Handle", mock.Anything).Run(func(args mock.Arguments) {
+		handled = time.Now()
+	}).Return(nil)
+
+	runner := newTestRunner(nil, new(fakeWriter))
+	runner.newReader = func(subscription pusu.Subscription, topic string) reader {
+		if topic == "testing.retry" {
+			return retryReader
+		}
+		return topicReader
+	}
+	err := runner.Run(subscription)
+
+	// Message must be handled once its retry delay is over, without pusu headers as attributes
+	assert.Equal(t, io.EOF, err)
+	assert.False(t, handled.Before(delayed.Truncate(time.Millisecond)), "Message is handled before its retry delay")
+	subscription.AssertCalled(t, "Handle", mock.MatchedBy(func(m *pusu.Message) bool {
+		return m.Attributes() == nil
+	}))
+	retryReader.AssertNumberOfCalls(t, "CommitMessages", 1)
+	topicReader.AssertCalled(t, "Close")
+}
+
+func TestConsumerRunner_RunRetryDelayOfPartition(t *testing.T) {
+	// Create fake reader which returns a delayed message of a partition and a message of another partition
+	delayed := kafkago.Message{
+		Topic:     "testing.retry",
+		Partition: 0,
+		Value:     []byte("delayed"),
+		Headers:   []kafkago.Header{{Key: notBeforeHeader, Value: []byte(strconv.FormatInt(time.Now().Add(100*time.Millisecond).UnixMilli(), 10))}},
+	}
+	other := kafkago.Message{Topic: "testing.retry", Partition: 1, Value: []byte("other")}
+	fakeReader := new(fakeReader)
+	fakeReader.On("FetchMessage", mock.Anything).Return(delayed, nil).Once()
+	fakeReader.On("FetchMessage", mock.Anything).Return(other, nil).Once()
+	fakeReader.On("FetchMessage", mock.Anything).Return(kafkago.Message{}, io.EOF)
+	fakeReader.On("CommitMessages", mock.Anything, mock.Anything).Return(nil)
+	fakeReader.On("Close").Return(nil)
+
+	handled := make(chan interface{}, 4)
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing")
+	subscription.On("Handle", mock.Anything).Run(func(args mock.Arguments) {
+		handled <- args.Get(0).(*pusu.Message).Message()
+	}).Return(nil)
+
+	runner := newTestRunner(fakeReader, new(fakeWriter))
+	runner.newReader = func(subscription pusu.Subscription, topic string) reader {
+		if topic == "testing.retry" {
+			return fakeReader
+		}
+		return newStoppedReader()
+	}
+	assert.Equal(t, io.EOF, runner.Run(subscription))
+
+	// Message of another partition must not wait for delayed message
+	assert.Equal(t, "other", <-handled)
+	assert.Equal(t, "delayed", <-handled)
+	fakeReader.AssertNumberOfCalls(t, "CommitMessages", 2)
+}
+
+func TestConsumerRunner_RunHandlerTimeout(t *testing.T) {
+	// Create fake reader which returns a single message and then stops
+	fakeReader := new(fakeReader)
+	fakeReader.On("FetchMessage", mock.Anything).Return(kafkago.Message{Topic: "test", Value: []byte("payload")}, nil).Once()
+	fakeReader.On("FetchMessage", mock.Anything).Return(kafkago.Message{}, io.EOF)
+	fakeReader.On("CommitMessages", mock.Anything, mock.Anything).Return(nil)
+	fakeReader.On("Close").Return(nil)
+	fakeWriter := new(fakeWriter)
+	fakeWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+
+	// Create subscription which blocks until its message is given up
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing")
+	subscription.On("Handle", mock.Anything).Run(func(args mock.Arguments) {
+		<-args.Get(0).(*pusu.Message).Context().Done()
+	}).Return(errors.New("cancelled"))
+
+	// Call real method with a short handler timeout
+	runner := newTestRunner(fakeReader, fakeWriter)
+	runner.handlerTimeout = 10 * time.Millisecond
+	runner.newReader = func(subscription pusu.Subscription, topic string) reader {
+		if topic == "test" {
+			return fakeReader
+		}
+		return newStoppedReader()
+	}
+	runner.Run(subscription)
+
+	// Timed out message must be sent to retry topic
+	assert.Equal(t, int64(1), runner.metrics.Count(subscription, pusu.OutcomeTimeout))
+	fakeWriter.AssertCalled(t, "WriteMessages", mock.Anything, mock.MatchedBy(func(messages []kafkago.Message) bool {
+		return len(messages) == 1 && messages[0].Topic == "testing.retry"
+	}))
+}
+
+func TestConsumerRunner_RunFailureToDeadLetterTopic(t *testing.T) {
+	// Create fake reader which returns a message failed on every attempt but the last one
+	message := kafkago.Message{
+		Topic:   "testing.retry",
+		Value:   []byte("payload"),
+		Headers: []kafkago.Header{{Key: attemptHeader, Value: []byte("4")}},
+	}
+	fakeReader := new(fakeReader)
+	fakeReader.On("FetchMessage", mock.Anything).Return(message, nil).Once()
+	fakeReader.On("FetchMessage", mock.Anything).Return(kafkago.Message{}, io.EOF)
+	fakeReader.On("CommitMessages", mock.Anything, mock.Anything).Return(nil)
+	fakeReader.On("Close").Return(nil)
+	fakeWriter := new(fakeWriter)
+	fakeWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+
+	// Call real method with a failing subscription
+	runner := newTestRunner(fakeReader, fakeWriter)
+	runner.Run(new(pusutest.Subscription).WillReturnError())
+
+	// Message must be sent to dead letter topic after last attempt
+	fakeWriter.AssertCalled(t, "WriteMessages", mock.Anything, mock.MatchedBy(func(messages []kafkago.Message) bool {
+		return len(messages) == 1 && messages[0].Topic == "testing.dlq" && attempts(messages[0]) == 5
+	}))
+}
+
+func TestConsumerRunner_RunDeadLetter(t *testing.T) {
+	// Create fake reader which returns a message on its first attempt and then stops
+	fakeReader := new(fakeReader)
+	fakeReader.On("FetchMessage", mock.Anything).Return(kafkago.Message{Topic: "test", Value: []byte("payload")}, nil).Once()
+	fakeReader.On("FetchMessage", mock.Anything).Return(kafkago.Message{}, io.EOF)
+	fakeReader.On("CommitMessages", mock.Anything, mock.Anything).Return(nil)
+	fakeReader.On("Close").Return(nil)
+	fakeWriter := new(fakeWriter)
+	fakeWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(nil)
+
+	// Call real method with a subscription which requires dead lettering
+	runner := newTestRunner(fakeReader, fakeWriter)
+	runner.Run(new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(pusu.ErrDeadLetter))
+
+	// Message must be sent to dead letter topic without further attempts
+	fakeWriter.AssertCalled(t, "WriteMessages", mock.Anything, mock.MatchedBy(func(messages []kafkago.Message) bool {
+		return len(messages) == 1 && messages[0].Topic == "testing.dlq" && attempts(messages[0]) == 1
+	}))
+}
+
+func TestConsumerRunner_RunErrorOnWriter(t *testing.T) {
+	// Create fake reader which returns a single message
+	fakeReader := new(fakeReader)
+	fakeReader.On("FetchMessage", mock.Anything).Return(kafkago.Message{Value: []byte("payload")}, nil)
+	fakeReader.On("Close").Return(nil)
+	fakeWriter := new(fakeWriter)
+	fakeWriter.On("WriteMessages", mock.Anything, mock.Anything).Return(errors.New("error"))
+
+	// Call real method with a failing subscription
+	runner := newTestRunner(fakeReader, fakeWriter)
+	err := runner.Run(new(pusutest.Subscription).WillReturnError())
+
+	// Runner must stop without committing message when it can not be sent to retry topic
+	assert.Error(t, err)
+	fakeReader.AssertNotCalled(t, "CommitMessages")
+}
+
+func TestConsumerRunner_RunRejection(t *testing.T) {
+	// Create fake reader which returns a single message and then stops
+	fakeReader := new(fakeReader)
+	fakeReader.On("FetchMessage", mock.Anything).Return(kafkago.Message{Value: []byte("payload")}, nil).Once()
+	fakeReader.On("FetchMessage", mock.Anything).Return(kafkago.Message{}, io.EOF)
+	fakeReader.On("CommitMessages", mock.Anything, mock.Anything).Return(nil)
+	fakeReader.On("Close").Return(nil)
+
+	// Create subscription which rejects the first attempt
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing")
+	subscription.On("Handle", mock.Anything).Return(pusu.ErrCircuitOpen).Once()
+	subscription.On("Handle", mock.Anything).Return(nil)
+
+	// Call real method
+	fakeWriter := new(fakeWriter)
+	runner := newTestRunner(fakeReader, fakeWriter)
+	runner.Run(subscription)
+
+	// Rejected message must be handled again instead of being sent to retry topic
+	subscription.AssertNumberOfCalls(t, "Handle", 2)
+	fakeWriter.AssertNotCalled(t, "WriteMessages")
+	fakeReader.AssertNumberOfCalls(t, "CommitMessages", 1)
+}
+
+// Creates a consumer runner with given fakes and without backoff
+func newTestRunner(r reader, w writer) *consumerRunner {
+	return &consumerRunner{
+		newReader: func(subscription pusu.Subscription, topic string) reader {
+			return r
+		},
+		writer:      w,
+		maxAttempts: defaultMaxAttempts,
+		retryDelay:  defaultRetryDelay,
+		metrics:     new(pusu.Metrics),
+	}
+}
+
+// Creates a fake reader which blocks until its context is done
+func newStoppedReader() *fakeReader {
+	r := new(fakeReader)
+	r.On("FetchMessage", mock.Anything).Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	}).Return(kafkago.Message{}, context.Canceled)
+	r.On("Close").Return(nil)
+	return r
+}
+
+type fakeReader struct {
+	mock.Mock
+}
+
+func (f *fakeReader) FetchMessage(ctx context.Context) (kafkago.Message, error) {
+	args := f.Called(ctx)
+	return args.Get(0).(kafkago.Message), args.Error(1)
+}
+
+func (f *fakeReader) CommitMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	args := f.Called(ctx, msgs)
+	return args.Error(0)
+}
+
+func (f *fakeReader) Close() error {
+	args := f.Called()
+	return args.Error(0)
+}
+
+type fakeWriter struct {
+	mock.Mock
+}
+
+func (f *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafkago.Message) error {
+	args := f.Called(ctx, msgs)
+	return args.Error(0)
+}
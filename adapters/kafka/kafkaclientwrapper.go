@@ -0,0 +1,48 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Kafka Client wrapper which implements client interface
+type kafkaClientWrapper struct {
+	client *kafkago.Client
+}
+
+func (k *kafkaClientWrapper) TopicExists(ctx context.Context, topic string) (bool, error) {
+	response, err := k.client.Metadata(ctx, &kafkago.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return false, err
+	}
+
+	for _, t := range response.Topics {
+		if t.Name != topic {
+			continue
+		}
+		if errors.Is(t.Error, kafkago.UnknownTopicOrPartition) {
+			return false, nil
+		}
+		return t.Error == nil, t.Error
+	}
+
+	return false, nil
+}
+
+func (k *kafkaClientWrapper) CreateTopic(ctx context.Context, topic string) error {
+	// Use broker defaults for partition count and replication factor
+	response, err := k.client.CreateTopics(ctx, &kafkago.CreateTopicsRequest{
+		Topics: []kafkago.TopicConfig{{Topic: topic, NumPartitions: -1, ReplicationFactor: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = response.Errors[topic]
+	if err != nil && !errors.Is(err, kafkago.TopicAlreadyExists) {
+		return err
+	}
+
+	return nil
+}
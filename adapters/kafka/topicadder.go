@@ -0,0 +1,55 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"github.com/metglobal-compass/pusu"
+)
+
+const (
+	// Failed messages of a subscription are sent to its retry topic and consumed again by the same consumer group
+	retryTopicPattern = "%s.retry"
+
+	// Messages failed on every attempt are sent to dead letter topic of subscription
+	deadLetterTopicPattern = "%s.dlq"
+)
+
+type topicAdder struct {
+	client client
+}
+
+// Implementation of internal Creator interface for Kafka Adapter.
+// Consumer group of subscription needs no provisioning, Kafka creates it when the first consumer joins.
+func (t *topicAdder) CreateSubscription(subscription pusu.Subscription) error {
+	// Use single context
+	ctx := context.Background()
+
+	topics := []string{subscription.Topic(), retryTopic(subscription), deadLetterTopic(subscription)}
+	for _, topic := range topics {
+		// Check if topic exists
+		exists, err := t.client.TopicExists(ctx, topic)
+		if err != nil {
+			return err
+		}
+
+		// If topic does not exists, create in cluster
+		if !exists {
+			err = t.client.CreateTopic(ctx, topic)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns name of retry topic of subscription
+func retryTopic(subscription pusu.Subscription) string {
+	return fmt.Sprintf(retryTopicPattern, subscription.Name())
+}
+
+// Returns name of dead letter topic of subscription
+func deadLetterTopic(subscription pusu.Subscription) string {
+	return fmt.Sprintf(deadLetterTopicPattern, subscription.Name())
+}
@@ -0,0 +1,78 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"github.com/metglobal-compass/pusu/pusutest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+func TestTopicAdder_CreateSubscription(t *testing.T) {
+	// Create fake mocked client. In this case, topic exists but retry and dead letter topics do not exist
+	fakeClient := new(fakeClient)
+	fakeClient.On("TopicExists", context.Background(), "test").Return(true, nil)
+	fakeClient.On("TopicExists", context.Background(), "testing.retry").Return(false, nil)
+	fakeClient.On("TopicExists", context.Background(), "testing.dlq").Return(false, nil)
+	fakeClient.On("CreateTopic", context.Background(), mock.Anything).Return(nil)
+
+	// Call real method
+	topicAdder := &topicAdder{client: fakeClient}
+	err := topicAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Everything is fine, so real method must return nil as error
+	assert.Nil(t, err)
+
+	// Only missing topics must be created
+	fakeClient.AssertNotCalled(t, "CreateTopic", mock.Anything, "test")
+	fakeClient.AssertCalled(t, "CreateTopic", mock.Anything, "testing.retry")
+	fakeClient.AssertCalled(t, "CreateTopic", mock.Anything, "testing.dlq")
+	fakeClient.AssertNumberOfCalls(t, "TopicExists", 3)
+	fakeClient.AssertNumberOfCalls(t, "CreateTopic", 2)
+}
+
+func TestTopicAdder_CreateSubscriptionErrorOnTopicExists(t *testing.T) {
+	// Create fake mocked client. In this case, we get an error while checking a topic's existence
+	fakeClient := new(fakeClient)
+	fakeClient.On("TopicExists", context.Background(), "test").Return(false, errors.New("error"))
+
+	// Call real method
+	topicAdder := &topicAdder{client: fakeClient}
+	err := topicAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Got an error and following methods must not be called
+	assert.Error(t, err)
+	fakeClient.AssertNumberOfCalls(t, "TopicExists", 1)
+	fakeClient.AssertNotCalled(t, "CreateTopic")
+}
+
+func TestTopicAdder_CreateSubscriptionErrorOnCreateTopic(t *testing.T) {
+	// Create fake mocked client. In this case, we get an error while creating topic
+	fakeClient := new(fakeClient)
+	fakeClient.On("TopicExists", context.Background(), "test").Return(false, nil)
+	fakeClient.On("CreateTopic", context.Background(), "test").Return(errors.New("error"))
+
+	// Call real method
+	topicAdder := &topicAdder{client: fakeClient}
+	err := topicAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Got an error and retry and dead letter topics must not be checked
+	assert.Error(t, err)
+	fakeClient.AssertNumberOfCalls(t, "TopicExists", 1)
+	fakeClient.AssertNumberOfCalls(t, "CreateTopic", 1)
+}
+
+type fakeClient struct {
+	mock.Mock
+}
+
+func (f *fakeClient) TopicExists(ctx context.Context, topic string) (bool, error) {
+	args := f.Called(ctx, topic)
+	return args.Bool(0), args.Error(1)
+}
+
+func (f *fakeClient) CreateTopic(ctx context.Context, topic string) error {
+	args := f.Called(ctx, topic)
+	return args.Error(0)
+}
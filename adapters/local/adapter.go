@@ -0,0 +1,94 @@
+// Package local provides implementation of pub/sub workflow on files of a local directory, for development
+// without external services. Topic of a subscription is an append-only log file and name of a subscription is
+// a cursor file on that log, so each subscription of a topic receives every message independently.
+// Messages survive restarts: cursor is moved only after successful handling, failed messages are delivered again
+// after a delay and dead lettered after maximum attempts.
+// Messages of a subscription are handled in publishing order and a subscription must be run by a single process.
+// Attributes of messages are kept in topic log, see PublishMessage.
+package local
+
+import (
+	"errors"
+	"fmt"
+	"github.com/metglobal-compass/pusu"
+	"net/url"
+)
+
+type Adapter struct {
+	// Appends published messages to topic logs
+	store store
+
+	// Adds topic log and cursor of subscription to directory
+	subscriptionAdder pusu.Creator
+
+	// Runs subscription as topic log tailer
+	runner pusu.Runner
+
+	// Validates and provisions subscriptions, tracking their health and metrics
+	pusu.Provisioner
+}
+
+// Implementation of pusu.Creator interface as part of pusu.Adapter interface
+func (a *Adapter) CreateSubscription(subscription pusu.Subscription) error {
+	return a.Provision(subscription, a.subscriptionAdder)
+}
+
+// Implementation of pusu.Runner interface as part of pusu.Adapter interface
+func (a *Adapter) Run(subscription pusu.Subscription) error {
+	err := a.runner.Run(subscription)
+	return err
+}
+
+// Publishes message to topic. Message is delivered to subscriptions which exist at the time of publishing.
+func (a *Adapter) Publish(topic string, payload string) error {
+	return a.PublishMessage(topic, pusu.NewMessage(payload))
+}
+
+// Publishes message with its attributes to topic. Message is delivered to subscriptions which exist at the time of
+// publishing. Payload of message must be string or []byte.
+func (a *Adapter) PublishMessage(topic string, m *pusu.Message) error {
+	var payload string
+	switch p := m.Message().(type) {
+	case string:
+		payload = p
+	case []byte:
+		payload = string(p)
+	default:
+		return fmt.Errorf("payload of message must be string or []byte, not %T", m.Message())
+	}
+
+	return a.store.Publish(topic, payload, m.Attributes())
+}
+
+// Creates Local Adapter. Directory is created on subscription creation if it doesn't exist.
+// dir: Directory of topic logs and subscription cursors. (Ex: .pusu)
+func CreateAdapter(dir string) (*Adapter, error) {
+	// Validate parameters
+	if dir == "" {
+		return nil, errors.New("dir must not be empty")
+	}
+	store := &fileStore{dir: dir}
+
+	localAdapter := new(Adapter)
+	localAdapter.store = store
+	localAdapter.subscriptionAdder = &subscriptionAdder{store: store}
+	localAdapter.runner = &tailRunner{
+		store:          store,
+		maxAttempts:    defaultMaxAttempts,
+		retryDelay:     defaultRetryDelay,
+		rejectionDelay: defaultRejectionDelay,
+		pollInterval:   defaultPollInterval,
+		metrics:        localAdapter.Metrics(),
+	}
+
+	return localAdapter, nil
+}
+
+func init() {
+	pusu.RegisterScheme("local", open)
+}
+
+// Opens Local Adapter of url in form of local://<relative dir> or local:///<absolute dir>
+func open(u *url.URL) (pusu.Adapter, error) {
+	return CreateAdapter(u.Host + u.Path)
+}
@@ -0,0 +1,66 @@
+package local
+
+import (
+	"github.com/metglobal-compass/pusu"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAdapter_Publish(t *testing.T) {
+	// Create fake store
+	fakeStore := new(fakeStore)
+	fakeStore.On("Publish", "test", "hello", map[string]string(nil)).Return(nil)
+	fakeStore.On("Publish", "test", "created", map[string]string{"type": "order"}).Return(nil)
+
+	// Call real methods
+	adapter := new(Adapter)
+	adapter.store = fakeStore
+	assert.Nil(t, adapter.Publish("test", "hello"))
+	assert.Nil(t, adapter.PublishMessage("test", pusu.NewMessage([]byte("created")).WithAttributes(map[string]string{"type": "order"})))
+
+	// Messages must be published to store with their attributes, payloads of other types must be rejected
+	fakeStore.AssertExpectations(t)
+	assert.Error(t, adapter.PublishMessage("test", pusu.NewMessage(1)))
+}
+
+func TestAdapter_CreateAdapter(t *testing.T) {
+	// Call real method and check each interfaces have proper types
+	adapter, err := CreateAdapter(t.TempDir())
+
+	assert.Nil(t, err)
+
+	subscriptionAdder, subscriptionAdderProper := adapter.subscriptionAdder.(*subscriptionAdder)
+	assert.True(t, subscriptionAdderProper, "Subscription adder is not proper")
+
+	_, fileStoreProper := subscriptionAdder.store.(*fileStore)
+	assert.True(t, fileStoreProper, "File store is not proper")
+	assert.True(t, subscriptionAdder.store == adapter.store, "Publishing store is not proper")
+
+	tailRunner, tailRunnerProper := adapter.runner.(*tailRunner)
+	assert.True(t, tailRunnerProper, "Runner is not proper")
+	assert.Equal(t, defaultMaxAttempts, tailRunner.maxAttempts)
+	assert.True(t, tailRunner.metrics == adapter.Metrics(), "Runner metrics is not proper")
+}
+
+func TestAdapter_CreateAdapterErrorWithEmptyDir(t *testing.T) {
+	// Call real method without directory
+	_, err := CreateAdapter("")
+	assert.Error(t, err)
+}
+
+func TestAdapter_Open(t *testing.T) {
+	// Open adapter with an absolute directory
+	dir := t.TempDir()
+	adapter, err := pusu.Open("local://" + dir)
+	assert.Nil(t, err)
+	assert.Equal(t, dir, adapter.(*Adapter).store.(*fileStore).dir)
+
+	// Open adapter with a relative directory
+	adapter, err = pusu.Open("local://.pusu")
+	assert.Nil(t, err)
+	assert.Equal(t, ".pusu", adapter.(*Adapter).store.(*fileStore).dir)
+
+	// Directory is required
+	_, err = pusu.Open("local://")
+	assert.Error(t, err)
+}
@@ -0,0 +1,194 @@
+package local
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var (
+	// Topic and subscription names are used as file names
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+)
+
+// A line of topic log
+type record struct {
+	Payload     string            `json:"payload"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	PublishedAt time.Time         `json:"published_at"`
+}
+
+// A line of dead letter log
+type deadLetter struct {
+	Payload    string            `json:"payload"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Reason     string            `json:"reason"`
+	FailedAt   time.Time         `json:"failed_at"`
+}
+
+// Directory backed store which implements store interface.
+// Layout of directory:
+//
+//	topics/<topic>.log                 append-only log of published messages, one JSON record per line
+//	subscriptions/<name>.json          cursor of subscription
+//	subscriptions/<name>.dead.log      dead lettered messages of subscription
+type fileStore struct {
+	dir string
+
+	// Serializes appends of this process
+	mutex sync.Mutex
+}
+
+// Creates cursor of subscription at the end of topic log, so subscription receives messages published after
+// its creation. Existing cursor is kept as is.
+func (f *fileStore) CreateSubscription(topic string, name string) error {
+	if !namePattern.MatchString(topic) {
+		return fmt.Errorf("topic name %q must contain only letters, digits, '.', '_' and '-'", topic)
+	}
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("subscription name %q must contain only letters, digits, '.', '_' and '-'", name)
+	}
+
+	for _, dir := range []string{"topics", "subscriptions"} {
+		err := os.MkdirAll(filepath.Join(f.dir, dir), 0755)
+		if err != nil {
+			return err
+		}
+	}
+
+	existing, err := f.Cursor(name)
+	if err == nil {
+		if existing.Topic != topic {
+			return fmt.Errorf("subscription %s already exists on topic %s", name, existing.Topic)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	// Create topic log if it doesn't exist and start from its end
+	topicLog, err := os.OpenFile(f.topicPath(topic), os.O_CREATE|os.O_RDONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer topicLog.Close()
+	info, err := topicLog.Stat()
+	if err != nil {
+		return err
+	}
+
+	return f.SaveCursor(name, cursor{Topic: topic, Offset: info.Size()})
+}
+
+// Appends message to topic log. Each record is written with a single write, so that concurrent publishers
+// on a local file system don't interleave records.
+func (f *fileStore) Publish(topic string, payload string, attributes map[string]string) error {
+	if !namePattern.MatchString(topic) {
+		return fmt.Errorf("topic name %q must contain only letters, digits, '.', '_' and '-'", topic)
+	}
+
+	err := os.MkdirAll(filepath.Join(f.dir, "topics"), 0755)
+	if err != nil {
+		return err
+	}
+
+	return f.append(f.topicPath(topic), record{Payload: payload, Attributes: attributes, PublishedAt: time.Now().UTC()})
+}
+
+func (f *fileStore) Cursor(name string) (cursor, error) {
+	var c cursor
+	data, err := os.ReadFile(f.cursorPath(name))
+	if err != nil {
+		return c, err
+	}
+
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
+
+// Replaces cursor file atomically, so cursor is never lost on crash
+func (f *fileStore) SaveCursor(name string, c cursor) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	temporary := f.cursorPath(name) + ".tmp"
+	err = os.WriteFile(temporary, data, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(temporary, f.cursorPath(name))
+}
+
+func (f *fileStore) Read(topic string, offset int64) (record, int64, error) {
+	var r record
+	topicLog, err := os.Open(f.topicPath(topic))
+	if err != nil {
+		return r, offset, err
+	}
+	defer topicLog.Close()
+
+	_, err = topicLog.Seek(offset, io.SeekStart)
+	if err != nil {
+		return r, offset, err
+	}
+
+	// A line without new line character is still being written
+	line, err := bufio.NewReader(topicLog).ReadBytes('\n')
+	if err != nil {
+		return r, offset, err
+	}
+
+	err = json.Unmarshal(line, &r)
+	if err != nil {
+		return r, offset, fmt.Errorf("corrupted record at offset %d of topic %s: %s", offset, topic, err)
+	}
+
+	return r, offset + int64(len(line)), nil
+}
+
+func (f *fileStore) DeadLetter(name string, r record, reason string) error {
+	d := deadLetter{Payload: r.Payload, Attributes: r.Attributes, Reason: reason, FailedAt: time.Now().UTC()}
+	return f.append(filepath.Join(f.dir, "subscriptions", name+".dead.log"), d)
+}
+
+// Appends value to file as a JSON line
+func (f *fileStore) append(path string, r interface{}) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func (f *fileStore) topicPath(topic string) string {
+	return filepath.Join(f.dir, "topics", topic+".log")
+}
+
+func (f *fileStore) cursorPath(name string) string {
+	return filepath.Join(f.dir, "subscriptions", name+".json")
+}
@@ -0,0 +1,110 @@
+package local
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore_PublishAndRead(t *testing.T) {
+	store := &fileStore{dir: t.TempDir()}
+
+	// Create two subscriptions of the same topic
+	assert.Nil(t, store.CreateSubscription("test", "first"))
+	assert.Nil(t, store.CreateSubscription("test", "second"))
+
+	// Publish messages, payloads may contain new lines
+	assert.Nil(t, store.Publish("test", "hello", map[string]string{"type": "created"}))
+	assert.Nil(t, store.Publish("test", "multi\nline", nil))
+
+	// Both subscriptions must read every message independently
+	for _, name := range []string{"first", "second"} {
+		c, err := store.Cursor(name)
+		assert.Nil(t, err)
+		assert.Equal(t, cursor{Topic: "test"}, c)
+
+		r, next, err := store.Read(c.Topic, c.Offset)
+		assert.Nil(t, err)
+		assert.Equal(t, "hello", r.Payload)
+		assert.Equal(t, map[string]string{"type": "created"}, r.Attributes)
+
+		r, next, err = store.Read(c.Topic, next)
+		assert.Nil(t, err)
+		assert.Equal(t, "multi\nline", r.Payload)
+		assert.Nil(t, r.Attributes)
+
+		_, _, err = store.Read(c.Topic, next)
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func TestFileStore_CreateSubscriptionStartsAtEnd(t *testing.T) {
+	store := &fileStore{dir: t.TempDir()}
+
+	// Message published before subscription creation must not be delivered
+	assert.Nil(t, store.Publish("test", "hello", nil))
+	assert.Nil(t, store.CreateSubscription("test", "testing"))
+
+	c, err := store.Cursor("testing")
+	assert.Nil(t, err)
+	_, _, err = store.Read(c.Topic, c.Offset)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestFileStore_CreateSubscriptionKeepsCursor(t *testing.T) {
+	store := &fileStore{dir: t.TempDir()}
+
+	// Cursor of existing subscription must survive restart
+	assert.Nil(t, store.CreateSubscription("test", "testing"))
+	assert.Nil(t, store.SaveCursor("testing", cursor{Topic: "test", Offset: 42, Attempts: 2}))
+	assert.Nil(t, store.CreateSubscription("test", "testing"))
+
+	c, err := store.Cursor("testing")
+	assert.Nil(t, err)
+	assert.Equal(t, cursor{Topic: "test", Offset: 42, Attempts: 2}, c)
+
+	// Existing subscription must not be moved to another topic
+	assert.Error(t, store.CreateSubscription("other", "testing"))
+}
+
+func TestFileStore_CreateSubscriptionErrorOnInvalidName(t *testing.T) {
+	store := &fileStore{dir: t.TempDir()}
+
+	// Names must not escape directory
+	assert.Error(t, store.CreateSubscription("../test", "testing"))
+	assert.Error(t, store.CreateSubscription("test", "a/b"))
+	assert.Error(t, store.Publish("..", "hello", nil))
+}
+
+func TestFileStore_ReadIncompleteRecord(t *testing.T) {
+	store := &fileStore{dir: t.TempDir()}
+	assert.Nil(t, store.CreateSubscription("test", "testing"))
+
+	// A record which is still being written must not be read
+	err := os.WriteFile(filepath.Join(store.dir, "topics", "test.log"), []byte(`{"payload":"hel`), 0644)
+	assert.Nil(t, err)
+
+	_, next, err := store.Read("test", 0)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, int64(0), next)
+}
+
+func TestFileStore_DeadLetter(t *testing.T) {
+	store := &fileStore{dir: t.TempDir()}
+	assert.Nil(t, store.CreateSubscription("test", "testing"))
+
+	// Dead lettered message must be appended with its attributes and reason
+	assert.Nil(t, store.DeadLetter("testing", record{Payload: "hello", Attributes: map[string]string{"type": "created"}}, "error"))
+
+	data, err := os.ReadFile(filepath.Join(store.dir, "subscriptions", "testing.dead.log"))
+	assert.Nil(t, err)
+	var d deadLetter
+	assert.Nil(t, json.Unmarshal(data, &d))
+	assert.Equal(t, "hello", d.Payload)
+	assert.Equal(t, map[string]string{"type": "created"}, d.Attributes)
+	assert.Equal(t, "error", d.Reason)
+	assert.False(t, d.FailedAt.IsZero())
+}
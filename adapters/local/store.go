@@ -0,0 +1,22 @@
+package local
+
+// Log and cursor operations used while provisioning and running subscriptions
+type store interface {
+	CreateSubscription(topic string, name string) error
+	Publish(topic string, payload string, attributes map[string]string) error
+	Cursor(name string) (cursor, error)
+	SaveCursor(name string, c cursor) error
+	// Reads record of topic log at offset and returns offset of next record. Returns io.EOF if there is no
+	// complete record at offset yet.
+	Read(topic string, offset int64) (record, int64, error)
+	DeadLetter(name string, r record, reason string) error
+}
+
+// Position of a subscription in its topic log
+type cursor struct {
+	Topic  string `json:"topic"`
+	Offset int64  `json:"offset"`
+
+	// Delivery attempts of record at offset
+	Attempts int `json:"attempts"`
+}
@@ -0,0 +1,15 @@
+package local
+
+import (
+	"github.com/metglobal-compass/pusu"
+)
+
+type subscriptionAdder struct {
+	store store
+}
+
+// Implementation of internal Creator interface for Local Adapter.
+// Creates topic log and cursor of subscription if they don't exist.
+func (s *subscriptionAdder) CreateSubscription(subscription pusu.Subscription) error {
+	return s.store.CreateSubscription(subscription.Topic(), subscription.Name())
+}
@@ -0,0 +1,71 @@
+package local
+
+import (
+	"errors"
+	"github.com/metglobal-compass/pusu/pusutest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+func TestSubscriptionAdder_CreateSubscription(t *testing.T) {
+	// Create fake mocked store
+	fakeStore := new(fakeStore)
+	fakeStore.On("CreateSubscription", "test", "testing").Return(nil)
+
+	// Call real method
+	subscriptionAdder := &subscriptionAdder{store: fakeStore}
+	err := subscriptionAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Cursor of subscription must be created on topic
+	assert.Nil(t, err)
+	fakeStore.AssertExpectations(t)
+}
+
+func TestSubscriptionAdder_CreateSubscriptionError(t *testing.T) {
+	// Create fake mocked store. In this case, we get an error while creating subscription
+	fakeStore := new(fakeStore)
+	fakeStore.On("CreateSubscription", "test", "testing").Return(errors.New("error"))
+
+	// Call real method
+	subscriptionAdder := &subscriptionAdder{store: fakeStore}
+	err := subscriptionAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Got an error
+	assert.Error(t, err)
+}
+
+type fakeStore struct {
+	mock.Mock
+}
+
+func (f *fakeStore) CreateSubscription(topic string, name string) error {
+	args := f.Called(topic, name)
+	return args.Error(0)
+}
+
+func (f *fakeStore) Publish(topic string, payload string, attributes map[string]string) error {
+	args := f.Called(topic, payload, attributes)
+	return args.Error(0)
+}
+
+func (f *fakeStore) Cursor(name string) (cursor, error) {
+	args := f.Called(name)
+	c, _ := args.Get(0).(cursor)
+	return c, args.Error(1)
+}
+
+func (f *fakeStore) SaveCursor(name string, c cursor) error {
+	args := f.Called(name, c)
+	return args.Error(0)
+}
+
+func (f *fakeStore) Read(topic string, offset int64) (record, int64, error) {
+	args := f.Called(topic, offset)
+	return args.Get(0).(record), args.Get(1).(int64), args.Error(2)
+}
+
+func (f *fakeStore) DeadLetter(name string, r record, reason string) error {
+	args := f.Called(name, r, reason)
+	return args.Error(0)
+}
@@ -0,0 +1,104 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	"io"
+	"log"
+	"time"
+)
+
+const (
+	// Records are not delivered again after this many attempts
+	defaultMaxAttempts = 5
+
+	// Delay of a failed record. It is multiplied by attempt count of record.
+	defaultRetryDelay = time.Second
+
+	// Delay of a record rejected by subscription (e.g. circuit is open)
+	defaultRejectionDelay = time.Second
+
+	// Topic log is checked for new records this often when subscription has caught up
+	defaultPollInterval = 200 * time.Millisecond
+)
+
+// Log tailing runner which implements pusu.Runner interface
+type tailRunner struct {
+	store store
+
+	maxAttempts    int
+	retryDelay     time.Duration
+	rejectionDelay time.Duration
+	pollInterval   time.Duration
+
+	// Counts outcomes of handled messages. Optional.
+	metrics *pusu.Metrics
+}
+
+// Delivers records of topic log to subscription in order, starting from its cursor, until store fails.
+// Cursor is moved after successful handling. A failed record is delivered again after a delay and dead lettered
+// when its last attempt fails. Attempts are saved before handling, so records are delivered again after restart.
+func (t *tailRunner) Run(subscription pusu.Subscription) error {
+	ctx := context.Background()
+	c, err := t.store.Cursor(subscription.Name())
+	if err != nil {
+		return err
+	}
+
+	for {
+		r, next, err := t.store.Read(c.Topic, c.Offset)
+		if err == io.EOF {
+			time.Sleep(t.pollInterval)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		c.Attempts++
+		err = t.store.SaveCursor(subscription.Name(), c)
+		if err != nil {
+			return err
+		}
+
+		c, err = t.handle(ctx, subscription, c, r, next)
+		if err != nil {
+			return err
+		}
+
+		err = t.store.SaveCursor(subscription.Name(), c)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Handles record with subscription and returns cursor moved according to result
+func (t *tailRunner) handle(ctx context.Context, subscription pusu.Subscription, c cursor, r record, next int64) (cursor, error) {
+	err := pusu.HandleBlocking(ctx, subscription, pusu.NewMessage(r.Payload).WithAttributes(r.Attributes).WithContext(ctx))
+	if t.metrics != nil {
+		t.metrics.Observe(subscription, err)
+	}
+
+	if err == nil {
+		return cursor{Topic: c.Topic, Offset: next}, nil
+	}
+
+	// Handler was not called at all, deliver same record again later without counting the attempt
+	if pusu.IsRejection(err) {
+		time.Sleep(t.rejectionDelay)
+		c.Attempts--
+		return c, nil
+	}
+
+	if c.Attempts >= t.maxAttempts || errors.Is(err, pusu.ErrDeadLetter) {
+		log.Printf("Handler of subscription %s failed on last attempt, message is dead lettered: %s", subscription.Name(), err)
+		deadLetterErr := t.store.DeadLetter(subscription.Name(), r, err.Error())
+		return cursor{Topic: c.Topic, Offset: next}, deadLetterErr
+	}
+
+	log.Printf("Handler of subscription %s failed: %s", subscription.Name(), err)
+	time.Sleep(t.retryDelay * time.Duration(c.Attempts))
+	return c, nil
+}
@@ -0,0 +1,131 @@
+package local
+
+import (
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	"github.com/metglobal-compass/pusu/pusutest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestTailRunner_Run(t *testing.T) {
+	// Create fake store which has a record, then waits for a new record and fails on next read
+	fakeStore := new(fakeStore)
+	fakeStore.On("Cursor", "testing").Return(cursor{Topic: "test", Offset: 10}, nil)
+	fakeStore.On("Read", "test", int64(10)).Return(record{Payload: "hello", Attributes: map[string]string{"type": "created"}}, int64(20), nil)
+	fakeStore.On("Read", "test", int64(20)).Return(record{}, int64(20), io.EOF).Once()
+	fakeStore.On("Read", "test", int64(20)).Return(record{}, int64(20), errors.New("error"))
+	fakeStore.On("SaveCursor", "testing", mock.Anything).Return(nil)
+
+	// Call real method with a successful subscription
+	runner := newTestRunner(fakeStore)
+	subscription := new(pusutest.Subscription).WillHaveProperFields()
+	err := runner.Run(subscription)
+
+	// Runner must return error of store
+	assert.Error(t, err)
+
+	// Record must be handled with its attributes, attempt must be saved before handling and cursor must be moved after it
+	subscription.(*pusutest.Subscription).AssertCalled(t, "Handle", mock.MatchedBy(func(m *pusu.Message) bool {
+		return m.Message() == "hello" && m.Attribute("type") == "created"
+	}))
+	fakeStore.AssertCalled(t, "SaveCursor", "testing", cursor{Topic: "test", Offset: 10, Attempts: 1})
+	fakeStore.AssertCalled(t, "SaveCursor", "testing", cursor{Topic: "test", Offset: 20})
+	assert.Equal(t, int64(1), runner.metrics.Count(subscription, pusu.OutcomeSuccess))
+}
+
+func TestTailRunner_RunFailure(t *testing.T) {
+	// Create fake store which has a record on its second attempt. Store fails after saving its third attempt.
+	fakeStore := new(fakeStore)
+	fakeStore.On("Cursor", "testing").Return(cursor{Topic: "test", Offset: 10, Attempts: 1}, nil)
+	fakeStore.On("Read", "test", int64(10)).Return(record{Payload: "hello"}, int64(20), nil)
+	fakeStore.On("SaveCursor", "testing", cursor{Topic: "test", Offset: 10, Attempts: 2}).Return(nil).Twice()
+	fakeStore.On("SaveCursor", "testing", cursor{Topic: "test", Offset: 10, Attempts: 3}).Return(errors.New("error"))
+
+	// Call real method with a failing subscription
+	runner := newTestRunner(fakeStore)
+	runner.Run(new(pusutest.Subscription).WillReturnError())
+
+	// Failed record must be delivered again without moving cursor
+	fakeStore.AssertNotCalled(t, "DeadLetter", mock.Anything, mock.Anything, mock.Anything)
+	fakeStore.AssertNumberOfCalls(t, "Read", 2)
+}
+
+func TestTailRunner_RunFailureOnLastAttempt(t *testing.T) {
+	// Create fake store which has a record on its last attempt, then fails on next read
+	fakeStore := new(fakeStore)
+	fakeStore.On("Cursor", "testing").Return(cursor{Topic: "test", Offset: 10, Attempts: defaultMaxAttempts - 1}, nil)
+	fakeStore.On("Read", "test", int64(10)).Return(record{Payload: "hello"}, int64(20), nil)
+	fakeStore.On("Read", "test", int64(20)).Return(record{}, int64(20), errors.New("error"))
+	fakeStore.On("SaveCursor", "testing", mock.Anything).Return(nil)
+	fakeStore.On("DeadLetter", "testing", record{Payload: "hello"}, "error").Return(nil)
+
+	// Call real method with a failing subscription
+	runner := newTestRunner(fakeStore)
+	runner.Run(new(pusutest.Subscription).WillReturnError())
+
+	// Record must be dead lettered and cursor must be moved
+	fakeStore.AssertCalled(t, "DeadLetter", "testing", record{Payload: "hello"}, "error")
+	fakeStore.AssertCalled(t, "SaveCursor", "testing", cursor{Topic: "test", Offset: 20})
+}
+
+func TestTailRunner_RunDeadLetter(t *testing.T) {
+	// Create fake store which has a record on its first attempt, then fails on next read
+	fakeStore := new(fakeStore)
+	fakeStore.On("Cursor", "testing").Return(cursor{Topic: "test", Offset: 10}, nil)
+	fakeStore.On("Read", "test", int64(10)).Return(record{Payload: "hello"}, int64(20), nil)
+	fakeStore.On("Read", "test", int64(20)).Return(record{}, int64(20), errors.New("error"))
+	fakeStore.On("SaveCursor", "testing", mock.Anything).Return(nil)
+	fakeStore.On("DeadLetter", "testing", record{Payload: "hello"}, pusu.ErrDeadLetter.Error()).Return(nil)
+
+	// Call real method with a subscription which requires dead lettering
+	runner := newTestRunner(fakeStore)
+	runner.Run(new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(pusu.ErrDeadLetter))
+
+	// Record must be dead lettered without further attempts
+	fakeStore.AssertCalled(t, "DeadLetter", "testing", record{Payload: "hello"}, pusu.ErrDeadLetter.Error())
+	fakeStore.AssertCalled(t, "SaveCursor", "testing", cursor{Topic: "test", Offset: 20})
+}
+
+func TestTailRunner_RunRejection(t *testing.T) {
+	// Create fake store which has a record on its last attempt. Store fails after saving rejected attempt.
+	fakeStore := new(fakeStore)
+	fakeStore.On("Cursor", "testing").Return(cursor{Topic: "test", Offset: 10, Attempts: defaultMaxAttempts - 1}, nil)
+	fakeStore.On("Read", "test", int64(10)).Return(record{Payload: "hello"}, int64(20), nil)
+	fakeStore.On("SaveCursor", "testing", cursor{Topic: "test", Offset: 10, Attempts: defaultMaxAttempts}).Return(nil)
+	fakeStore.On("SaveCursor", "testing", cursor{Topic: "test", Offset: 10, Attempts: defaultMaxAttempts - 1}).
+		Return(errors.New("error"))
+
+	// Call real method with a subscription which rejects messages
+	runner := newTestRunner(fakeStore)
+	err := runner.Run(new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(pusu.ErrCircuitOpen))
+
+	// Rejected record must be delivered again without counting the attempt, even on its last attempt
+	assert.Error(t, err)
+	fakeStore.AssertNotCalled(t, "DeadLetter", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTailRunner_RunErrorOnCursor(t *testing.T) {
+	// Create fake store which has no cursor of subscription
+	fakeStore := new(fakeStore)
+	fakeStore.On("Cursor", "testing").Return(nil, errors.New("error"))
+
+	// Runner must return error
+	runner := newTestRunner(fakeStore)
+	assert.Error(t, runner.Run(new(pusutest.Subscription).WillHaveProperFields()))
+}
+
+// Creates a tail runner with given fake store and short delays
+func newTestRunner(s store) *tailRunner {
+	return &tailRunner{
+		store:          s,
+		maxAttempts:    defaultMaxAttempts,
+		retryDelay:     time.Millisecond,
+		rejectionDelay: time.Millisecond,
+		pollInterval:   time.Millisecond,
+		metrics:        new(pusu.Metrics),
+	}
+}
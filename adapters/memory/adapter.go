@@ -0,0 +1,87 @@
+// Package memory provides implementation of pub/sub workflow in process memory, for tests and local development.
+// Each subscription of a topic receives every message published after its creation which passes its filter. Failed
+// messages are delivered again after a delay and dropped after maximum attempts. Messages are lost when process exits.
+package memory
+
+import (
+	"fmt"
+	"github.com/metglobal-compass/pusu"
+	"net/url"
+)
+
+type Adapter struct {
+	// Fans out published messages to subscriptions
+	broker *broker
+
+	// Adds queue of subscription to broker
+	subscriptionAdder pusu.Creator
+
+	// Runs subscription as queue consumer
+	runner pusu.Runner
+
+	// Validates and provisions subscriptions, tracking their health and metrics
+	pusu.Provisioner
+}
+
+// Implementation of pusu.Creator interface as part of pusu.Adapter interface
+func (a *Adapter) CreateSubscription(subscription pusu.Subscription) error {
+	return a.Provision(subscription, a.subscriptionAdder)
+}
+
+// Implementation of pusu.Runner interface as part of pusu.Adapter interface
+func (a *Adapter) Run(subscription pusu.Subscription) error {
+	err := a.runner.Run(subscription)
+	return err
+}
+
+// Publishes message to topic. Message is delivered to subscriptions which exist at the time of publishing.
+func (a *Adapter) Publish(topic string, payload string) error {
+	return a.PublishMessage(topic, pusu.NewMessage(payload))
+}
+
+// Publishes message with its attributes to topic. Message is delivered to subscriptions which exist at the time of
+// publishing and whose filters its attributes pass. Payload of message must be string or []byte.
+func (a *Adapter) PublishMessage(topic string, m *pusu.Message) error {
+	var payload string
+	switch p := m.Message().(type) {
+	case string:
+		payload = p
+	case []byte:
+		payload = string(p)
+	default:
+		return fmt.Errorf("payload of message must be string or []byte, not %T", m.Message())
+	}
+
+	a.broker.Publish(topic, payload, m.Attributes())
+	return nil
+}
+
+// Creates Memory Adapter on a private broker
+func CreateAdapter() (*Adapter, error) {
+	return createAdapter(newBroker()), nil
+}
+
+func init() {
+	pusu.RegisterScheme("memory", open)
+}
+
+// Opens Memory Adapter of url in form of memory://<name>. Adapters opened with the same name share their broker,
+// so messages published through one are received by subscriptions of another.
+func open(u *url.URL) (pusu.Adapter, error) {
+	return createAdapter(sharedBroker(u.Host)), nil
+}
+
+func createAdapter(b *broker) *Adapter {
+	memoryAdapter := new(Adapter)
+	memoryAdapter.broker = b
+	memoryAdapter.subscriptionAdder = &subscriptionAdder{broker: b}
+	memoryAdapter.runner = &queueRunner{
+		broker:         b,
+		maxAttempts:    defaultMaxAttempts,
+		retryDelay:     defaultRetryDelay,
+		rejectionDelay: defaultRejectionDelay,
+		metrics:        memoryAdapter.Metrics(),
+	}
+
+	return memoryAdapter
+}
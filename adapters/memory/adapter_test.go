@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"github.com/metglobal-compass/pusu"
+	"github.com/metglobal-compass/pusu/pusutest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAdapter_Publish(t *testing.T) {
+	// Create two subscriptions of the same topic and one of another topic
+	adapter, err := CreateAdapter()
+	assert.Nil(t, err)
+	first := new(pusutest.Subscription).WithTopic("test").WithName("first").WithReturning(nil)
+	second := new(pusutest.Subscription).WithTopic("test").WithName("second").WithReturning(nil)
+	other := new(pusutest.Subscription).WithTopic("other").WithName("other").WithReturning(nil)
+	for _, subscription := range []*pusutest.Subscription{first, second, other} {
+		assert.Nil(t, adapter.CreateSubscription(subscription))
+	}
+
+	// Message must be queued for every subscription of topic
+	assert.Nil(t, adapter.Publish("test", "hello"))
+	assert.Equal(t, 1, adapter.broker.Queue("first").Len())
+	assert.Equal(t, 1, adapter.broker.Queue("second").Len())
+	assert.Equal(t, 0, adapter.broker.Queue("other").Len())
+}
+
+func TestAdapter_PublishMessageFiltered(t *testing.T) {
+	// Create subscriptions of the same topic with and without filter
+	adapter, err := CreateAdapter()
+	assert.Nil(t, err)
+	all := new(pusutest.Subscription).WithTopic("test").WithName("all").WithReturning(nil)
+	orders := new(pusutest.Subscription).WithTopic("test").WithName("orders").WithReturning(nil)
+	assert.Nil(t, adapter.CreateSubscription(all))
+	assert.Nil(t, adapter.CreateSubscription(pusu.WithFilter(orders, `attributes.kind = "order"`)))
+
+	// Message must be queued only for subscriptions whose filter it passes
+	assert.Nil(t, adapter.Publish("test", "hello"))
+	assert.Nil(t, adapter.PublishMessage("test", pusu.NewMessage("order").WithAttributes(map[string]string{"kind": "order"})))
+	assert.Equal(t, 2, adapter.broker.Queue("all").Len())
+	assert.Equal(t, 1, adapter.broker.Queue("orders").Len())
+	assert.Equal(t, map[string]string{"kind": "order"}, adapter.broker.Queue("orders").Pop().attributes)
+
+	// Invalid filters must be rejected on creation
+	invalid := new(pusutest.Subscription).WithTopic("test").WithName("invalid").WithReturning(nil)
+	assert.Error(t, adapter.CreateSubscription(pusu.WithFilter(invalid, `attributes.kind > "order"`)))
+	assert.Nil(t, adapter.broker.Queue("invalid"))
+
+	// Payloads other than string or []byte must be rejected
+	assert.Error(t, adapter.PublishMessage("test", pusu.NewMessage(1)))
+}
+
+func TestAdapter_Open(t *testing.T) {
+	// Adapters opened with the same name must share broker
+	first, err := pusu.Open("memory://shared")
+	assert.Nil(t, err)
+	second, err := pusu.Open("memory://shared")
+	assert.Nil(t, err)
+	assert.True(t, first.(*Adapter).broker == second.(*Adapter).broker, "Broker is not shared")
+
+	// Adapters opened with different names must not share broker
+	third, err := pusu.Open("memory://another")
+	assert.Nil(t, err)
+	assert.False(t, first.(*Adapter).broker == third.(*Adapter).broker, "Broker is shared")
+
+	// Runner must use the same broker
+	runner, runnerProper := first.(*Adapter).runner.(*queueRunner)
+	assert.True(t, runnerProper, "Runner is not proper")
+	assert.True(t, runner.broker == first.(*Adapter).broker, "Runner broker is not proper")
+	assert.True(t, runner.metrics == first.(*Adapter).Metrics(), "Runner metrics is not proper")
+}
@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"github.com/metglobal-compass/pusu"
+	"sync"
+)
+
+var (
+	sharedMutex sync.Mutex
+
+	// Brokers shared by adapters opened with the same name
+	sharedBrokers = make(map[string]*broker)
+)
+
+// In-process broker which fans out published messages to queues of subscriptions
+type broker struct {
+	mutex sync.RWMutex
+
+	// Queues keyed by subscription name
+	queues map[string]*queue
+
+	// Subscription names keyed by topic
+	subscriptions map[string][]string
+
+	// Filters of subscriptions keyed by subscription name. Subscriptions without filter receive every message.
+	filters map[string]*pusu.Filter
+}
+
+// Returns broker of given name, creating it on first call
+func sharedBroker(name string) *broker {
+	sharedMutex.Lock()
+	defer sharedMutex.Unlock()
+
+	b, ok := sharedBrokers[name]
+	if !ok {
+		b = newBroker()
+		sharedBrokers[name] = b
+	}
+
+	return b
+}
+
+func newBroker() *broker {
+	b := new(broker)
+	b.queues = make(map[string]*queue)
+	b.subscriptions = make(map[string][]string)
+	b.filters = make(map[string]*pusu.Filter)
+
+	return b
+}
+
+// Creates queue of subscription. Subscription receives messages published after its creation which pass its filter.
+// Filter of an existing subscription is not changed, as in Pub/Sub.
+func (b *broker) CreateSubscription(topic string, name string, filter *pusu.Filter) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, ok := b.queues[name]; ok {
+		return
+	}
+	b.queues[name] = newQueue()
+	b.filters[name] = filter
+	b.subscriptions[topic] = append(b.subscriptions[topic], name)
+}
+
+// Adds message to queue of every subscription of topic whose filter it passes
+func (b *broker) Publish(topic string, payload string, attributes map[string]string) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for _, name := range b.subscriptions[topic] {
+		if b.filters[name].Matches(attributes) {
+			b.queues[name].Push(&delivery{payload: payload, attributes: attributes})
+		}
+	}
+}
+
+// Returns queue of subscription or nil if subscription doesn't exist
+func (b *broker) Queue(name string) *queue {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	return b.queues[name]
+}
+
+// A message waiting in queue of a subscription
+type delivery struct {
+	payload    string
+	attributes map[string]string
+	attempts   int
+}
+
+// Unbounded FIFO queue of deliveries
+type queue struct {
+	mutex      sync.Mutex
+	cond       *sync.Cond
+	deliveries []*delivery
+}
+
+func newQueue() *queue {
+	q := new(queue)
+	q.cond = sync.NewCond(&q.mutex)
+
+	return q
+}
+
+func (q *queue) Push(d *delivery) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.deliveries = append(q.deliveries, d)
+	q.cond.Signal()
+}
+
+// Blocks until a delivery is available and removes it from queue
+func (q *queue) Pop() *delivery {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for len(q.deliveries) == 0 {
+		q.cond.Wait()
+	}
+	d := q.deliveries[0]
+	q.deliveries[0] = nil
+	q.deliveries = q.deliveries[1:]
+
+	return d
+}
+
+// Returns number of waiting deliveries
+func (q *queue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.deliveries)
+}
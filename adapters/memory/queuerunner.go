@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/metglobal-compass/pusu"
+	"log"
+	"time"
+)
+
+const (
+	// Messages are dropped after this many failed attempts
+	defaultMaxAttempts = 5
+
+	// Delay of a failed message. It is multiplied by attempt count of message.
+	defaultRetryDelay = 100 * time.Millisecond
+
+	// Delay of a message rejected by subscription (e.g. circuit is open)
+	defaultRejectionDelay = 100 * time.Millisecond
+)
+
+// Queue consuming runner which implements pusu.Runner interface
+type queueRunner struct {
+	broker *broker
+
+	maxAttempts    int
+	retryDelay     time.Duration
+	rejectionDelay time.Duration
+
+	// Counts outcomes of handled messages. Optional.
+	metrics *pusu.Metrics
+}
+
+// Handles messages of subscription one by one, forever. A failed message is added to queue again after a delay
+// and dropped when its last attempt fails.
+func (q *queueRunner) Run(subscription pusu.Subscription) error {
+	queue := q.broker.Queue(subscription.Name())
+	if queue == nil {
+		return fmt.Errorf("subscription %s is not created", subscription.Name())
+	}
+
+	ctx := context.Background()
+	for {
+		q.handle(ctx, subscription, queue, queue.Pop())
+	}
+}
+
+// Handles delivery with subscription and schedules its redelivery on failure
+func (q *queueRunner) handle(ctx context.Context, subscription pusu.Subscription, queue *queue, d *delivery) {
+	err := pusu.HandleBlocking(ctx, subscription, pusu.NewMessage(d.payload).WithAttributes(d.attributes).WithContext(ctx))
+	if q.metrics != nil {
+		q.metrics.Observe(subscription, err)
+	}
+
+	if err == nil {
+		return
+	}
+
+	// Handler was not called at all, deliver same message again later without counting the attempt
+	if pusu.IsRejection(err) {
+		time.AfterFunc(q.rejectionDelay, func() { queue.Push(d) })
+		return
+	}
+
+	d.attempts++
+	if d.attempts >= q.maxAttempts || errors.Is(err, pusu.ErrDeadLetter) {
+		log.Printf("Handler of subscription %s failed on last attempt, message is dropped: %s", subscription.Name(), err)
+		return
+	}
+
+	log.Printf("Handler of subscription %s failed: %s", subscription.Name(), err)
+	time.AfterFunc(q.retryDelay*time.Duration(d.attempts), func() { queue.Push(d) })
+}
@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"github.com/metglobal-compass/pusu"
+	"github.com/metglobal-compass/pusu/pusutest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+func TestQueueRunner_Run(t *testing.T) {
+	// Create subscription and publish a message
+	b := newBroker()
+	b.CreateSubscription("test", "testing", nil)
+	b.Publish("test", "hello", nil)
+
+	// Call real method in background with a successful subscription
+	runner := newTestRunner(b)
+	subscription := new(pusutest.Subscription).WillHaveProperFields()
+	go runner.Run(subscription)
+
+	// Message must be handled once
+	assert.Eventually(t, func() bool {
+		return runner.metrics.Count(subscription, pusu.OutcomeSuccess) == 1
+	}, time.Second, time.Millisecond)
+	subscription.(*pusutest.Subscription).AssertCalled(t, "Handle", mock.MatchedBy(func(m *pusu.Message) bool {
+		return m.Message() == "hello"
+	}))
+}
+
+func TestQueueRunner_RunFailure(t *testing.T) {
+	// Create subscription and publish a message
+	b := newBroker()
+	b.CreateSubscription("test", "testing", nil)
+	b.Publish("test", "hello", nil)
+
+	// Call real method in background with a failing subscription
+	runner := newTestRunner(b)
+	subscription := new(pusutest.Subscription).WillReturnError()
+	go runner.Run(subscription)
+
+	// Message must be delivered again until its last attempt, then dropped
+	assert.Eventually(t, func() bool {
+		return runner.metrics.Count(subscription, pusu.OutcomeFailure) == defaultMaxAttempts
+	}, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int64(defaultMaxAttempts), runner.metrics.Count(subscription, pusu.OutcomeFailure))
+	assert.Equal(t, 0, b.Queue("testing").Len())
+}
+
+func TestQueueRunner_RunRejection(t *testing.T) {
+	// Create subscription and publish a message
+	b := newBroker()
+	b.CreateSubscription("test", "testing", nil)
+	b.Publish("test", "hello", nil)
+
+	// Call real method in background with a subscription which rejects messages
+	runner := newTestRunner(b)
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(pusu.ErrCircuitOpen)
+	go runner.Run(subscription)
+
+	// Rejected message must be delivered again without counting the attempt
+	assert.Eventually(t, func() bool {
+		return runner.metrics.Count(subscription, pusu.OutcomeCircuitOpen) > defaultMaxAttempts
+	}, time.Second, time.Millisecond)
+}
+
+func TestQueueRunner_RunErrorOnMissingSubscription(t *testing.T) {
+	// Runner must return error when subscription is not created
+	runner := newTestRunner(newBroker())
+	assert.Error(t, runner.Run(new(pusutest.Subscription).WillHaveProperFields()))
+}
+
+// Creates a queue runner on given broker with short delays
+func newTestRunner(b *broker) *queueRunner {
+	return &queueRunner{
+		broker:         b,
+		maxAttempts:    defaultMaxAttempts,
+		retryDelay:     time.Millisecond,
+		rejectionDelay: time.Millisecond,
+		metrics:        new(pusu.Metrics),
+	}
+}
+
+func TestBroker_CreateSubscription(t *testing.T) {
+	b := newBroker()
+
+	// Message published before subscription creation must not be delivered
+	b.Publish("test", "before", nil)
+	b.CreateSubscription("test", "testing", nil)
+	b.Publish("test", "after", nil)
+
+	// Creating an existing subscription again must keep its queue
+	b.CreateSubscription("test", "testing", nil)
+	b.Publish("test", "again", nil)
+
+	queue := b.Queue("testing")
+	assert.Equal(t, 2, queue.Len())
+	assert.Equal(t, "after", queue.Pop().payload)
+	assert.Equal(t, "again", queue.Pop().payload)
+	assert.Nil(t, b.Queue("unknown"))
+}
+
+func TestQueue_PopBlocks(t *testing.T) {
+	q := newQueue()
+	popped := make(chan *delivery)
+	go func() { popped <- q.Pop() }()
+
+	// Pop must wait for a delivery
+	select {
+	case <-popped:
+		t.Error("Pop must block on empty queue")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	q.Push(&delivery{payload: "hello"})
+	assert.Equal(t, "hello", (<-popped).payload)
+}
@@ -0,0 +1,27 @@
+package memory
+
+import (
+	"github.com/metglobal-compass/pusu"
+)
+
+type subscriptionAdder struct {
+	broker *broker
+}
+
+// Implementation of internal Creator interface for Memory Adapter
+func (s *subscriptionAdder) CreateSubscription(subscription pusu.Subscription) error {
+	// Filter is validated as Pub/Sub would do, so invalid filters fail in tests as well
+	expression, _ := pusu.FilterOf(subscription)
+	filter, err := pusu.ParseFilter(expression)
+	if err != nil {
+		return err
+	}
+
+	s.broker.CreateSubscription(subscription.Topic(), subscription.Name(), filter)
+	return nil
+}
+
+// Implementation of pusu.FilterEnforcer interface, since broker delivers only messages which pass filter
+func (s *subscriptionAdder) EnforcesFilters() bool {
+	return true
+}
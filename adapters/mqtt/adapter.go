@@ -0,0 +1,85 @@
+// Package mqtt provides implementation of pub/sub workflow on MQTT brokers (e.g. Mosquitto).
+// Topic of a subscription is an MQTT topic filter, wildcards allowed, and name of a subscription is a shared
+// subscription group ($share/<name>/<topic>), so each message is handled by a single subscriber of the group.
+// Messages are received with QoS 1 on a persistent session, handled one by one in order of receipt and acknowledged
+// only after successful handling. A message which fails maximum attempts or requires dead lettering is logged and
+// acknowledged, since MQTT has no dead letters. Messages have no attributes, since paho client speaks MQTT 3.1.1 which
+// has no user properties, so routers with routes by attribute are rejected. Client id of a subscriber is
+// <subscription>-<instance>, where instance defaults to hostname and process id and may be set with SetInstance.
+package mqtt
+
+import (
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	"net/url"
+)
+
+type Adapter struct {
+	// Joins shared subscription group with persistent session
+	subscriptionAdder pusu.Creator
+
+	// Runs subscription on persistent session
+	runner pusu.Runner
+
+	// Connects sessions of both subscription adder and runner
+	client *pahoClientWrapper
+
+	// Validates and provisions subscriptions, tracking their health and metrics
+	pusu.Provisioner
+}
+
+// Implementation of pusu.Creator interface as part of pusu.Adapter interface
+func (a *Adapter) CreateSubscription(subscription pusu.Subscription) error {
+	return a.Provision(subscription, a.subscriptionAdder)
+}
+
+// Implementation of pusu.Runner interface as part of pusu.Adapter interface
+func (a *Adapter) Run(subscription pusu.Subscription) error {
+	err := a.runner.Run(subscription)
+	return err
+}
+
+// Replaces instance of subscriber in client ids, which are <subscription>-<instance>. Defaults to hostname and process
+// id, so replicas on the same host do not take over sessions of each other, but a restarted subscriber starts a new
+// session and messages queued for its earlier session are delivered only when it resumes. Set a stable instance unique
+// among replicas (e.g. pod name of a StatefulSet) to resume the same session across restarts. Must be called before
+// subscriptions are created.
+func (a *Adapter) SetInstance(instance string) error {
+	if instance == "" {
+		return errors.New("instance must not be empty")
+	}
+
+	a.client.instance = instance
+	return nil
+}
+
+// Creates MQTT Adapter
+// broker: MQTT broker url. (Ex: tcp://localhost:1883)
+func CreateAdapter(broker string) (*Adapter, error) {
+	// Validate parameters
+	if broker == "" {
+		return nil, errors.New("broker must not be empty")
+	}
+	client := &pahoClientWrapper{broker: broker, instance: defaultInstance()}
+
+	mqttAdapter := &Adapter{client: client}
+	mqttAdapter.subscriptionAdder = &subscriptionAdder{client: client}
+	mqttAdapter.runner = &sessionRunner{
+		client:      client,
+		retryDelay:  defaultRetryDelay,
+		maxAttempts: defaultMaxAttempts,
+		metrics:     mqttAdapter.Metrics(),
+	}
+
+	return mqttAdapter, nil
+}
+
+func init() {
+	pusu.RegisterScheme("mqtt", open)
+	pusu.RegisterScheme("mqtts", open)
+}
+
+// Opens MQTT Adapter of url in form of mqtt://<host>:<port> or mqtts://<host>:<port>
+func open(u *url.URL) (pusu.Adapter, error) {
+	return CreateAdapter(u.String())
+}
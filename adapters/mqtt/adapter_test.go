@@ -0,0 +1,52 @@
+package mqtt
+
+import (
+	"github.com/metglobal-compass/pusu"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAdapter_CreateAdapter(t *testing.T) {
+	// Call real method and check each interfaces have proper types
+	adapter, err := CreateAdapter("tcp://localhost:1883")
+
+	assert.Nil(t, err)
+
+	subscriptionAdder, subscriptionAdderProper := adapter.subscriptionAdder.(*subscriptionAdder)
+	assert.True(t, subscriptionAdderProper, "Subscription adder is not proper")
+
+	pahoClient, pahoClientProper := subscriptionAdder.client.(*pahoClientWrapper)
+	assert.True(t, pahoClientProper, "Paho client is not proper")
+	assert.Equal(t, defaultInstance(), pahoClient.instance)
+
+	sessionRunner, sessionRunnerProper := adapter.runner.(*sessionRunner)
+	assert.True(t, sessionRunnerProper, "Runner is not proper")
+	assert.True(t, sessionRunner.metrics == adapter.Metrics(), "Runner metrics is not proper")
+	assert.True(t, sessionRunner.client == subscriptionAdder.client, "Runner client is not proper")
+}
+
+func TestAdapter_SetInstance(t *testing.T) {
+	adapter, err := CreateAdapter("tcp://localhost:1883")
+	assert.Nil(t, err)
+
+	// Instance must be set on client shared by subscription adder and runner
+	assert.Nil(t, adapter.SetInstance("subscriber-0"))
+	assert.Equal(t, "subscriber-0", adapter.runner.(*sessionRunner).client.(*pahoClientWrapper).instance)
+
+	// Empty instance must not be accepted
+	assert.Error(t, adapter.SetInstance(""))
+	assert.Equal(t, "subscriber-0", adapter.client.instance)
+}
+
+func TestAdapter_CreateAdapterErrorWithEmptyBroker(t *testing.T) {
+	// Call real method without broker
+	_, err := CreateAdapter("")
+	assert.Error(t, err)
+}
+
+func TestAdapter_Open(t *testing.T) {
+	// Open adapter by broker url
+	adapter, err := pusu.Open("mqtt://localhost:1883")
+	assert.Nil(t, err)
+	assert.Equal(t, "mqtt://localhost:1883", adapter.(*Adapter).subscriptionAdder.(*subscriptionAdder).client.(*pahoClientWrapper).broker)
+}
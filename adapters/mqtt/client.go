@@ -0,0 +1,23 @@
+package mqtt
+
+// MQTT broker operations used while provisioning and running subscriptions
+type client interface {
+	// Connects with persistent session of subscriber of subscription, so unacknowledged messages are delivered again
+	// when session resumes
+	Connect(subscription string) (connection, error)
+}
+
+// Connection of a persistent session
+type connection interface {
+	// Subscribes to topic filter with QoS 1. Handler is called sequentially in order of receipt, so messages are
+	// acknowledged in that order as MQTT requires.
+	Subscribe(filter string, handler func(m message)) error
+	Disconnect()
+}
+
+// Received QoS 1 message
+type message interface {
+	Topic() string
+	Payload() []byte
+	Ack()
+}
@@ -0,0 +1,120 @@
+package mqtt
+
+import (
+	pahomqtt "github.com/eclipse/paho.mqtt.golang"
+	"sync"
+	"time"
+)
+
+const (
+	// QoS of subscriptions. Messages are acknowledged only after successful handling.
+	qos = 1
+
+	// Time given to in-flight work to complete on disconnection
+	disconnectQuiesce = 250 * time.Millisecond
+)
+
+// Paho client wrapper which implements client interface
+type pahoClientWrapper struct {
+	broker string
+
+	// Identifies subscriber among replicas of subscription in client ids
+	instance string
+}
+
+func (p *pahoClientWrapper) Connect(subscription string) (connection, error) {
+	options := pahomqtt.NewClientOptions().
+		AddBroker(p.broker).
+		SetClientID(clientId(subscription, p.instance)).
+		SetCleanSession(false).
+		SetAutoAckDisabled(true).
+		SetAutoReconnect(true).
+		// Messages are routed in order of receipt and queued, since handlers of ordered routing must not block
+		SetOrderMatters(true)
+
+	c := pahomqtt.NewClient(options)
+	token := c.Connect()
+	token.Wait()
+	if token.Error() != nil {
+		return nil, token.Error()
+	}
+
+	return &pahoConnection{client: c, done: make(chan struct{})}, nil
+}
+
+// Paho client which implements connection interface
+type pahoConnection struct {
+	client pahomqtt.Client
+
+	// Closed on disconnection to stop delivering queued messages
+	done chan struct{}
+}
+
+func (p *pahoConnection) Subscribe(filter string, handler func(m message)) error {
+	q := &queue{ready: make(chan struct{}, 1)}
+	token := p.client.Subscribe(filter, qos, func(_ pahomqtt.Client, m pahomqtt.Message) {
+		q.push(m)
+	})
+	token.Wait()
+	if token.Error() != nil {
+		return token.Error()
+	}
+
+	go q.deliver(p.done, handler)
+	return nil
+}
+
+func (p *pahoConnection) Disconnect() {
+	close(p.done)
+	p.client.Disconnect(uint(disconnectQuiesce / time.Millisecond))
+}
+
+// Unbounded queue of received messages which are delivered to handler one by one in order of receipt
+type queue struct {
+	mutex    sync.Mutex
+	messages []message
+
+	// Signals queued messages to deliver
+	ready chan struct{}
+}
+
+// Queues message without blocking routing of paho client
+func (q *queue) push(m message) {
+	q.mutex.Lock()
+	q.messages = append(q.messages, m)
+	q.mutex.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Delivers queued messages to handler until done is closed
+func (q *queue) deliver(done <-chan struct{}, handler func(m message)) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-q.ready:
+		}
+
+		for {
+			q.mutex.Lock()
+			if len(q.messages) == 0 {
+				q.mutex.Unlock()
+				break
+			}
+			m := q.messages[0]
+			q.messages = q.messages[1:]
+			q.mutex.Unlock()
+
+			select {
+			case <-done:
+				return
+			default:
+				handler(m)
+			}
+		}
+	}
+}
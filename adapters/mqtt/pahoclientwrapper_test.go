@@ -0,0 +1,118 @@
+package mqtt
+
+import (
+	pahomqtt "github.com/eclipse/paho.mqtt.golang"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPahoClientWrapper(t *testing.T) {
+	// Connect to embedded broker
+	broker := runBroker(t)
+	wrapper := &pahoClientWrapper{broker: broker}
+	c, err := wrapper.Connect("testing-client")
+	assert.Nil(t, err)
+	defer c.Disconnect()
+
+	// Subscribe to shared subscription of a wildcard filter
+	received := make(chan message, 1)
+	err = c.Subscribe("$share/testing/devices/+/telemetry", func(m message) {
+		received <- m
+	})
+	assert.Nil(t, err)
+
+	// Publish a message to a matching topic
+	publisher := pahomqtt.NewClient(pahomqtt.NewClientOptions().AddBroker(broker).SetClientID("publisher"))
+	token := publisher.Connect()
+	token.Wait()
+	assert.Nil(t, token.Error())
+	defer publisher.Disconnect(0)
+	token = publisher.Publish("devices/1/telemetry", qos, false, "payload")
+	token.Wait()
+	assert.Nil(t, token.Error())
+
+	// Published message must be received
+	select {
+	case m := <-received:
+		assert.Equal(t, "payload", string(m.Payload()))
+		m.Ack()
+	case <-time.After(5 * time.Second):
+		t.Error("Message is not received")
+	}
+}
+
+func TestPahoClientWrapper_Order(t *testing.T) {
+	// Connect to embedded broker and subscribe with a slow handler
+	broker := runBroker(t)
+	wrapper := &pahoClientWrapper{broker: broker}
+	c, err := wrapper.Connect("testing-client")
+	assert.Nil(t, err)
+	defer c.Disconnect()
+
+	received := make(chan string, 10)
+	err = c.Subscribe("$share/testing/test", func(m message) {
+		time.Sleep(10 * time.Millisecond)
+		received <- string(m.Payload())
+		m.Ack()
+	})
+	assert.Nil(t, err)
+
+	// Publish messages in order
+	publisher := pahomqtt.NewClient(pahomqtt.NewClientOptions().AddBroker(broker).SetClientID("publisher"))
+	token := publisher.Connect()
+	token.Wait()
+	assert.Nil(t, token.Error())
+	defer publisher.Disconnect(0)
+
+	for i := 0; i < 5; i++ {
+		token = publisher.Publish("test", qos, false, strconv.Itoa(i))
+		token.Wait()
+		assert.Nil(t, token.Error())
+	}
+
+	// Messages must be handled one by one in order of receipt
+	for i := 0; i < 5; i++ {
+		select {
+		case payload := <-received:
+			assert.Equal(t, strconv.Itoa(i), payload)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Message is not received")
+		}
+	}
+}
+
+func TestPahoClientWrapper_ConnectError(t *testing.T) {
+	// Connection to a closed port must fail
+	wrapper := &pahoClientWrapper{broker: "tcp://127.0.0.1:1"}
+	_, err := wrapper.Connect("testing-client")
+	assert.Error(t, err)
+}
+
+// Runs an embedded MQTT broker for the duration of test and returns its url
+func runBroker(t *testing.T) string {
+	// Find a free port
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	l.Close()
+
+	server := mochi.New(&mochi.Options{InlineClient: true})
+	server.AddHook(new(auth.AllowHook), nil)
+	err = server.AddListener(listeners.NewTCP(listeners.Config{ID: "tcp", Address: address}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go server.Serve()
+	t.Cleanup(func() { server.Close() })
+
+	return "tcp://" + address
+}
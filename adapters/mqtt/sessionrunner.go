@@ -0,0 +1,113 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	"log"
+	"time"
+)
+
+const (
+	// Session is resumed after this delay when a message is not handled, so broker delivers it again
+	defaultRetryDelay = 5 * time.Second
+
+	// Message is dropped after this many failed attempts, so it doesn't block messages behind it forever
+	defaultMaxAttempts = 5
+)
+
+// Persistent session runner which implements pusu.Runner interface
+type sessionRunner struct {
+	client client
+
+	retryDelay time.Duration
+
+	// Failed attempts of a message before it is acknowledged without handling
+	maxAttempts int
+
+	// Counts outcomes of handled messages. Optional.
+	metrics *pusu.Metrics
+}
+
+// Failed attempts of the message at head of session. Messages are handled in order and session is resumed on
+// failure, so the failed message is redelivered first.
+type attempts struct {
+	key   string
+	count int
+}
+
+// Receives messages of subscription until connection fails. A message is acknowledged after successful handling.
+// MQTT has no negative acknowledgement, so when a message is not handled, remaining messages of session are left
+// unacknowledged, session is resumed after retry delay and broker delivers unacknowledged messages again. Messages
+// which fail maximum attempts or require dead lettering are logged and acknowledged, since MQTT has no dead letters.
+func (s *sessionRunner) Run(subscription pusu.Subscription) error {
+	ctx := context.Background()
+	a := new(attempts)
+
+	for {
+		c, err := s.client.Connect(subscription.Name())
+		if err != nil {
+			return err
+		}
+
+		// Acknowledgements must follow order of receipt, so nothing is handled after a failure until session resumes
+		failed := make(chan struct{})
+		handler := func(m message) {
+			select {
+			case <-failed:
+				return
+			default:
+			}
+
+			if !s.handle(ctx, subscription, m, a) {
+				close(failed)
+			}
+		}
+
+		err = c.Subscribe(sharedFilter(subscription), handler)
+		if err != nil {
+			c.Disconnect()
+			return err
+		}
+
+		<-failed
+		time.Sleep(s.retryDelay)
+		c.Disconnect()
+	}
+}
+
+// Handles message with subscription and acknowledges it on success. Reports whether message is acknowledged.
+func (s *sessionRunner) handle(ctx context.Context, subscription pusu.Subscription, m message, a *attempts) bool {
+	err := pusu.HandleBlocking(ctx, subscription, pusu.NewMessage(string(m.Payload())).WithContext(ctx))
+	if s.metrics != nil {
+		s.metrics.Observe(subscription, err)
+	}
+
+	if err == nil {
+		*a = attempts{}
+		m.Ack()
+		return true
+	}
+
+	// Rejected messages are not attempted
+	if pusu.IsRejection(err) {
+		return false
+	}
+
+	log.Printf("Handler of subscription %s failed: %s", subscription.Name(), err)
+
+	key := m.Topic() + "\x00" + string(m.Payload())
+	if a.key != key {
+		*a = attempts{key: key}
+	}
+	a.count++
+
+	if errors.Is(err, pusu.ErrDeadLetter) || a.count >= s.maxAttempts {
+		log.Printf("Message of subscription %s is dropped after %d attempts: %s", subscription.Name(), a.count, m.Payload())
+		*a = attempts{}
+		m.Ack()
+		return true
+	}
+
+	return false
+}
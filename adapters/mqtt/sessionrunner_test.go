@@ -0,0 +1,184 @@
+package mqtt
+
+import (
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	"github.com/metglobal-compass/pusu/pusutest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+func TestSessionRunner_Run(t *testing.T) {
+	// Create fake connection which delivers a message on subscription
+	fakeMessage := new(fakeMessage)
+	fakeMessage.On("Topic").Return("test")
+	fakeMessage.On("Payload").Return([]byte("hello"))
+	fakeMessage.On("Ack").Return()
+	fakeConnection := new(fakeConnection)
+	fakeConnection.On("Subscribe", "$share/testing/test", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(func(m message))(fakeMessage)
+	})
+	fakeConnection.On("Disconnect").Return()
+
+	// Runner must stop when session can't be resumed
+	subscription := new(pusutest.Subscription).WillHaveProperFields()
+	fakeClient := new(fakeClient)
+	fakeClient.On("Connect", "testing").Return(fakeConnection, nil).Once()
+	fakeClient.On("Connect", "testing").Return(nil, errors.New("error"))
+
+	// Call real method in background with a successful subscription
+	runner := newTestRunner(fakeClient)
+	go runner.Run(subscription)
+
+	// Message must be handled and acknowledged, session must be kept
+	assert.Eventually(t, func() bool {
+		return runner.metrics.Count(subscription, pusu.OutcomeSuccess) == 1
+	}, time.Second, time.Millisecond)
+	fakeMessage.AssertCalled(t, "Ack")
+	fakeConnection.AssertNotCalled(t, "Disconnect")
+}
+
+func TestSessionRunner_RunFailure(t *testing.T) {
+	// Create fake connection which delivers a message on subscription
+	fakeMessage := new(fakeMessage)
+	fakeMessage.On("Topic").Return("test")
+	fakeMessage.On("Payload").Return([]byte("hello"))
+	fakeConnection := new(fakeConnection)
+	fakeConnection.On("Subscribe", "$share/testing/test", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(func(m message))(fakeMessage)
+	})
+	fakeConnection.On("Disconnect").Return()
+
+	// Resuming session fails after first failure
+	subscription := new(pusutest.Subscription).WillReturnError()
+	fakeClient := new(fakeClient)
+	fakeClient.On("Connect", "testing").Return(fakeConnection, nil).Once()
+	fakeClient.On("Connect", "testing").Return(nil, errors.New("error"))
+
+	// Call real method with a failing subscription
+	runner := newTestRunner(fakeClient)
+	err := runner.Run(subscription)
+
+	// Failed message must not be acknowledged and session must be resumed for redelivery
+	assert.Error(t, err)
+	fakeMessage.AssertNotCalled(t, "Ack")
+	fakeConnection.AssertCalled(t, "Disconnect")
+	fakeClient.AssertNumberOfCalls(t, "Connect", 2)
+}
+
+func TestSessionRunner_RunErrorOnSubscribe(t *testing.T) {
+	// Create fake connection which fails while subscribing
+	fakeConnection := new(fakeConnection)
+	fakeConnection.On("Subscribe", mock.Anything, mock.Anything).Return(errors.New("error"))
+	fakeConnection.On("Disconnect").Return()
+	fakeClient := new(fakeClient)
+	fakeClient.On("Connect", mock.Anything).Return(fakeConnection, nil)
+
+	// Runner must return error and close connection
+	runner := newTestRunner(fakeClient)
+	assert.Error(t, runner.Run(new(pusutest.Subscription).WillHaveProperFields()))
+	fakeConnection.AssertCalled(t, "Disconnect")
+}
+
+func TestSessionRunner_RunSkipsAfterFailure(t *testing.T) {
+	// Create fake connection which delivers a failing message and another message behind it
+	failing := new(fakeMessage)
+	failing.On("Topic").Return("test")
+	failing.On("Payload").Return([]byte("fail"))
+	next := new(fakeMessage)
+	next.On("Topic").Return("test")
+	next.On("Payload").Return([]byte("hello"))
+	fakeConnection := new(fakeConnection)
+	fakeConnection.On("Subscribe", "$share/testing/test", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(func(m message))(failing)
+		args.Get(1).(func(m message))(next)
+	})
+	fakeConnection.On("Disconnect").Return()
+
+	subscription := new(pusutest.Subscription).WillReturnError()
+	fakeClient := new(fakeClient)
+	fakeClient.On("Connect", "testing").Return(fakeConnection, nil).Once()
+	fakeClient.On("Connect", "testing").Return(nil, errors.New("error"))
+
+	// Call real method with a failing subscription
+	runner := newTestRunner(fakeClient)
+	runner.Run(subscription)
+
+	// Message behind failed one must be left for redelivery in order, without being handled or acknowledged
+	subscription.AssertNumberOfCalls(t, "Handle", 1)
+	next.AssertNotCalled(t, "Ack")
+}
+
+func TestSessionRunner_RunMaxAttempts(t *testing.T) {
+	// Create fake connection which delivers same message on each session
+	fakeMessage := new(fakeMessage)
+	fakeMessage.On("Topic").Return("test")
+	fakeMessage.On("Payload").Return([]byte("hello"))
+	acked := make(chan struct{}, 1)
+	fakeMessage.On("Ack").Return().Run(func(args mock.Arguments) { acked <- struct{}{} })
+	fakeConnection := new(fakeConnection)
+	fakeConnection.On("Subscribe", "$share/testing/test", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(func(m message))(fakeMessage)
+	})
+	fakeConnection.On("Disconnect").Return()
+
+	subscription := new(pusutest.Subscription).WillReturnError()
+	fakeClient := new(fakeClient)
+	fakeClient.On("Connect", "testing").Return(fakeConnection, nil).Times(defaultMaxAttempts)
+	fakeClient.On("Connect", "testing").Return(nil, errors.New("error"))
+
+	// Call real method in background with a failing subscription
+	runner := newTestRunner(fakeClient)
+	go runner.Run(subscription)
+
+	// Message must be acknowledged and dropped on its last attempt, so session is kept
+	select {
+	case <-acked:
+	case <-time.After(time.Second):
+		t.Fatal("Message is not acknowledged")
+	}
+	assert.Equal(t, int64(defaultMaxAttempts), runner.metrics.Count(subscription, pusu.OutcomeFailure))
+}
+
+func TestSessionRunner_RunDeadLetter(t *testing.T) {
+	// Create fake connection which delivers a message on subscription
+	fakeMessage := new(fakeMessage)
+	fakeMessage.On("Topic").Return("test")
+	fakeMessage.On("Payload").Return([]byte("hello"))
+	acked := make(chan struct{}, 1)
+	fakeMessage.On("Ack").Return().Run(func(args mock.Arguments) { acked <- struct{}{} })
+	fakeConnection := new(fakeConnection)
+	fakeConnection.On("Subscribe", "$share/testing/test", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		args.Get(1).(func(m message))(fakeMessage)
+	})
+	fakeConnection.On("Disconnect").Return()
+
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(pusu.ErrDeadLetter)
+	fakeClient := new(fakeClient)
+	fakeClient.On("Connect", "testing").Return(fakeConnection, nil)
+
+	// Call real method in background with a subscription which requires dead lettering
+	runner := newTestRunner(fakeClient)
+	go runner.Run(subscription)
+
+	// Message must be dropped without further attempts and session must be kept
+	select {
+	case <-acked:
+	case <-time.After(time.Second):
+		t.Fatal("Message is not acknowledged")
+	}
+	assert.Equal(t, int64(1), runner.metrics.Count(subscription, pusu.OutcomeDeadLetter))
+	fakeConnection.AssertNotCalled(t, "Disconnect")
+}
+
+// Creates a session runner with given fake client
+func newTestRunner(c client) *sessionRunner {
+	return &sessionRunner{
+		client:      c,
+		retryDelay:  time.Millisecond,
+		maxAttempts: defaultMaxAttempts,
+		metrics:     new(pusu.Metrics),
+	}
+}
@@ -0,0 +1,99 @@
+package mqtt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/metglobal-compass/pusu"
+	"os"
+	"strings"
+)
+
+const (
+	// Length of client ids which MQTT 3.1.1 servers must accept
+	maxClientIdLength = 23
+
+	// Hex digits of hash in shortened client ids
+	clientIdHashLength = 8
+)
+
+type subscriptionAdder struct {
+	client client
+}
+
+// Implementation of internal Creator interface for MQTT Adapter.
+// Validates topic filter and joins shared subscription group with persistent session of subscriber, so messages
+// published until subscriber runs are kept by broker. Routers with routes by attribute are rejected, since MQTT 3.1.1
+// messages have no attributes.
+func (s *subscriptionAdder) CreateSubscription(subscription pusu.Subscription) error {
+	err := validateFilter(subscription.Topic())
+	if err != nil {
+		return err
+	}
+	if pusu.RoutesByAttribute(subscription) {
+		return fmt.Errorf("subscription %s routes by attribute, but MQTT messages have no attributes", subscription.Name())
+	}
+	if strings.ContainsAny(subscription.Name(), "/+#") {
+		return fmt.Errorf("subscription name %q must not contain '/', '+' or '#'", subscription.Name())
+	}
+
+	c, err := s.client.Connect(subscription.Name())
+	if err != nil {
+		return err
+	}
+	defer c.Disconnect()
+
+	// Messages received here are not acknowledged, so they are delivered again when runner resumes session
+	return c.Subscribe(sharedFilter(subscription), func(m message) {})
+}
+
+// Returns shared subscription filter of subscription. Brokers deliver each message of topic filter to
+// a single subscriber of the group.
+func sharedFilter(subscription pusu.Subscription) string {
+	return fmt.Sprintf("$share/%s/%s", subscription.Name(), subscription.Topic())
+}
+
+// Returns client id of subscriber of subscription on given instance. Ids longer than MQTT 3.1.1 servers must accept
+// are shortened with a hash of the whole id.
+func clientId(subscription string, instance string) string {
+	id := fmt.Sprintf("%s-%s", subscription, instance)
+	if len(id) <= maxClientIdLength {
+		return id
+	}
+
+	hash := sha256.Sum256([]byte(id))
+	suffix := "-" + hex.EncodeToString(hash[:])[:clientIdHashLength]
+	prefix := strings.ToValidUTF8(id[:maxClientIdLength-len(suffix)], "")
+	return prefix + suffix
+}
+
+// Returns default instance of subscriber, which is its hostname and process id. Replicas on the same host get distinct
+// client ids, but restarted subscriber starts a new session.
+func defaultInstance() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// Validates topic filter. Single level wildcard '+' must occupy a whole level and multi level wildcard '#' must be
+// the last level.
+func validateFilter(filter string) error {
+	if filter == "" {
+		return errors.New("topic filter must not be empty")
+	}
+	if strings.HasPrefix(filter, "$share/") {
+		return errors.New("topic filter must not be a shared subscription filter")
+	}
+
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.Contains(level, "+") && level != "+" {
+			return fmt.Errorf("wildcard '+' must occupy a whole level of topic filter %q", filter)
+		}
+		if strings.Contains(level, "#") && (level != "#" || i != len(levels)-1) {
+			return fmt.Errorf("wildcard '#' must be the last level of topic filter %q", filter)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package mqtt
+
+import (
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	"github.com/metglobal-compass/pusu/pusutest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestSubscriptionAdder_CreateSubscription(t *testing.T) {
+	// Create fake mocked client
+	subscription := new(pusutest.Subscription).WillHaveProperFields()
+	fakeConnection := new(fakeConnection)
+	fakeConnection.On("Subscribe", "$share/testing/test", mock.Anything).Return(nil)
+	fakeConnection.On("Disconnect").Return()
+	fakeClient := new(fakeClient)
+	fakeClient.On("Connect", "testing").Return(fakeConnection, nil)
+
+	// Call real method
+	subscriptionAdder := &subscriptionAdder{client: fakeClient}
+	err := subscriptionAdder.CreateSubscription(subscription)
+
+	// Session of subscriber must join shared subscription group
+	assert.Nil(t, err)
+	fakeClient.AssertExpectations(t)
+	fakeConnection.AssertExpectations(t)
+}
+
+func TestSubscriptionAdder_CreateSubscriptionErrorOnConnect(t *testing.T) {
+	// Create fake mocked client. In this case, we get an error while connecting to broker
+	fakeClient := new(fakeClient)
+	fakeClient.On("Connect", mock.Anything).Return(nil, errors.New("error"))
+
+	// Call real method
+	subscriptionAdder := &subscriptionAdder{client: fakeClient}
+	err := subscriptionAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Got an error
+	assert.Error(t, err)
+}
+
+func TestSubscriptionAdder_CreateSubscriptionErrorOnInvalidFilter(t *testing.T) {
+	// Call real method with an invalid topic filter
+	fakeClient := new(fakeClient)
+	subscriptionAdder := &subscriptionAdder{client: fakeClient}
+	err := subscriptionAdder.CreateSubscription(new(pusutest.Subscription).WithTopic("devices/#/telemetry").WithName("testing"))
+
+	// Got an error without connecting to broker
+	assert.Error(t, err)
+	fakeClient.AssertNotCalled(t, "Connect", mock.Anything)
+}
+
+func TestSubscriptionAdder_CreateSubscriptionErrorOnAttributeRoutes(t *testing.T) {
+	// Call real method with a router which routes by attribute
+	fakeClient := new(fakeClient)
+	subscriptionAdder := &subscriptionAdder{client: fakeClient}
+	router := pusu.NewRouter("test", "testing").OnAttribute("type", "created", func(m *pusu.Message) error { return nil })
+	err := subscriptionAdder.CreateSubscription(router)
+
+	// Got an error without connecting to broker
+	assert.Error(t, err)
+	fakeClient.AssertNotCalled(t, "Connect", mock.Anything)
+}
+
+func TestClientId(t *testing.T) {
+	// Long client ids must be shortened to the length MQTT 3.1.1 servers accept, staying stable and distinct
+	long := "a-very-long-subscription-name"
+	assert.LessOrEqual(t, len(clientId(long, "host-1")), maxClientIdLength)
+	assert.Equal(t, clientId(long, "host-1"), clientId(long, "host-1"))
+	assert.NotEqual(t, clientId(long, "host-1"), clientId(long+"-too", "host-1"))
+	assert.NotEqual(t, clientId(long, "host-1"), clientId(long, "host-2"))
+
+	// Short client ids must be kept as is
+	assert.Equal(t, "s-host-1", clientId("s", "host-1"))
+}
+
+func TestDefaultInstance(t *testing.T) {
+	// Default instance must tell replicas on the same host apart by their process ids
+	hostname, _ := os.Hostname()
+	assert.Equal(t, hostname+"-"+strconv.Itoa(os.Getpid()), defaultInstance())
+}
+
+func TestValidateFilter(t *testing.T) {
+	// Valid filters
+	for _, filter := range []string{"test", "devices/+/telemetry", "devices/#", "#", "+/+"} {
+		assert.Nil(t, validateFilter(filter), filter)
+	}
+
+	// Invalid filters
+	for _, filter := range []string{"", "devices/a+/telemetry", "devices/#/telemetry", "devices#", "$share/group/test"} {
+		assert.Error(t, validateFilter(filter), filter)
+	}
+}
+
+type fakeClient struct {
+	mock.Mock
+}
+
+func (f *fakeClient) Connect(subscription string) (connection, error) {
+	args := f.Called(subscription)
+	c, _ := args.Get(0).(connection)
+	return c, args.Error(1)
+}
+
+type fakeConnection struct {
+	mock.Mock
+}
+
+func (f *fakeConnection) Subscribe(filter string, handler func(m message)) error {
+	args := f.Called(filter, handler)
+	return args.Error(0)
+}
+
+func (f *fakeConnection) Disconnect() {
+	f.Called()
+}
+
+type fakeMessage struct {
+	mock.Mock
+}
+
+func (f *fakeMessage) Topic() string {
+	args := f.Called()
+	return args.String(0)
+}
+
+func (f *fakeMessage) Payload() []byte {
+	args := f.Called()
+	return args.Get(0).([]byte)
+}
+
+func (f *fakeMessage) Ack() {
+	f.Called()
+}
@@ -0,0 +1,78 @@
+// Package nats provides implementation of pub/sub workflow on NATS JetStream.
+// Topic of a subscription is a subject captured by a stream and name of a subscription is a durable pull consumer
+// of that stream. Messages are acked after successful handling and nacked on failure until maximum deliveries, with a
+// redelivery delay doubling on every delivery.
+// Messages rejected by subscription wrappers are kept in progress and handled again, without using up deliveries.
+// Attributes of messages are their headers, except headers reserved by NATS.
+package nats
+
+import (
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"net/url"
+)
+
+type Adapter struct {
+	// Adds stream and durable consumer of subscription to JetStream
+	streamAdder pusu.Creator
+
+	// Runs subscription as pull consumer
+	runner pusu.Runner
+
+	// Validates and provisions subscriptions, tracking their health and metrics
+	pusu.Provisioner
+}
+
+// Implementation of pusu.Creator interface as part of pusu.Adapter interface
+func (n *Adapter) CreateSubscription(subscription pusu.Subscription) error {
+	return n.Provision(subscription, n.streamAdder)
+}
+
+// Implementation of pusu.Runner interface as part of pusu.Adapter interface
+func (n *Adapter) Run(subscription pusu.Subscription) error {
+	err := n.runner.Run(subscription)
+	return err
+}
+
+// Creates NATS Adapter
+// url: NATS server url. (Ex: nats://localhost:4222)
+func CreateAdapter(url string) (*Adapter, error) {
+	// Validate parameters
+	if url == "" {
+		return nil, errors.New("url must not be empty")
+	}
+
+	connection, err := natsgo.Connect(url)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(connection)
+	if err != nil {
+		return nil, err
+	}
+	client := &jetStreamWrapper{js: js}
+
+	natsAdapter := new(Adapter)
+	natsAdapter.streamAdder = &streamAdder{client: client, maxDeliver: defaultMaxDeliver}
+	natsAdapter.runner = &pullRunner{
+		client:           client,
+		maxDeliver:       defaultMaxDeliver,
+		rejectionBackoff: defaultRejectionBackoff,
+		retryDelay:       defaultRetryDelay,
+		metrics:          natsAdapter.Metrics(),
+	}
+
+	return natsAdapter, nil
+}
+
+func init() {
+	pusu.RegisterScheme("nats", open)
+}
+
+// Opens NATS Adapter of url in form of nats://<host>:<port>
+func open(u *url.URL) (pusu.Adapter, error) {
+	return CreateAdapter(u.String())
+}
@@ -0,0 +1,42 @@
+package nats
+
+import (
+	"github.com/metglobal-compass/pusu"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAdapter_CreateAdapter(t *testing.T) {
+	// Call real method against embedded server and check each interfaces have proper types
+	server := runServer(t)
+	adapter, err := CreateAdapter(server.ClientURL())
+
+	assert.Nil(t, err)
+
+	streamAdder, streamAdderProper := adapter.streamAdder.(*streamAdder)
+	assert.True(t, streamAdderProper, "Stream adder is not proper")
+	assert.Equal(t, defaultMaxDeliver, streamAdder.maxDeliver)
+
+	_, jetStreamProper := streamAdder.client.(*jetStreamWrapper)
+	assert.True(t, jetStreamProper, "JetStream client is not proper")
+
+	pullRunner, pullRunnerProper := adapter.runner.(*pullRunner)
+	assert.True(t, pullRunnerProper, "Runner is not proper")
+	assert.Equal(t, defaultMaxDeliver, pullRunner.maxDeliver)
+	assert.True(t, pullRunner.metrics == adapter.Metrics(), "Runner metrics is not proper")
+}
+
+func TestAdapter_CreateAdapterErrorWithEmptyUrl(t *testing.T) {
+	// Call real method without url
+	_, err := CreateAdapter("")
+	assert.Error(t, err)
+}
+
+func TestAdapter_Open(t *testing.T) {
+	// Open adapter by url of embedded server
+	server := runServer(t)
+	adapter, err := pusu.Open(server.ClientURL())
+	assert.Nil(t, err)
+	_, proper := adapter.(*Adapter)
+	assert.True(t, proper, "Adapter is not proper")
+}
@@ -0,0 +1,33 @@
+package nats
+
+import (
+	"context"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"time"
+)
+
+// JetStream operations used while provisioning and running subscriptions
+type client interface {
+	StreamExists(ctx context.Context, stream string) (bool, error)
+	CreateStream(ctx context.Context, stream string, subject string) error
+	CreateConsumer(ctx context.Context, stream string, config jetstream.ConsumerConfig) error
+	Consumer(ctx context.Context, stream string, name string) (consumer, error)
+}
+
+// Durable pull consumer of a subscription
+type consumer interface {
+	// Blocks until next message is pulled
+	Next() (message, error)
+}
+
+// Pulled JetStream message
+type message interface {
+	Data() []byte
+	Headers() natsgo.Header
+	Metadata() (*jetstream.MsgMetadata, error)
+	Ack() error
+	NakWithDelay(delay time.Duration) error
+	InProgress() error
+	Term() error
+}
@@ -0,0 +1,67 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"time"
+)
+
+const (
+	// Maximum waiting duration of a single pull request
+	pullMaxWait = 30 * time.Second
+)
+
+// JetStream wrapper which implements client interface
+type jetStreamWrapper struct {
+	js jetstream.JetStream
+}
+
+func (j *jetStreamWrapper) StreamExists(ctx context.Context, stream string) (bool, error) {
+	_, err := j.js.Stream(ctx, stream)
+	if errors.Is(err, jetstream.ErrStreamNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (j *jetStreamWrapper) CreateStream(ctx context.Context, stream string, subject string) error {
+	_, err := j.js.CreateStream(ctx, jetstream.StreamConfig{Name: stream, Subjects: []string{subject}})
+	return err
+}
+
+func (j *jetStreamWrapper) CreateConsumer(ctx context.Context, stream string, config jetstream.ConsumerConfig) error {
+	_, err := j.js.CreateOrUpdateConsumer(ctx, stream, config)
+	return err
+}
+
+func (j *jetStreamWrapper) Consumer(ctx context.Context, stream string, name string) (consumer, error) {
+	c, err := j.js.Consumer(ctx, stream, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &consumerWrapper{consumer: c}, nil
+}
+
+// JetStream consumer wrapper which implements consumer interface
+type consumerWrapper struct {
+	consumer jetstream.Consumer
+}
+
+// Pulls next message. Pull requests are repeated until a message arrives.
+func (c *consumerWrapper) Next() (message, error) {
+	for {
+		m, err := c.consumer.Next(jetstream.FetchMaxWait(pullMaxWait))
+		if errors.Is(err, natsgo.ErrTimeout) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return m, nil
+	}
+}
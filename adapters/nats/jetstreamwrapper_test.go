@@ -0,0 +1,60 @@
+package nats
+
+import (
+	"context"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats-server/v2/test"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestJetStreamWrapper(t *testing.T) {
+	// Connect to embedded server
+	server := runServer(t)
+	connection, err := natsgo.Connect(server.ClientURL())
+	assert.Nil(t, err)
+	defer connection.Close()
+	js, _ := jetstream.New(connection)
+	wrapper := &jetStreamWrapper{js: js}
+	ctx := context.Background()
+
+	// Stream must not exist before creation
+	exists, err := wrapper.StreamExists(ctx, "test")
+	assert.Nil(t, err)
+	assert.False(t, exists)
+
+	// Stream must exist after creation
+	assert.Nil(t, wrapper.CreateStream(ctx, "test", "test"))
+	exists, err = wrapper.StreamExists(ctx, "test")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+
+	// Create durable consumer and publish a message
+	config := jetstream.ConsumerConfig{Durable: "testing", AckPolicy: jetstream.AckExplicitPolicy, MaxDeliver: 3}
+	assert.Nil(t, wrapper.CreateConsumer(ctx, "test", config))
+	_, err = js.Publish(ctx, "test", []byte("payload"))
+	assert.Nil(t, err)
+
+	// Published message must be pulled by consumer
+	consumer, err := wrapper.Consumer(ctx, "test", "testing")
+	assert.Nil(t, err)
+	m, err := consumer.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, "payload", string(m.Data()))
+	assert.Nil(t, m.Ack())
+}
+
+// Runs an embedded NATS server with JetStream enabled for the duration of test
+func runServer(t *testing.T) *server.Server {
+	options := test.DefaultTestOptions
+	options.Port = -1
+	options.JetStream = true
+	options.StoreDir = t.TempDir()
+
+	s := test.RunServer(&options)
+	t.Cleanup(s.Shutdown)
+
+	return s
+}
@@ -0,0 +1,134 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// Messages are not delivered again after this many attempts
+	defaultMaxDeliver = 5
+
+	// Waiting duration before handling a message again when subscription rejects it (e.g. circuit is open)
+	defaultRejectionBackoff = time.Second
+
+	// Redelivery delay of a failed message after its first delivery. It doubles on every further delivery up to
+	// maxRetryDelay.
+	defaultRetryDelay = 10 * time.Second
+	maxRetryDelay     = 10 * time.Minute
+)
+
+// Pull consumer runner which implements pusu.Runner interface
+type pullRunner struct {
+	client client
+
+	maxDeliver       int
+	rejectionBackoff time.Duration
+	retryDelay       time.Duration
+
+	// Counts outcomes of handled messages. Optional.
+	metrics *pusu.Metrics
+}
+
+// Pulls messages of subscription until consumer fails. A message is acked after successful handling,
+// nacked with a growing delay on failure and terminated when its last delivery attempt fails or handler requires dead lettering.
+func (p *pullRunner) Run(subscription pusu.Subscription) error {
+	ctx := context.Background()
+	c, err := p.client.Consumer(ctx, streamName(subscription), subscription.Name())
+	if err != nil {
+		return err
+	}
+
+	for {
+		m, err := c.Next()
+		if err != nil {
+			return err
+		}
+
+		err = p.handle(ctx, subscription, m)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Handles message with subscription and acknowledges result to JetStream
+func (p *pullRunner) handle(ctx context.Context, subscription pusu.Subscription, m message) error {
+	for {
+		message := pusu.NewMessage(string(m.Data())).WithAttributes(attributes(m)).WithContext(ctx)
+		err := pusu.HandleBlocking(ctx, subscription, message)
+		if p.metrics != nil {
+			p.metrics.Observe(subscription, err)
+		}
+
+		// Handler was not called at all. Every redelivery counts to maximum deliveries of consumer, so keep the message
+		// in progress and try it again later instead of nacking it.
+		if pusu.IsRejection(err) {
+			err = m.InProgress()
+			if err != nil {
+				return err
+			}
+			time.Sleep(p.rejectionBackoff)
+			continue
+		}
+
+		return p.acknowledge(subscription, m, err)
+	}
+}
+
+// Acknowledges result of handled message to JetStream
+func (p *pullRunner) acknowledge(subscription pusu.Subscription, m message, err error) error {
+	if err == nil {
+		return m.Ack()
+	}
+
+	if errors.Is(err, pusu.ErrDeadLetter) {
+		log.Printf("Handler of subscription %s requires dead lettering, message is terminated: %s", subscription.Name(), err)
+		return m.Term()
+	}
+
+	// Stop delivering message on its last attempt
+	delivered := uint64(1)
+	metadata, metadataErr := m.Metadata()
+	if metadataErr == nil {
+		delivered = max(metadata.NumDelivered, 1)
+	}
+	if delivered >= uint64(p.maxDeliver) {
+		log.Printf("Handler of subscription %s failed on last delivery, message is terminated: %s", subscription.Name(), err)
+		return m.Term()
+	}
+
+	log.Printf("Handler of subscription %s failed: %s", subscription.Name(), err)
+	return m.NakWithDelay(p.delay(delivered))
+}
+
+// Returns redelivery delay of a message failed on given delivery, doubling on every delivery
+func (p *pullRunner) delay(delivered uint64) time.Duration {
+	delay := p.retryDelay
+	for i := uint64(1); i < delivered && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxRetryDelay)
+}
+
+// Returns attributes of message, which are first values of its headers except headers reserved by NATS.
+// Returns nil if there are none.
+func attributes(m message) map[string]string {
+	var attributes map[string]string
+	for key, values := range m.Headers() {
+		if len(values) == 0 || strings.HasPrefix(key, "Nats-") {
+			continue
+		}
+		if attributes == nil {
+			attributes = make(map[string]string)
+		}
+		attributes[key] = values[0]
+	}
+
+	return attributes
+}
@@ -0,0 +1,180 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	"github.com/metglobal-compass/pusu/pusutest"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestPullRunner_Run(t *testing.T) {
+	// Create fake consumer which returns a single message and then stops
+	fakeMessage := newFakeMessage(1)
+	fakeMessage.On("Ack").Return(nil)
+	fakeConsumer := new(fakeConsumer)
+	fakeConsumer.On("Next").Return(fakeMessage, nil).Once()
+	fakeConsumer.On("Next").Return(nil, io.EOF)
+	fakeClient := new(fakeClient)
+	fakeClient.On("Consumer", mock.Anything, "test", "testing").Return(fakeConsumer, nil)
+
+	// Call real method with a successful subscription
+	runner := &pullRunner{client: fakeClient, maxDeliver: 3, metrics: new(pusu.Metrics)}
+	subscription := new(pusutest.Subscription).WillHaveProperFields()
+	err := runner.Run(subscription)
+
+	// Runner must return error of consumer
+	assert.Equal(t, io.EOF, err)
+
+	// Message must be handled with its payload and headers other than reserved ones, and acked
+	subscription.(*pusutest.Subscription).AssertCalled(t, "Handle", mock.MatchedBy(func(m *pusu.Message) bool {
+		return m.Message() == "payload" && assert.ObjectsAreEqual(map[string]string{"type": "created"}, m.Attributes())
+	}))
+	fakeMessage.AssertNumberOfCalls(t, "Ack", 1)
+	assert.Equal(t, int64(1), runner.metrics.Count(subscription, pusu.OutcomeSuccess))
+}
+
+func TestPullRunner_RunErrorOnConsumer(t *testing.T) {
+	// Create fake client which can not find consumer
+	fakeClient := new(fakeClient)
+	fakeClient.On("Consumer", mock.Anything, "test", "testing").Return(nil, errors.New("error"))
+
+	// Runner must return error
+	runner := &pullRunner{client: fakeClient, maxDeliver: 3}
+	assert.Error(t, runner.Run(new(pusutest.Subscription).WillHaveProperFields()))
+}
+
+func TestPullRunner_HandleFailure(t *testing.T) {
+	// Failed message must be nacked with a delay doubling on every delivery when it has remaining delivery attempts
+	fakeMessage := newFakeMessage(2)
+	fakeMessage.On("NakWithDelay", 2*time.Second).Return(nil)
+
+	runner := &pullRunner{maxDeliver: 3, retryDelay: time.Second}
+	err := runner.handle(context.Background(), new(pusutest.Subscription).WillReturnError(), fakeMessage)
+
+	assert.Nil(t, err)
+	fakeMessage.AssertNumberOfCalls(t, "NakWithDelay", 1)
+	fakeMessage.AssertNotCalled(t, "Term")
+}
+
+func TestPullRunner_Delay(t *testing.T) {
+	// Delay must double on every delivery up to maximum delay
+	runner := &pullRunner{retryDelay: defaultRetryDelay}
+	assert.Equal(t, defaultRetryDelay, runner.delay(1))
+	assert.Equal(t, 4*defaultRetryDelay, runner.delay(3))
+	assert.Equal(t, maxRetryDelay, runner.delay(100))
+}
+
+func TestPullRunner_HandleFailureOnLastDelivery(t *testing.T) {
+	// Failed message must be terminated on its last delivery attempt
+	fakeMessage := newFakeMessage(3)
+	fakeMessage.On("Term").Return(nil)
+
+	runner := &pullRunner{maxDeliver: 3}
+	err := runner.handle(context.Background(), new(pusutest.Subscription).WillReturnError(), fakeMessage)
+
+	assert.Nil(t, err)
+	fakeMessage.AssertNumberOfCalls(t, "Term", 1)
+	fakeMessage.AssertNotCalled(t, "NakWithDelay", mock.Anything)
+}
+
+func TestPullRunner_HandleRejection(t *testing.T) {
+	// Create a message on its last delivery attempt
+	fakeMessage := newFakeMessage(3)
+	fakeMessage.On("InProgress").Return(nil)
+	fakeMessage.On("Ack").Return(nil)
+
+	// Create subscription which rejects more times than maximum deliveries and then succeeds
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing")
+	subscription.On("Handle", mock.Anything).Return(pusu.ErrCircuitOpen).Times(10)
+	subscription.On("Handle", mock.Anything).Return(nil)
+
+	runner := &pullRunner{maxDeliver: 3, rejectionBackoff: time.Millisecond}
+	err := runner.handle(context.Background(), subscription, fakeMessage)
+
+	// Rejections must not use up delivery attempts, message must be kept in progress until it is handled
+	assert.Nil(t, err)
+	subscription.AssertNumberOfCalls(t, "Handle", 11)
+	fakeMessage.AssertNumberOfCalls(t, "InProgress", 10)
+	fakeMessage.AssertNumberOfCalls(t, "Ack", 1)
+	fakeMessage.AssertNotCalled(t, "NakWithDelay", mock.Anything)
+	fakeMessage.AssertNotCalled(t, "Term")
+}
+
+func TestPullRunner_HandleDeadLetter(t *testing.T) {
+	// Message must be terminated on its first delivery when handler requires dead lettering
+	fakeMessage := newFakeMessage(1)
+	fakeMessage.On("Term").Return(nil)
+
+	runner := &pullRunner{maxDeliver: 3}
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(pusu.ErrDeadLetter)
+	err := runner.handle(context.Background(), subscription, fakeMessage)
+
+	assert.Nil(t, err)
+	fakeMessage.AssertNumberOfCalls(t, "Term", 1)
+	fakeMessage.AssertNotCalled(t, "NakWithDelay", mock.Anything)
+}
+
+type fakeConsumer struct {
+	mock.Mock
+}
+
+func (f *fakeConsumer) Next() (message, error) {
+	args := f.Called()
+	m, _ := args.Get(0).(message)
+	return m, args.Error(1)
+}
+
+type fakeMessage struct {
+	mock.Mock
+}
+
+// Creates a fake message with payload delivered given number of times
+func newFakeMessage(delivered uint64) *fakeMessage {
+	f := new(fakeMessage)
+	f.On("Data").Return([]byte("payload"))
+	f.On("Headers").Return(natsgo.Header{"type": {"created"}, "Nats-Msg-Id": {"1"}})
+	f.On("Metadata").Return(&jetstream.MsgMetadata{NumDelivered: delivered}, nil)
+	return f
+}
+
+func (f *fakeMessage) Data() []byte {
+	args := f.Called()
+	return args.Get(0).([]byte)
+}
+
+func (f *fakeMessage) Headers() natsgo.Header {
+	args := f.Called()
+	return args.Get(0).(natsgo.Header)
+}
+
+func (f *fakeMessage) Metadata() (*jetstream.MsgMetadata, error) {
+	args := f.Called()
+	return args.Get(0).(*jetstream.MsgMetadata), args.Error(1)
+}
+
+func (f *fakeMessage) Ack() error {
+	args := f.Called()
+	return args.Error(0)
+}
+
+func (f *fakeMessage) NakWithDelay(delay time.Duration) error {
+	args := f.Called(delay)
+	return args.Error(0)
+}
+
+func (f *fakeMessage) InProgress() error {
+	args := f.Called()
+	return args.Error(0)
+}
+
+func (f *fakeMessage) Term() error {
+	args := f.Called()
+	return args.Error(0)
+}
@@ -0,0 +1,50 @@
+package nats
+
+import (
+	"context"
+	"github.com/metglobal-compass/pusu"
+	"github.com/nats-io/nats.go/jetstream"
+	"strings"
+)
+
+type streamAdder struct {
+	client client
+
+	// Maximum delivery attempts of a message
+	maxDeliver int
+}
+
+// Implementation of internal Creator interface for NATS Adapter
+func (s *streamAdder) CreateSubscription(subscription pusu.Subscription) error {
+	// Use single context
+	ctx := context.Background()
+	stream := streamName(subscription)
+
+	// Check if stream exists
+	exists, err := s.client.StreamExists(ctx, stream)
+	if err != nil {
+		return err
+	}
+
+	// If stream does not exists, create it for topic subject
+	if !exists {
+		err = s.client.CreateStream(ctx, stream, subscription.Topic())
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create durable consumer, or update it with current configuration
+	return s.client.CreateConsumer(ctx, stream, jetstream.ConsumerConfig{
+		Durable:       subscription.Name(),
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		MaxDeliver:    s.maxDeliver,
+		FilterSubject: subscription.Topic(),
+	})
+}
+
+// Returns stream name of subscription topic. Characters which are not allowed in stream names are replaced.
+func streamName(subscription pusu.Subscription) string {
+	return strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_", "/", "_", "\\", "_").
+		Replace(subscription.Topic())
+}
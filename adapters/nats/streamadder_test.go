@@ -0,0 +1,113 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"github.com/metglobal-compass/pusu/pusutest"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+func TestStreamAdder_CreateSubscription(t *testing.T) {
+	// Create fake mocked client. In this case, stream does not exist
+	fakeClient := new(fakeClient)
+	fakeClient.On("StreamExists", context.Background(), "test").Return(false, nil)
+	fakeClient.On("CreateStream", context.Background(), "test", "test").Return(nil)
+	fakeClient.On("CreateConsumer", context.Background(), "test", mock.Anything).Return(nil)
+
+	// Call real method
+	streamAdder := &streamAdder{client: fakeClient, maxDeliver: 3}
+	err := streamAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Everything is fine, so real method must return nil as error
+	assert.Nil(t, err)
+
+	// Check durable consumer is created with proper configuration
+	expectedConfig := jetstream.ConsumerConfig{
+		Durable:       "testing",
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		MaxDeliver:    3,
+		FilterSubject: "test",
+	}
+	fakeClient.AssertCalled(t, "CreateConsumer", mock.Anything, "test", expectedConfig)
+	fakeClient.AssertExpectations(t)
+}
+
+func TestStreamAdder_CreateSubscriptionExistingStream(t *testing.T) {
+	// Create fake mocked client. In this case, stream exists
+	fakeClient := new(fakeClient)
+	fakeClient.On("StreamExists", context.Background(), "test").Return(true, nil)
+	fakeClient.On("CreateConsumer", context.Background(), "test", mock.Anything).Return(nil)
+
+	// Call real method
+	streamAdder := &streamAdder{client: fakeClient, maxDeliver: 3}
+	err := streamAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Existing stream must not be created again
+	assert.Nil(t, err)
+	fakeClient.AssertNotCalled(t, "CreateStream")
+	fakeClient.AssertNumberOfCalls(t, "CreateConsumer", 1)
+}
+
+func TestStreamAdder_CreateSubscriptionErrorOnStreamExists(t *testing.T) {
+	// Create fake mocked client. In this case, we get an error while checking stream's existence
+	fakeClient := new(fakeClient)
+	fakeClient.On("StreamExists", context.Background(), "test").Return(false, errors.New("error"))
+
+	// Call real method
+	streamAdder := &streamAdder{client: fakeClient, maxDeliver: 3}
+	err := streamAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Got an error and following methods must not be called
+	assert.Error(t, err)
+	fakeClient.AssertNotCalled(t, "CreateStream")
+	fakeClient.AssertNotCalled(t, "CreateConsumer")
+}
+
+func TestStreamAdder_CreateSubscriptionErrorOnCreateStream(t *testing.T) {
+	// Create fake mocked client. In this case, we get an error while creating stream
+	fakeClient := new(fakeClient)
+	fakeClient.On("StreamExists", context.Background(), "test").Return(false, nil)
+	fakeClient.On("CreateStream", context.Background(), "test", "test").Return(errors.New("error"))
+
+	// Call real method
+	streamAdder := &streamAdder{client: fakeClient, maxDeliver: 3}
+	err := streamAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Got an error and consumer must not be created
+	assert.Error(t, err)
+	fakeClient.AssertNotCalled(t, "CreateConsumer")
+}
+
+func TestStreamName(t *testing.T) {
+	// Characters which are not allowed in stream names must be replaced
+	subscription := new(pusutest.Subscription).WithTopic("orders.created.>")
+	assert.Equal(t, "orders_created__", streamName(subscription))
+}
+
+type fakeClient struct {
+	mock.Mock
+}
+
+func (f *fakeClient) StreamExists(ctx context.Context, stream string) (bool, error) {
+	args := f.Called(ctx, stream)
+	return args.Bool(0), args.Error(1)
+}
+
+func (f *fakeClient) CreateStream(ctx context.Context, stream string, subject string) error {
+	args := f.Called(ctx, stream, subject)
+	return args.Error(0)
+}
+
+func (f *fakeClient) CreateConsumer(ctx context.Context, stream string, config jetstream.ConsumerConfig) error {
+	args := f.Called(ctx, stream, config)
+	return args.Error(0)
+}
+
+func (f *fakeClient) Consumer(ctx context.Context, stream string, name string) (consumer, error) {
+	args := f.Called(ctx, stream, name)
+	c, _ := args.Get(0).(consumer)
+	return c, args.Error(1)
+}
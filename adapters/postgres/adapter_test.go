@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"github.com/metglobal-compass/pusu"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAdapter_CreateAdapter(t *testing.T) {
+	// Call real method and check each interfaces have proper types
+	adapter, err := CreateAdapter("postgres://localhost:5432/pusu")
+
+	assert.Nil(t, err)
+
+	subscriptionAdder, subscriptionAdderProper := adapter.subscriptionAdder.(*subscriptionAdder)
+	assert.True(t, subscriptionAdderProper, "Subscription adder is not proper")
+
+	_, pgxClientProper := subscriptionAdder.client.(*pgxClientWrapper)
+	assert.True(t, pgxClientProper, "Postgres client is not proper")
+
+	claimRunner, claimRunnerProper := adapter.runner.(*claimRunner)
+	assert.True(t, claimRunnerProper, "Runner is not proper")
+	assert.Equal(t, defaultMaxAttempts, claimRunner.maxAttempts)
+	assert.True(t, claimRunner.metrics == adapter.Metrics(), "Runner metrics is not proper")
+}
+
+func TestAdapter_CreateAdapterErrorWithEmptyUrl(t *testing.T) {
+	// Call real method without url
+	_, err := CreateAdapter("")
+	assert.Error(t, err)
+}
+
+func TestAdapter_CreateAdapterErrorWithInvalidUrl(t *testing.T) {
+	// Call real method with a url which is not a connection string
+	_, err := CreateAdapter("postgres://localhost:notaport/pusu")
+	assert.Error(t, err)
+}
+
+func TestAdapter_Open(t *testing.T) {
+	// Open adapter by url with both schemes of PostgreSQL
+	for _, dsn := range []string{"postgres://localhost:5432/pusu", "postgresql://localhost:5432/pusu"} {
+		adapter, err := pusu.Open(dsn)
+		assert.Nil(t, err)
+		_, proper := adapter.(*Adapter)
+		assert.True(t, proper, "Adapter is not proper")
+	}
+}
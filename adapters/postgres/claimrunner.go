@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	"log"
+	"time"
+)
+
+const (
+	// Maximum number of deliveries claimed at once
+	defaultBatchSize = 10
+
+	// Claimed deliveries are delivered again after this duration unless they are handled
+	defaultVisibility = 30 * time.Second
+
+	// Deliveries are moved to dead letters after this many failed attempts
+	defaultMaxAttempts = 5
+
+	// Delay of a failed delivery. It is multiplied by attempt count of delivery.
+	defaultRetryDelay = 10 * time.Second
+
+	// Delay of a delivery rejected by subscription (e.g. circuit is open)
+	defaultRejectionDelay = time.Second
+
+	// Deliveries are claimed at least this often, even without notifications (e.g. retries becoming visible)
+	defaultPollInterval = 5 * time.Second
+)
+
+// Claiming runner which implements pusu.Runner interface
+type claimRunner struct {
+	client client
+
+	batchSize      int
+	visibility     time.Duration
+	maxAttempts    int
+	retryDelay     time.Duration
+	rejectionDelay time.Duration
+	pollInterval   time.Duration
+
+	// Counts outcomes of handled messages. Optional.
+	metrics *pusu.Metrics
+}
+
+// Claims deliveries of subscription until client fails. When there is nothing to claim, runner waits for
+// a notification of its topic or poll interval. A delivery is deleted after successful handling, retried later
+// on failure and moved to dead letters when its last attempt fails.
+func (c *claimRunner) Run(subscription pusu.Subscription) error {
+	ctx := context.Background()
+
+	// Listen before first claim, so messages published in between are not missed
+	l, err := c.client.Listen(ctx)
+	if err != nil {
+		return err
+	}
+	defer l.Close(ctx)
+
+	for {
+		deliveries, err := c.client.Claim(ctx, subscription.Name(), c.batchSize, c.visibility)
+		if err != nil {
+			return err
+		}
+
+		for _, d := range deliveries {
+			// Visibility timeout of later deliveries may pass while earlier ones are handled, so extend it from now on
+			// and leave delivery to its new claimer if it is claimed again meanwhile
+			claimed, err := c.client.Extend(ctx, d, c.visibility)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				continue
+			}
+
+			err = c.handle(ctx, subscription, d)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(deliveries) == 0 {
+			err = l.Wait(ctx, subscription.Topic(), c.pollInterval)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Handles delivery with subscription and records result
+func (c *claimRunner) handle(ctx context.Context, subscription pusu.Subscription, d delivery) error {
+	err := pusu.HandleBlocking(ctx, subscription, pusu.NewMessage(d.payload).WithAttributes(d.attributes).WithContext(ctx))
+	if c.metrics != nil {
+		c.metrics.Observe(subscription, err)
+	}
+
+	if err == nil {
+		return c.client.Delete(ctx, d)
+	}
+
+	// Handler was not called at all, deliver same message again later without counting the attempt
+	if pusu.IsRejection(err) {
+		return c.client.Postpone(ctx, d, c.rejectionDelay)
+	}
+
+	if d.attempts >= c.maxAttempts || errors.Is(err, pusu.ErrDeadLetter) {
+		log.Printf("Handler of subscription %s failed on last attempt, message is dead lettered: %s", subscription.Name(), err)
+		return c.client.DeadLetter(ctx, d, err.Error())
+	}
+
+	log.Printf("Handler of subscription %s failed: %s", subscription.Name(), err)
+	return c.client.Retry(ctx, d, c.retryDelay*time.Duration(d.attempts))
+}
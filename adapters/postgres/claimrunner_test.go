@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	"github.com/metglobal-compass/pusu/pusutest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+func TestClaimRunner_Run(t *testing.T) {
+	// Create fake client which claims a delivery, then nothing, then fails on next claim
+	fakeClient, fakeListener := newTestClient()
+	fakeClient.On("Claim", mock.Anything, "testing", 10, time.Minute).
+		Return([]delivery{{id: 1, payload: "hello", attributes: map[string]string{"type": "created"}, attempts: 1}}, nil).Once()
+	fakeClient.On("Claim", mock.Anything, "testing", 10, time.Minute).Return(nil, nil).Once()
+	fakeClient.On("Claim", mock.Anything, "testing", 10, time.Minute).Return(nil, errors.New("error"))
+	fakeClient.On("Delete", mock.Anything, int64(1)).Return(nil)
+	fakeListener.On("Wait", mock.Anything, "test", time.Second).Return(nil)
+
+	// Call real method with a successful subscription
+	runner := newTestRunner(fakeClient)
+	subscription := new(pusutest.Subscription).WillHaveProperFields()
+	err := runner.Run(subscription)
+
+	// Runner must return error of client and close its listener
+	assert.Error(t, err)
+	fakeListener.AssertCalled(t, "Close", mock.Anything)
+
+	// Delivery must be handled with its attributes and deleted, runner must wait for notification when there is nothing
+	// to claim
+	subscription.(*pusutest.Subscription).AssertCalled(t, "Handle", mock.MatchedBy(func(m *pusu.Message) bool {
+		return m.Message() == "hello" && m.Attribute("type") == "created"
+	}))
+	fakeClient.AssertCalled(t, "Delete", mock.Anything, int64(1))
+	fakeListener.AssertNumberOfCalls(t, "Wait", 1)
+	assert.Equal(t, int64(1), runner.metrics.Count(subscription, pusu.OutcomeSuccess))
+}
+
+func TestClaimRunner_RunFailure(t *testing.T) {
+	// Create fake client which claims a delivery on its second attempt, then fails on next claim
+	fakeClient, _ := newTestClient()
+	fakeClient.On("Claim", mock.Anything, "testing", 10, time.Minute).
+		Return([]delivery{{id: 1, payload: "hello", attempts: 2}}, nil).Once()
+	fakeClient.On("Claim", mock.Anything, "testing", 10, time.Minute).Return(nil, errors.New("error"))
+	fakeClient.On("Retry", mock.Anything, int64(1), 20*time.Second).Return(nil)
+
+	// Call real method with a failing subscription
+	runner := newTestRunner(fakeClient)
+	runner.Run(new(pusutest.Subscription).WillReturnError())
+
+	// Failed delivery must be retried later by its attempt count
+	fakeClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	fakeClient.AssertCalled(t, "Retry", mock.Anything, int64(1), 20*time.Second)
+}
+
+func TestClaimRunner_RunFailureOnLastAttempt(t *testing.T) {
+	// Create fake client which claims a delivery on its last attempt, then fails on next claim
+	fakeClient, _ := newTestClient()
+	fakeClient.On("Claim", mock.Anything, "testing", 10, time.Minute).
+		Return([]delivery{{id: 1, payload: "hello", attempts: defaultMaxAttempts}}, nil).Once()
+	fakeClient.On("Claim", mock.Anything, "testing", 10, time.Minute).Return(nil, errors.New("error"))
+	fakeClient.On("DeadLetter", mock.Anything, int64(1), "error").Return(nil)
+
+	// Call real method with a failing subscription
+	runner := newTestRunner(fakeClient)
+	runner.Run(new(pusutest.Subscription).WillReturnError())
+
+	// Delivery must be moved to dead letters with reason of failure
+	fakeClient.AssertCalled(t, "DeadLetter", mock.Anything, int64(1), "error")
+	fakeClient.AssertNotCalled(t, "Retry", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestClaimRunner_RunDeadLetter(t *testing.T) {
+	// Create fake client which claims a delivery on its first attempt, then fails on next claim
+	fakeClient, _ := newTestClient()
+	fakeClient.On("Claim", mock.Anything, "testing", 10, time.Minute).
+		Return([]delivery{{id: 1, payload: "hello", attempts: 1}}, nil).Once()
+	fakeClient.On("Claim", mock.Anything, "testing", 10, time.Minute).Return(nil, errors.New("error"))
+	fakeClient.On("DeadLetter", mock.Anything, int64(1), pusu.ErrDeadLetter.Error()).Return(nil)
+
+	// Call real method with a subscription which requires dead lettering
+	runner := newTestRunner(fakeClient)
+	runner.Run(new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(pusu.ErrDeadLetter))
+
+	// Delivery must be moved to dead letters without further attempts
+	fakeClient.AssertCalled(t, "DeadLetter", mock.Anything, int64(1), pusu.ErrDeadLetter.Error())
+	fakeClient.AssertNotCalled(t, "Retry", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestClaimRunner_RunRejection(t *testing.T) {
+	// Create fake client which claims a delivery, then fails on next claim
+	fakeClient, _ := newTestClient()
+	fakeClient.On("Claim", mock.Anything, "testing", 10, time.Minute).
+		Return([]delivery{{id: 1, payload: "hello", attempts: defaultMaxAttempts}}, nil).Once()
+	fakeClient.On("Claim", mock.Anything, "testing", 10, time.Minute).Return(nil, errors.New("error"))
+	fakeClient.On("Postpone", mock.Anything, int64(1), defaultRejectionDelay).Return(nil)
+
+	// Call real method with a subscription which rejects messages
+	runner := newTestRunner(fakeClient)
+	runner.Run(new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(pusu.ErrCircuitOpen))
+
+	// Rejected delivery must be postponed without counting the attempt, even on its last attempt
+	fakeClient.AssertCalled(t, "Postpone", mock.Anything, int64(1), defaultRejectionDelay)
+	fakeClient.AssertNotCalled(t, "DeadLetter", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestClaimRunner_RunReclaimed(t *testing.T) {
+	// Create fake client which claims a delivery claimed again by another subscriber, then fails on next claim
+	fakeListener := new(fakeListener)
+	fakeListener.On("Close", mock.Anything).Return(nil)
+	fakeClient := new(fakeClient)
+	fakeClient.On("Listen", mock.Anything).Return(fakeListener, nil)
+	fakeClient.On("Claim", mock.Anything, "testing", 10, time.Minute).
+		Return([]delivery{{id: 1, payload: "hello", attempts: 1}}, nil).Once()
+	fakeClient.On("Claim", mock.Anything, "testing", 10, time.Minute).Return(nil, errors.New("error"))
+	fakeClient.On("Extend", mock.Anything, delivery{id: 1, payload: "hello", attempts: 1}, time.Minute).Return(false, nil)
+
+	// Call real method with a subscription which must not be called
+	runner := newTestRunner(fakeClient)
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing")
+	runner.Run(subscription)
+
+	// Delivery must be left to its new claimer
+	subscription.AssertNotCalled(t, "Handle", mock.Anything)
+	fakeClient.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestClaimRunner_RunErrorOnListen(t *testing.T) {
+	// Create fake client which fails while listening notifications
+	fakeClient := new(fakeClient)
+	fakeClient.On("Listen", mock.Anything).Return(nil, errors.New("error"))
+
+	// Runner must return error without claiming
+	runner := newTestRunner(fakeClient)
+	assert.Error(t, runner.Run(new(pusutest.Subscription).WillHaveProperFields()))
+	fakeClient.AssertNotCalled(t, "Claim", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Creates a fake client which listens with a fake listener
+func newTestClient() (*fakeClient, *fakeListener) {
+	fakeListener := new(fakeListener)
+	fakeListener.On("Close", mock.Anything).Return(nil)
+
+	fakeClient := new(fakeClient)
+	fakeClient.On("Listen", mock.Anything).Return(fakeListener, nil)
+	fakeClient.On("Extend", mock.Anything, mock.Anything, time.Minute).Return(true, nil).Maybe()
+
+	return fakeClient, fakeListener
+}
+
+// Creates a claim runner with given fake client
+func newTestRunner(c client) *claimRunner {
+	return &claimRunner{
+		client:         c,
+		batchSize:      defaultBatchSize,
+		visibility:     time.Minute,
+		maxAttempts:    defaultMaxAttempts,
+		retryDelay:     defaultRetryDelay,
+		rejectionDelay: defaultRejectionDelay,
+		pollInterval:   time.Second,
+		metrics:        new(pusu.Metrics),
+	}
+}
@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+	"time"
+)
+
+// Table operations used while provisioning and running subscriptions
+type client interface {
+	CreateSubscription(ctx context.Context, topic string, name string) error
+	Claim(ctx context.Context, subscription string, limit int, visibility time.Duration) ([]delivery, error)
+
+	// Hides claimed delivery for visibility timeout from now on. Reports false if delivery is claimed again by
+	// another subscriber or handled already.
+	Extend(ctx context.Context, d delivery, visibility time.Duration) (bool, error)
+
+	// Following operations leave delivery as is if it is claimed again by another subscriber
+	Delete(ctx context.Context, d delivery) error
+	Retry(ctx context.Context, d delivery, delay time.Duration) error
+	Postpone(ctx context.Context, d delivery, delay time.Duration) error
+	DeadLetter(ctx context.Context, d delivery, reason string) error
+	Listen(ctx context.Context) (listener, error)
+}
+
+// Dedicated connection which receives notifications of published messages
+type listener interface {
+	// Waits until a message is published to topic or timeout passes. Returns nil on timeout.
+	Wait(ctx context.Context, topic string, timeout time.Duration) error
+	Close(ctx context.Context) error
+}
+
+// A message claimed by a subscription
+type delivery struct {
+	id      int64
+	payload string
+
+	// Attributes of message. Optional.
+	attributes map[string]string
+
+	attempts int
+
+	// Claim count of delivery when it is claimed, which identifies the claim
+	lease int64
+}
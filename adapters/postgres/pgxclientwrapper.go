@@ -0,0 +1,179 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"time"
+)
+
+// Connection pool wrapper which implements client interface
+type pgxClientWrapper struct {
+	pool *pgxpool.Pool
+}
+
+// Migrates schema and inserts topic and subscription rows in a single transaction
+func (p *pgxClientWrapper) CreateSubscription(ctx context.Context, topic string, name string) error {
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", schemaLockKey)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, schema)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, "INSERT INTO pusu_topics (name) VALUES ($1) ON CONFLICT DO NOTHING", topic)
+		if err != nil {
+			return err
+		}
+
+		// Existing subscription is returned as is, so a subscription bound to another topic is detected
+		var existing string
+		err = tx.QueryRow(ctx, subscriptionQuery, name, topic).Scan(&existing)
+		if err != nil {
+			return err
+		}
+		if existing != topic {
+			return fmt.Errorf("subscription %s already exists for topic %s, not %s", name, existing, topic)
+		}
+
+		return nil
+	})
+}
+
+func (p *pgxClientWrapper) Claim(ctx context.Context, subscription string, limit int, visibility time.Duration) ([]delivery, error) {
+	rows, err := p.pool.Query(ctx, claimQuery, subscription, limit, visibility.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (delivery, error) {
+		var d delivery
+		var attributes []byte
+		err := row.Scan(&d.id, &d.payload, &attributes, &d.attempts, &d.lease)
+		if err != nil {
+			return d, err
+		}
+
+		d.attributes, err = decodeAttributes(attributes)
+		return d, err
+	})
+}
+
+// Extends visibility timeout of delivery unless it is claimed again after given claim
+func (p *pgxClientWrapper) Extend(ctx context.Context, d delivery, visibility time.Duration) (bool, error) {
+	tag, err := p.pool.Exec(ctx,
+		"UPDATE pusu_deliveries SET visible_at = now() + make_interval(secs => $3) WHERE id = $1 AND claims = $2",
+		d.id, d.lease, visibility.Seconds())
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (p *pgxClientWrapper) Delete(ctx context.Context, d delivery) error {
+	_, err := p.pool.Exec(ctx, "DELETE FROM pusu_deliveries WHERE id = $1 AND claims = $2", d.id, d.lease)
+	return err
+}
+
+// Makes delivery visible again after delay
+func (p *pgxClientWrapper) Retry(ctx context.Context, d delivery, delay time.Duration) error {
+	_, err := p.pool.Exec(ctx,
+		"UPDATE pusu_deliveries SET visible_at = now() + make_interval(secs => $3) WHERE id = $1 AND claims = $2",
+		d.id, d.lease, delay.Seconds())
+	return err
+}
+
+// Makes delivery visible again after delay without counting the attempt
+func (p *pgxClientWrapper) Postpone(ctx context.Context, d delivery, delay time.Duration) error {
+	_, err := p.pool.Exec(ctx,
+		"UPDATE pusu_deliveries SET attempts = attempts - 1, visible_at = now() + make_interval(secs => $3) WHERE id = $1 AND claims = $2",
+		d.id, d.lease, delay.Seconds())
+	return err
+}
+
+func (p *pgxClientWrapper) DeadLetter(ctx context.Context, d delivery, reason string) error {
+	_, err := p.pool.Exec(ctx, deadLetterQuery, d.id, d.lease, reason)
+	return err
+}
+
+// Takes a connection out of pool and listens notifications on it
+func (p *pgxClientWrapper) Listen(ctx context.Context) (listener, error) {
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &pgxListener{conn: conn.Hijack()}
+	_, err = l.conn.Exec(ctx, "LISTEN "+notifyChannel)
+	if err != nil {
+		l.conn.Close(ctx)
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Notification listener which implements listener interface
+type pgxListener struct {
+	conn *pgx.Conn
+}
+
+func (p *pgxListener) Wait(ctx context.Context, topic string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		notification, err := p.conn.WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil
+			}
+			return err
+		}
+
+		if notification.Payload == topic {
+			return nil
+		}
+	}
+}
+
+func (p *pgxListener) Close(ctx context.Context) error {
+	return p.conn.Close(ctx)
+}
+
+// Decodes attributes column of a delivery. Values which are not strings are taken in their JSON form, e.g. 1 or true.
+// Returns nil if column is null or empty.
+func decodeAttributes(column []byte) (map[string]string, error) {
+	if column == nil {
+		return nil, nil
+	}
+
+	var values map[string]json.RawMessage
+	err := json.Unmarshal(column, &values)
+	if err != nil {
+		return nil, err
+	}
+
+	var attributes map[string]string
+	for key, value := range values {
+		if attributes == nil {
+			attributes = make(map[string]string, len(values))
+		}
+
+		var s string
+		if json.Unmarshal(value, &s) == nil {
+			attributes[key] = s
+		} else {
+			attributes[key] = string(value)
+		}
+	}
+
+	return attributes, nil
+}
@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+	"time"
+)
+
+// Environment variable of PostgreSQL connection string which enables integration tests
+const testUrlEnv = "PUSU_POSTGRES_URL"
+
+func TestPgxClientWrapper_CreateSubscription(t *testing.T) {
+	client, names := newIntegrationClient(t)
+	ctx := context.Background()
+
+	// Creating same subscription again must be idempotent
+	require.NoError(t, client.CreateSubscription(ctx, names+"-topic", names+"-sub"))
+	assert.NoError(t, client.CreateSubscription(ctx, names+"-topic", names+"-sub"))
+
+	// Subscription bound to another topic must not be accepted
+	assert.Error(t, client.CreateSubscription(ctx, names+"-other", names+"-sub"))
+}
+
+func TestPgxClientWrapper_Deliveries(t *testing.T) {
+	client, names := newIntegrationClient(t)
+	ctx := context.Background()
+	topic, subscription := names+"-topic", names+"-sub"
+	require.NoError(t, client.CreateSubscription(ctx, topic, subscription))
+
+	// Listen before publishing, so the notification of published message is not missed
+	l, err := client.Listen(ctx)
+	require.NoError(t, err)
+	defer l.Close(ctx)
+
+	_, err = client.pool.Exec(ctx,
+		`INSERT INTO pusu_messages (topic, payload, attributes) VALUES ($1, 'hello', '{"type": "created"}')`, topic)
+	require.NoError(t, err)
+	assert.NoError(t, l.Wait(ctx, topic, time.Second))
+
+	// Published message must be fanned out with its attributes and claimed once until visibility timeout passes
+	deliveries, err := client.Claim(ctx, subscription, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, "hello", deliveries[0].payload)
+	assert.Equal(t, map[string]string{"type": "created"}, deliveries[0].attributes)
+	assert.Equal(t, 1, deliveries[0].attempts)
+
+	empty, err := client.Claim(ctx, subscription, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	// Postponed delivery must be claimed again without counting the attempt, but as a new claim which earlier claimer
+	// can not extend
+	d := deliveries[0]
+	require.NoError(t, client.Postpone(ctx, d, 0))
+	deliveries, err = client.Claim(ctx, subscription, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, 1, deliveries[0].attempts)
+
+	claimed, err := client.Extend(ctx, d, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	// Earlier claimer must not retry, delete or dead letter delivery claimed again
+	require.NoError(t, client.Retry(ctx, d, 0))
+	require.NoError(t, client.Delete(ctx, d))
+	require.NoError(t, client.DeadLetter(ctx, d, "failed"))
+	empty, err = client.Claim(ctx, subscription, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	// Retried delivery must be claimed again with its attempt counted
+	d = deliveries[0]
+	claimed, err = client.Extend(ctx, d, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	require.NoError(t, client.Retry(ctx, d, 0))
+	deliveries, err = client.Claim(ctx, subscription, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, 2, deliveries[0].attempts)
+
+	// Dead lettered delivery must not be claimed again
+	d = deliveries[0]
+	require.NoError(t, client.DeadLetter(ctx, d, "failed"))
+	var deadLetters int
+	require.NoError(t, client.pool.QueryRow(ctx,
+		"SELECT count(*) FROM pusu_dead_letters WHERE id = $1", d.id).Scan(&deadLetters))
+	assert.Equal(t, 1, deadLetters)
+	deliveries, err = client.Claim(ctx, subscription, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+
+	// Deleted delivery must be gone
+	_, err = client.pool.Exec(ctx, "INSERT INTO pusu_messages (topic, payload) VALUES ($1, 'bye')", topic)
+	require.NoError(t, err)
+	deliveries, err = client.Claim(ctx, subscription, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Nil(t, deliveries[0].attributes)
+	require.NoError(t, client.Delete(ctx, deliveries[0]))
+	deliveries, err = client.Claim(ctx, subscription, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+}
+
+func TestDecodeAttributes(t *testing.T) {
+	// Values which are not strings must be taken in their JSON form
+	attributes, err := decodeAttributes([]byte(`{"type": "created", "version": 2, "draft": false}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"type": "created", "version": "2", "draft": "false"}, attributes)
+
+	// Null and empty attributes must be nil
+	for _, column := range [][]byte{nil, []byte(`{}`)} {
+		attributes, err = decodeAttributes(column)
+		assert.NoError(t, err)
+		assert.Nil(t, attributes)
+	}
+}
+
+// Connects to database of testUrlEnv and returns a client with a unique name prefix, skipping test if it is not set
+func newIntegrationClient(t *testing.T) (*pgxClientWrapper, string) {
+	url := os.Getenv(testUrlEnv)
+	if url == "" {
+		t.Skipf("%s is not set", testUrlEnv)
+	}
+
+	pool, err := pgxpool.New(context.Background(), url)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	return &pgxClientWrapper{pool: pool}, fmt.Sprintf("test-%d", time.Now().UnixNano())
+}
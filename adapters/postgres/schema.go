@@ -0,0 +1,104 @@
+package postgres
+
+const (
+	// Notification channel of published messages. Payload of notifications is the topic name.
+	notifyChannel = "pusu"
+
+	// Lock key which serializes schema migrations of concurrently starting subscribers
+	schemaLockKey = 7_301_908_551
+
+	// Tables, fan-out trigger and indexes. Every statement is idempotent.
+	//
+	// Messages are published by inserting into pusu_messages, optionally within the transaction of the business
+	// change. Trigger copies each message into pusu_deliveries for every subscription of its topic, notifies
+	// listeners on commit and discards the published row, so pusu_messages stays empty.
+	schema = `
+CREATE TABLE IF NOT EXISTS pusu_topics (
+	name text PRIMARY KEY
+);
+
+CREATE TABLE IF NOT EXISTS pusu_subscriptions (
+	name text PRIMARY KEY,
+	topic text NOT NULL REFERENCES pusu_topics (name)
+);
+
+CREATE TABLE IF NOT EXISTS pusu_messages (
+	topic text NOT NULL,
+	payload text NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS pusu_deliveries (
+	id bigserial PRIMARY KEY,
+	subscription text NOT NULL REFERENCES pusu_subscriptions (name) ON DELETE CASCADE,
+	payload text NOT NULL,
+	attempts integer NOT NULL DEFAULT 0,
+	visible_at timestamptz NOT NULL DEFAULT now(),
+	published_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- Attributes are JSON objects of messages. Columns are added separately, so tables of earlier versions get them too.
+ALTER TABLE pusu_messages ADD COLUMN IF NOT EXISTS attributes jsonb CHECK (jsonb_typeof(attributes) = 'object');
+ALTER TABLE pusu_deliveries ADD COLUMN IF NOT EXISTS attributes jsonb;
+
+-- Claims count every claim of a delivery, unlike attempts which are not counted on rejections. Claim count is the lease
+-- of a claimer, so a claimer whose visibility timeout passed can not change a delivery claimed again.
+ALTER TABLE pusu_deliveries ADD COLUMN IF NOT EXISTS claims bigint NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS pusu_deliveries_visible ON pusu_deliveries (subscription, visible_at, id);
+
+CREATE TABLE IF NOT EXISTS pusu_dead_letters (
+	id bigint PRIMARY KEY,
+	subscription text NOT NULL,
+	payload text NOT NULL,
+	attempts integer NOT NULL,
+	reason text NOT NULL,
+	published_at timestamptz NOT NULL,
+	failed_at timestamptz NOT NULL DEFAULT now()
+);
+
+ALTER TABLE pusu_dead_letters ADD COLUMN IF NOT EXISTS attributes jsonb;
+
+CREATE OR REPLACE FUNCTION pusu_fan_out() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO pusu_deliveries (subscription, payload, attributes)
+		SELECT name, NEW.payload, NEW.attributes FROM pusu_subscriptions WHERE topic = NEW.topic;
+	PERFORM pg_notify('` + notifyChannel + `', NEW.topic);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER pusu_fan_out BEFORE INSERT ON pusu_messages
+	FOR EACH ROW EXECUTE FUNCTION pusu_fan_out();
+`
+
+	// Inserts subscription unless it exists and returns topic of subscription. Conflicting row is updated without
+	// changes, so it is returned too.
+	subscriptionQuery = `
+INSERT INTO pusu_subscriptions (name, topic) VALUES ($1, $2)
+ON CONFLICT (name) DO UPDATE SET topic = pusu_subscriptions.topic
+RETURNING topic`
+
+	// Claims visible deliveries of subscription, skipping rows locked by other subscribers.
+	// Claimed deliveries are hidden until visibility timeout passes, so they are delivered again
+	// if subscriber dies before deleting them.
+	claimQuery = `
+UPDATE pusu_deliveries d SET attempts = d.attempts + 1, claims = d.claims + 1, visible_at = now() + make_interval(secs => $3)
+FROM (
+	SELECT id FROM pusu_deliveries
+	WHERE subscription = $1 AND visible_at <= now()
+	ORDER BY visible_at, id
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED
+) claimed
+WHERE d.id = claimed.id
+RETURNING d.id, d.payload, d.attributes, d.attempts, d.claims`
+
+	// Moves delivery to dead letters unless it is claimed again
+	deadLetterQuery = `
+WITH failed AS (
+	DELETE FROM pusu_deliveries WHERE id = $1 AND claims = $2
+	RETURNING id, subscription, payload, attributes, attempts, published_at
+)
+INSERT INTO pusu_dead_letters (id, subscription, payload, attributes, attempts, reason, published_at)
+SELECT id, subscription, payload, attributes, attempts, $3, published_at FROM failed`
+)
@@ -0,0 +1,16 @@
+package postgres
+
+import (
+	"context"
+	"github.com/metglobal-compass/pusu"
+)
+
+type subscriptionAdder struct {
+	client client
+}
+
+// Implementation of internal Creator interface for Postgres Adapter.
+// Creates tables of adapter if they don't exist, then topic and subscription rows.
+func (s *subscriptionAdder) CreateSubscription(subscription pusu.Subscription) error {
+	return s.client.CreateSubscription(context.Background(), subscription.Topic(), subscription.Name())
+}
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"github.com/metglobal-compass/pusu/pusutest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+func TestSubscriptionAdder_CreateSubscription(t *testing.T) {
+	// Create fake mocked client
+	fakeClient := new(fakeClient)
+	fakeClient.On("CreateSubscription", context.Background(), "test", "testing").Return(nil)
+
+	// Call real method
+	subscriptionAdder := &subscriptionAdder{client: fakeClient}
+	err := subscriptionAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Subscription row must be created for topic
+	assert.Nil(t, err)
+	fakeClient.AssertExpectations(t)
+}
+
+func TestSubscriptionAdder_CreateSubscriptionError(t *testing.T) {
+	// Create fake mocked client. In this case, we get an error while creating subscription
+	fakeClient := new(fakeClient)
+	fakeClient.On("CreateSubscription", context.Background(), "test", "testing").Return(errors.New("error"))
+
+	// Call real method
+	subscriptionAdder := &subscriptionAdder{client: fakeClient}
+	err := subscriptionAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Got an error
+	assert.Error(t, err)
+}
+
+type fakeClient struct {
+	mock.Mock
+}
+
+func (f *fakeClient) CreateSubscription(ctx context.Context, topic string, name string) error {
+	args := f.Called(ctx, topic, name)
+	return args.Error(0)
+}
+
+func (f *fakeClient) Claim(ctx context.Context, subscription string, limit int, visibility time.Duration) ([]delivery, error) {
+	args := f.Called(ctx, subscription, limit, visibility)
+	deliveries, _ := args.Get(0).([]delivery)
+	return deliveries, args.Error(1)
+}
+
+func (f *fakeClient) Extend(ctx context.Context, d delivery, visibility time.Duration) (bool, error) {
+	args := f.Called(ctx, d, visibility)
+	return args.Bool(0), args.Error(1)
+}
+
+func (f *fakeClient) Delete(ctx context.Context, d delivery) error {
+	args := f.Called(ctx, d.id)
+	return args.Error(0)
+}
+
+func (f *fakeClient) Retry(ctx context.Context, d delivery, delay time.Duration) error {
+	args := f.Called(ctx, d.id, delay)
+	return args.Error(0)
+}
+
+func (f *fakeClient) Postpone(ctx context.Context, d delivery, delay time.Duration) error {
+	args := f.Called(ctx, d.id, delay)
+	return args.Error(0)
+}
+
+func (f *fakeClient) DeadLetter(ctx context.Context, d delivery, reason string) error {
+	args := f.Called(ctx, d.id, reason)
+	return args.Error(0)
+}
+
+func (f *fakeClient) Listen(ctx context.Context) (listener, error) {
+	args := f.Called(ctx)
+	l, _ := args.Get(0).(listener)
+	return l, args.Error(1)
+}
+
+type fakeListener struct {
+	mock.Mock
+}
+
+func (f *fakeListener) Wait(ctx context.Context, topic string, timeout time.Duration) error {
+	args := f.Called(ctx, topic, timeout)
+	return args.Error(0)
+}
+
+func (f *fakeListener) Close(ctx context.Context) error {
+	args := f.Called(ctx)
+	return args.Error(0)
+}
@@ -0,0 +1,75 @@
+// Package redis provides implementation of pub/sub workflow on Redis Streams.
+// Topic of a subscription is a stream and name of a subscription is a consumer group of that stream.
+// Payload of a message is the "data" field of stream entry and its attributes are other fields. Entries are acked after successful handling.
+// Failed entries and entries of crashed consumers stay pending and are claimed again after an idle duration.
+// Entries failed on their last delivery are moved to dead letter stream <stream>:dead.
+package redis
+
+import (
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	goredis "github.com/redis/go-redis/v9"
+	"net/url"
+)
+
+type Adapter struct {
+	// Adds stream and consumer group of subscription to Redis
+	groupAdder pusu.Creator
+
+	// Runs subscription as consumer group member
+	runner pusu.Runner
+
+	// Validates and provisions subscriptions, tracking their health and metrics
+	pusu.Provisioner
+}
+
+// Implementation of pusu.Creator interface as part of pusu.Adapter interface
+func (r *Adapter) CreateSubscription(subscription pusu.Subscription) error {
+	return r.Provision(subscription, r.groupAdder)
+}
+
+// Implementation of pusu.Runner interface as part of pusu.Adapter interface
+func (r *Adapter) Run(subscription pusu.Subscription) error {
+	err := r.runner.Run(subscription)
+	return err
+}
+
+// Creates Redis Adapter
+// url: Redis server url. (Ex: redis://localhost:6379/0)
+func CreateAdapter(url string) (*Adapter, error) {
+	// Validate parameters
+	if url == "" {
+		return nil, errors.New("url must not be empty")
+	}
+
+	options, err := goredis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	client := &redisClientWrapper{client: goredis.NewClient(options)}
+
+	redisAdapter := new(Adapter)
+	redisAdapter.groupAdder = &groupAdder{client: client}
+	redisAdapter.runner = &streamRunner{
+		client:           client,
+		consumer:         consumerName(),
+		batchSize:        defaultBatchSize,
+		block:            defaultBlock,
+		minIdle:          defaultMinIdle,
+		maxDeliveries:    defaultMaxDeliveries,
+		rejectionBackoff: defaultRejectionBackoff,
+		metrics:          redisAdapter.Metrics(),
+	}
+
+	return redisAdapter, nil
+}
+
+func init() {
+	pusu.RegisterScheme("redis", open)
+	pusu.RegisterScheme("rediss", open)
+}
+
+// Opens Redis Adapter of url in form of redis://<host>:<port>/<db>
+func open(u *url.URL) (pusu.Adapter, error) {
+	return CreateAdapter(u.String())
+}
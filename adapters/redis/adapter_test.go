@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"github.com/metglobal-compass/pusu"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAdapter_CreateAdapter(t *testing.T) {
+	// Call real method and check each interfaces have proper types
+	adapter, err := CreateAdapter("redis://localhost:6379/0")
+
+	assert.Nil(t, err)
+
+	groupAdder, groupAdderProper := adapter.groupAdder.(*groupAdder)
+	assert.True(t, groupAdderProper, "Group adder is not proper")
+
+	_, redisClientProper := groupAdder.client.(*redisClientWrapper)
+	assert.True(t, redisClientProper, "Redis client is not proper")
+
+	streamRunner, streamRunnerProper := adapter.runner.(*streamRunner)
+	assert.True(t, streamRunnerProper, "Runner is not proper")
+	assert.NotEmpty(t, streamRunner.consumer)
+	assert.True(t, streamRunner.metrics == adapter.Metrics(), "Runner metrics is not proper")
+}
+
+func TestAdapter_CreateAdapterErrorWithEmptyUrl(t *testing.T) {
+	// Call real method without url
+	_, err := CreateAdapter("")
+	assert.Error(t, err)
+}
+
+func TestAdapter_CreateAdapterErrorWithInvalidUrl(t *testing.T) {
+	// Call real method with a url which is not a Redis url
+	_, err := CreateAdapter("http://localhost")
+	assert.Error(t, err)
+}
+
+func TestAdapter_Open(t *testing.T) {
+	// Open adapter by url
+	adapter, err := pusu.Open("redis://localhost:6379/0")
+	assert.Nil(t, err)
+	_, proper := adapter.(*Adapter)
+	assert.True(t, proper, "Adapter is not proper")
+	assert.Contains(t, pusu.Schemes(), "rediss")
+}
@@ -0,0 +1,17 @@
+package redis
+
+import (
+	"context"
+	goredis "github.com/redis/go-redis/v9"
+	"time"
+)
+
+// Redis Streams operations used while provisioning and running subscriptions
+type client interface {
+	CreateGroup(ctx context.Context, stream string, group string) error
+	ReadGroup(ctx context.Context, stream string, group string, consumer string, count int64, block time.Duration) ([]goredis.XMessage, error)
+	Ack(ctx context.Context, stream string, group string, id string) error
+	AutoClaim(ctx context.Context, stream string, group string, consumer string, minIdle time.Duration, start string, count int64) ([]goredis.XMessage, string, error)
+	DeliveryCount(ctx context.Context, stream string, group string, id string) (int64, error)
+	DeadLetter(ctx context.Context, stream string, group string, m goredis.XMessage, reason string) error
+}
@@ -0,0 +1,16 @@
+package redis
+
+import (
+	"context"
+	"github.com/metglobal-compass/pusu"
+)
+
+type groupAdder struct {
+	client client
+}
+
+// Implementation of internal Creator interface for Redis Adapter.
+// Stream of topic is created together with consumer group of subscription (XGROUP CREATE MKSTREAM).
+func (g *groupAdder) CreateSubscription(subscription pusu.Subscription) error {
+	return g.client.CreateGroup(context.Background(), subscription.Topic(), subscription.Name())
+}
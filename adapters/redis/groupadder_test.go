@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"github.com/metglobal-compass/pusu/pusutest"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+func TestGroupAdder_CreateSubscription(t *testing.T) {
+	// Create fake mocked client
+	fakeClient := new(fakeClient)
+	fakeClient.On("CreateGroup", context.Background(), "test", "testing").Return(nil)
+
+	// Call real method
+	groupAdder := &groupAdder{client: fakeClient}
+	err := groupAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Consumer group of subscription must be created on stream of topic
+	assert.Nil(t, err)
+	fakeClient.AssertExpectations(t)
+}
+
+func TestGroupAdder_CreateSubscriptionError(t *testing.T) {
+	// Create fake mocked client. In this case, we get an error while creating consumer group
+	fakeClient := new(fakeClient)
+	fakeClient.On("CreateGroup", context.Background(), "test", "testing").Return(errors.New("error"))
+
+	// Call real method
+	groupAdder := &groupAdder{client: fakeClient}
+	err := groupAdder.CreateSubscription(new(pusutest.Subscription).WillHaveProperFields())
+
+	// Got an error
+	assert.Error(t, err)
+}
+
+type fakeClient struct {
+	mock.Mock
+}
+
+func (f *fakeClient) CreateGroup(ctx context.Context, stream string, group string) error {
+	args := f.Called(ctx, stream, group)
+	return args.Error(0)
+}
+
+func (f *fakeClient) ReadGroup(ctx context.Context, stream string, group string, consumer string, count int64, block time.Duration) ([]goredis.XMessage, error) {
+	args := f.Called(ctx, stream, group, consumer, count, block)
+	messages, _ := args.Get(0).([]goredis.XMessage)
+	return messages, args.Error(1)
+}
+
+func (f *fakeClient) Ack(ctx context.Context, stream string, group string, id string) error {
+	args := f.Called(ctx, stream, group, id)
+	return args.Error(0)
+}
+
+func (f *fakeClient) AutoClaim(ctx context.Context, stream string, group string, consumer string, minIdle time.Duration, start string, count int64) ([]goredis.XMessage, string, error) {
+	args := f.Called(ctx, stream, group, consumer, minIdle, start, count)
+	messages, _ := args.Get(0).([]goredis.XMessage)
+	return messages, args.String(1), args.Error(2)
+}
+
+func (f *fakeClient) DeliveryCount(ctx context.Context, stream string, group string, id string) (int64, error) {
+	args := f.Called(ctx, stream, group, id)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (f *fakeClient) DeadLetter(ctx context.Context, stream string, group string, m goredis.XMessage, reason string) error {
+	args := f.Called(ctx, stream, group, m, reason)
+	return args.Error(0)
+}
@@ -0,0 +1,98 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	goredis "github.com/redis/go-redis/v9"
+	"strings"
+	"time"
+)
+
+// Redis client wrapper which implements client interface
+type redisClientWrapper struct {
+	client goredis.UniversalClient
+}
+
+// Creates consumer group reading new entries of stream. Stream is created if it does not exist.
+// Existing consumer groups are left as is.
+func (r *redisClientWrapper) CreateGroup(ctx context.Context, stream string, group string) error {
+	err := r.client.XGroupCreateMkStream(ctx, stream, group, "$").Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+
+	return err
+}
+
+// Reads new entries of stream for consumer. Returns no entries when block duration passes without any entry.
+func (r *redisClientWrapper) ReadGroup(ctx context.Context, stream string, group string, consumer string, count int64, block time.Duration) ([]goredis.XMessage, error) {
+	streams, err := r.client.XReadGroup(ctx, &goredis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, goredis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []goredis.XMessage
+	for _, s := range streams {
+		messages = append(messages, s.Messages...)
+	}
+
+	return messages, nil
+}
+
+func (r *redisClientWrapper) Ack(ctx context.Context, stream string, group string, id string) error {
+	return r.client.XAck(ctx, stream, group, id).Err()
+}
+
+// Claims pending entries idle longer than minIdle, starting from start id. Returns start id of next call.
+func (r *redisClientWrapper) AutoClaim(ctx context.Context, stream string, group string, consumer string, minIdle time.Duration, start string, count int64) ([]goredis.XMessage, string, error) {
+	return r.client.XAutoClaim(ctx, &goredis.XAutoClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Start:    start,
+		Count:    count,
+	}).Result()
+}
+
+// Returns number of times pending entry is delivered to consumers of group. Returns zero if entry is not pending.
+func (r *redisClientWrapper) DeliveryCount(ctx context.Context, stream string, group string, id string) (int64, error) {
+	pending, err := r.client.XPendingExt(ctx, &goredis.XPendingExtArgs{
+		Stream: stream,
+		Group:  group,
+		Start:  id,
+		End:    id,
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+
+	return pending[0].RetryCount, nil
+}
+
+// Adds entry to dead letter stream of stream together with group and failure reason, and acks it in the same
+// transaction
+func (r *redisClientWrapper) DeadLetter(ctx context.Context, stream string, group string, m goredis.XMessage, reason string) error {
+	values := map[string]interface{}{groupField: group, idField: m.ID, reasonField: reason}
+	for key, value := range m.Values {
+		values[key] = value
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.XAdd(ctx, &goredis.XAddArgs{Stream: deadLetterStream(stream), Values: values})
+		pipe.XAck(ctx, stream, group, m.ID)
+		return nil
+	})
+
+	return err
+}
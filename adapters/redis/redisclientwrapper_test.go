@@ -0,0 +1,46 @@
+package redis
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRedisClientWrapper(t *testing.T) {
+	// Connect to in-process Redis server
+	server := miniredis.RunT(t)
+	redisClient := goredis.NewClient(&goredis.Options{Addr: server.Addr()})
+	defer redisClient.Close()
+	wrapper := &redisClientWrapper{client: redisClient}
+	ctx := context.Background()
+
+	// Consumer group must be created with its stream, and creating it again must not fail
+	assert.Nil(t, wrapper.CreateGroup(ctx, "test", "testing"))
+	assert.Nil(t, wrapper.CreateGroup(ctx, "test", "testing"))
+
+	// Add an entry to stream. It must be read by consumer of group.
+	redisClient.XAdd(ctx, &goredis.XAddArgs{Stream: "test", Values: map[string]interface{}{"data": "payload"}})
+	messages, err := wrapper.ReadGroup(ctx, "test", "testing", "crashed", 10, time.Millisecond)
+	assert.Nil(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "payload", messages[0].Values["data"])
+
+	// Reading again must return no entries when there is no new entry
+	messages, err = wrapper.ReadGroup(ctx, "test", "testing", "crashed", 10, time.Millisecond)
+	assert.Nil(t, err)
+	assert.Empty(t, messages)
+
+	// Unacked entry of crashed consumer must be claimed by another consumer
+	claimed, _, err := wrapper.AutoClaim(ctx, "test", "testing", "consumer", 0, "0-0", 10)
+	assert.Nil(t, err)
+	assert.Len(t, claimed, 1)
+
+	// Acked entry must not be pending anymore
+	assert.Nil(t, wrapper.Ack(ctx, "test", "testing", claimed[0].ID))
+	claimed, _, err = wrapper.AutoClaim(ctx, "test", "testing", "consumer", 0, "0-0", 10)
+	assert.Nil(t, err)
+	assert.Empty(t, claimed)
+}
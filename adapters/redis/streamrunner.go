@@ -0,0 +1,158 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/metglobal-compass/pusu"
+	goredis "github.com/redis/go-redis/v9"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// Field of stream entries which holds message payload
+	payloadField = "data"
+
+	// Maximum number of entries read or claimed at once
+	defaultBatchSize = 10
+
+	// Maximum waiting duration for new entries before checking stale pending entries again
+	defaultBlock = 5 * time.Second
+
+	// Pending entries idle longer than this duration are claimed from their consumers. Entries of crashed consumers
+	// and failed entries are redelivered this way.
+	defaultMinIdle = time.Minute
+
+	// Entries are moved to dead letter stream after this many failed deliveries
+	defaultMaxDeliveries = 5
+
+	// Waiting duration before handling an entry again when subscription rejects it (e.g. circuit is open)
+	defaultRejectionBackoff = time.Second
+
+	// Dead letter stream of a stream, which failed entries of its consumer groups are moved to
+	deadLetterStreamPattern = "%s:dead"
+
+	// Fields added to dead lettered entries
+	groupField  = "pusu-group"
+	idField     = "pusu-id"
+	reasonField = "pusu-reason"
+)
+
+// Consumer group runner which implements pusu.Runner interface
+type streamRunner struct {
+	client client
+
+	// Name of consumer in consumer groups. Must be unique among running processes.
+	consumer string
+
+	batchSize        int64
+	block            time.Duration
+	minIdle          time.Duration
+	maxDeliveries    int64
+	rejectionBackoff time.Duration
+
+	// Counts outcomes of handled messages. Optional.
+	metrics *pusu.Metrics
+}
+
+// Consumes stream of subscription until client fails. Stale pending entries are claimed and handled before
+// each read of new entries. An entry is acked after successful handling, otherwise it stays pending until claimed.
+// Entries failed on their last delivery, or which handler requires to dead letter, are moved to dead letter stream.
+func (s *streamRunner) Run(subscription pusu.Subscription) error {
+	ctx := context.Background()
+	start := "0-0"
+
+	for {
+		claimed, next, err := s.client.AutoClaim(ctx, subscription.Topic(), subscription.Name(), s.consumer, s.minIdle, start, s.batchSize)
+		if err != nil {
+			return err
+		}
+		start = next
+
+		read, err := s.client.ReadGroup(ctx, subscription.Topic(), subscription.Name(), s.consumer, s.batchSize, s.block)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range append(claimed, read...) {
+			err = s.handle(ctx, subscription, m)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Handles entry with subscription and acks it on success
+func (s *streamRunner) handle(ctx context.Context, subscription pusu.Subscription, m goredis.XMessage) error {
+	for {
+		message := pusu.NewMessage(m.Values[payloadField]).WithAttributes(attributes(m)).WithContext(ctx)
+		err := pusu.HandleBlocking(ctx, subscription, message)
+		if s.metrics != nil {
+			s.metrics.Observe(subscription, err)
+		}
+
+		if err == nil {
+			return s.client.Ack(ctx, subscription.Topic(), subscription.Name(), m.ID)
+		}
+
+		// Handler was not called at all. Every claim counts as a delivery, so try same entry again later instead of
+		// leaving it pending.
+		if pusu.IsRejection(err) {
+			time.Sleep(s.rejectionBackoff)
+			continue
+		}
+
+		return s.fail(ctx, subscription, m, err)
+	}
+}
+
+// Leaves failed entry pending to be claimed again, or moves it to dead letter stream on its last delivery
+func (s *streamRunner) fail(ctx context.Context, subscription pusu.Subscription, m goredis.XMessage, err error) error {
+	if !errors.Is(err, pusu.ErrDeadLetter) {
+		deliveries, countErr := s.client.DeliveryCount(ctx, subscription.Topic(), subscription.Name(), m.ID)
+		if countErr != nil {
+			return countErr
+		}
+		if deliveries < s.maxDeliveries {
+			log.Printf("Handler of subscription %s failed on entry %s: %s", subscription.Name(), m.ID, err)
+			return nil
+		}
+	}
+
+	log.Printf("Handler of subscription %s failed on last delivery of entry %s, entry is dead lettered: %s",
+		subscription.Name(), m.ID, err)
+	return s.client.DeadLetter(ctx, subscription.Topic(), subscription.Name(), m, err.Error())
+}
+
+// Returns name of dead letter stream of stream
+func deadLetterStream(stream string) string {
+	return fmt.Sprintf(deadLetterStreamPattern, stream)
+}
+
+// Returns a consumer name unique among running processes
+func consumerName() string {
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// Returns attributes of entry, which are its string fields other than payload field and fields of pusu.
+// Returns nil if there are none.
+func attributes(m goredis.XMessage) map[string]string {
+	var attributes map[string]string
+	for field, value := range m.Values {
+		v, ok := value.(string)
+		if !ok || field == payloadField || strings.HasPrefix(field, "pusu-") {
+			continue
+		}
+		if attributes == nil {
+			attributes = make(map[string]string, len(m.Values))
+		}
+		attributes[field] = v
+	}
+
+	return attributes
+}
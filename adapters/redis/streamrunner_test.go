@@ -0,0 +1,176 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/metglobal-compass/pusu"
+	"github.com/metglobal-compass/pusu/pusutest"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+func TestStreamRunner_Run(t *testing.T) {
+	// Create fake client which claims a stale entry and reads a new entry, then fails on next claim
+	fakeClient := new(fakeClient)
+	claimed := goredis.XMessage{ID: "1-0", Values: map[string]interface{}{"data": "claimed"}}
+	read := goredis.XMessage{ID: "2-0", Values: map[string]interface{}{"data": "read", "type": "created", idField: "1-0"}}
+	fakeClient.On("AutoClaim", mock.Anything, "test", "testing", "consumer", time.Minute, "0-0", int64(10)).
+		Return([]goredis.XMessage{claimed}, "1-1", nil).Once()
+	fakeClient.On("AutoClaim", mock.Anything, "test", "testing", "consumer", time.Minute, "1-1", int64(10)).
+		Return(nil, "", errors.New("error"))
+	fakeClient.On("ReadGroup", mock.Anything, "test", "testing", "consumer", int64(10), time.Second).
+		Return([]goredis.XMessage{read}, nil)
+	fakeClient.On("Ack", mock.Anything, "test", "testing", mock.Anything).Return(nil)
+
+	// Call real method with a successful subscription
+	runner := newTestRunner(fakeClient)
+	subscription := new(pusutest.Subscription).WillHaveProperFields()
+	err := runner.Run(subscription)
+
+	// Runner must return error of client
+	assert.Error(t, err)
+
+	// Both claimed and read entries must be handled with fields other than payload and fields of pusu, and acked
+	subscription.(*pusutest.Subscription).AssertCalled(t, "Handle", mock.MatchedBy(func(m *pusu.Message) bool {
+		return m.Message() == "claimed" && m.Attributes() == nil
+	}))
+	subscription.(*pusutest.Subscription).AssertCalled(t, "Handle", mock.MatchedBy(func(m *pusu.Message) bool {
+		return m.Message() == "read" && assert.ObjectsAreEqual(map[string]string{"type": "created"}, m.Attributes())
+	}))
+	fakeClient.AssertCalled(t, "Ack", mock.Anything, "test", "testing", "1-0")
+	fakeClient.AssertCalled(t, "Ack", mock.Anything, "test", "testing", "2-0")
+	assert.Equal(t, int64(2), runner.metrics.Count(subscription, pusu.OutcomeSuccess))
+}
+
+func TestStreamRunner_RunFailure(t *testing.T) {
+	// Create fake client which reads a new entry, then fails on next claim
+	fakeClient := new(fakeClient)
+	fakeClient.On("AutoClaim", mock.Anything, "test", "testing", "consumer", time.Minute, "0-0", int64(10)).
+		Return(nil, "0-0", nil).Once()
+	fakeClient.On("AutoClaim", mock.Anything, "test", "testing", "consumer", time.Minute, "0-0", int64(10)).
+		Return(nil, "", errors.New("error"))
+	fakeClient.On("ReadGroup", mock.Anything, "test", "testing", "consumer", int64(10), time.Second).
+		Return([]goredis.XMessage{{ID: "1-0", Values: map[string]interface{}{"data": "read"}}}, nil)
+	fakeClient.On("DeliveryCount", mock.Anything, "test", "testing", "1-0").Return(int64(1), nil)
+
+	// Call real method with a failing subscription
+	runner := newTestRunner(fakeClient)
+	runner.Run(new(pusutest.Subscription).WillReturnError())
+	fakeClient.AssertNotCalled(t, "DeadLetter", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	// Failed entry must stay pending to be claimed later
+	fakeClient.AssertNotCalled(t, "Ack", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestStreamRunner_RunErrorOnRead(t *testing.T) {
+	// Create fake client which fails while reading new entries
+	fakeClient := new(fakeClient)
+	fakeClient.On("AutoClaim", mock.Anything, "test", "testing", "consumer", time.Minute, "0-0", int64(10)).
+		Return(nil, "0-0", nil)
+	fakeClient.On("ReadGroup", mock.Anything, "test", "testing", "consumer", int64(10), time.Second).
+		Return(nil, errors.New("error"))
+
+	// Runner must return error
+	runner := newTestRunner(fakeClient)
+	assert.Error(t, runner.Run(new(pusutest.Subscription).WillHaveProperFields()))
+}
+
+func TestStreamRunner_HandleFailureOnLastDelivery(t *testing.T) {
+	// Entry failed on its last delivery must be moved to dead letter stream
+	entry := goredis.XMessage{ID: "1-0", Values: map[string]interface{}{"data": "payload"}}
+	fakeClient := new(fakeClient)
+	fakeClient.On("DeliveryCount", mock.Anything, "test", "testing", "1-0").Return(int64(5), nil)
+	fakeClient.On("DeadLetter", mock.Anything, "test", "testing", entry, "error").Return(nil)
+
+	runner := newTestRunner(fakeClient)
+	assert.Nil(t, runner.handle(context.Background(), new(pusutest.Subscription).WillReturnError(), entry))
+	fakeClient.AssertNumberOfCalls(t, "DeadLetter", 1)
+}
+
+func TestStreamRunner_HandleDeadLetter(t *testing.T) {
+	// Entry must be moved to dead letter stream on its first delivery when handler requires it
+	entry := goredis.XMessage{ID: "1-0", Values: map[string]interface{}{"data": "payload"}}
+	fakeClient := new(fakeClient)
+	fakeClient.On("DeadLetter", mock.Anything, "test", "testing", entry, pusu.ErrDeadLetter.Error()).Return(nil)
+
+	runner := newTestRunner(fakeClient)
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(pusu.ErrDeadLetter)
+	assert.Nil(t, runner.handle(context.Background(), subscription, entry))
+	fakeClient.AssertNumberOfCalls(t, "DeadLetter", 1)
+	fakeClient.AssertNotCalled(t, "DeliveryCount", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestStreamRunner_HandleRejection(t *testing.T) {
+	// Create subscription which rejects the first attempts
+	entry := goredis.XMessage{ID: "1-0", Values: map[string]interface{}{"data": "payload"}}
+	subscription := new(pusutest.Subscription).WithTopic("test").WithName("testing")
+	subscription.On("Handle", mock.Anything).Return(pusu.ErrCircuitOpen).Times(3)
+	subscription.On("Handle", mock.Anything).Return(nil)
+	fakeClient := new(fakeClient)
+	fakeClient.On("Ack", mock.Anything, "test", "testing", "1-0").Return(nil)
+
+	// Rejected entry must be handled again without leaving it pending to be claimed
+	runner := newTestRunner(fakeClient)
+	assert.Nil(t, runner.handle(context.Background(), subscription, entry))
+	subscription.AssertNumberOfCalls(t, "Handle", 4)
+	fakeClient.AssertNumberOfCalls(t, "Ack", 1)
+}
+
+func TestStreamRunner_RunDeadLetterStream(t *testing.T) {
+	// Connect to in-process Redis server with a stream entry
+	server := miniredis.RunT(t)
+	redisClient := goredis.NewClient(&goredis.Options{Addr: server.Addr()})
+	ctx := context.Background()
+	wrapper := &redisClientWrapper{client: redisClient}
+	assert.Nil(t, wrapper.CreateGroup(ctx, "test", "testing"))
+	redisClient.XAdd(ctx, &goredis.XAddArgs{Stream: "test", Values: map[string]interface{}{"data": "payload"}})
+
+	// Run a failing subscription which claims failed entries right away
+	runner := newTestRunner(wrapper)
+	runner.minIdle = 0
+	runner.block = time.Millisecond
+	runner.maxDeliveries = 3
+	subscription := new(pusutest.Subscription).WillReturnError()
+	result := make(chan error)
+	go func() {
+		result <- runner.Run(subscription)
+	}()
+
+	// Entry must be moved to dead letter stream after its last delivery and must not be pending anymore
+	assert.Eventually(t, func() bool {
+		dead, _ := redisClient.XRange(ctx, "test:dead", "-", "+").Result()
+		return len(dead) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	redisClient.Close()
+	assert.Error(t, <-result)
+
+	redisClient = goredis.NewClient(&goredis.Options{Addr: server.Addr()})
+	defer redisClient.Close()
+	dead, err := redisClient.XRange(ctx, "test:dead", "-", "+").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, "payload", dead[0].Values["data"])
+	assert.Equal(t, "testing", dead[0].Values[groupField])
+	assert.Equal(t, "error", dead[0].Values[reasonField])
+	pending, err := redisClient.XPending(ctx, "test", "testing").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), pending.Count)
+	subscription.AssertNumberOfCalls(t, "Handle", 3)
+}
+
+// Creates a stream runner with given fake client
+func newTestRunner(c client) *streamRunner {
+	return &streamRunner{
+		client:           c,
+		consumer:         "consumer",
+		batchSize:        defaultBatchSize,
+		block:            time.Second,
+		minIdle:          time.Minute,
+		maxDeliveries:    defaultMaxDeliveries,
+		rejectionBackoff: time.Millisecond,
+		metrics:          new(pusu.Metrics),
+	}
+}
@@ -0,0 +1,134 @@
+// Package webhook provides implementation of pub/sub workflow on signed http requests of arbitrary producers
+// (internal services, GitHub or Stripe-like systems). Each subscription is served on its own url path and raw
+// request body is handled as message payload. Attributes of messages are request headers other than transport and
+// signature ones, with lower case keys.
+// By default, requests must be signed with HMAC-SHA256 of "<timestamp>.<body>" using one of the shared secrets
+// (see Sign). Webhooks of GitHub and Stripe are verified with their own signature schemes instead (see SetScheme).
+// Requests with invalid signatures or timestamps outside of tolerance are rejected with 401 response code, so
+// captured requests can't be replayed later. Secrets may be rotated without downtime with SetSecrets.
+// If message processing is successful, pusu returns a 200 OK response code, otherwise producers are expected to
+// retry on 429 or 5xx response codes. Messages which require dead lettering (pusu.ErrDeadLetter) or match no route
+// (pusu.ErrUnmatched) get 422 response code, so producers don't retry them.
+// Requests are not deduplicated, so a request retried by its producer or replayed within the timestamp tolerance
+// (5 minutes) is handled again. Handlers must be idempotent, e.g. by keeping ids of handled events.
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"github.com/metglobal-compass/pusu"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	livenessPath  = "/healthz"
+	readinessPath = "/readyz"
+	metricsPath   = "/metrics"
+)
+
+type Adapter struct {
+	// Adds url path of subscription and handles verified requests as pusu.Message
+	httpHandlerAdder pusu.Creator
+
+	// Runs http server of webhooks
+	runner pusu.Runner
+
+	// Routes requests to webhook, health and metrics handlers
+	mux *http.ServeMux
+
+	// Verifies signatures and timestamps of requests
+	verifier *verifier
+
+	// Validates and provisions subscriptions, tracking their health and metrics
+	pusu.Provisioner
+}
+
+// Implementation of pusu.Creator interface as part of pusu.Adapter interface
+func (a *Adapter) CreateSubscription(subscription pusu.Subscription) error {
+	return a.Provision(subscription, a.httpHandlerAdder)
+}
+
+// Implementation of pusu.Runner interface as part of pusu.Adapter interface
+func (a *Adapter) Run(subscription pusu.Subscription) error {
+	err := a.runner.Run(subscription)
+	return err
+}
+
+// Implementation of net/http Handler interface, so webhooks may be served on an existing http server instead of Run
+func (a *Adapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mux.ServeHTTP(w, r)
+}
+
+// Replaces accepted secrets. During rotation, pass both new and old secret until every producer signs with new one.
+// Secrets are kept as is if none or an empty one is passed.
+func (a *Adapter) SetSecrets(secrets ...string) error {
+	return a.verifier.SetSecrets(secrets...)
+}
+
+// Replaces signature scheme of requests. (Ex: GitHubScheme{} or StripeScheme{}) Defaults to PusuScheme{}.
+func (a *Adapter) SetScheme(scheme Scheme) {
+	a.verifier.SetScheme(scheme)
+}
+
+// Creates Webhook Adapter
+// address: Listening address of http server. (Ex: :8080)
+// secrets: Shared secrets of producers. Requests signed with any of them are accepted.
+func CreateAdapter(address string, secrets ...string) (*Adapter, error) {
+	// Validate parameters
+	if address == "" {
+		return nil, errors.New("address must not be empty")
+	}
+	webhookAdapter := new(Adapter)
+	webhookAdapter.mux = http.NewServeMux()
+	webhookAdapter.verifier = &verifier{scheme: PusuScheme{}, tolerance: defaultTolerance, now: time.Now}
+	err := webhookAdapter.verifier.SetSecrets(secrets...)
+	if err != nil {
+		return nil, err
+	}
+	webhookAdapter.httpHandlerAdder = &httpHandlerAdder{
+		mux:            webhookAdapter.mux,
+		verifier:       webhookAdapter.verifier,
+		handlerTimeout: defaultHandlerTimeout,
+		metrics:        webhookAdapter.Metrics(),
+	}
+	webhookAdapter.runner = &httpRunner{address: address, handler: webhookAdapter.mux}
+
+	// Add health and metrics handlers
+	webhookAdapter.mux.HandleFunc(livenessPath, webhookAdapter.Health().ServeLiveness)
+	webhookAdapter.mux.HandleFunc(readinessPath, webhookAdapter.Health().ServeReadiness)
+	webhookAdapter.mux.Handle(metricsPath, webhookAdapter.Metrics())
+
+	return webhookAdapter, nil
+}
+
+func init() {
+	pusu.RegisterScheme("webhook", open)
+}
+
+// Signature schemes by their names in urls
+var schemes = map[string]Scheme{
+	"pusu":   PusuScheme{},
+	"github": GitHubScheme{},
+	"stripe": StripeScheme{},
+}
+
+// Opens Webhook Adapter of url in form of
+// webhook://<listening address>?secret=<secret>[&secret=<secret>...][&scheme=pusu|github|stripe]
+func open(u *url.URL) (pusu.Adapter, error) {
+	webhookAdapter, err := CreateAdapter(u.Host, u.Query()["secret"]...)
+	if err != nil {
+		return nil, err
+	}
+
+	if name := u.Query().Get("scheme"); name != "" {
+		scheme, ok := schemes[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown signature scheme %q", name)
+		}
+		webhookAdapter.SetScheme(scheme)
+	}
+
+	return webhookAdapter, nil
+}
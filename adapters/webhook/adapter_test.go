@@ -0,0 +1,95 @@
+package webhook
+
+import (
+	"github.com/metglobal-compass/pusu"
+	"github.com/metglobal-compass/pusu/pusutest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdapter_CreateAdapter(t *testing.T) {
+	// Call real method and check each interfaces have proper types
+	adapter, err := CreateAdapter(":8080", "secret")
+
+	assert.Nil(t, err)
+
+	httpHandlerAdder, httpHandlerAdderProper := adapter.httpHandlerAdder.(*httpHandlerAdder)
+	assert.True(t, httpHandlerAdderProper, "Http handler adder is not proper")
+	assert.True(t, httpHandlerAdder.verifier == adapter.verifier, "Verifier is not proper")
+	assert.True(t, httpHandlerAdder.metrics == adapter.Metrics(), "Http handler metrics is not proper")
+
+	httpRunner, httpRunnerProper := adapter.runner.(*httpRunner)
+	assert.True(t, httpRunnerProper, "Runner is not proper")
+	assert.Equal(t, ":8080", httpRunner.address)
+}
+
+func TestAdapter_CreateAdapterErrorWithEmptyParameters(t *testing.T) {
+	// Call real method without address, secrets or with an empty secret
+	_, err := CreateAdapter("", "secret")
+	assert.Error(t, err)
+	_, err = CreateAdapter(":8080")
+	assert.Error(t, err)
+	_, err = CreateAdapter(":8080", "secret", "")
+	assert.Error(t, err)
+}
+
+func TestAdapter_ServeHTTP(t *testing.T) {
+	// Create adapter and subscription
+	adapter, _ := CreateAdapter(":8080", "old", "new")
+	subscription := new(pusutest.Subscription).WillHaveProperFields()
+	assert.Nil(t, adapter.CreateSubscription(subscription))
+
+	// Signed webhook must be handled
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, newSignedRequest("/_webhooks/topics/test/subscribers/testing", "new", time.Now(), "payload"))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Requests signed with a retired secret must be rejected after rotation
+	assert.Nil(t, adapter.SetSecrets("new"))
+	w = httptest.NewRecorder()
+	adapter.ServeHTTP(w, newSignedRequest("/_webhooks/topics/test/subscribers/testing", "old", time.Now(), "payload"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Health and metrics must be served
+	for _, path := range []string{livenessPath, readinessPath, metricsPath} {
+		w = httptest.NewRecorder()
+		adapter.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+}
+
+func TestAdapter_SetSecretsErrorWithEmptySecrets(t *testing.T) {
+	// Create adapter and subscription
+	adapter, _ := CreateAdapter(":8080", "secret")
+	subscription := new(pusutest.Subscription).WillHaveProperFields()
+	assert.Nil(t, adapter.CreateSubscription(subscription))
+
+	// Missing or empty secrets must be rejected and previous secrets must be kept
+	assert.Error(t, adapter.SetSecrets())
+	assert.Error(t, adapter.SetSecrets(""))
+	w := httptest.NewRecorder()
+	adapter.ServeHTTP(w, newSignedRequest("/_webhooks/topics/test/subscribers/testing", "secret", time.Now(), "payload"))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAdapter_Open(t *testing.T) {
+	// Open adapter with rotating secrets
+	adapter, err := pusu.Open("webhook://:8080?secret=old&secret=new")
+	assert.Nil(t, err)
+	assert.Equal(t, ":8080", adapter.(*Adapter).runner.(*httpRunner).address)
+	assert.Len(t, adapter.(*Adapter).verifier.secrets, 2)
+
+	// Secret is required
+	_, err = pusu.Open("webhook://:8080")
+	assert.Error(t, err)
+
+	// Signature scheme must be selected by name
+	adapter, err = pusu.Open("webhook://:8080?secret=secret&scheme=github")
+	assert.Nil(t, err)
+	assert.Equal(t, GitHubScheme{}, adapter.(*Adapter).verifier.scheme)
+	_, err = pusu.Open("webhook://:8080?secret=secret&scheme=unknown")
+	assert.Error(t, err)
+}
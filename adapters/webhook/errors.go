@@ -0,0 +1,13 @@
+package webhook
+
+const (
+	ErrorRequestBody      string = "Fatal error while reading http request body."
+	ErrorSignature        string = "Signature of request is not valid."
+	ErrorTimestamp        string = "Timestamp of request is missing or outside of tolerance."
+	ErrorMessageExecution string = "Message execution unsuccessful."
+	ErrorTooManyInFlight  string = "Too many messages in flight, try again later."
+	ErrorRateLimited      string = "Rate limit exceeded, try again later."
+	ErrorCircuitOpen      string = "Circuit is open, try again later."
+	ErrorHandlerTimeout   string = "Message execution timed out."
+	ErrorUnprocessable    string = "Message can not be processed, do not retry."
+)
@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"github.com/metglobal-compass/pusu"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// Requests with larger bodies are rejected
+	maxBodySize = 10 << 20
+
+	// Handling is given up after this timeout unless subscription has its own. Producers usually time out
+	// webhook requests after a few seconds and retry.
+	defaultHandlerTimeout = 10 * time.Second
+)
+
+// Headers of signature schemes, which are not attributes of messages
+var signatureHeaders = []string{SignatureHeader, TimestampHeader, GitHubSignatureHeader, "X-Hub-Signature", StripeSignatureHeader}
+
+type httpHandlerAdder struct {
+	mux      *http.ServeMux
+	verifier *verifier
+
+	handlerTimeout time.Duration
+
+	mutex sync.RWMutex
+
+	// Subscriptions keyed by url path
+	subscriptions map[string]pusu.Subscription
+
+	// Counts outcomes of handled messages. Optional.
+	metrics *pusu.Metrics
+}
+
+// Implementation of internal Creator interface for Webhook Adapter
+func (h *httpHandlerAdder) CreateSubscription(subscription pusu.Subscription) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	path := h.UrlPath(subscription)
+	if h.subscriptions == nil {
+		h.subscriptions = make(map[string]pusu.Subscription)
+	}
+	if _, ok := h.subscriptions[path]; !ok {
+		h.mux.Handle(path, h)
+	}
+	h.subscriptions[path] = subscription
+
+	return nil
+}
+
+// Implementation of handler interface of net/http Handler interface
+func (h *httpHandlerAdder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mutex.RLock()
+	subscription, ok := h.subscriptions[r.URL.Path]
+	h.mutex.RUnlock()
+	if !ok || r.Method != http.MethodPost {
+		http.Error(w, ErrorMessageExecution, http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, ErrorRequestBody, http.StatusBadRequest)
+		return
+	}
+
+	// Reject unsigned, forged and replayed requests
+	err = h.verifier.Verify(r.Header, body)
+	if err == errTimestamp {
+		log.Printf("Request of subscription %s is rejected: %s", subscription.Name(), err)
+		http.Error(w, ErrorTimestamp, http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		log.Printf("Request of subscription %s is rejected: %s", subscription.Name(), err)
+		http.Error(w, ErrorSignature, http.StatusUnauthorized)
+		return
+	}
+
+	// Execute real method of subscription with raw body and attributes of headers. Its context is cancelled when
+	// producer drops the request.
+	message := pusu.NewMessage(string(body)).WithAttributes(pusu.HeaderAttributes(r.Header, signatureHeaders...)).WithContext(r.Context())
+	err = pusu.HandleWithTimeout(subscription, message, h.handlerTimeout)
+	if h.metrics != nil {
+		h.metrics.Observe(subscription, err)
+	}
+	if errors.Is(err, pusu.ErrHandlerTimeout) {
+		log.Printf("Handler of subscription %s timed out", subscription.Name())
+	} else if err != nil && !pusu.IsRejection(err) {
+		log.Printf("Handler of subscription %s failed: %s", subscription.Name(), err)
+	}
+
+	// Return 429 status code when subscription is over its concurrency or rate limit and 503 status code while its
+	// circuit is open, so producers back off before retrying.
+	// Return 422 status code when message requires dead lettering or matches no route, so producers don't retry it.
+	// Return 500 status code in case of any other error, otherwise do nothing
+	if errors.Is(err, pusu.ErrTooManyInFlight) {
+		http.Error(w, ErrorTooManyInFlight, http.StatusTooManyRequests)
+	} else if errors.Is(err, pusu.ErrRateLimited) {
+		http.Error(w, ErrorRateLimited, http.StatusTooManyRequests)
+	} else if errors.Is(err, pusu.ErrCircuitOpen) {
+		http.Error(w, ErrorCircuitOpen, http.StatusServiceUnavailable)
+	} else if errors.Is(err, pusu.ErrHandlerTimeout) {
+		http.Error(w, ErrorHandlerTimeout, http.StatusInternalServerError)
+	} else if errors.Is(err, pusu.ErrDeadLetter) || errors.Is(err, pusu.ErrUnmatched) {
+		http.Error(w, ErrorUnprocessable, http.StatusUnprocessableEntity)
+	} else if err != nil {
+		http.Error(w, ErrorMessageExecution, http.StatusInternalServerError)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Get url path of subscriber
+func (h *httpHandlerAdder) UrlPath(subscription pusu.Subscription) string {
+	return fmt.Sprintf("/_webhooks/topics/%s/subscribers/%s", subscription.Topic(), subscription.Name())
+}
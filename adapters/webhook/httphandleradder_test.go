@@ -0,0 +1,140 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"github.com/metglobal-compass/pusu"
+	"github.com/metglobal-compass/pusu/pusutest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHttpHandlerAdder_CreateSubscription(t *testing.T) {
+	// Create two subscriptions of the same topic
+	handler := newTestHandler()
+	first := new(pusutest.Subscription).WithTopic("test").WithName("first").WithReturning(nil)
+	second := new(pusutest.Subscription).WithTopic("test").WithName("second").WithReturning(nil)
+	assert.Nil(t, handler.CreateSubscription(first))
+	assert.Nil(t, handler.CreateSubscription(second))
+
+	// Each request must be handled by subscription of its own path, with attributes of headers other than transport,
+	// proxy and signature ones
+	w := httptest.NewRecorder()
+	r := newSignedRequest("/_webhooks/topics/test/subscribers/second", "secret", time.Now(), "payload")
+	r.Header.Set("X-GitHub-Event", "push")
+	r.Header.Set("User-Agent", "GitHub-Hookshot")
+	r.Header.Set("Cookie", "session=1")
+	r.Header.Set("X-Forwarded-For", "10.0.0.1")
+	r.Header.Set("X-Hub-Signature", "sha1=1")
+	handler.mux.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	first.AssertNotCalled(t, "Handle", mock.Anything)
+	second.AssertCalled(t, "Handle", mock.MatchedBy(func(m *pusu.Message) bool {
+		return m.Message() == "payload" && assert.ObjectsAreEqual(map[string]string{"x-github-event": "push"}, m.Attributes())
+	}))
+	assert.Equal(t, int64(1), handler.metrics.Count(second, pusu.OutcomeSuccess))
+}
+
+func TestHttpHandlerAdder_UrlPath(t *testing.T) {
+	// Check generated path
+	handler := newTestHandler()
+	assert.Equal(t, "/_webhooks/topics/test/subscribers/testing", handler.UrlPath(new(pusutest.Subscription).WillHaveProperFields()))
+}
+
+func TestHttpHandlerAdder_ServeHTTPErrorOnUnknownPath(t *testing.T) {
+	// Create handler without subscriptions
+	handler := newTestHandler()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newSignedRequest("/_webhooks/topics/test/subscribers/testing", "secret", time.Now(), "payload"))
+
+	// Must return 404
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHttpHandlerAdder_ServeHTTPErrorOnSignature(t *testing.T) {
+	// Create handler and subscription
+	handler := newTestHandler()
+	subscription := new(pusutest.Subscription).WillHaveProperFields()
+	handler.CreateSubscription(subscription)
+
+	// Request signed with an unknown secret must be rejected without handling
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newSignedRequest("/_webhooks/topics/test/subscribers/testing", "unknown", time.Now(), "payload"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, ErrorSignature, strings.TrimSpace(w.Body.String()))
+	subscription.(*pusutest.Subscription).AssertNotCalled(t, "Handle", mock.Anything)
+}
+
+func TestHttpHandlerAdder_ServeHTTPErrorOnReplay(t *testing.T) {
+	// Create handler and subscription
+	handler := newTestHandler()
+	subscription := new(pusutest.Subscription).WillHaveProperFields()
+	handler.CreateSubscription(subscription)
+
+	// Request signed long ago must be rejected without handling
+	w := httptest.NewRecorder()
+	signedAt := time.Now().Add(-time.Hour)
+	handler.ServeHTTP(w, newSignedRequest("/_webhooks/topics/test/subscribers/testing", "secret", signedAt, "payload"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, ErrorTimestamp, strings.TrimSpace(w.Body.String()))
+	subscription.(*pusutest.Subscription).AssertNotCalled(t, "Handle", mock.Anything)
+}
+
+func TestHttpHandlerAdder_ServeHTTPErrorOnHandle(t *testing.T) {
+	// Check status codes of handler errors
+	cases := map[error]int{
+		errors.New("error"):     http.StatusInternalServerError,
+		pusu.ErrTooManyInFlight: http.StatusTooManyRequests,
+		pusu.ErrRateLimited:     http.StatusTooManyRequests,
+		pusu.ErrCircuitOpen:     http.StatusServiceUnavailable,
+	}
+	for err, code := range cases {
+		handler := newTestHandler()
+		handler.CreateSubscription(new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(err))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newSignedRequest("/_webhooks/topics/test/subscribers/testing", "secret", time.Now(), "payload"))
+		assert.Equal(t, code, w.Code, err.Error())
+	}
+}
+
+func TestHttpHandlerAdder_ServeHTTPErrorOnUnprocessable(t *testing.T) {
+	// Messages which require dead lettering or match no route must not be retried by producers
+	for _, err := range []error{pusu.ErrDeadLetter, fmt.Errorf("invalid payload: %w", pusu.ErrDeadLetter), pusu.ErrUnmatched} {
+		handler := newTestHandler()
+		handler.CreateSubscription(new(pusutest.Subscription).WithTopic("test").WithName("testing").WithReturning(err))
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newSignedRequest("/_webhooks/topics/test/subscribers/testing", "secret", time.Now(), "payload"))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, err.Error())
+		assert.Equal(t, ErrorUnprocessable, strings.TrimSpace(w.Body.String()))
+	}
+}
+
+// Creates an http handler adder which accepts requests signed with "secret"
+func newTestHandler() *httpHandlerAdder {
+	v := &verifier{scheme: PusuScheme{}, tolerance: defaultTolerance, now: time.Now}
+	v.SetSecrets("secret")
+
+	return &httpHandlerAdder{
+		mux:            http.NewServeMux(),
+		verifier:       v,
+		handlerTimeout: time.Second,
+		metrics:        new(pusu.Metrics),
+	}
+}
+
+// Creates a webhook request whose body is signed with given secret at given time
+func newSignedRequest(path string, secret string, signedAt time.Time, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	r.Header.Set(TimestampHeader, strconv.FormatInt(signedAt.Unix(), 10))
+	r.Header.Set(SignatureHeader, Sign(secret, signedAt, []byte(body)))
+
+	return r
+}
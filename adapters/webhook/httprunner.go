@@ -0,0 +1,17 @@
+package webhook
+
+import (
+	"github.com/metglobal-compass/pusu"
+	"net/http"
+)
+
+// Http server which implements pusu.Runner interface
+type httpRunner struct {
+	address string
+	handler http.Handler
+}
+
+// Serves webhooks of every created subscription, together with health and metrics handlers
+func (h *httpRunner) Run(subscription pusu.Subscription) error {
+	return http.ListenAndServe(h.address, h.handler)
+}
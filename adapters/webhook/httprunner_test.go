@@ -0,0 +1,14 @@
+package webhook
+
+import (
+	"github.com/metglobal-compass/pusu/pusutest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestHttpRunner_RunErrorOnInvalidAddress(t *testing.T) {
+	// Server must not start on an invalid address
+	runner := &httpRunner{address: "invalid:address:8080", handler: http.NewServeMux()}
+	assert.Error(t, runner.Run(new(pusutest.Subscription).WillHaveProperFields()))
+}
@@ -0,0 +1,122 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Header of GitHub webhook signatures. Value is "sha256=<hex>" signature of body.
+	GitHubSignatureHeader = "X-Hub-Signature-256"
+
+	// Header of Stripe webhook signatures. Value is "t=<timestamp>,v1=<hex>[,v1=<hex>...]" where signatures are
+	// HMAC-SHA256 of "<timestamp>.<body>".
+	StripeSignatureHeader = "Stripe-Signature"
+)
+
+// Signature scheme of a producer. Verifies a request against a set of secrets.
+type Scheme interface {
+	// Returns nil if one of request signatures matches one of secrets. Schemes which sign a timestamp return
+	// errTimestamp if it is missing or outside of tolerance of now.
+	Verify(header http.Header, body []byte, secrets [][]byte, now time.Time, tolerance time.Duration) error
+}
+
+// Default scheme of pusu producers, see Sign. Signatures are sent in SignatureHeader and timestamp in
+// TimestampHeader.
+type PusuScheme struct{}
+
+func (PusuScheme) Verify(header http.Header, body []byte, secrets [][]byte, now time.Time, tolerance time.Duration) error {
+	timestamp, err := parseTimestamp(header.Get(TimestampHeader), now, tolerance)
+	if err != nil {
+		return err
+	}
+
+	var signatures []string
+	for _, signature := range strings.Split(header.Get(SignatureHeader), ",") {
+		signature = strings.TrimSpace(signature)
+		if strings.HasPrefix(signature, signaturePrefix) {
+			signatures = append(signatures, strings.TrimPrefix(signature, signaturePrefix))
+		}
+	}
+
+	return match(signatures, secrets, func(secret []byte) []byte { return mac(secret, timestamp, body) })
+}
+
+// Scheme of GitHub webhooks. GitHub doesn't sign a timestamp, so captured requests can't be told from replays
+// by signature. Handlers should deduplicate by X-GitHub-Delivery header of the request if that matters.
+type GitHubScheme struct{}
+
+func (GitHubScheme) Verify(header http.Header, body []byte, secrets [][]byte, _ time.Time, _ time.Duration) error {
+	signature := header.Get(GitHubSignatureHeader)
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return errSignature
+	}
+
+	return match([]string{strings.TrimPrefix(signature, signaturePrefix)}, secrets, func(secret []byte) []byte {
+		h := hmac.New(sha256.New, secret)
+		h.Write(body)
+		return h.Sum(nil)
+	})
+}
+
+// Scheme of Stripe webhooks. Secrets are signing secrets of Stripe endpoints (whsec_...). Stripe signs with every
+// active secret of endpoint while a secret is rolled, so any of v1 signatures may match.
+type StripeScheme struct{}
+
+func (StripeScheme) Verify(header http.Header, body []byte, secrets [][]byte, now time.Time, tolerance time.Duration) error {
+	var value string
+	var signatures []string
+	for _, item := range strings.Split(header.Get(StripeSignatureHeader), ",") {
+		key, v, _ := strings.Cut(strings.TrimSpace(item), "=")
+		switch key {
+		case "t":
+			value = v
+		case "v1":
+			signatures = append(signatures, v)
+		}
+	}
+
+	timestamp, err := parseTimestamp(value, now, tolerance)
+	if err != nil {
+		return err
+	}
+
+	return match(signatures, secrets, func(secret []byte) []byte { return mac(secret, timestamp, body) })
+}
+
+// Parses timestamp of unix seconds and returns errTimestamp if it is missing or outside of tolerance of now
+func parseTimestamp(value string, now time.Time, tolerance time.Duration) (int64, error) {
+	timestamp, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errTimestamp
+	}
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return 0, errTimestamp
+	}
+
+	return timestamp, nil
+}
+
+// Returns nil if one of hex encoded signatures equals expected signature of one of secrets
+func match(signatures []string, secrets [][]byte, expected func(secret []byte) []byte) error {
+	for _, signature := range signatures {
+		actual, err := hex.DecodeString(signature)
+		if err != nil {
+			continue
+		}
+
+		for _, secret := range secrets {
+			if hmac.Equal(actual, expected(secret)) {
+				return nil
+			}
+		}
+	}
+
+	return errSignature
+}
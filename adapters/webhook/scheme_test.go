@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGitHubScheme_Verify(t *testing.T) {
+	// Test vector of GitHub documentation
+	secrets := [][]byte{[]byte("old"), []byte("It's a Secret to Everybody")}
+	body := []byte("Hello, World!")
+	header := http.Header{}
+	header.Set(GitHubSignatureHeader, "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17")
+
+	// Signature of any secret must be accepted regardless of time
+	assert.Nil(t, GitHubScheme{}.Verify(header, body, secrets, time.Now(), defaultTolerance))
+
+	// Tampered body, unknown secret, malformed and missing signatures must be rejected
+	assert.Equal(t, errSignature, GitHubScheme{}.Verify(header, []byte("Hello, World?"), secrets, time.Now(), defaultTolerance))
+	assert.Equal(t, errSignature, GitHubScheme{}.Verify(header, body, [][]byte{[]byte("unknown")}, time.Now(), defaultTolerance))
+	header.Set(GitHubSignatureHeader, "757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17")
+	assert.Equal(t, errSignature, GitHubScheme{}.Verify(header, body, secrets, time.Now(), defaultTolerance))
+	assert.Equal(t, errSignature, GitHubScheme{}.Verify(http.Header{}, body, secrets, time.Now(), defaultTolerance))
+}
+
+func TestStripeScheme_Verify(t *testing.T) {
+	// HMAC-SHA256 of "1700000000.<body>" with secret whsec_test_secret
+	now := time.Unix(1700000000, 0)
+	secrets := [][]byte{[]byte("whsec_test_secret")}
+	body := []byte(`{"id":"evt_test"}`)
+	signature := "13941114bb88ac44a76abcfddea5b92aa6182a4b63d8be3aae908a616083bd7e"
+
+	// Any of v1 signatures must match, other versions must be ignored
+	header := http.Header{}
+	header.Set(StripeSignatureHeader, "t=1700000000,v1=00,v1="+signature+",v0=00")
+	assert.Nil(t, StripeScheme{}.Verify(header, body, secrets, now, defaultTolerance))
+
+	// Only v0 signature must be rejected
+	header.Set(StripeSignatureHeader, "t=1700000000,v0="+signature)
+	assert.Equal(t, errSignature, StripeScheme{}.Verify(header, body, secrets, now, defaultTolerance))
+
+	// Tampered body and timestamp must be rejected
+	header.Set(StripeSignatureHeader, "t=1700000000,v1="+signature)
+	assert.Equal(t, errSignature, StripeScheme{}.Verify(header, []byte(`{"id":"evt_other"}`), secrets, now, defaultTolerance))
+	header.Set(StripeSignatureHeader, "t=1700000001,v1="+signature)
+	assert.Equal(t, errSignature, StripeScheme{}.Verify(header, body, secrets, now, defaultTolerance))
+
+	// Replayed and missing timestamps must be rejected
+	header.Set(StripeSignatureHeader, "t=1700000000,v1="+signature)
+	assert.Equal(t, errTimestamp, StripeScheme{}.Verify(header, body, secrets, now.Add(6*time.Minute), defaultTolerance))
+	header.Set(StripeSignatureHeader, "v1="+signature)
+	assert.Equal(t, errTimestamp, StripeScheme{}.Verify(header, body, secrets, now, defaultTolerance))
+}
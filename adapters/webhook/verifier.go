@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// Header of request signatures. Value is one or more comma separated "sha256=<hex>" signatures, so producers
+	// may sign with both old and new secret while secrets are rotated.
+	SignatureHeader = "X-Pusu-Signature"
+
+	// Header of request timestamp in unix seconds. Timestamp is signed together with body.
+	TimestampHeader = "X-Pusu-Timestamp"
+
+	signaturePrefix = "sha256="
+
+	// Requests signed earlier or later than this are rejected as replays
+	defaultTolerance = 5 * time.Minute
+)
+
+var (
+	errSignature = errors.New("signature is not valid")
+	errTimestamp = errors.New("timestamp is missing or outside of tolerance")
+)
+
+// Verifies HMAC signatures of requests against a set of secrets with a signature scheme
+type verifier struct {
+	mutex   sync.RWMutex
+	secrets [][]byte
+	scheme  Scheme
+
+	tolerance time.Duration
+
+	// Returns current time. Replaced in tests.
+	now func() time.Time
+}
+
+// Replaces accepted secrets. Requests signed with any of them are accepted. At least one secret is required and
+// secrets must not be empty, otherwise secrets are kept as is.
+func (v *verifier) SetSecrets(secrets ...string) error {
+	if len(secrets) == 0 {
+		return errors.New("at least one secret is required")
+	}
+	for _, secret := range secrets {
+		if secret == "" {
+			return errors.New("secrets must not be empty")
+		}
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.secrets = nil
+	for _, secret := range secrets {
+		v.secrets = append(v.secrets, []byte(secret))
+	}
+
+	return nil
+}
+
+// Replaces signature scheme of requests
+func (v *verifier) SetScheme(scheme Scheme) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.scheme = scheme
+}
+
+// Returns nil if request is signed with one of secrets according to scheme
+func (v *verifier) Verify(header http.Header, body []byte) error {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+
+	return v.scheme.Verify(header, body, v.secrets, v.now(), v.tolerance)
+}
+
+// Returns value of SignatureHeader for given body signed at given time. Producers set it together with
+// TimestampHeader holding unix seconds of the same time.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	return signaturePrefix + hex.EncodeToString(mac([]byte(secret), timestamp.Unix(), body))
+}
+
+// Returns HMAC-SHA256 of "<timestamp>.<body>"
+func mac(secret []byte, timestamp int64, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	fmt.Fprintf(h, "%d.", timestamp)
+	h.Write(body)
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	v := newTestVerifier(now, "old", "new")
+	body := []byte("payload")
+
+	// Signatures of any secret must be accepted
+	assert.Nil(t, v.Verify(newHeader(now, Sign("old", now, body)), body))
+	assert.Nil(t, v.Verify(newHeader(now, Sign("new", now, body)), body))
+
+	// One of several signatures must match
+	assert.Nil(t, v.Verify(newHeader(now, Sign("unknown", now, body)+", "+Sign("new", now, body)), body))
+
+	// Timestamps within tolerance must be accepted
+	signedAt := now.Add(-4 * time.Minute)
+	assert.Nil(t, v.Verify(newHeader(signedAt, Sign("new", signedAt, body)), body))
+}
+
+func TestVerifier_VerifyErrorOnSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	v := newTestVerifier(now, "secret")
+	body := []byte("payload")
+
+	// Unknown secret, tampered body, tampered timestamp, malformed and missing signatures must be rejected
+	assert.Equal(t, errSignature, v.Verify(newHeader(now, Sign("unknown", now, body)), body))
+	assert.Equal(t, errSignature, v.Verify(newHeader(now, Sign("secret", now, body)), []byte("tampered")))
+	assert.Equal(t, errSignature, v.Verify(newHeader(now, Sign("secret", now.Add(-time.Second), body)), body))
+	assert.Equal(t, errSignature, v.Verify(newHeader(now, "sha256=zz"), body))
+	assert.Equal(t, errSignature, v.Verify(newHeader(now, ""), body))
+}
+
+func TestVerifier_VerifyErrorOnTimestamp(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	v := newTestVerifier(now, "secret")
+	body := []byte("payload")
+
+	// Replayed and future requests must be rejected even if their signatures are valid
+	for _, signedAt := range []time.Time{now.Add(-6 * time.Minute), now.Add(6 * time.Minute)} {
+		assert.Equal(t, errTimestamp, v.Verify(newHeader(signedAt, Sign("secret", signedAt, body)), body))
+	}
+
+	// Missing timestamp must be rejected
+	header := http.Header{}
+	header.Set(SignatureHeader, Sign("secret", now, body))
+	assert.Equal(t, errTimestamp, v.Verify(header, body))
+}
+
+func TestVerifier_SetSecrets(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	v := newTestVerifier(now, "secret")
+	body := []byte("payload")
+
+	// Missing and empty secrets must be rejected without replacing secrets
+	assert.Error(t, v.SetSecrets())
+	assert.Error(t, v.SetSecrets("new", ""))
+	assert.Nil(t, v.Verify(newHeader(now, Sign("secret", now, body)), body))
+}
+
+// Creates a verifier of given secrets whose clock is stopped at given time
+func newTestVerifier(now time.Time, secrets ...string) *verifier {
+	v := &verifier{scheme: PusuScheme{}, tolerance: defaultTolerance, now: func() time.Time { return now }}
+	v.SetSecrets(secrets...)
+
+	return v
+}
+
+// Creates request header with given timestamp and signature
+func newHeader(timestamp time.Time, signature string) http.Header {
+	header := http.Header{}
+	header.Set(TimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+	header.Set(SignatureHeader, signature)
+
+	return header
+}
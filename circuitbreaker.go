@@ -0,0 +1,175 @@
+package pusu
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// State of a circuit breaker
+type CircuitState int
+
+const (
+	// Messages are handled as usual
+	CircuitClosed CircuitState = iota
+
+	// Messages are rejected with ErrCircuitOpen without calling the handler
+	CircuitOpen
+
+	// A single probe message is handled to decide whether the circuit closes or opens again
+	CircuitHalfOpen
+)
+
+// Returns human readable name of circuit state
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Returns circuit state of given subscription, looking through subscription wrappers.
+// Reports false if subscription has no circuit breaker.
+func CircuitStateOf(subscription Subscription) (CircuitState, bool) {
+	for ; subscription != nil; subscription = Unwrap(subscription) {
+		if breaker, ok := subscription.(*circuitBreaker); ok {
+			return breaker.CircuitState(), true
+		}
+	}
+
+	return CircuitClosed, false
+}
+
+// Subscription wrapper which stops calling a failing handler for a while
+type circuitBreaker struct {
+	Subscription
+
+	// Number of consecutive failures which opens the circuit
+	threshold int
+
+	// Duration circuit stays open before probing the handler again
+	cooldown time.Duration
+
+	mutex    sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// Wraps subscription with a circuit breaker. After threshold consecutive failures the circuit opens and messages
+// are rejected quickly with ErrCircuitOpen, so a recovering downstream dependency is not hammered by retries.
+// After cooldown the circuit becomes half-open and a single probe message decides whether it closes or opens again.
+// Messages which must be dead lettered or which no route of a Router matches are not failures, since they fail by
+// their content rather than by a downstream dependency. Non-positive threshold means no circuit breaker.
+func WithCircuitBreaker(subscription Subscription, threshold int, cooldown time.Duration) Subscription {
+	if threshold <= 0 {
+		return subscription
+	}
+
+	c := new(circuitBreaker)
+	c.Subscription = subscription
+	c.threshold = threshold
+	c.cooldown = cooldown
+
+	return c
+}
+
+// Handles message unless circuit is open
+func (c *circuitBreaker) Handle(m *Message) error {
+	if !c.allow() {
+		return ErrCircuitOpen
+	}
+
+	err := c.Subscription.Handle(m)
+	c.record(err)
+
+	return err
+}
+
+// Handles message unless circuit is open, waiting for capacity of wrapped subscription
+func (c *circuitBreaker) HandleBlocking(ctx context.Context, m *Message) error {
+	if !c.allow() {
+		return ErrCircuitOpen
+	}
+
+	err := HandleBlocking(ctx, c.Subscription, m)
+	c.record(err)
+
+	return err
+}
+
+// Returns current state of circuit
+func (c *circuitBreaker) CircuitState() CircuitState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= c.cooldown {
+		return CircuitHalfOpen
+	}
+
+	return c.state
+}
+
+// Returns wrapped subscription
+func (c *circuitBreaker) Unwrap() Subscription {
+	return c.Subscription
+}
+
+// Decides whether a message may be handled. Only a single probe is allowed while circuit is half-open.
+func (c *circuitBreaker) allow() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= c.cooldown {
+		c.state = CircuitHalfOpen
+	}
+
+	switch c.state {
+	case CircuitOpen:
+		return false
+	case CircuitHalfOpen:
+		if c.probing {
+			return false
+		}
+		c.probing = true
+	}
+
+	return true
+}
+
+// Records result of a handled message and moves circuit to its next state
+func (c *circuitBreaker) record(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Rejections of inner wrappers are not failures of the handler itself
+	if IsRejection(err) {
+		if c.state == CircuitHalfOpen {
+			c.probing = false
+		}
+		return
+	}
+
+	// Poison and unmatched messages are handled as far as downstream dependencies are concerned
+	if err == nil || errors.Is(err, ErrDeadLetter) || errors.Is(err, ErrUnmatched) {
+		c.state = CircuitClosed
+		c.failures = 0
+		c.probing = false
+		return
+	}
+
+	c.failures++
+	if c.state == CircuitHalfOpen || c.failures >= c.threshold {
+		c.state = CircuitOpen
+		c.openedAt = time.Now()
+		c.probing = false
+	}
+}
@@ -0,0 +1,121 @@
+package pusu
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestWithCircuitBreaker(t *testing.T) {
+	// Create a failing subscription with a circuit breaker opening after 2 failures
+	subscription := new(switchingSubscription)
+	subscription.err = errors.New("downstream is down")
+	breaker := WithCircuitBreaker(subscription, 2, 20*time.Millisecond)
+
+	// Circuit must stay closed until threshold is reached
+	breaker.Handle(new(Message))
+	if state, _ := CircuitStateOf(breaker); state != CircuitClosed {
+		t.Errorf("Circuit state:\nExpected: %s\nActual: %s", CircuitClosed, state)
+	}
+
+	// Circuit must open on threshold and reject messages without calling the handler
+	breaker.Handle(new(Message))
+	if state, _ := CircuitStateOf(breaker); state != CircuitOpen {
+		t.Errorf("Circuit state:\nExpected: %s\nActual: %s", CircuitOpen, state)
+	}
+	if err := breaker.Handle(new(Message)); err != ErrCircuitOpen {
+		t.Errorf("Handle error:\nExpected: %v\nActual: %v", ErrCircuitOpen, err)
+	}
+	if subscription.calls != 2 {
+		t.Errorf("Real handler call count:\nExpected: 2\nActual: %d", subscription.calls)
+	}
+
+	// Circuit must become half-open after cooldown. A failing probe must open it again.
+	time.Sleep(25 * time.Millisecond)
+	if state, _ := CircuitStateOf(breaker); state != CircuitHalfOpen {
+		t.Errorf("Circuit state:\nExpected: %s\nActual: %s", CircuitHalfOpen, state)
+	}
+	breaker.Handle(new(Message))
+	if state, _ := CircuitStateOf(breaker); state != CircuitOpen {
+		t.Errorf("Circuit state:\nExpected: %s\nActual: %s", CircuitOpen, state)
+	}
+
+	// A successful probe must close the circuit
+	time.Sleep(25 * time.Millisecond)
+	subscription.err = nil
+	if err := breaker.Handle(new(Message)); err != nil {
+		t.Errorf("Probe message must be handled. Actual error: %s", err)
+	}
+	if state, _ := CircuitStateOf(breaker); state != CircuitClosed {
+		t.Errorf("Circuit state:\nExpected: %s\nActual: %s", CircuitClosed, state)
+	}
+}
+
+func TestWithCircuitBreakerIgnoresRejections(t *testing.T) {
+	// Rejections of inner wrappers must not open the circuit
+	subscription := new(switchingSubscription)
+	subscription.err = ErrTooManyInFlight
+	breaker := WithCircuitBreaker(subscription, 1, time.Minute)
+
+	breaker.Handle(new(Message))
+	if state, _ := CircuitStateOf(breaker); state != CircuitClosed {
+		t.Errorf("Circuit state:\nExpected: %s\nActual: %s", CircuitClosed, state)
+	}
+}
+
+func TestWithCircuitBreakerIgnoresContentFailures(t *testing.T) {
+	// Dead lettered and unmatched messages must not open the circuit
+	for _, err := range []error{ErrDeadLetter, ErrUnmatched, fmt.Errorf("%w: %w", ErrDeadLetter, ErrUnmatched)} {
+		subscription := new(switchingSubscription)
+		subscription.err = err
+		breaker := WithCircuitBreaker(subscription, 1, time.Minute)
+
+		if handled := breaker.Handle(new(Message)); handled != err {
+			t.Errorf("Handle error:\nExpected: %v\nActual: %v", err, handled)
+		}
+		if state, _ := CircuitStateOf(breaker); state != CircuitClosed {
+			t.Errorf("Circuit state on %v:\nExpected: %s\nActual: %s", err, CircuitClosed, state)
+		}
+	}
+
+	// Unmatched messages of a router must not open the circuit either
+	router := NewRouter("test", "testing").Unmatched(DeadLetterUnmatched)
+	breaker := WithCircuitBreaker(router, 1, time.Minute)
+	breaker.Handle(NewMessage("hello"))
+	if err := breaker.Handle(NewMessage("hello")); !errors.Is(err, ErrUnmatched) {
+		t.Errorf("Handle error:\nExpected: %v\nActual: %v", ErrUnmatched, err)
+	}
+}
+
+func TestCircuitStateOf(t *testing.T) {
+	// Subscription without circuit breaker must not report state
+	if _, ok := CircuitStateOf(new(failureSubscription)); ok {
+		t.Error("Subscription without circuit breaker must not report circuit state")
+	}
+
+	// Circuit breaker must be found through other wrappers
+	wrapped := LimitConcurrency(WithCircuitBreaker(new(failureSubscription), 1, time.Minute), 10)
+	if _, ok := CircuitStateOf(wrapped); !ok {
+		t.Error("Circuit breaker must be found through subscription wrappers")
+	}
+}
+
+// A subscription which counts handled messages and returns configured error
+type switchingSubscription struct {
+	err   error
+	calls int
+}
+
+func (s *switchingSubscription) Topic() string {
+	return "test"
+}
+
+func (s *switchingSubscription) Name() string {
+	return "testing"
+}
+
+func (s *switchingSubscription) Handle(m *Message) error {
+	s.calls++
+	return s.err
+}
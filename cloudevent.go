@@ -0,0 +1,254 @@
+package pusu
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// Prefix of CloudEvents context attributes in message attributes, as in Pub/Sub protocol binding and binary
+	// content mode of HTTP protocol binding (as header names)
+	CloudEventAttributePrefix = "ce-"
+
+	// Message attribute of content type of CloudEvents data
+	ContentTypeAttribute = "content-type"
+
+	// Content type of CloudEvents in structured content mode
+	CloudEventContentType = "application/cloudevents+json"
+
+	// Supported version of CloudEvents specification
+	CloudEventSpecVersion = "1.0"
+)
+
+// CloudEvent of CloudEvents specification, see https://cloudevents.io
+type CloudEvent struct {
+	ID          string
+	Source      string
+	SpecVersion string
+	Type        string
+
+	// Content type of data, e.g. application/json. Optional.
+	DataContentType string
+
+	DataSchema string
+	Subject    string
+	Time       time.Time
+
+	// Extension context attributes, e.g. traceparent
+	Extensions map[string]string
+
+	Data []byte
+}
+
+// Checks that required context attributes are set
+func (e CloudEvent) Validate() error {
+	if e.SpecVersion != CloudEventSpecVersion {
+		return fmt.Errorf("CloudEvent spec version %q is not supported", e.SpecVersion)
+	}
+	if e.ID == "" || e.Source == "" || e.Type == "" {
+		return errors.New("CloudEvent id, source and type must not be empty")
+	}
+
+	return nil
+}
+
+// Converts event to message in binary content mode. Data is payload of message as string and context attributes are
+// attributes of message with ce- prefix.
+func (e CloudEvent) Message() *Message {
+	attributes := make(map[string]string)
+	for name, value := range e.Extensions {
+		attributes[CloudEventAttributePrefix+name] = value
+	}
+	for name, value := range e.contextAttributes() {
+		attributes[CloudEventAttributePrefix+name] = value
+	}
+	if e.DataContentType != "" {
+		attributes[ContentTypeAttribute] = e.DataContentType
+	}
+
+	return NewMessage(string(e.Data)).WithAttributes(attributes)
+}
+
+// Returns CloudEvent carried by message, either in binary content mode (ce- attributes) or in structured content mode
+// (payload with application/cloudevents+json content type attribute). Payload of message must be string or []byte.
+// Reports false if message does not carry a valid CloudEvent.
+func CloudEventOf(m *Message) (CloudEvent, bool) {
+	var data []byte
+	switch payload := m.Message().(type) {
+	case string:
+		data = []byte(payload)
+	case []byte:
+		data = payload
+	default:
+		return CloudEvent{}, false
+	}
+
+	if strings.HasPrefix(m.Attribute(ContentTypeAttribute), CloudEventContentType) {
+		e, err := ParseCloudEvent(data)
+		return e, err == nil
+	}
+
+	e := CloudEvent{DataContentType: m.Attribute(ContentTypeAttribute), Data: data}
+	for key, value := range m.Attributes() {
+		name := strings.TrimPrefix(key, CloudEventAttributePrefix)
+		if name == key || name == "datacontenttype" {
+			continue
+		}
+		err := e.setAttribute(name, value)
+		if err != nil {
+			return CloudEvent{}, false
+		}
+	}
+
+	return e, e.Validate() == nil
+}
+
+// Parses and validates a CloudEvent in structured content mode, i.e. JSON format
+func ParseCloudEvent(data []byte) (CloudEvent, error) {
+	var e CloudEvent
+	err := json.Unmarshal(data, &e)
+	if err != nil {
+		return e, err
+	}
+
+	return e, e.Validate()
+}
+
+// Encodes event in JSON format. Data is written as JSON if content type is JSON, as string if it is text and as
+// base64 otherwise, e.g. when data is not valid for its content type.
+func (e CloudEvent) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{})
+	for name, value := range e.Extensions {
+		fields[name] = value
+	}
+	for name, value := range e.contextAttributes() {
+		fields[name] = value
+	}
+	if e.DataContentType != "" {
+		fields["datacontenttype"] = e.DataContentType
+	}
+
+	jsonData := isJsonContentType(e.DataContentType)
+	if jsonData && json.Valid(e.Data) {
+		fields["data"] = json.RawMessage(e.Data)
+	} else if e.Data != nil && !jsonData && utf8.Valid(e.Data) {
+		fields["data"] = string(e.Data)
+	} else if e.Data != nil {
+		fields["data_base64"] = base64.StdEncoding.EncodeToString(e.Data)
+	}
+
+	return json.Marshal(fields)
+}
+
+// Decodes event from JSON format
+func (e *CloudEvent) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return err
+	}
+
+	*e = CloudEvent{}
+	for name, raw := range fields {
+		if name == "data" || name == "data_base64" {
+			continue
+		}
+
+		// Extensions may be numbers or booleans, they are kept in their JSON form
+		var value string
+		if json.Unmarshal(raw, &value) != nil {
+			value = string(raw)
+		}
+		err = e.setAttribute(name, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	if raw, ok := fields["data_base64"]; ok {
+		var encoded string
+		err = json.Unmarshal(raw, &encoded)
+		if err == nil {
+			e.Data, err = base64.StdEncoding.DecodeString(encoded)
+		}
+		return err
+	}
+
+	if raw, ok := fields["data"]; ok {
+		// Strings are data themselves unless data is JSON
+		var text string
+		if !isJsonContentType(e.DataContentType) && json.Unmarshal(raw, &text) == nil {
+			e.Data = []byte(text)
+		} else {
+			e.Data = []byte(raw)
+		}
+	}
+
+	return nil
+}
+
+// Returns context attributes which are set, except data content type
+func (e CloudEvent) contextAttributes() map[string]string {
+	attributes := map[string]string{
+		"id":          e.ID,
+		"source":      e.Source,
+		"specversion": e.SpecVersion,
+		"type":        e.Type,
+		"dataschema":  e.DataSchema,
+		"subject":     e.Subject,
+	}
+	if !e.Time.IsZero() {
+		attributes["time"] = e.Time.Format(time.RFC3339Nano)
+	}
+	for name, value := range attributes {
+		if value == "" {
+			delete(attributes, name)
+		}
+	}
+
+	return attributes
+}
+
+// Sets context attribute or extension of given name
+func (e *CloudEvent) setAttribute(name string, value string) error {
+	switch name {
+	case "id":
+		e.ID = value
+	case "source":
+		e.Source = value
+	case "specversion":
+		e.SpecVersion = value
+	case "type":
+		e.Type = value
+	case "datacontenttype":
+		e.DataContentType = value
+	case "dataschema":
+		e.DataSchema = value
+	case "subject":
+		e.Subject = value
+	case "time":
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("CloudEvent time %q is not in RFC 3339 format", value)
+		}
+		e.Time = t
+	default:
+		if e.Extensions == nil {
+			e.Extensions = make(map[string]string)
+		}
+		e.Extensions[name] = value
+	}
+
+	return nil
+}
+
+// Reports whether content type is JSON. Data without content type is JSON by CloudEvents specification.
+func isJsonContentType(contentType string) bool {
+	mediaType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+	return mediaType == "" || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,117 @@
+package pusu
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testCloudEvent() CloudEvent {
+	return CloudEvent{
+		ID:              "1",
+		Source:          "//orders",
+		SpecVersion:     CloudEventSpecVersion,
+		Type:            "com.example.order.created",
+		DataContentType: "application/json",
+		Subject:         "orders/1",
+		Time:            time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Extensions:      map[string]string{"traceparent": "00-abc-def-01"},
+		Data:            []byte(`{"id":1}`),
+	}
+}
+
+func TestCloudEvent_Message(t *testing.T) {
+	message := testCloudEvent().Message()
+
+	// Event must be carried by attributes in binary content mode
+	expectedAttributes := map[string]string{
+		"ce-id":          "1",
+		"ce-source":      "//orders",
+		"ce-specversion": "1.0",
+		"ce-type":        "com.example.order.created",
+		"ce-subject":     "orders/1",
+		"ce-time":        "2024-01-02T03:04:05Z",
+		"ce-traceparent": "00-abc-def-01",
+		"content-type":   "application/json",
+	}
+	if !reflect.DeepEqual(message.Attributes(), expectedAttributes) {
+		t.Errorf("Error: Expected: %v, Actual: %v", expectedAttributes, message.Attributes())
+	}
+	if message.Message() != `{"id":1}` {
+		t.Errorf("Error: Expected: %s, Actual: %s", `{"id":1}`, message.Message())
+	}
+
+	// Event must be converted back from message
+	event, ok := CloudEventOf(message)
+	if !ok || !reflect.DeepEqual(event, testCloudEvent()) {
+		t.Errorf("Error: Expected: %v, Actual: %v", testCloudEvent(), event)
+	}
+}
+
+func TestCloudEventOf(t *testing.T) {
+	// Messages without required context attributes must not be taken as events
+	for _, message := range []*Message{
+		NewMessage("data"),
+		NewMessage("data").WithAttributes(map[string]string{"ce-id": "1", "ce-source": "//orders", "ce-type": "created"}),
+		NewMessage(1).WithAttributes(testCloudEvent().Message().Attributes()),
+		NewMessage("{}").WithAttributes(map[string]string{"content-type": CloudEventContentType}),
+	} {
+		if _, ok := CloudEventOf(message); ok {
+			t.Errorf("Message with attributes %v must not be taken as event", message.Attributes())
+		}
+	}
+
+	// Events in structured content mode must be parsed from payload
+	data, err := testCloudEvent().MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := NewMessage(data).WithAttributes(map[string]string{"content-type": CloudEventContentType + "; charset=utf-8"})
+	event, ok := CloudEventOf(message)
+	if !ok || !reflect.DeepEqual(event, testCloudEvent()) {
+		t.Errorf("Error: Expected: %v, Actual: %v", testCloudEvent(), event)
+	}
+}
+
+func TestParseCloudEvent(t *testing.T) {
+	// Data must be decoded by its content type
+	events := map[string]CloudEvent{
+		`{"specversion": "1.0", "id": "1", "source": "s", "type": "t", "data": {"id":1}}`: {
+			SpecVersion: "1.0", ID: "1", Source: "s", Type: "t", Data: []byte(`{"id":1}`),
+		},
+		`{"specversion": "1.0", "id": "1", "source": "s", "type": "t", "datacontenttype": "text/plain", "data": "hello"}`: {
+			SpecVersion: "1.0", ID: "1", Source: "s", Type: "t", DataContentType: "text/plain", Data: []byte("hello"),
+		},
+		`{"specversion": "1.0", "id": "1", "source": "s", "type": "t", "data_base64": "AAE=", "priority": 2}`: {
+			SpecVersion: "1.0", ID: "1", Source: "s", Type: "t", Data: []byte{0, 1}, Extensions: map[string]string{"priority": "2"},
+		},
+	}
+	for data, expected := range events {
+		event, err := ParseCloudEvent([]byte(data))
+		if err != nil || !reflect.DeepEqual(event, expected) {
+			t.Errorf("Error: Expected: %v, Actual: %v, %v", expected, event, err)
+		}
+
+		// Event must survive encoding
+		encoded, err := event.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := ParseCloudEvent(encoded)
+		if err != nil || !reflect.DeepEqual(decoded, expected) {
+			t.Errorf("Error: Expected: %v, Actual: %v, %v", expected, decoded, err)
+		}
+	}
+
+	// Invalid events must be rejected
+	for _, data := range []string{
+		`{"specversion": "0.3", "id": "1", "source": "s", "type": "t"}`,
+		`{"specversion": "1.0", "source": "s", "type": "t"}`,
+		`{"specversion": "1.0", "id": "1", "source": "s", "type": "t", "time": "yesterday"}`,
+		`[]`,
+	} {
+		if _, err := ParseCloudEvent([]byte(data)); err == nil {
+			t.Errorf("Event %s must be rejected", data)
+		}
+	}
+}
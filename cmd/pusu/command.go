@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/metglobal-compass/pusu/adapters/google"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `Usage: pusu [flags] <command> [arguments]
+
+Commands:
+  topics list
+  topics create <topic>
+  topics delete <topic>
+  subscriptions list
+  subscriptions create <topic> <subscription>
+  subscriptions describe <topic> <subscription>   (-raw: <subscription>)
+  subscriptions update <topic> <subscription>     (-raw: <subscription>)
+  subscriptions delete <topic> <subscription>     (-raw: <subscription>)
+  publish [-file <path>] [-attr <key>=<value>]... <topic>
+  tail [-format json|raw] <topic>
+  apply [-prune] [-dry-run] <file>
+  export [-format yaml|json]
+
+Flags:
+`
+
+// Topology operations used by commands. Implemented by google.Topology.
+type topology interface {
+	Topics() ([]string, error)
+	CreateTopic(name string) error
+	DeleteTopic(name string) error
+	Subscriptions() ([]google.SubscriptionInfo, error)
+	DescribeSubscription(name string) (google.SubscriptionInfo, error)
+	CreateSubscription(topic string, name string) error
+	UpdateSubscription(name string) error
+	DeleteSubscription(name string) error
+	Publish(topic string, data []byte, attributes map[string]string) (string, error)
+	Tail(ctx context.Context, topic string, handle func(m google.ReceivedMessage)) error
+	Plan(spec google.TopologySpec, prune bool) ([]google.Change, error)
+	Apply(spec google.TopologySpec, prune bool) ([]google.Change, error)
+	Export() (google.TopologySpec, error)
+	Close() error
+}
+
+// Commands which are not grouped under a resource
+var standaloneCommands = map[string]bool{"publish": true, "tail": true, "apply": true, "export": true}
+
+// Creates topology of given project
+type opener func(projectId string, host string, path string, naming google.Naming, noWrapper noWrapperFlag) (topology, error)
+
+// Parses arguments and runs command, reading its input from in and writing its output to out.
+// Long running commands stop when context is done.
+func run(ctx context.Context, args []string, in io.Reader, out io.Writer, open opener) error {
+	flags := flag.NewFlagSet("pusu", flag.ContinueOnError)
+	flags.SetOutput(out)
+	projectId := flags.String("project", firstEnv("PUSU_PROJECT", "GOOGLE_CLOUD_PROJECT"), "Google Cloud project id")
+	host := flags.String("host", os.Getenv("PUSU_HOST"), "base host uri of subscriber http handlers")
+	path := flags.String("path", os.Getenv("PUSU_ENDPOINT_PATH"), "path template of subscriber http handlers, e.g. /events/{topic}/{subscription}")
+	var naming google.Naming
+	flags.StringVar(&naming.Env, "env", os.Getenv("PUSU_ENV"), "environment of naming templates")
+	flags.StringVar(&naming.TopicTemplate, "topic-template", os.Getenv("PUSU_TOPIC_TEMPLATE"), "naming template of topics, e.g. {env}-{topic}")
+	flags.StringVar(&naming.SubscriptionTemplate, "subscription-template", os.Getenv("PUSU_SUBSCRIPTION_TEMPLATE"), "naming template of subscriptions, e.g. {env}-{subscription}")
+	flags.StringVar(&naming.Prefix, "prefix", os.Getenv("PUSU_PREFIX"), "prefix of topic and subscription names")
+	flags.StringVar(&naming.Suffix, "suffix", os.Getenv("PUSU_SUFFIX"), "suffix of topic and subscription names")
+	raw := flags.Bool("raw", false, "take names of topics and subscriptions as they are in Pub/Sub, without naming policy")
+	var noWrapper noWrapperFlag
+	err := noWrapper.setDefault(os.Getenv("PUSU_NO_WRAPPER"))
+	if err != nil {
+		return err
+	}
+	flags.Var(&noWrapper, "no-wrapper", "push raw payloads instead of JSON envelopes, with metadata headers if set to metadata")
+	flags.Usage = func() {
+		fmt.Fprint(out, usage)
+		flags.PrintDefaults()
+	}
+	err = flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	args = flags.Args()
+	if len(args) == 0 || (!standaloneCommands[args[0]] && len(args) < 2) {
+		flags.Usage()
+		return errors.New("command is missing")
+	}
+	if *raw && naming != (google.Naming{}) {
+		return errors.New("naming flags can't be used with -raw")
+	}
+
+	t, err := open(*projectId, *host, *path, naming, noWrapper)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	switch args[0] {
+	case "publish":
+		return publish(t, args[1:], in, out)
+	case "tail":
+		return tail(ctx, t, args[1:], out)
+	case "apply":
+		return apply(t, args[1:], out)
+	case "export":
+		return export(t, args[1:], out)
+	}
+
+	// Subscriptions are named by their topic too, unless names are taken as they are in Pub/Sub
+	subscriptionArgs := 2
+	if *raw {
+		subscriptionArgs = 1
+	}
+
+	resource, command, args := args[0], args[1], args[2:]
+	switch {
+	case resource == "topics" && command == "list" && len(args) == 0:
+		return listTopics(t, out)
+	case resource == "topics" && command == "create" && len(args) == 1:
+		return t.CreateTopic(naming.Topic(args[0]))
+	case resource == "topics" && command == "delete" && len(args) == 1:
+		return t.DeleteTopic(naming.Topic(args[0]))
+	case resource == "subscriptions" && command == "list" && len(args) == 0:
+		return listSubscriptions(t, out)
+	case resource == "subscriptions" && command == "create" && len(args) == 2:
+		return t.CreateSubscription(args[0], args[1])
+	case resource == "subscriptions" && command == "describe" && len(args) == subscriptionArgs:
+		return describeSubscription(t, out, subscriptionName(naming, args))
+	case resource == "subscriptions" && command == "update" && len(args) == subscriptionArgs:
+		return t.UpdateSubscription(subscriptionName(naming, args))
+	case resource == "subscriptions" && command == "delete" && len(args) == subscriptionArgs:
+		return t.DeleteSubscription(subscriptionName(naming, args))
+	}
+
+	flags.Usage()
+	return fmt.Errorf("unknown command or wrong number of arguments: %s %s", resource, command)
+}
+
+// Returns name of subscription in Pub/Sub by arguments of topic and subscription, or by subscription name in Pub/Sub
+func subscriptionName(naming google.Naming, args []string) string {
+	if len(args) == 1 {
+		return args[0]
+	}
+
+	return naming.Subscription(args[0], args[1])
+}
+
+func listTopics(t topology, out io.Writer) error {
+	topics, err := t.Topics()
+	if err != nil {
+		return err
+	}
+
+	for _, topic := range topics {
+		fmt.Fprintln(out, topic)
+	}
+
+	return nil
+}
+
+// Lists subscriptions as a table. Subscriptions whose push endpoint differs from endpoint served by pusu are marked.
+func listSubscriptions(t topology, out io.Writer) error {
+	subscriptions, err := t.Subscriptions()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SUBSCRIPTION\tTOPIC\tENDPOINT\t")
+	for _, s := range subscriptions {
+		endpoint := s.Endpoint
+		if endpoint == "" {
+			endpoint = "(pull)"
+		}
+		if s.Outdated() {
+			endpoint += " (outdated)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", s.Name, s.Topic, endpoint)
+	}
+
+	return w.Flush()
+}
+
+func describeSubscription(t topology, out io.Writer, name string) error {
+	s, err := t.DescribeSubscription(name)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", s.Name)
+	fmt.Fprintf(w, "Topic:\t%s\n", s.Topic)
+	fmt.Fprintf(w, "Endpoint:\t%s\n", s.Endpoint)
+	fmt.Fprintf(w, "Expected endpoint:\t%s\n", s.ExpectedEndpoint)
+	fmt.Fprintf(w, "Ack deadline:\t%s\n", s.AckDeadline)
+
+	return w.Flush()
+}
+
+// Publishes data of file or input with attributes and prints id of message
+func publish(t topology, args []string, in io.Reader, out io.Writer) error {
+	flags := flag.NewFlagSet("publish", flag.ContinueOnError)
+	flags.SetOutput(out)
+	file := flags.String("file", "", "file of message data, input is read if not set")
+	attributes := make(attributeFlag)
+	flags.Var(attributes, "attr", "message attribute as key=value, may be repeated")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: pusu publish [-file <path>] [-attr <key>=<value>]... <topic>")
+	}
+
+	var data []byte
+	if *file != "" {
+		data, err = os.ReadFile(*file)
+	} else {
+		data, err = io.ReadAll(in)
+	}
+	if err != nil {
+		return err
+	}
+
+	id, err := t.Publish(flags.Arg(0), data, attributes)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(out, id)
+
+	return nil
+}
+
+// Prints messages published to topic until context is done
+func tail(ctx context.Context, t topology, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("tail", flag.ContinueOnError)
+	flags.SetOutput(out)
+	format := flags.String("format", "json", "output format of messages, json or raw")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || (*format != "json" && *format != "raw") {
+		return errors.New("usage: pusu tail [-format json|raw] <topic>")
+	}
+
+	encoder := json.NewEncoder(out)
+	return t.Tail(ctx, flags.Arg(0), func(m google.ReceivedMessage) {
+		if *format == "raw" {
+			fmt.Fprintf(out, "%s\n", m.Data)
+			return
+		}
+
+		encoder.Encode(tailedMessage{
+			ID:          m.ID,
+			PublishTime: m.PublishTime,
+			Attributes:  m.Attributes,
+			Data:        string(m.Data),
+		})
+	})
+}
+
+// Reconciles project against topology file and prints changes
+func apply(t topology, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("apply", flag.ContinueOnError)
+	flags.SetOutput(out)
+	prune := flags.Bool("prune", false, "delete topics and subscriptions which are not declared in file")
+	dryRun := flags.Bool("dry-run", false, "print changes without applying them")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: pusu apply [-prune] [-dry-run] <file>")
+	}
+
+	data, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	spec, err := google.ParseTopologySpec(data)
+	if err != nil {
+		return err
+	}
+
+	var changes []google.Change
+	if *dryRun {
+		changes, err = t.Plan(spec, *prune)
+	} else {
+		changes, err = t.Apply(spec, *prune)
+	}
+	for _, change := range changes {
+		fmt.Fprintln(out, change)
+	}
+
+	return err
+}
+
+// Prints topology file of project
+func export(t topology, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(out)
+	format := flags.String("format", "yaml", "format of topology file, yaml or json")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return errors.New("usage: pusu export [-format yaml|json]")
+	}
+
+	spec, err := t.Export()
+	if err != nil {
+		return err
+	}
+	data, err := google.EncodeTopologySpec(spec, *format)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+
+	return err
+}
+
+// Json output of tail
+type tailedMessage struct {
+	ID          string            `json:"id"`
+	PublishTime time.Time         `json:"publish_time"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Data        string            `json:"data"`
+}
+
+// Repeatable key=value flag
+type attributeFlag map[string]string
+
+func (a attributeFlag) String() string {
+	var pairs []string
+	for key, value := range a {
+		pairs = append(pairs, key+"="+value)
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (a attributeFlag) Set(pair string) error {
+	key, value, ok := strings.Cut(pair, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("attribute %q must be in key=value form", pair)
+	}
+	a[key] = value
+
+	return nil
+}
+
+// Flag of push wrapper which is set without value to push raw payloads, or to "metadata" to push them with metadata
+// headers too
+type noWrapperFlag struct {
+	set           bool
+	writeMetadata bool
+}
+
+func (n *noWrapperFlag) String() string {
+	if n == nil || !n.set {
+		return ""
+	}
+	if n.writeMetadata {
+		return "metadata"
+	}
+
+	return "true"
+}
+
+func (n *noWrapperFlag) Set(value string) error {
+	switch value {
+	case "true":
+		*n = noWrapperFlag{set: true}
+	case "metadata":
+		*n = noWrapperFlag{set: true, writeMetadata: true}
+	case "false":
+		*n = noWrapperFlag{}
+	default:
+		return fmt.Errorf("no wrapper %q must be true, false or metadata", value)
+	}
+
+	return nil
+}
+
+// Flag may be set without value like a boolean flag
+func (n *noWrapperFlag) IsBoolFlag() bool {
+	return true
+}
+
+// Sets flag by value of environment variable unless it is empty
+func (n *noWrapperFlag) setDefault(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	return n.Set(value)
+}
+
+// Returns value of first set environment variable
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
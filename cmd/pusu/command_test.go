@@ -0,0 +1,458 @@
+package main
+
+import (
+	"bytes"
+	"cloud.google.com/go/pubsub/pstest"
+	"context"
+	"errors"
+	"github.com/metglobal-compass/pusu/adapters/google"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun_TopicsList(t *testing.T) {
+	fakeTopology := new(fakeTopology)
+	fakeTopology.On("Topics").Return([]string{"a", "b"}, nil)
+
+	// Topics must be printed line by line
+	out, err := runWith(fakeTopology, "topics", "list")
+	assert.Nil(t, err)
+	assert.Equal(t, "a\nb\n", out)
+}
+
+func TestRun_SubscriptionsList(t *testing.T) {
+	fakeTopology := new(fakeTopology)
+	fakeTopology.On("Subscriptions").Return([]google.SubscriptionInfo{
+		{Name: "pulling", Topic: "test"},
+		{Name: "testing", Topic: "test", Endpoint: "http://old", ExpectedEndpoint: "http://new"},
+	}, nil)
+
+	// Pull and outdated subscriptions must be marked
+	out, err := runWith(fakeTopology, "subscriptions", "list")
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[1], "(pull)")
+	assert.Contains(t, lines[2], "http://old (outdated)")
+}
+
+func TestRun_SubscriptionsDescribe(t *testing.T) {
+	fakeTopology := new(fakeTopology)
+	fakeTopology.On("DescribeSubscription", "testing").Return(google.SubscriptionInfo{
+		Name: "testing", Topic: "test", Endpoint: "http://new", ExpectedEndpoint: "http://new", AckDeadline: 10 * time.Second,
+	}, nil)
+
+	// Configuration and expected endpoint must be printed
+	out, err := runWith(fakeTopology, "subscriptions", "describe", "test", "testing")
+	assert.Nil(t, err)
+	assert.Contains(t, out, "Expected endpoint:  http://new")
+	assert.Contains(t, out, "Ack deadline:       10s")
+}
+
+func TestRun_Mutations(t *testing.T) {
+	fakeTopology := new(fakeTopology)
+	fakeTopology.On("CreateTopic", "test").Return(nil)
+	fakeTopology.On("DeleteTopic", "test").Return(nil)
+	fakeTopology.On("CreateSubscription", "test", "testing").Return(nil)
+	fakeTopology.On("UpdateSubscription", "testing").Return(nil)
+	fakeTopology.On("DeleteSubscription", "testing").Return(errors.New("error"))
+
+	// Commands must be dispatched to topology with their arguments
+	_, err := runWith(fakeTopology, "topics", "create", "test")
+	assert.Nil(t, err)
+	_, err = runWith(fakeTopology, "topics", "delete", "test")
+	assert.Nil(t, err)
+	_, err = runWith(fakeTopology, "subscriptions", "create", "test", "testing")
+	assert.Nil(t, err)
+	_, err = runWith(fakeTopology, "subscriptions", "update", "test", "testing")
+	assert.Nil(t, err)
+	_, err = runWith(fakeTopology, "subscriptions", "delete", "test", "testing")
+	assert.Error(t, err)
+	fakeTopology.AssertExpectations(t)
+}
+
+func TestRun_MutationsWithNaming(t *testing.T) {
+	fakeTopology := new(fakeTopology)
+	fakeTopology.On("CreateTopic", "staging-test").Return(nil)
+	fakeTopology.On("DeleteTopic", "staging-test").Return(nil)
+	fakeTopology.On("DescribeSubscription", "staging-testing").Return(google.SubscriptionInfo{Name: "staging-testing"}, nil)
+	fakeTopology.On("UpdateSubscription", "staging-testing").Return(nil)
+	fakeTopology.On("DeleteSubscription", "staging-testing").Return(nil)
+
+	// Names of pusu topics and subscriptions must be mapped to names in Pub/Sub by naming policy
+	for _, args := range [][]string{
+		{"topics", "create", "test"},
+		{"topics", "delete", "test"},
+		{"subscriptions", "describe", "test", "testing"},
+		{"subscriptions", "update", "test", "testing"},
+		{"subscriptions", "delete", "test", "testing"},
+	} {
+		_, err := runWith(fakeTopology, append([]string{"-env", "staging"}, args...)...)
+		assert.Nil(t, err, strings.Join(args, " "))
+	}
+	fakeTopology.AssertExpectations(t)
+}
+
+func TestRun_MutationsWithRawNames(t *testing.T) {
+	fakeTopology := new(fakeTopology)
+	fakeTopology.On("CreateTopic", "staging-test").Return(nil)
+	fakeTopology.On("DescribeSubscription", "staging-testing").Return(google.SubscriptionInfo{Name: "staging-testing"}, nil)
+	fakeTopology.On("UpdateSubscription", "staging-testing").Return(nil)
+	fakeTopology.On("DeleteSubscription", "staging-testing").Return(nil)
+
+	// Names in Pub/Sub must be taken as they are listed
+	for _, args := range [][]string{
+		{"topics", "create", "staging-test"},
+		{"subscriptions", "describe", "staging-testing"},
+		{"subscriptions", "update", "staging-testing"},
+		{"subscriptions", "delete", "staging-testing"},
+	} {
+		_, err := runWith(fakeTopology, append([]string{"-raw"}, args...)...)
+		assert.Nil(t, err, strings.Join(args, " "))
+	}
+	fakeTopology.AssertExpectations(t)
+
+	// Raw names must not be mixed with naming policy
+	_, err := runWith(fakeTopology, "-raw", "-env", "staging", "topics", "list")
+	assert.Error(t, err)
+}
+
+func TestRun_NoWrapper(t *testing.T) {
+	// No wrapper flag must be passed to topology, with metadata if requested
+	cases := map[string]noWrapperFlag{
+		"":                      {},
+		"-no-wrapper":           {set: true},
+		"-no-wrapper=true":      {set: true},
+		"-no-wrapper=metadata":  {set: true, writeMetadata: true},
+		"--no-wrapper=metadata": {set: true, writeMetadata: true},
+		"-no-wrapper=false":     {},
+	}
+	for flag, expected := range cases {
+		args := []string{"topics", "list"}
+		if flag != "" {
+			args = append([]string{flag}, args...)
+		}
+		noWrapper, err := noWrapperOf(args...)
+		assert.Nil(t, err, flag)
+		assert.Equal(t, expected, noWrapper, flag)
+	}
+
+	// Environment variable must be default of flag
+	t.Setenv("PUSU_NO_WRAPPER", "metadata")
+	noWrapper, err := noWrapperOf("topics", "list")
+	assert.Nil(t, err)
+	assert.Equal(t, noWrapperFlag{set: true, writeMetadata: true}, noWrapper)
+	noWrapper, err = noWrapperOf("-no-wrapper=false", "topics", "list")
+	assert.Nil(t, err)
+	assert.Equal(t, noWrapperFlag{}, noWrapper)
+
+	// Unknown values must be rejected
+	_, err = noWrapperOf("-no-wrapper=headers", "topics", "list")
+	assert.Error(t, err)
+	t.Setenv("PUSU_NO_WRAPPER", "headers")
+	_, err = noWrapperOf("topics", "list")
+	assert.Error(t, err)
+}
+
+func TestRun_Publish(t *testing.T) {
+	fakeTopology := new(fakeTopology)
+	fakeTopology.On("Publish", "test", []byte("hello"), map[string]string{"k": "v", "empty": ""}).Return("1", nil)
+
+	// Input must be published with attributes and id must be printed
+	out, err := runWithInput(fakeTopology, "hello", "publish", "--attr", "k=v", "-attr", "empty=", "test")
+	assert.Nil(t, err)
+	assert.Equal(t, "1\n", out)
+}
+
+func TestRun_PublishFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "message.json")
+	assert.Nil(t, os.WriteFile(file, []byte(`{"id": 1}`), 0644))
+	fakeTopology := new(fakeTopology)
+	fakeTopology.On("Publish", "test", []byte(`{"id": 1}`), map[string]string{}).Return("1", nil)
+
+	// Data of file must be published instead of input
+	_, err := runWithInput(fakeTopology, "ignored", "publish", "-file", file, "test")
+	assert.Nil(t, err)
+	fakeTopology.AssertExpectations(t)
+}
+
+func TestRun_PublishErrorOnInvalidAttribute(t *testing.T) {
+	// Attributes must be in key=value form
+	_, err := runWith(new(fakeTopology), "publish", "-attr", "invalid", "test")
+	assert.Error(t, err)
+}
+
+func TestRun_Tail(t *testing.T) {
+	publishTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fakeTopology := new(fakeTopology)
+	fakeTopology.On("Tail", mock.Anything, "test", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		handle := args.Get(2).(func(m google.ReceivedMessage))
+		handle(google.ReceivedMessage{ID: "1", PublishTime: publishTime, Attributes: map[string]string{"k": "v"}, Data: []byte("hello")})
+		handle(google.ReceivedMessage{ID: "2", PublishTime: publishTime, Data: []byte("world")})
+	})
+
+	// Messages must be printed as json lines
+	out, err := runWith(fakeTopology, "tail", "test")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"id":"1","publish_time":"2024-01-02T03:04:05Z","attributes":{"k":"v"},"data":"hello"}
+{"id":"2","publish_time":"2024-01-02T03:04:05Z","data":"world"}
+`, out)
+
+	// Messages must be printed as raw data
+	out, err = runWith(fakeTopology, "tail", "-format", "raw", "test")
+	assert.Nil(t, err)
+	assert.Equal(t, "hello\nworld\n", out)
+
+	// Unknown formats must be rejected
+	_, err = runWith(fakeTopology, "tail", "-format", "xml", "test")
+	assert.Error(t, err)
+}
+
+func TestRun_Apply(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "topology.yaml")
+	assert.Nil(t, os.WriteFile(file, []byte("topics:\n  - name: test\n"), 0644))
+	spec := google.TopologySpec{Topics: []google.TopicSpec{{Name: "test"}}}
+	changes := []google.Change{
+		{Action: "create", Kind: "topic", Name: "test"},
+		{Action: "update", Kind: "subscription", Name: "testing", Fields: []string{"endpoint", "labels"}},
+	}
+	fakeTopology := new(fakeTopology)
+	fakeTopology.On("Plan", spec, true).Return(changes, nil)
+	fakeTopology.On("Apply", spec, false).Return(changes[:1], errors.New("permission denied"))
+
+	// Dry run must only print changes
+	out, err := runWith(fakeTopology, "apply", "-dry-run", "-prune", file)
+	assert.Nil(t, err)
+	assert.Equal(t, "create topic test\nupdate subscription testing: endpoint, labels\n", out)
+	fakeTopology.AssertNotCalled(t, "Apply", mock.Anything, mock.Anything)
+
+	// Applied changes must be printed even if applying fails
+	out, err = runWith(fakeTopology, "apply", file)
+	assert.EqualError(t, err, "permission denied")
+	assert.Equal(t, "create topic test\n", out)
+
+	// Invalid files must be rejected
+	assert.Nil(t, os.WriteFile(file, []byte("topics:\n  - name: \"\"\n"), 0644))
+	_, err = runWith(fakeTopology, "apply", file)
+	assert.Error(t, err)
+}
+
+func TestRun_Export(t *testing.T) {
+	fakeTopology := new(fakeTopology)
+	fakeTopology.On("Export").Return(google.TopologySpec{Topics: []google.TopicSpec{{
+		Name:          "test",
+		Subscriptions: []google.SubscriptionSpec{{Name: "testing", AckDeadline: google.Duration(10 * time.Second)}},
+	}}}, nil)
+
+	// Topology must be printed as yaml by default
+	out, err := runWith(fakeTopology, "export")
+	assert.Nil(t, err)
+	assert.Equal(t, "topics:\n- name: test\n  subscriptions:\n  - ack_deadline: 10s\n    name: testing\n", out)
+
+	// Topology must be printed as json
+	out, err = runWith(fakeTopology, "export", "-format", "json")
+	assert.Nil(t, err)
+	assert.Contains(t, out, `"ack_deadline": "10s"`)
+}
+
+func TestRun_ErrorOnUnknownCommand(t *testing.T) {
+	// Unknown commands and wrong number of arguments must fail with usage
+	for _, args := range [][]string{{}, {"topics"}, {"topics", "rename", "a"}, {"subscriptions", "create", "test"}} {
+		out, err := runWith(new(fakeTopology), args...)
+		assert.Error(t, err, strings.Join(args, " "))
+		assert.Contains(t, out, "Usage: pusu")
+	}
+}
+
+func TestRun_Emulator(t *testing.T) {
+	// Run commands against an in-memory server through emulator host
+	server := pstest.NewServer()
+	defer server.Close()
+	t.Setenv("PUBSUB_EMULATOR_HOST", server.Addr)
+	open := opener(openTopology)
+
+	var out bytes.Buffer
+	err := run(context.Background(), []string{"-project", "my-project", "-host", "http://localhost", "subscriptions", "create", "test", "testing"}, nil, &out, open)
+	assert.Nil(t, err)
+
+	// Created subscription must point to endpoint of pusu subscriber
+	err = run(context.Background(), []string{"-project", "my-project", "-host", "http://localhost", "subscriptions", "list"}, nil, &out, open)
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), "http://localhost/_handlers/topics/test/subscribers/testing")
+
+	// Subscription must be pointed to endpoint of custom path template
+	out.Reset()
+	err = run(context.Background(), []string{"-project", "my-project", "-host", "http://localhost", "-path", "/events/{subscription}", "subscriptions", "update", "test", "testing"}, nil, &out, open)
+	assert.Nil(t, err)
+	err = run(context.Background(), []string{"-project", "my-project", "-host", "http://localhost", "-path", "/events/{subscription}", "subscriptions", "list"}, nil, &out, open)
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), "http://localhost/events/testing")
+	assert.NotContains(t, out.String(), "outdated")
+	err = run(context.Background(), []string{"-project", "my-project", "-host", "http://localhost", "subscriptions", "update", "test", "testing"}, nil, &out, open)
+	assert.Nil(t, err)
+	assert.NotContains(t, out.String(), "outdated")
+
+	// Subscription must push raw payloads with metadata when requested
+	err = run(context.Background(), []string{"-project", "my-project", "-host", "http://localhost", "-no-wrapper=metadata", "subscriptions", "update", "test", "testing"}, nil, &out, open)
+	assert.Nil(t, err)
+	topology, err := open("my-project", "", "", google.Naming{}, noWrapperFlag{})
+	assert.Nil(t, err)
+	info, err := topology.DescribeSubscription("testing")
+	assert.Nil(t, err)
+	assert.True(t, info.Unwrapped && info.WriteMetadata)
+
+	// Published message must be tailed until context is done and temporary subscription must be deleted
+	ctx, cancel := context.WithCancel(context.Background())
+	tailed := make(chan error)
+	var tailOut bytes.Buffer
+	go func() {
+		tailed <- run(ctx, []string{"-project", "my-project", "tail", "-format", "raw", "test"}, nil, &tailOut, open)
+	}()
+	assert.Eventually(t, func() bool {
+		return len(server.Messages()) == 0 && strings.Count(listSubscriptionsOf(t, open), "test-tail-") == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	out.Reset()
+	err = run(context.Background(), []string{"-project", "my-project", "publish", "test"}, strings.NewReader("hello"), &out, open)
+	assert.Nil(t, err)
+	assert.Eventually(t, func() bool {
+		return server.Messages()[0].Acks > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	assert.Nil(t, <-tailed)
+	assert.Equal(t, "hello\n", tailOut.String())
+	assert.NotContains(t, listSubscriptionsOf(t, open), "test-tail-")
+}
+
+func TestRun_EmulatorWithNaming(t *testing.T) {
+	// Run commands against an in-memory server through emulator host
+	server := pstest.NewServer()
+	defer server.Close()
+	t.Setenv("PUBSUB_EMULATOR_HOST", server.Addr)
+	t.Setenv("PUSU_ENV", "staging")
+	open := opener(openTopology)
+
+	// Subscription must be named by naming policy of environment
+	var out bytes.Buffer
+	err := run(context.Background(), []string{"-project", "my-project", "-host", "http://localhost", "subscriptions", "create", "test", "testing"}, nil, &out, open)
+	assert.Nil(t, err)
+	assert.Contains(t, listSubscriptionsOf(t, open), "staging-testing  staging-test  http://localhost/_handlers/topics/staging-test/subscribers/staging-testing")
+
+	// Naming flags must override environment variables and be validated
+	err = run(context.Background(), []string{"-project", "my-project", "-env", "prod", "-subscription-template", "{subscription}", "topics", "list"}, nil, &out, open)
+	assert.Error(t, err)
+}
+
+// Runs command with a fake topology and returns no wrapper flag which topology is opened with
+func noWrapperOf(args ...string) (noWrapperFlag, error) {
+	fakeTopology := new(fakeTopology)
+	fakeTopology.On("Topics").Return(nil, nil)
+
+	var noWrapper noWrapperFlag
+	err := run(context.Background(), args, nil, new(bytes.Buffer), func(projectId string, host string, path string, naming google.Naming, n noWrapperFlag) (topology, error) {
+		noWrapper = n
+		return fakeTopology, nil
+	})
+
+	return noWrapper, err
+}
+
+// Returns output of subscriptions list command
+func listSubscriptionsOf(t *testing.T, open opener) string {
+	var out bytes.Buffer
+	err := run(context.Background(), []string{"-project", "my-project", "subscriptions", "list"}, nil, &out, open)
+	assert.Nil(t, err)
+
+	return out.String()
+}
+
+// Runs command with given fake topology and returns its output
+func runWith(t topology, args ...string) (string, error) {
+	return runWithInput(t, "", args...)
+}
+
+// Runs command with given fake topology and input, returns its output
+func runWithInput(t topology, input string, args ...string) (string, error) {
+	var out bytes.Buffer
+	err := run(context.Background(), args, strings.NewReader(input), &out, func(projectId string, host string, path string, naming google.Naming, noWrapper noWrapperFlag) (topology, error) {
+		return t, nil
+	})
+
+	return out.String(), err
+}
+
+type fakeTopology struct {
+	mock.Mock
+}
+
+func (f *fakeTopology) Topics() ([]string, error) {
+	args := f.Called()
+	topics, _ := args.Get(0).([]string)
+	return topics, args.Error(1)
+}
+
+func (f *fakeTopology) CreateTopic(name string) error {
+	return f.Called(name).Error(0)
+}
+
+func (f *fakeTopology) DeleteTopic(name string) error {
+	return f.Called(name).Error(0)
+}
+
+func (f *fakeTopology) Subscriptions() ([]google.SubscriptionInfo, error) {
+	args := f.Called()
+	subscriptions, _ := args.Get(0).([]google.SubscriptionInfo)
+	return subscriptions, args.Error(1)
+}
+
+func (f *fakeTopology) DescribeSubscription(name string) (google.SubscriptionInfo, error) {
+	args := f.Called(name)
+	return args.Get(0).(google.SubscriptionInfo), args.Error(1)
+}
+
+func (f *fakeTopology) CreateSubscription(topic string, name string) error {
+	return f.Called(topic, name).Error(0)
+}
+
+func (f *fakeTopology) UpdateSubscription(name string) error {
+	return f.Called(name).Error(0)
+}
+
+func (f *fakeTopology) DeleteSubscription(name string) error {
+	return f.Called(name).Error(0)
+}
+
+func (f *fakeTopology) Publish(topic string, data []byte, attributes map[string]string) (string, error) {
+	args := f.Called(topic, data, attributes)
+	return args.String(0), args.Error(1)
+}
+
+func (f *fakeTopology) Tail(ctx context.Context, topic string, handle func(m google.ReceivedMessage)) error {
+	return f.Called(ctx, topic, handle).Error(0)
+}
+
+func (f *fakeTopology) Plan(spec google.TopologySpec, prune bool) ([]google.Change, error) {
+	args := f.Called(spec, prune)
+	changes, _ := args.Get(0).([]google.Change)
+	return changes, args.Error(1)
+}
+
+func (f *fakeTopology) Apply(spec google.TopologySpec, prune bool) ([]google.Change, error) {
+	args := f.Called(spec, prune)
+	changes, _ := args.Get(0).([]google.Change)
+	return changes, args.Error(1)
+}
+
+func (f *fakeTopology) Export() (google.TopologySpec, error) {
+	args := f.Called()
+	return args.Get(0).(google.TopologySpec), args.Error(1)
+}
+
+func (f *fakeTopology) Close() error {
+	return nil
+}
@@ -0,0 +1,93 @@
+// Command pusu manages topics and subscriptions of a Google Cloud Pub/Sub project. Subscriptions are created with
+// the same configuration as the google adapter creates them, so topology may be provisioned ahead of deployment.
+// Set PUBSUB_EMULATOR_HOST environment variable to use the emulator.
+//
+// Usage:
+//
+//	pusu [-project <project id>] [-host <subscriber host>] [-path <endpoint path template>] [naming flags] [-raw]
+//	     [-no-wrapper[=metadata]] <command> [arguments]
+//
+// Commands:
+//
+//	topics list
+//	topics create <topic>
+//	topics delete <topic>
+//	subscriptions list
+//	subscriptions create <topic> <subscription>
+//	subscriptions describe <topic> <subscription>
+//	subscriptions update <topic> <subscription>
+//	subscriptions delete <topic> <subscription>
+//	publish [-file <path>] [-attr <key>=<value>]... <topic>
+//	tail [-format json|raw] <topic>
+//	apply [-prune] [-dry-run] <file>
+//	export [-format yaml|json]
+//
+// Publish sends data of file, or standard input if file is not given. Tail prints messages published to topic
+// until interrupted, through a temporary pull subscription which is deleted on exit.
+//
+// Apply reconciles project against a YAML or JSON topology file, see google.TopologySpec for its format. Topics and
+// subscriptions which are not declared in file are deleted only with prune. Export prints topology file of project.
+//
+// Project, host and path default to PUSU_PROJECT (or GOOGLE_CLOUD_PROJECT), PUSU_HOST and PUSU_ENDPOINT_PATH
+// environment variables. Path must be same as endpoint path template of subscribers.
+//
+// Naming flags -env, -topic-template, -subscription-template, -prefix and -suffix set naming policy of subscribers,
+// see google.Naming, and default to PUSU_ENV, PUSU_TOPIC_TEMPLATE, PUSU_SUBSCRIPTION_TEMPLATE, PUSU_PREFIX and
+// PUSU_SUFFIX environment variables. Commands take names of pusu topics and subscriptions, which are mapped to names
+// in Pub/Sub by naming policy, so subscriptions are given together with their topics. List commands print names in
+// Pub/Sub. With -raw flag, commands take names in Pub/Sub as they are listed instead, subscriptions describe, update
+// and delete take only subscription name and naming flags are not accepted.
+//
+// Flag -no-wrapper requests Pub/Sub to push raw payloads instead of JSON envelopes to subscriptions created or
+// updated, and -no-wrapper=metadata pushes metadata headers too, see google.Adapter.SetNoWrapper. It must be same as
+// subscribers request and defaults to PUSU_NO_WRAPPER environment variable.
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/metglobal-compass/pusu/adapters/google"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	// Stop long running commands like tail gracefully, so their temporary resources are cleaned up
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, openTopology)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pusu:", err)
+		stop()
+		os.Exit(1)
+	}
+}
+
+// Creates topology of given project whose resources are named by naming policy and push endpoints are routed to given
+// path template, unless it is empty. Raw payloads are pushed if no wrapper flag is set.
+func openTopology(projectId string, host string, path string, naming google.Naming, noWrapper noWrapperFlag) (topology, error) {
+	t, err := google.CreateTopology(projectId, host)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.SetNaming(naming)
+	if err != nil {
+		return nil, err
+	}
+
+	if path != "" {
+		err = t.SetEndpointPath(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if noWrapper.set {
+		t.SetNoWrapper(noWrapper.writeMetadata)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,57 @@
+package pusu
+
+import "context"
+
+// Subscription wrapper which limits number of concurrently handled messages
+type concurrencyLimiter struct {
+	Subscription
+
+	// Buffered channel used as a semaphore. Capacity is maximum in-flight messages.
+	inFlight chan struct{}
+}
+
+// Wraps subscription so that at most maxInFlight messages are handled at the same time.
+// Messages above the limit are rejected immediately with ErrTooManyInFlight, so cloud vendor's backoff kicks in
+// instead of piling up goroutines and connections. Non-positive maxInFlight means no limit.
+// A slot is released when wrapped subscription returns. Handlers given up by WithTimeout inside the limiter keep
+// running after that, so wrap timeouts outside of it, e.g. WithTimeout(LimitConcurrency(s, n), d), to keep the cap.
+func LimitConcurrency(subscription Subscription, maxInFlight int) Subscription {
+	if maxInFlight <= 0 {
+		return subscription
+	}
+
+	c := new(concurrencyLimiter)
+	c.Subscription = subscription
+	c.inFlight = make(chan struct{}, maxInFlight)
+
+	return c
+}
+
+// Handles message if there is a free slot, otherwise returns ErrTooManyInFlight without waiting
+func (c *concurrencyLimiter) Handle(m *Message) error {
+	select {
+	case c.inFlight <- struct{}{}:
+	default:
+		return ErrTooManyInFlight
+	}
+	defer func() { <-c.inFlight }()
+
+	return c.Subscription.Handle(m)
+}
+
+// Waits for a free slot and handles message. Returns context error if context is done before.
+func (c *concurrencyLimiter) HandleBlocking(ctx context.Context, m *Message) error {
+	select {
+	case c.inFlight <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-c.inFlight }()
+
+	return HandleBlocking(ctx, c.Subscription, m)
+}
+
+// Returns wrapped subscription
+func (c *concurrencyLimiter) Unwrap() Subscription {
+	return c.Subscription
+}
@@ -0,0 +1,96 @@
+package pusu
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimitConcurrency(t *testing.T) {
+	// Create a subscription which blocks until released
+	subscription := &blockingSubscription{started: make(chan struct{}, 1), release: make(chan struct{})}
+	limited := LimitConcurrency(subscription, 1)
+
+	// Topic and name must be kept as is
+	if limited.Topic() != "test" || limited.Name() != "testing" {
+		t.Errorf("Limited subscription must keep topic and name. Actual: %s %s", limited.Topic(), limited.Name())
+	}
+
+	// Occupy the only slot
+	done := make(chan error)
+	go func() {
+		done <- limited.Handle(new(Message))
+	}()
+	<-subscription.started
+
+	// Second message must be rejected immediately
+	if err := limited.Handle(new(Message)); err != ErrTooManyInFlight {
+		t.Errorf("Handle error:\nExpected: %v\nActual: %v", ErrTooManyInFlight, err)
+	}
+
+	// Release first message. Slot must be freed afterwards.
+	close(subscription.release)
+	if err := <-done; err != nil {
+		t.Errorf("First message must be handled successfully. Actual error: %s", err)
+	}
+	if err := limited.Handle(new(Message)); err != nil {
+		t.Errorf("Message must be handled after slot is freed. Actual error: %s", err)
+	}
+}
+
+func TestLimitConcurrencyHandleBlocking(t *testing.T) {
+	// Create a subscription which blocks until released and occupy the only slot
+	subscription := &blockingSubscription{started: make(chan struct{}, 1), release: make(chan struct{})}
+	limited := LimitConcurrency(subscription, 1)
+	go limited.Handle(new(Message))
+	<-subscription.started
+
+	// Blocking handle must give up when context is done before a slot is freed
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := HandleBlocking(ctx, limited, new(Message)); err != context.DeadlineExceeded {
+		t.Errorf("Handle error:\nExpected: %v\nActual: %v", context.DeadlineExceeded, err)
+	}
+
+	// Blocking handle must wait for the slot instead of rejecting
+	done := make(chan error)
+	go func() {
+		done <- HandleBlocking(context.Background(), limited, new(Message))
+	}()
+	close(subscription.release)
+	if err := <-done; err != nil {
+		t.Errorf("Message must be handled after waiting for slot. Actual error: %s", err)
+	}
+}
+
+func TestLimitConcurrencyUnlimited(t *testing.T) {
+	// Non-positive limit must return subscription as is
+	subscription := new(failureSubscription)
+
+	if LimitConcurrency(subscription, 0) != Subscription(subscription) {
+		t.Error("Subscription must not be wrapped when limit is not positive")
+	}
+}
+
+// A subscription which signals when handling starts and blocks until release channel is closed
+type blockingSubscription struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingSubscription) Topic() string {
+	return "test"
+}
+
+func (b *blockingSubscription) Name() string {
+	return "testing"
+}
+
+func (b *blockingSubscription) Handle(m *Message) error {
+	select {
+	case b.started <- struct{}{}:
+	default:
+	}
+	<-b.release
+	return nil
+}
@@ -0,0 +1,32 @@
+package pusu
+
+import "errors"
+
+var (
+	// Returned by a concurrency limited subscription when maximum number of messages are already being handled.
+	// Adapters must reject such messages quickly with a retryable response instead of queueing them.
+	ErrTooManyInFlight = errors.New("too many messages in flight")
+
+	// Returned by a rate limited subscription when its token bucket is empty.
+	// Adapters must reject such messages with a retryable response.
+	ErrRateLimited = errors.New("rate limit exceeded")
+
+	// Returned by a subscription with circuit breaker while the circuit is open.
+	// Adapters must reject such messages quickly with a retryable response.
+	ErrCircuitOpen = errors.New("circuit is open")
+
+	// Returned when handling of a message takes longer than handler timeout.
+	// Adapters must treat it as a retryable failure.
+	ErrHandlerTimeout = errors.New("handler timed out")
+
+	// Returned when a message must be dead lettered without further attempts, e.g. a message no route of a Router
+	// matches. Adapters which count attempts themselves dead letter such messages immediately, others treat it as a
+	// failure and leave dead lettering to the broker.
+	ErrDeadLetter = errors.New("message must be dead lettered")
+)
+
+// Reports whether err is a rejection of a subscription wrapper, meaning the handler was not called at all
+// and message must be retried later.
+func IsRejection(err error) bool {
+	return errors.Is(err, ErrTooManyInFlight) || errors.Is(err, ErrRateLimited) || errors.Is(err, ErrCircuitOpen)
+}
@@ -3,26 +3,26 @@ package main
 import (
 	"fmt"
 	"github.com/metglobal-compass/pusu"
-	"github.com/metglobal-compass/pusu/adapters/google"
+	_ "github.com/metglobal-compass/pusu/adapters/google"
 	"os"
 )
 
 var (
 	subscription *Subscription
-	adapter      *google.Adapter
+	adapter      pusu.Adapter
 )
 
 func init() {
 	var err error
-	subscription := new(Subscription)
+	subscription = new(Subscription)
 
-	// Create adapter with environment variables
-	adapter, err = google.CreateAdapter(os.Getenv("PUB_SUB_PROJECT_ID"), os.Getenv("BASE_HOST"))
+	// Create adapter of broker given with environment variable (Ex: gcp://my-project-id?host=http://printing:8080)
+	adapter, err = pusu.Open(os.Getenv("PUSU_URL"))
 	if err != nil {
 		panic(err)
 	}
 
-	// Create subscription in google cloud
+	// Create subscription on broker
 	err = adapter.CreateSubscription(subscription)
 	if err != nil {
 		panic(err)
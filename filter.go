@@ -0,0 +1,379 @@
+package pusu
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Maximum length of filter expressions in bytes, as in Google Cloud Pub/Sub
+const maxFilterLength = 256
+
+// Filter is an attribute filter expression of a subscription, in syntax of Google Cloud Pub/Sub filters:
+//
+//	attributes.KEY = "value"           attribute has value
+//	attributes.KEY != "value"          attribute is missing or has another value
+//	attributes:KEY                     attribute exists
+//	hasPrefix(attributes.KEY, "pre")   attribute value starts with prefix
+//
+// Conditions are combined with NOT, AND, OR and parentheses. AND and OR must not be mixed without parentheses.
+// Keys which are not made of letters, numbers, - and _ are quoted, e.g. attributes."ce-type". Matches evaluates keys
+// case insensitively, since attributes of headers have lower case keys, while Pub/Sub matches them exactly.
+type Filter struct {
+	expression string
+	matches    func(attributes map[string]string) bool
+}
+
+// Parses and validates filter expression. Returns nil filter, which matches every message, if expression is empty.
+func ParseFilter(expression string) (*Filter, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, nil
+	}
+	if len(expression) > maxFilterLength {
+		return nil, fmt.Errorf("filter %q is longer than %d bytes", expression, maxFilterLength)
+	}
+
+	tokens, err := scanFilter(expression)
+	if err != nil {
+		return nil, fmt.Errorf("filter %q is invalid: %w", expression, err)
+	}
+	p := &filterParser{tokens: tokens}
+	matches, err := p.parseExpression()
+	if err == nil && p.peek().kind != filterEnd {
+		err = p.unexpected()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("filter %q is invalid: %w", expression, err)
+	}
+
+	f := new(Filter)
+	f.expression = expression
+	f.matches = matches
+
+	return f, nil
+}
+
+// Reports whether message with given attributes passes filter. Nil filter passes every message.
+func (f *Filter) Matches(attributes map[string]string) bool {
+	if f == nil {
+		return true
+	}
+
+	return f.matches(attributes)
+}
+
+// Returns filter expression
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+
+	return f.expression
+}
+
+// Subscription wrapper which declares a filter expression
+type filteredSubscription struct {
+	Subscription
+	filter string
+}
+
+// Wraps subscription so that it receives only messages whose attributes pass filter expression. Filter is evaluated by
+// broker, e.g. as a Pub/Sub subscription filter, so filtered out messages never reach the handler. Adapters whose
+// brokers can't evaluate filters reject filtered subscriptions when they are created, rather than handling every
+// message. Empty filter means no filter.
+func WithFilter(subscription Subscription, filter string) Subscription {
+	if filter == "" {
+		return subscription
+	}
+
+	f := new(filteredSubscription)
+	f.Subscription = subscription
+	f.filter = filter
+
+	return f
+}
+
+// Returns wrapped subscription
+func (f *filteredSubscription) Unwrap() Subscription {
+	return f.Subscription
+}
+
+// Creators which enforce filters of subscriptions set with WithFilter implement FilterEnforcer, so Provisioner accepts
+// filtered subscriptions of their adapters
+type FilterEnforcer interface {
+	EnforcesFilters() bool
+}
+
+// Reports whether any of given creators enforces filters
+func enforcesFilters(creators []Creator) bool {
+	for _, creator := range creators {
+		if enforcer, ok := creator.(FilterEnforcer); ok && enforcer.EnforcesFilters() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns filter expression of given subscription set with WithFilter, looking through subscription wrappers.
+// Reports false if subscription has no filter.
+func FilterOf(subscription Subscription) (string, bool) {
+	for ; subscription != nil; subscription = Unwrap(subscription) {
+		if filtered, ok := subscription.(*filteredSubscription); ok {
+			return filtered.filter, true
+		}
+	}
+
+	return "", false
+}
+
+// Kinds of filter tokens
+const (
+	filterEnd = iota
+	filterWord
+	filterString
+	filterSymbol
+)
+
+// Token of filter expression
+type filterToken struct {
+	kind int
+
+	// Word, symbol or unquoted string
+	text string
+
+	// Byte offset in expression
+	position int
+}
+
+// Splits filter expression into tokens
+func scanFilter(expression string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isFilterWordByte(c):
+			start := i
+			for i < len(expression) && isFilterWordByte(expression[i]) {
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: filterWord, text: expression[start:i], position: start})
+		case c == '"':
+			start := i
+			for i++; i < len(expression) && expression[i] != '"'; i++ {
+				if expression[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(expression) {
+				return nil, fmt.Errorf("string at position %d is not terminated", start)
+			}
+			i++
+			text, err := strconv.Unquote(expression[start:i])
+			if err != nil {
+				return nil, fmt.Errorf("string at position %d is malformed", start)
+			}
+			tokens = append(tokens, filterToken{kind: filterString, text: text, position: start})
+		case strings.HasPrefix(expression[i:], "!="):
+			tokens = append(tokens, filterToken{kind: filterSymbol, text: "!=", position: i})
+			i += 2
+		case strings.IndexByte("().,:=", c) >= 0:
+			tokens = append(tokens, filterToken{kind: filterSymbol, text: string(c), position: i})
+			i++
+		default:
+			return nil, fmt.Errorf("operator or character %q at position %d is not supported", c, i)
+		}
+	}
+
+	return append(tokens, filterToken{kind: filterEnd, position: len(expression)}), nil
+}
+
+func isFilterWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// Recursive descent parser of filter expressions, which compiles them to functions
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() filterToken {
+	t := p.tokens[p.pos]
+	if t.kind != filterEnd {
+		p.pos++
+	}
+
+	return t
+}
+
+// Consumes next token if it is given word or symbol
+func (p *filterParser) accept(text string) bool {
+	t := p.peek()
+	if (t.kind == filterWord || t.kind == filterSymbol) && t.text == text {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *filterParser) expect(text string) error {
+	if !p.accept(text) {
+		return fmt.Errorf("expected %s at position %d", text, p.peek().position)
+	}
+
+	return nil
+}
+
+func (p *filterParser) unexpected() error {
+	t := p.peek()
+	if t.kind == filterEnd {
+		return errors.New("unexpected end of filter")
+	}
+
+	return fmt.Errorf("unexpected %q at position %d", t.text, t.position)
+}
+
+// expression := condition {AND condition} | condition {OR condition}
+func (p *filterParser) parseExpression() (func(map[string]string) bool, error) {
+	left, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+
+	operator := ""
+	for t := p.peek(); t.kind == filterWord && (t.text == "AND" || t.text == "OR"); t = p.peek() {
+		if operator != "" && t.text != operator {
+			return nil, fmt.Errorf("AND and OR must not be mixed without parentheses at position %d", t.position)
+		}
+		operator = p.next().text
+
+		right, err := p.parseCondition()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if operator == "AND" {
+			left = func(a map[string]string) bool { return l(a) && right(a) }
+		} else {
+			left = func(a map[string]string) bool { return l(a) || right(a) }
+		}
+	}
+
+	return left, nil
+}
+
+// condition := NOT condition | ( expression ) | attributes.KEY = "v" | attributes.KEY != "v" | attributes:KEY |
+// hasPrefix(attributes.KEY, "v")
+func (p *filterParser) parseCondition() (func(map[string]string) bool, error) {
+	switch {
+	case p.accept("NOT"):
+		condition, err := p.parseCondition()
+		if err != nil {
+			return nil, err
+		}
+		return func(a map[string]string) bool { return !condition(a) }, nil
+
+	case p.accept("("):
+		expression, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		return expression, p.expect(")")
+
+	case p.accept("hasPrefix"):
+		err := p.expect("(")
+		if err != nil {
+			return nil, err
+		}
+		key, err := p.parseAttribute()
+		if err != nil {
+			return nil, err
+		}
+		err = p.expect(",")
+		if err != nil {
+			return nil, err
+		}
+		prefix, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return func(a map[string]string) bool {
+			value, ok := lookupAttribute(a, key)
+			return ok && strings.HasPrefix(value, prefix)
+		}, p.expect(")")
+
+	case p.accept("attributes"):
+		if p.accept(":") {
+			key, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+			return func(a map[string]string) bool {
+				_, ok := lookupAttribute(a, key)
+				return ok
+			}, nil
+		}
+
+		p.pos--
+		key, err := p.parseAttribute()
+		if err != nil {
+			return nil, err
+		}
+		negated := p.accept("!=")
+		if !negated && !p.accept("=") {
+			return nil, fmt.Errorf("expected = or != at position %d", p.peek().position)
+		}
+		expected, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return func(a map[string]string) bool {
+			value, ok := lookupAttribute(a, key)
+			return (ok && value == expected) != negated
+		}, nil
+	}
+
+	return nil, p.unexpected()
+}
+
+// attribute := attributes.KEY
+func (p *filterParser) parseAttribute() (string, error) {
+	err := p.expect("attributes")
+	if err != nil {
+		return "", err
+	}
+	err = p.expect(".")
+	if err != nil {
+		return "", err
+	}
+
+	return p.parseKey()
+}
+
+// Key is a word or a quoted string
+func (p *filterParser) parseKey() (string, error) {
+	t := p.peek()
+	if t.kind != filterWord && t.kind != filterString {
+		return "", fmt.Errorf("expected attribute key at position %d", t.position)
+	}
+
+	return p.next().text, nil
+}
+
+func (p *filterParser) parseString() (string, error) {
+	t := p.peek()
+	if t.kind != filterString {
+		return "", fmt.Errorf("expected quoted string at position %d", t.position)
+	}
+
+	return p.next().text, nil
+}
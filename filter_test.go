@@ -0,0 +1,92 @@
+package pusu
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	attributes := map[string]string{"type": "order.created", "region": "eu", "ce-source": "//orders"}
+
+	// Filters must be evaluated against attributes
+	filters := map[string]bool{
+		`attributes.type = "order.created"`:                                                    true,
+		`attributes.type = "order.deleted"`:                                                    false,
+		`attributes.type != "order.deleted"`:                                                   true,
+		`attributes.missing != "x"`:                                                            true,
+		`attributes:region`:                                                                    true,
+		`NOT attributes:region`:                                                                false,
+		`attributes:"ce-source" AND attributes."ce-source" = "//orders"`:                       true,
+		`hasPrefix(attributes.type, "order.")`:                                                 true,
+		`hasPrefix(attributes.missing, "")`:                                                    false,
+		`attributes.region = "us" OR attributes.region = "eu"`:                                 true,
+		`attributes.region = "eu" AND attributes.type = "order.deleted"`:                       false,
+		`(attributes.region = "us" OR attributes.region = "eu") AND NOT attributes:debug`:      true,
+		`attributes.type = "order.created" AND (attributes.region = "us" OR attributes:other)`: false,
+		`attributes.quote = "say \"hi\""`:                                                      false,
+		`attributes.Region = "eu" AND attributes:"CE-Source"`:                                  true,
+	}
+	for expression, expected := range filters {
+		filter, err := ParseFilter(expression)
+		if err != nil {
+			t.Errorf("Filter %s must be valid: %s", expression, err)
+			continue
+		}
+		if filter.Matches(attributes) != expected {
+			t.Errorf("Filter %s:\nExpected: %t\nActual: %t", expression, expected, !expected)
+		}
+		if filter.String() != expression {
+			t.Errorf("Error: Expected: %s, Actual: %s", expression, filter.String())
+		}
+	}
+
+	// Empty filter must match every message
+	filter, err := ParseFilter(" ")
+	if err != nil || filter != nil || !filter.Matches(nil) {
+		t.Errorf("Empty filter must match every message: %v", err)
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	// Filters with invalid syntax or unsupported operators must be rejected
+	expressions := []string{
+		`attributes.type = order`,
+		`attributes.type > "a"`,
+		`attributes.type == "a"`,
+		`attributes.type = "a`,
+		`attributes.type`,
+		`data = "a"`,
+		`attributes.a = "1" AND attributes.b = "2" OR attributes.c = "3"`,
+		`(attributes:a`,
+		`attributes:a)`,
+		`NOT`,
+		`hasPrefix(attributes.type "a")`,
+		`attributes.type = "a" && attributes.region = "eu"`,
+		`attributes.type = "` + strings.Repeat("a", maxFilterLength) + `"`,
+	}
+	for _, expression := range expressions {
+		if _, err := ParseFilter(expression); err == nil {
+			t.Errorf("Filter %s must be rejected", expression)
+		}
+	}
+}
+
+func TestFilterOf(t *testing.T) {
+	// Subscription without filter must not report one
+	if _, ok := FilterOf(new(failureSubscription)); ok {
+		t.Error("Subscription without filter must not report a filter")
+	}
+	if _, ok := FilterOf(WithFilter(new(failureSubscription), "")); ok {
+		t.Error("Empty filter must not be reported")
+	}
+
+	// Filter must be found through other wrappers
+	subscription := LimitConcurrency(WithFilter(new(failureSubscription), `attributes:type`), 1)
+	filter, ok := FilterOf(subscription)
+	if !ok || filter != `attributes:type` {
+		t.Errorf("Error: Expected: %s, Actual: %s", `attributes:type`, filter)
+	}
+	if subscription.Name() != "testing" || subscription.Topic() != "test" {
+		t.Error("Filtered subscription must keep its topic and name")
+	}
+}
@@ -0,0 +1,84 @@
+package pusu
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Headers which are set by HTTP, browsers, proxies, load balancers or tracing rather than by producers, so they are not
+// attributes of messages pushed over HTTP
+var transportHeaders = map[string]bool{
+	"accept": true, "accept-encoding": true, "accept-language": true, "authorization": true, "cache-control": true,
+	"connection": true, "content-length": true, "cookie": true, "forwarded": true, "from": true, "host": true,
+	"origin": true, "proxy-authorization": true, "referer": true, "te": true, "traceparent": true,
+	"tracestate": true, "transfer-encoding": true, "true-client-ip": true, "upgrade": true, "user-agent": true,
+	"via": true, "x-amzn-trace-id": true, "x-cloud-trace-context": true, "x-real-ip": true, "x-request-id": true,
+}
+
+// Prefixes of headers which are set by proxies and platforms, e.g. X-Forwarded-For or X-Appengine-Country
+var transportHeaderPrefixes = []string{"x-appengine-", "x-b3-", "x-envoy-", "x-forwarded-", "cf-"}
+
+// Returns attributes of HTTP request headers, which are headers other than transport, proxy and given excluded ones.
+// Excluded headers ending with * are prefixes, e.g. X-Goog-Pubsub-*. Keys are lower case, since header names are case
+// insensitive and HTTP/2 sends them in lower case anyway, so attributes with upper case keys can't be told apart.
+// Returns nil if there are no attributes.
+func HeaderAttributes(header http.Header, excluded ...string) map[string]string {
+	var attributes map[string]string
+	for name := range header {
+		key := strings.ToLower(name)
+		if isTransportHeader(key) || isExcludedHeader(key, excluded) {
+			continue
+		}
+		if attributes == nil {
+			attributes = make(map[string]string)
+		}
+		attributes[key] = header.Get(name)
+	}
+
+	return attributes
+}
+
+// Reports whether lower case header key is a transport or proxy header
+func isTransportHeader(key string) bool {
+	if transportHeaders[key] {
+		return true
+	}
+	for _, prefix := range transportHeaderPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reports whether lower case header key is one of excluded headers or prefixes
+func isExcludedHeader(key string, excluded []string) bool {
+	for _, name := range excluded {
+		name = strings.ToLower(name)
+		if prefix, ok := strings.CutSuffix(name, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if name == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns value of attribute with given key, matching key case insensitively if there is no attribute of the exact key,
+// since attributes of headers have lower case keys
+func lookupAttribute(attributes map[string]string, key string) (string, bool) {
+	if value, ok := attributes[key]; ok {
+		return value, true
+	}
+	for k, value := range attributes {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+
+	return "", false
+}
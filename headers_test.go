@@ -0,0 +1,48 @@
+package pusu
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestHeaderAttributes(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "push")
+	header.Set("Content-Type", "application/json")
+	header.Set("Cookie", "session=1")
+	header.Set("Origin", "https://example.com")
+	header.Set("X-Request-Id", "1")
+	header.Set("X-Cloud-Trace-Context", "1/1")
+	header.Set("X-Appengine-Country", "TR")
+	header.Set("X-Forwarded-For", "10.0.0.1")
+	header.Set("X-Signature", "sha256=1")
+	header.Set("X-Goog-Pubsub-Message-Id", "1")
+
+	// Transport, proxy and excluded headers must not be attributes and keys must be lower case
+	expected := map[string]string{"x-github-event": "push", "content-type": "application/json"}
+	attributes := HeaderAttributes(header, "x-signature", "X-Goog-Pubsub-*")
+	if !reflect.DeepEqual(attributes, expected) {
+		t.Errorf("Error: Expected: %v, Actual: %v", expected, attributes)
+	}
+
+	// Headers without attributes must have nil attributes
+	if attributes := HeaderAttributes(http.Header{"Cookie": {"session=1"}}); attributes != nil {
+		t.Errorf("Error: Expected: nil, Actual: %v", attributes)
+	}
+}
+
+func TestLookupAttribute(t *testing.T) {
+	attributes := map[string]string{"type": "created", "Type": "deleted"}
+
+	// Exact key must be preferred, other keys must be matched case insensitively
+	if value, ok := lookupAttribute(attributes, "Type"); !ok || value != "deleted" {
+		t.Errorf("Error: Expected: deleted, Actual: %s", value)
+	}
+	if value, ok := lookupAttribute(map[string]string{"x-github-event": "push"}, "X-GitHub-Event"); !ok || value != "push" {
+		t.Errorf("Error: Expected: push, Actual: %s", value)
+	}
+	if _, ok := lookupAttribute(attributes, "kind"); ok {
+		t.Error("Missing attribute must not be found")
+	}
+}
@@ -0,0 +1,141 @@
+package pusu
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// ReadinessCheck reports whether a dependency of the subscriber (database, cache etc.) is ready.
+// Returning a non-nil error marks the whole subscriber as not ready.
+type ReadinessCheck func() error
+
+// Health tracks provisioning state of subscriptions and user-registered readiness checks.
+// It serves liveness and readiness probes of container platforms like Cloud Run, GKE and App Engine.
+// The zero value of Health is ready to use.
+type Health struct {
+	mutex sync.RWMutex
+
+	// Registered subscriptions keyed by subscription name
+	subscriptions map[string]Subscription
+
+	// Provisioning state of subscriptions keyed by subscription name
+	provisioned map[string]bool
+
+	// User-registered readiness checks keyed by check name
+	checks map[string]ReadinessCheck
+}
+
+// Marks subscription as registered. Subscriber is not ready until subscription is provisioned. Registering an already
+// provisioned subscription again keeps it provisioned.
+func (h *Health) Register(subscription Subscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.register(subscription)
+	if _, ok := h.provisioned[subscription.Name()]; !ok {
+		h.provisioned[subscription.Name()] = false
+	}
+}
+
+// Marks subscription as provisioned.
+func (h *Health) Provisioned(subscription Subscription) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.register(subscription)
+	h.provisioned[subscription.Name()] = true
+}
+
+// Adds a readiness check with given name. A check with the same name is replaced.
+func (h *Health) AddReadinessCheck(name string, check ReadinessCheck) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.checks == nil {
+		h.checks = make(map[string]ReadinessCheck)
+	}
+	h.checks[name] = check
+}
+
+// Returns nil if every registered subscription is provisioned and every readiness check passes.
+func (h *Health) Ready() error {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if len(h.subscriptions) == 0 {
+		return errors.New("no subscription is registered")
+	}
+
+	// Iterate in sorted order to keep reported reason deterministic
+	names := h.subscriptionNames()
+	for _, name := range names {
+		if !h.provisioned[name] {
+			return fmt.Errorf("subscription %s is not provisioned", name)
+		}
+	}
+
+	names = nil
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := h.checks[name](); err != nil {
+			return fmt.Errorf("readiness check %s failed: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Http handler of liveness probe. Responds 200 OK as long as the process is able to serve http requests.
+func (h *Health) ServeLiveness(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+// Http handler of readiness probe. Responds 200 OK when subscriber is ready, otherwise 503 with the reason.
+// Circuit breaker states of subscriptions are reported in response body. An open circuit does not make
+// subscriber unready, because rejecting messages quickly is the expected behaviour while dependency is down.
+func (h *Health) ServeReadiness(w http.ResponseWriter, r *http.Request) {
+	status, reason := http.StatusOK, "ok"
+	if err := h.Ready(); err != nil {
+		status, reason = http.StatusServiceUnavailable, err.Error()
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	fmt.Fprintln(w, reason)
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	for _, name := range h.subscriptionNames() {
+		if state, ok := CircuitStateOf(h.subscriptions[name]); ok {
+			fmt.Fprintf(w, "circuit %s: %s\n", name, state)
+		}
+	}
+}
+
+// Adds subscription to registered subscriptions. Caller must hold the lock.
+func (h *Health) register(subscription Subscription) {
+	if h.subscriptions == nil {
+		h.subscriptions = make(map[string]Subscription)
+		h.provisioned = make(map[string]bool)
+	}
+	h.subscriptions[subscription.Name()] = subscription
+}
+
+// Returns sorted names of registered subscriptions. Caller must hold the lock.
+func (h *Health) subscriptionNames() []string {
+	var names []string
+	for name := range h.subscriptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
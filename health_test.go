@@ -0,0 +1,139 @@
+package pusu
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHealth_ReadyWithoutSubscription(t *testing.T) {
+	// A subscriber without any registered subscription must not be ready
+	health := new(Health)
+
+	if health.Ready() == nil {
+		t.Error("Health must not be ready when no subscription is registered")
+	}
+}
+
+func TestHealth_ReadyAfterProvisioning(t *testing.T) {
+	health := new(Health)
+	subscription := new(failureSubscription)
+
+	// Registered but not provisioned subscription must not be ready
+	health.Register(subscription)
+	if health.Ready() == nil {
+		t.Error("Health must not be ready before subscription is provisioned")
+	}
+
+	// Provisioned subscription must be ready
+	health.Provisioned(subscription)
+	if err := health.Ready(); err != nil {
+		t.Errorf("Health must be ready after subscription is provisioned. Actual error: %s", err)
+	}
+}
+
+func TestHealth_RegisterProvisionedSubscription(t *testing.T) {
+	health := new(Health)
+	subscription := new(failureSubscription)
+	health.Provisioned(subscription)
+
+	// Registering a provisioned subscription again must not make subscriber unready
+	health.Register(subscription)
+	if err := health.Ready(); err != nil {
+		t.Errorf("Health must stay ready after subscription is registered again. Actual error: %s", err)
+	}
+}
+
+func TestHealth_ReadyWithFailingCheck(t *testing.T) {
+	health := new(Health)
+	health.Provisioned(new(failureSubscription))
+
+	// Add a failing readiness check. Subscriber must not be ready.
+	health.AddReadinessCheck("database", func() error {
+		return errors.New("connection refused")
+	})
+
+	err := health.Ready()
+	if err == nil || err.Error() != "readiness check database failed: connection refused" {
+		t.Errorf("Readiness error:\nExpected: %s\nActual: %v", "readiness check database failed: connection refused", err)
+	}
+
+	// Replace check with a passing one. Subscriber must be ready.
+	health.AddReadinessCheck("database", func() error {
+		return nil
+	})
+	if err := health.Ready(); err != nil {
+		t.Errorf("Health must be ready when every check passes. Actual error: %s", err)
+	}
+}
+
+func TestHealth_ServeLiveness(t *testing.T) {
+	// Liveness must not depend on readiness
+	w := httptest.NewRecorder()
+	new(Health).ServeLiveness(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Status code is not valid. \nExpected: 200\n Actual:%d", w.Code)
+	}
+}
+
+func TestHealth_ServeReadiness(t *testing.T) {
+	health := new(Health)
+	subscription := new(failureSubscription)
+	health.Register(subscription)
+
+	// Must return 503 before provisioning
+	w := httptest.NewRecorder()
+	health.ServeReadiness(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Status code is not valid. \nExpected: 503\n Actual:%d", w.Code)
+	}
+
+	// Must return 200 after provisioning
+	health.Provisioned(subscription)
+	w = httptest.NewRecorder()
+	health.ServeReadiness(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Status code is not valid. \nExpected: 200\n Actual:%d", w.Code)
+	}
+}
+
+func TestHealth_ServeReadinessCircuitState(t *testing.T) {
+	// Open the circuit of a provisioned subscription
+	health := new(Health)
+	subscription := WithCircuitBreaker(new(failureSubscription), 1, time.Minute)
+	subscription.Handle(new(Message))
+	health.Provisioned(subscription)
+
+	// Open circuit must be reported but must not make subscriber unready
+	w := httptest.NewRecorder()
+	health.ServeReadiness(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Status code is not valid. \nExpected: 200\n Actual:%d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "circuit testing: open") {
+		t.Errorf("Readiness body must report circuit state. Actual:\n%s", w.Body.String())
+	}
+}
+
+func TestHealth_ServeReadinessCircuitStateWhenNotReady(t *testing.T) {
+	// Open the circuit of a subscription which is not provisioned yet
+	health := new(Health)
+	subscription := WithCircuitBreaker(new(failureSubscription), 1, time.Minute)
+	subscription.Handle(new(Message))
+	health.Register(subscription)
+
+	// Reason and circuit state must both be reported with 503
+	w := httptest.NewRecorder()
+	health.ServeReadiness(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Status code is not valid. \nExpected: 503\n Actual:%d", w.Code)
+	}
+	expected := "subscription testing is not provisioned\ncircuit testing: open\n"
+	if w.Body.String() != expected {
+		t.Errorf("Readiness body:\nExpected: %q\nActual: %q", expected, w.Body.String())
+	}
+}
@@ -1,8 +1,12 @@
 package pusu
 
+import "context"
+
 // Message struct holds immutable message payload.
 type Message struct {
-	message interface{}
+	message    interface{}
+	attributes map[string]string
+	ctx        context.Context
 }
 
 // Creates new message with payload message data
@@ -17,3 +21,47 @@ func NewMessage(message interface{}) *Message {
 func (m *Message) Message() interface{} {
 	return m.message
 }
+
+// Get context of message. Context is done when adapter gives up handling of message (e.g. on handler timeout),
+// so long running handlers should stop their work. Returns background context if message has no context.
+func (m *Message) Context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+
+	return m.ctx
+}
+
+// Returns a copy of message with given context
+func (m *Message) WithContext(ctx context.Context) *Message {
+	copied := new(Message)
+	*copied = *m
+	copied.ctx = ctx
+
+	return copied
+}
+
+// Returns a copy of message with given attributes, e.g. Pub/Sub attributes or CloudEvents context attributes
+func (m *Message) WithAttributes(attributes map[string]string) *Message {
+	copied := new(Message)
+	*copied = *m
+	copied.attributes = nil
+	for key, value := range attributes {
+		if copied.attributes == nil {
+			copied.attributes = make(map[string]string, len(attributes))
+		}
+		copied.attributes[key] = value
+	}
+
+	return copied
+}
+
+// Get attributes of message. Returns nil if message has no attributes. Returned map must not be modified.
+func (m *Message) Attributes() map[string]string {
+	return m.attributes
+}
+
+// Get value of attribute. Returns empty string if message does not have attribute.
+func (m *Message) Attribute(key string) string {
+	return m.attributes[key]
+}
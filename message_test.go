@@ -1,6 +1,9 @@
 package pusu
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestMessage_NewMessage(t *testing.T) {
 	expectedPayload := "testmessagedata"
@@ -12,3 +15,51 @@ func TestMessage_NewMessage(t *testing.T) {
 		t.Errorf("Error: Expected: %s, Actual: %s", expectedPayload, actualPayload)
 	}
 }
+
+func TestMessage_WithContext(t *testing.T) {
+	message := NewMessage("testmessagedata")
+
+	// Message without context must return background context
+	if message.Context() != context.Background() {
+		t.Error("Message without context must return background context")
+	}
+
+	// Message with context must keep payload and must not change original message
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	withContext := message.WithContext(ctx)
+
+	if withContext.Context() != ctx {
+		t.Error("Message context is not the given context")
+	}
+	if withContext.Message() != "testmessagedata" {
+		t.Errorf("Error: Expected: %s, Actual: %s", "testmessagedata", withContext.Message())
+	}
+	if message.Context() != context.Background() {
+		t.Error("Original message must not be changed")
+	}
+}
+
+func TestMessage_WithAttributes(t *testing.T) {
+	message := NewMessage("testmessagedata")
+
+	// Message without attributes must return empty attributes
+	if message.Attributes() != nil || message.Attribute("type") != "" {
+		t.Error("Message without attributes must return empty attributes")
+	}
+
+	// Message with attributes must keep payload and must not change original message or be changed by given map
+	attributes := map[string]string{"type": "created"}
+	withAttributes := message.WithAttributes(attributes)
+	attributes["type"] = "deleted"
+
+	if withAttributes.Attribute("type") != "created" || len(withAttributes.Attributes()) != 1 {
+		t.Errorf("Error: Expected: %s, Actual: %s", "created", withAttributes.Attribute("type"))
+	}
+	if withAttributes.Message() != "testmessagedata" {
+		t.Errorf("Error: Expected: %s, Actual: %s", "testmessagedata", withAttributes.Message())
+	}
+	if message.Attributes() != nil {
+		t.Error("Original message must not be changed")
+	}
+}
@@ -0,0 +1,125 @@
+package pusu
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Outcomes of handled messages
+const (
+	OutcomeSuccess         = "success"
+	OutcomeFailure         = "failure"
+	OutcomeTooManyInFlight = "too_many_in_flight"
+	OutcomeRateLimited     = "rate_limited"
+	OutcomeCircuitOpen     = "circuit_open"
+	OutcomeTimeout         = "timeout"
+	OutcomeDeadLetter      = "dead_letter"
+)
+
+// Returns outcome name of a message handled with given error
+func Outcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, ErrTooManyInFlight):
+		return OutcomeTooManyInFlight
+	case errors.Is(err, ErrRateLimited):
+		return OutcomeRateLimited
+	case errors.Is(err, ErrCircuitOpen):
+		return OutcomeCircuitOpen
+	case errors.Is(err, ErrHandlerTimeout):
+		return OutcomeTimeout
+	case errors.Is(err, ErrDeadLetter):
+		return OutcomeDeadLetter
+	default:
+		return OutcomeFailure
+	}
+}
+
+// Metrics counts outcomes of handled messages per subscription and reports circuit breaker states.
+// It serves collected values in Prometheus text exposition format.
+// The zero value of Metrics is ready to use.
+type Metrics struct {
+	mutex sync.RWMutex
+
+	// Registered subscriptions keyed by subscription name
+	subscriptions map[string]Subscription
+
+	// Handled message counters keyed by subscription name and outcome
+	handled map[string]map[string]int64
+}
+
+// Registers subscription to report its circuit breaker state
+func (m *Metrics) Register(subscription Subscription) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.subscriptions == nil {
+		m.subscriptions = make(map[string]Subscription)
+	}
+	m.subscriptions[subscription.Name()] = subscription
+}
+
+// Counts a message of subscription handled with given error
+func (m *Metrics) Observe(subscription Subscription, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.handled == nil {
+		m.handled = make(map[string]map[string]int64)
+	}
+	if m.handled[subscription.Name()] == nil {
+		m.handled[subscription.Name()] = make(map[string]int64)
+	}
+	m.handled[subscription.Name()][Outcome(err)]++
+}
+
+// Returns number of messages of subscription handled with given outcome
+func (m *Metrics) Count(subscription Subscription, outcome string) int64 {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.handled[subscription.Name()][outcome]
+}
+
+// Http handler of metrics endpoint. Writes metrics in Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP pusu_messages_total Number of handled messages by subscription and outcome.")
+	fmt.Fprintln(w, "# TYPE pusu_messages_total counter")
+	var names []string
+	for name := range m.handled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var outcomes []string
+		for outcome := range m.handled[name] {
+			outcomes = append(outcomes, outcome)
+		}
+		sort.Strings(outcomes)
+		for _, outcome := range outcomes {
+			fmt.Fprintf(w, "pusu_messages_total{subscription=%q,outcome=%q} %d\n", name, outcome, m.handled[name][outcome])
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP pusu_circuit_state Circuit breaker state by subscription. 0: closed, 1: open, 2: half-open.")
+	fmt.Fprintln(w, "# TYPE pusu_circuit_state gauge")
+	names = nil
+	for name := range m.subscriptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if state, ok := CircuitStateOf(m.subscriptions[name]); ok {
+			fmt.Fprintf(w, "pusu_circuit_state{subscription=%q} %d\n", name, state)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package pusu
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutcome(t *testing.T) {
+	outcomes := map[error]string{
+		nil:                OutcomeSuccess,
+		errors.New("fail"): OutcomeFailure,
+		ErrTooManyInFlight: OutcomeTooManyInFlight,
+		ErrRateLimited:     OutcomeRateLimited,
+		ErrCircuitOpen:     OutcomeCircuitOpen,
+		ErrHandlerTimeout:  OutcomeTimeout,
+		ErrDeadLetter:      OutcomeDeadLetter,
+	}
+
+	for err, expected := range outcomes {
+		if actual := Outcome(err); actual != expected {
+			t.Errorf("Outcome of %v:\nExpected: %s\nActual: %s", err, expected, actual)
+		}
+	}
+}
+
+func TestMetrics_Observe(t *testing.T) {
+	metrics := new(Metrics)
+	subscription := new(failureSubscription)
+
+	metrics.Observe(subscription, nil)
+	metrics.Observe(subscription, nil)
+	metrics.Observe(subscription, ErrRateLimited)
+
+	if count := metrics.Count(subscription, OutcomeSuccess); count != 2 {
+		t.Errorf("Success count:\nExpected: 2\nActual: %d", count)
+	}
+	if count := metrics.Count(subscription, OutcomeRateLimited); count != 1 {
+		t.Errorf("Rate limited count:\nExpected: 1\nActual: %d", count)
+	}
+	if count := metrics.Count(subscription, OutcomeFailure); count != 0 {
+		t.Errorf("Failure count:\nExpected: 0\nActual: %d", count)
+	}
+}
+
+func TestMetrics_ServeHTTP(t *testing.T) {
+	// Register a subscription with circuit breaker and observe a message
+	metrics := new(Metrics)
+	subscription := WithCircuitBreaker(new(failureSubscription), 1, time.Minute)
+	metrics.Register(subscription)
+	metrics.Observe(subscription, subscription.Handle(new(Message)))
+
+	w := httptest.NewRecorder()
+	metrics.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// Counter and open circuit gauge must be exposed
+	expectedLines := []string{
+		`pusu_messages_total{subscription="testing",outcome="failure"} 1`,
+		`pusu_circuit_state{subscription="testing"} 1`,
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(w.Body.String(), line) {
+			t.Errorf("Metrics output must contain:\n%s\nActual:\n%s", line, w.Body.String())
+		}
+	}
+}
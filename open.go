@@ -0,0 +1,69 @@
+package pusu
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// Opener creates an adapter from a parsed DSN. Adapter packages register their openers on URL schemes.
+type Opener func(u *url.URL) (Adapter, error)
+
+var (
+	openersMutex sync.RWMutex
+
+	// Registered openers keyed by URL scheme
+	openers = make(map[string]Opener)
+)
+
+// Registers opener of an adapter for given URL scheme. Adapter packages call it in their init function,
+// so importing an adapter package (even as `_`) makes its schemes available to Open.
+// Panics if opener is nil or scheme is already registered.
+func RegisterScheme(scheme string, opener Opener) {
+	openersMutex.Lock()
+	defer openersMutex.Unlock()
+
+	if opener == nil {
+		panic("pusu: opener of scheme " + scheme + " is nil")
+	}
+	if _, ok := openers[scheme]; ok {
+		panic("pusu: scheme " + scheme + " is already registered")
+	}
+	openers[scheme] = opener
+}
+
+// Creates adapter of given DSN by its URL scheme, so broker may be picked from configuration or environment.
+// (Ex: gcp://my-project?host=https://service.appspot.com, kafka://localhost:9092, memory://)
+func Open(dsn string) (Adapter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("scheme of dsn %q is missing", dsn)
+	}
+
+	openersMutex.RLock()
+	opener, ok := openers[u.Scheme]
+	openersMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("scheme %s is not registered, forgotten import of adapter package?", u.Scheme)
+	}
+
+	return opener(u)
+}
+
+// Returns sorted list of registered URL schemes
+func Schemes() []string {
+	openersMutex.RLock()
+	defer openersMutex.RUnlock()
+
+	var schemes []string
+	for scheme := range openers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+
+	return schemes
+}
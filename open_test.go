@@ -0,0 +1,80 @@
+package pusu
+
+import (
+	"net/url"
+	"testing"
+)
+
+// Adapter returned by test opener
+type openedAdapter struct {
+	url *url.URL
+}
+
+func (o *openedAdapter) CreateSubscription(subscription Subscription) error {
+	return nil
+}
+
+func (o *openedAdapter) Run(subscription Subscription) error {
+	return nil
+}
+
+func init() {
+	RegisterScheme("pusutest", func(u *url.URL) (Adapter, error) {
+		return &openedAdapter{url: u}, nil
+	})
+}
+
+func TestOpen(t *testing.T) {
+	adapter, err := Open("pusutest://project?host=http://localhost:8080")
+	if err != nil {
+		t.Fatalf("Open must not fail. Actual error: %s", err)
+	}
+
+	// Opener of scheme must receive parsed dsn
+	opened, ok := adapter.(*openedAdapter)
+	if !ok {
+		t.Fatalf("Adapter of registered opener must be returned")
+	}
+	if opened.url.Host != "project" || opened.url.Query().Get("host") != "http://localhost:8080" {
+		t.Errorf("Dsn is not parsed properly.\nExpected: project http://localhost:8080\nActual: %s %s",
+			opened.url.Host, opened.url.Query().Get("host"))
+	}
+}
+
+func TestOpenErrorOnUnknownScheme(t *testing.T) {
+	if _, err := Open("unknown://localhost"); err == nil {
+		t.Error("Open must fail on a scheme which is not registered")
+	}
+}
+
+func TestOpenErrorOnMissingScheme(t *testing.T) {
+	if _, err := Open("localhost:9092"); err == nil {
+		t.Error("Open must fail on a dsn without scheme")
+	}
+	if _, err := Open(""); err == nil {
+		t.Error("Open must fail on an empty dsn")
+	}
+}
+
+func TestRegisterSchemePanicsOnDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Registering a scheme twice must panic")
+		}
+	}()
+
+	RegisterScheme("pusutest", func(u *url.URL) (Adapter, error) {
+		return nil, nil
+	})
+}
+
+func TestSchemes(t *testing.T) {
+	schemes := Schemes()
+	for _, scheme := range schemes {
+		if scheme == "pusutest" {
+			return
+		}
+	}
+
+	t.Errorf("Registered scheme must be listed.\nExpected: pusutest\nActual: %v", schemes)
+}
@@ -0,0 +1,72 @@
+package pusu
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Provisioner provisions subscriptions of an adapter and tracks their health and metrics. Adapters embed it and
+// create subscriptions through Provision, so validation, readiness and metrics work the same on every transport.
+// The zero value of Provisioner is ready to use.
+type Provisioner struct {
+	// Tracks provisioned subscriptions and readiness checks for liveness and readiness probes
+	health Health
+
+	// Counts outcomes of handled messages and reports circuit breaker states
+	metrics Metrics
+}
+
+// Validates subscription, then creates it with given creators in order. Subscriber is not ready until every creator
+// succeeds. Filtered subscriptions are rejected unless a creator implements FilterEnforcer, since their handlers would
+// receive every message.
+func (p *Provisioner) Provision(subscription Subscription, creators ...Creator) error {
+	err := ValidateSubscription(subscription)
+	if err != nil {
+		return err
+	}
+	if _, ok := FilterOf(subscription); ok && !enforcesFilters(creators) {
+		return fmt.Errorf("subscription %s has a filter, but adapter can't enforce filters", subscription.Name())
+	}
+
+	// Subscriber is not ready until subscription is provisioned
+	p.health.Register(subscription)
+	p.metrics.Register(subscription)
+
+	for _, creator := range creators {
+		err = creator.CreateSubscription(subscription)
+		if err != nil {
+			return err
+		}
+	}
+
+	p.health.Provisioned(subscription)
+
+	return nil
+}
+
+// Adds a readiness check (e.g. database ping) which must pass before subscriber reports ready on readiness probe
+func (p *Provisioner) AddReadinessCheck(name string, check ReadinessCheck) {
+	p.health.AddReadinessCheck(name, check)
+}
+
+// Returns health of adapter. Its liveness and readiness handlers may be served on any http server.
+func (p *Provisioner) Health() *Health {
+	return &p.health
+}
+
+// Returns metrics of adapter. It may be served on any http server.
+func (p *Provisioner) Metrics() *Metrics {
+	return &p.metrics
+}
+
+// Checks that subscription has a name and a topic
+func ValidateSubscription(subscription Subscription) error {
+	if subscription.Name() == "" {
+		return errors.New("Subscription name must not be empty. ")
+	}
+	if subscription.Topic() == "" {
+		return errors.New("Subscription topic must not be empty. ")
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package pusu
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProvisioner_ProvisionErrorOnEmptyFields(t *testing.T) {
+	provisioner := new(Provisioner)
+	creator := new(countingCreator)
+
+	// Subscriptions without topic or name must be rejected before any creator is called
+	for _, subscription := range []Subscription{&namedSubscription{name: "testing"}, &namedSubscription{topic: "test"}} {
+		if provisioner.Provision(subscription, creator) == nil {
+			t.Errorf("Subscription %+v must be rejected", subscription)
+		}
+	}
+	if creator.calls != 0 {
+		t.Errorf("Creator call count:\nExpected: 0\nActual: %d", creator.calls)
+	}
+}
+
+func TestProvisioner_Provision(t *testing.T) {
+	provisioner := new(Provisioner)
+	first, second := new(countingCreator), new(countingCreator)
+
+	// Every creator must be called and subscriber must be ready
+	if err := provisioner.Provision(new(failureSubscription), first, second); err != nil {
+		t.Errorf("Provision error: %s", err)
+	}
+	if first.calls != 1 || second.calls != 1 {
+		t.Errorf("Creator call counts:\nExpected: 1, 1\nActual: %d, %d", first.calls, second.calls)
+	}
+	if err := provisioner.Health().Ready(); err != nil {
+		t.Errorf("Subscriber must be ready after provisioning. Actual error: %s", err)
+	}
+}
+
+func TestProvisioner_ProvisionOnCreatorFailure(t *testing.T) {
+	provisioner := new(Provisioner)
+	failing := &countingCreator{err: errors.New("error")}
+	next := new(countingCreator)
+
+	// Provisioning must stop at failing creator and subscriber must not be ready
+	if provisioner.Provision(new(failureSubscription), failing, next) == nil {
+		t.Error("Provision must return error of creator")
+	}
+	if next.calls != 0 {
+		t.Errorf("Creators after failing one must not be called. Actual calls: %d", next.calls)
+	}
+	if provisioner.Health().Ready() == nil {
+		t.Error("Subscriber must not be ready when provisioning fails")
+	}
+
+	// Readiness checks must be added to health of provisioner
+	provisioner = new(Provisioner)
+	provisioner.Provision(new(failureSubscription))
+	provisioner.AddReadinessCheck("database", func() error {
+		return errors.New("connection refused")
+	})
+	if provisioner.Health().Ready() == nil {
+		t.Error("Subscriber must not be ready when a readiness check fails")
+	}
+}
+
+func TestProvisioner_ProvisionFiltered(t *testing.T) {
+	provisioner := new(Provisioner)
+	creator := new(countingCreator)
+	filtered := WithFilter(&namedSubscription{topic: "test", name: "testing"}, `attributes.kind = "order"`)
+
+	// Filtered subscriptions must be rejected unless a creator enforces filters
+	if provisioner.Provision(filtered, creator) == nil {
+		t.Error("Filtered subscription must be rejected when no creator enforces filters")
+	}
+	if creator.calls != 0 {
+		t.Errorf("Creator call count:\nExpected: 0\nActual: %d", creator.calls)
+	}
+	if err := provisioner.Provision(filtered, creator, new(filteringCreator)); err != nil {
+		t.Errorf("Provision error: %s", err)
+	}
+}
+
+// A creator which counts created subscriptions and returns err
+type countingCreator struct {
+	calls int
+	err   error
+}
+
+func (c *countingCreator) CreateSubscription(subscription Subscription) error {
+	c.calls++
+	return c.err
+}
+
+// A subscription with given topic and name
+type namedSubscription struct {
+	topic string
+	name  string
+}
+
+func (n *namedSubscription) Topic() string {
+	return n.topic
+}
+
+func (n *namedSubscription) Name() string {
+	return n.name
+}
+
+func (n *namedSubscription) Handle(m *Message) error {
+	return nil
+}
+
+// A creator which enforces filters
+type filteringCreator struct {
+	countingCreator
+}
+
+func (f *filteringCreator) EnforcesFilters() bool {
+	return true
+}
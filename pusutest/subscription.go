@@ -0,0 +1,56 @@
+// Package pusutest provides fakes for testing adapters and subscribers of pusu.
+package pusutest
+
+import (
+	"errors"
+	"github.com/metglobal-compass/pusu"
+	"github.com/stretchr/testify/mock"
+)
+
+// A fake subscription whose topic, name and handler results are set with testify expectations
+type Subscription struct {
+	mock.Mock
+}
+
+func (f *Subscription) Handle(m *pusu.Message) error {
+	args := f.Called(m)
+	return args.Error(0)
+}
+
+func (f *Subscription) Topic() string {
+	args := f.Called()
+	return args.String(0)
+}
+
+func (f *Subscription) Name() string {
+	args := f.Called()
+	return args.String(0)
+}
+
+// Sets topic of subscription
+func (f *Subscription) WithTopic(topic string) *Subscription {
+	f.On("Topic").Return(topic)
+	return f
+}
+
+// Sets name of subscription
+func (f *Subscription) WithName(name string) *Subscription {
+	f.On("Name").Return(name)
+	return f
+}
+
+// Sets error returned by handler of subscription
+func (f *Subscription) WithReturning(err error) *Subscription {
+	f.On("Handle", mock.Anything).Return(err)
+	return f
+}
+
+// Returns subscription of topic test named testing, which handles every message successfully
+func (f *Subscription) WillHaveProperFields() pusu.Subscription {
+	return f.WithTopic("test").WithName("testing").WithReturning(nil)
+}
+
+// Returns subscription of topic test named testing, which fails to handle every message
+func (f *Subscription) WillReturnError() *Subscription {
+	return f.WithTopic("test").WithName("testing").WithReturning(errors.New("error"))
+}
@@ -0,0 +1,61 @@
+package pusu
+
+import (
+	"context"
+	"golang.org/x/time/rate"
+)
+
+// Subscription wrapper which throttles handled messages through a token bucket
+type rateLimiter struct {
+	Subscription
+	limiter *rate.Limiter
+}
+
+// Wraps subscription so that at most perSecond messages are handled per second, allowing bursts of burst messages.
+// In push mode messages are rejected with ErrRateLimited when bucket is empty. In pull mode adapters use
+// HandleBlocking which waits for a token instead. Non-positive perSecond means no limit.
+func LimitRate(subscription Subscription, perSecond float64, burst int) Subscription {
+	if perSecond <= 0 {
+		return subscription
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+
+	r := new(rateLimiter)
+	r.Subscription = subscription
+	r.limiter = rate.NewLimiter(rate.Limit(perSecond), burst)
+
+	return r
+}
+
+// Handles message if there is a token in bucket, otherwise returns ErrRateLimited without waiting
+func (r *rateLimiter) Handle(m *Message) error {
+	if !r.limiter.Allow() {
+		return ErrRateLimited
+	}
+
+	return r.Subscription.Handle(m)
+}
+
+// Waits for a token in bucket and handles message. Returns error of context if it is done before. Returns
+// ErrHandlerTimeout without waiting if a token would not be available before deadline of context, which limiter
+// tells early.
+func (r *rateLimiter) HandleBlocking(ctx context.Context, m *Message) error {
+	if err := r.limiter.Wait(ctx); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if _, ok := ctx.Deadline(); ok {
+			return ErrHandlerTimeout
+		}
+		return err
+	}
+
+	return HandleBlocking(ctx, r.Subscription, m)
+}
+
+// Returns wrapped subscription
+func (r *rateLimiter) Unwrap() Subscription {
+	return r.Subscription
+}
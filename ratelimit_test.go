@@ -0,0 +1,99 @@
+package pusu
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimitRate(t *testing.T) {
+	// Create a rate limited subscription which allows a single message per minute
+	subscription := new(countingSubscription)
+	limited := LimitRate(subscription, 1.0/60, 1)
+
+	// Topic and name must be kept as is
+	if limited.Topic() != "test" || limited.Name() != "testing" {
+		t.Errorf("Limited subscription must keep topic and name. Actual: %s %s", limited.Topic(), limited.Name())
+	}
+
+	// First message consumes the only token
+	if err := limited.Handle(new(Message)); err != nil {
+		t.Errorf("First message must be handled. Actual error: %s", err)
+	}
+
+	// Second message must be rejected without calling real handler
+	if err := limited.Handle(new(Message)); err != ErrRateLimited {
+		t.Errorf("Handle error:\nExpected: %v\nActual: %v", ErrRateLimited, err)
+	}
+	if subscription.calls != 1 {
+		t.Errorf("Real handler call count:\nExpected: 1\nActual: %d", subscription.calls)
+	}
+}
+
+func TestLimitRateHandleBlocking(t *testing.T) {
+	// Create a rate limited subscription which allows 100 messages per second without burst
+	subscription := new(countingSubscription)
+	limited := LimitRate(subscription, 100, 1)
+
+	// Blocking handle must wait for tokens instead of rejecting
+	for i := 0; i < 3; i++ {
+		if err := HandleBlocking(context.Background(), limited, new(Message)); err != nil {
+			t.Errorf("Message must be handled after waiting. Actual error: %s", err)
+		}
+	}
+	if subscription.calls != 3 {
+		t.Errorf("Real handler call count:\nExpected: 3\nActual: %d", subscription.calls)
+	}
+
+	// Blocking handle must give up with timeout error when a token would not be available before deadline
+	limited = LimitRate(subscription, 1.0/60, 1)
+	limited.Handle(new(Message))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := HandleBlocking(ctx, limited, new(Message)); err != ErrHandlerTimeout {
+		t.Errorf("Handle error:\nExpected: %v\nActual: %v", ErrHandlerTimeout, err)
+	}
+
+	// Blocking handle must return as soon as a context without deadline is cancelled
+	ctx, cancel = context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- HandleBlocking(ctx, limited, new(Message))
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Handle error:\nExpected: %v\nActual: %v", context.Canceled, err)
+		}
+	case <-time.After(time.Second):
+		t.Error("Blocking handle must not block after context is cancelled")
+	}
+}
+
+func TestLimitRateUnlimited(t *testing.T) {
+	// Non-positive rate must return subscription as is
+	subscription := new(failureSubscription)
+
+	if LimitRate(subscription, 0, 10) != Subscription(subscription) {
+		t.Error("Subscription must not be wrapped when rate is not positive")
+	}
+}
+
+// A subscription which counts handled messages
+type countingSubscription struct {
+	calls int
+}
+
+func (c *countingSubscription) Topic() string {
+	return "test"
+}
+
+func (c *countingSubscription) Name() string {
+	return "testing"
+}
+
+func (c *countingSubscription) Handle(m *Message) error {
+	c.calls++
+	return nil
+}
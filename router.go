@@ -0,0 +1,215 @@
+package pusu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Returned by a Router for messages which none of its routes matches, unless they are acknowledged
+var ErrUnmatched = errors.New("no route matches message")
+
+// Handles messages dispatched by a Router
+type Handler func(m *Message) error
+
+// Outcome of messages which none of routes of a Router matches and which have no default handler
+type UnmatchedOutcome int
+
+const (
+	// Unmatched messages are acknowledged without handling, i.e. dropped
+	AckUnmatched UnmatchedOutcome = iota
+
+	// Unmatched messages fail with ErrUnmatched, so they are retried
+	NackUnmatched
+
+	// Unmatched messages fail with ErrUnmatched and ErrDeadLetter, so they are dead lettered without retries
+	DeadLetterUnmatched
+)
+
+// Router is a subscription which dispatches messages of a topic carrying several kinds of messages to handlers, by
+// attribute value, CloudEvents type or a field of JSON payload. Routes are tried in order they are added and the first
+// matching route handles the message.
+type Router struct {
+	topic string
+	name  string
+
+	routes []route
+
+	// Handles messages which none of routes matches. Optional.
+	fallback Handler
+
+	// Outcome of unmatched messages if there is no default handler
+	unmatched UnmatchedOutcome
+
+	// Whether one of routes matches by attribute
+	byAttribute bool
+}
+
+// Route of a Router
+type route struct {
+	matches func(m *routedMessage) bool
+	handler Handler
+}
+
+// Message being routed, which is decoded lazily and at most once by routes
+type routedMessage struct {
+	*Message
+
+	event        *CloudEvent
+	eventDecoded bool
+
+	fields        map[string]interface{}
+	fieldsDecoded bool
+}
+
+// Creates router subscription of topic with given subscription name. Unmatched messages are acknowledged, unless
+// outcome is changed with Unmatched.
+func NewRouter(topic string, name string) *Router {
+	r := new(Router)
+	r.topic = topic
+	r.name = name
+
+	return r
+}
+
+// Routes messages with given attribute value to handler. Key is matched case insensitively, since attributes of
+// headers have lower case keys. Adapters which can't carry attributes reject routers with such routes, see
+// RoutesByAttribute.
+func (r *Router) OnAttribute(key string, value string, handler Handler) *Router {
+	r.byAttribute = true
+	return r.on(func(m *routedMessage) bool {
+		v, ok := lookupAttribute(m.Attributes(), key)
+		return ok && v == value
+	}, handler)
+}
+
+// Routes CloudEvents of given type to handler, carried by message in either binary or structured content mode.
+// Messages which don't carry a CloudEvent never match.
+func (r *Router) OnEventType(eventType string, handler Handler) *Router {
+	return r.on(func(m *routedMessage) bool {
+		e := m.cloudEvent()
+		return e != nil && e.Type == eventType
+	}, handler)
+}
+
+// Routes messages whose JSON payload has given value at field to handler. Field is a dot separated path of object keys,
+// e.g. event.type. Values which are not strings are compared in their JSON form, e.g. 1 or true.
+// Messages which are not JSON objects never match.
+func (r *Router) OnField(field string, value string, handler Handler) *Router {
+	path := strings.Split(field, ".")
+	return r.on(func(m *routedMessage) bool {
+		v, ok := m.field(path)
+		return ok && v == value
+	}, handler)
+}
+
+// Handles messages which none of routes matches with handler, instead of applying outcome of unmatched messages
+func (r *Router) Default(handler Handler) *Router {
+	r.fallback = handler
+	return r
+}
+
+// Sets outcome of messages which none of routes matches, if there is no default handler
+func (r *Router) Unmatched(outcome UnmatchedOutcome) *Router {
+	r.unmatched = outcome
+	return r
+}
+
+// Returns the name of topic
+func (r *Router) Topic() string {
+	return r.topic
+}
+
+// Returns the name of subscription
+func (r *Router) Name() string {
+	return r.name
+}
+
+// Handles message with handler of first matching route, or with default handler if none of routes matches
+func (r *Router) Handle(m *Message) error {
+	routed := &routedMessage{Message: m}
+	for _, route := range r.routes {
+		if route.matches(routed) {
+			return route.handler(m)
+		}
+	}
+
+	if r.fallback != nil {
+		return r.fallback(m)
+	}
+
+	switch r.unmatched {
+	case NackUnmatched:
+		return ErrUnmatched
+	case DeadLetterUnmatched:
+		return fmt.Errorf("%w: %w", ErrDeadLetter, ErrUnmatched)
+	default:
+		return nil
+	}
+}
+
+// Reports whether given subscription is a Router with routes by attribute, looking through subscription wrappers.
+// Adapters which can't carry attributes reject such subscriptions when they are created.
+func RoutesByAttribute(subscription Subscription) bool {
+	for ; subscription != nil; subscription = Unwrap(subscription) {
+		if r, ok := subscription.(*Router); ok {
+			return r.byAttribute
+		}
+	}
+
+	return false
+}
+
+// Adds route with given matcher
+func (r *Router) on(matches func(m *routedMessage) bool, handler Handler) *Router {
+	r.routes = append(r.routes, route{matches: matches, handler: handler})
+	return r
+}
+
+// Returns CloudEvent carried by message. Returns nil if message does not carry a CloudEvent.
+func (m *routedMessage) cloudEvent() *CloudEvent {
+	if !m.eventDecoded {
+		m.eventDecoded = true
+		if e, ok := CloudEventOf(m.Message); ok {
+			m.event = &e
+		}
+	}
+
+	return m.event
+}
+
+// Returns value of JSON payload at path of object keys. Reports false if payload has no such field.
+func (m *routedMessage) field(path []string) (string, bool) {
+	if !m.fieldsDecoded {
+		m.fieldsDecoded = true
+		switch payload := m.Message.Message().(type) {
+		case string:
+			_ = json.Unmarshal([]byte(payload), &m.fields)
+		case []byte:
+			_ = json.Unmarshal(payload, &m.fields)
+		}
+	}
+
+	var value interface{} = m.fields
+	for _, key := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		value, ok = object[key]
+		if !ok {
+			return "", false
+		}
+	}
+
+	if s, ok := value.(string); ok {
+		return s, true
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", false
+	}
+
+	return string(encoded), true
+}
@@ -0,0 +1,102 @@
+package pusu
+
+import (
+	"errors"
+	"testing"
+)
+
+// Returns handler which records name of route handling message
+func routeHandler(routed *string, name string) Handler {
+	return func(m *Message) error {
+		*routed = name
+		return nil
+	}
+}
+
+func TestRouter_Handle(t *testing.T) {
+	var routed string
+	router := NewRouter("test", "testing").
+		OnAttribute("kind", "order", routeHandler(&routed, "attribute")).
+		OnEventType("com.example.order.created", routeHandler(&routed, "event")).
+		OnField("event.type", "deleted", routeHandler(&routed, "field")).
+		OnField("version", "2", routeHandler(&routed, "number"))
+
+	if router.Topic() != "test" || router.Name() != "testing" {
+		t.Errorf("Error: Expected: %s/%s, Actual: %s/%s", "test", "testing", router.Topic(), router.Name())
+	}
+
+	event := CloudEvent{ID: "1", Source: "//orders", SpecVersion: CloudEventSpecVersion, Type: "com.example.order.created"}
+	messages := map[string]*Message{
+		"attribute": NewMessage(`{"event": {"type": "deleted"}}`).WithAttributes(map[string]string{"kind": "order"}),
+		"event":     event.Message(),
+		"field":     NewMessage([]byte(`{"event": {"type": "deleted"}}`)),
+		"number":    NewMessage(`{"version": 2}`),
+	}
+	for expected, message := range messages {
+		routed = ""
+		err := router.Handle(message)
+		if err != nil || routed != expected {
+			t.Errorf("Error: Expected: %s, Actual: %s, %v", expected, routed, err)
+		}
+	}
+}
+
+func TestRouter_HandleAttributeOfHeader(t *testing.T) {
+	var routed string
+	router := NewRouter("test", "testing").OnAttribute("X-GitHub-Event", "push", routeHandler(&routed, "attribute"))
+
+	// Attribute keys of headers are lower case, so keys must be matched case insensitively
+	err := router.Handle(NewMessage("{}").WithAttributes(map[string]string{"x-github-event": "push"}))
+	if err != nil || routed != "attribute" {
+		t.Errorf("Error: Expected: %s, Actual: %s, %v", "attribute", routed, err)
+	}
+}
+
+func TestRouter_HandleUnmatched(t *testing.T) {
+	var routed string
+	handled := errors.New("handled")
+	router := NewRouter("test", "testing").OnField("type", "created", func(m *Message) error {
+		return handled
+	})
+
+	// Errors of handlers must be returned
+	if err := router.Handle(NewMessage(`{"type": "created"}`)); err != handled {
+		t.Errorf("Error: Expected: %v, Actual: %v", handled, err)
+	}
+
+	// Unmatched messages must be acknowledged by default
+	unmatched := []*Message{NewMessage(`{"type": "deleted"}`), NewMessage("not json"), NewMessage(1), NewMessage(`[]`)}
+	for _, message := range unmatched {
+		if err := router.Handle(message); err != nil {
+			t.Errorf("Unmatched message %v must be acknowledged, got %v", message.Message(), err)
+		}
+	}
+
+	// Unmatched messages must fail if they are nacked or dead lettered
+	err := router.Unmatched(NackUnmatched).Handle(unmatched[0])
+	if !errors.Is(err, ErrUnmatched) || errors.Is(err, ErrDeadLetter) {
+		t.Errorf("Error: Expected: %v, Actual: %v", ErrUnmatched, err)
+	}
+	err = router.Unmatched(DeadLetterUnmatched).Handle(unmatched[0])
+	if !errors.Is(err, ErrUnmatched) || !errors.Is(err, ErrDeadLetter) {
+		t.Errorf("Error: Expected: %v, Actual: %v", ErrDeadLetter, err)
+	}
+
+	// Default handler must handle unmatched messages regardless of outcome
+	err = router.Default(routeHandler(&routed, "default")).Handle(unmatched[0])
+	if err != nil || routed != "default" {
+		t.Errorf("Error: Expected: %s, Actual: %s, %v", "default", routed, err)
+	}
+}
+
+func TestRoutesByAttribute(t *testing.T) {
+	// Routers with a route by attribute must be reported through subscription wrappers
+	router := NewRouter("test", "testing").OnField("type", "created", routeHandler(new(string), "field"))
+	if RoutesByAttribute(LimitConcurrency(router, 1)) {
+		t.Error("Router without routes by attribute must not be reported")
+	}
+	router.OnAttribute("kind", "order", routeHandler(new(string), "attribute"))
+	if !RoutesByAttribute(LimitConcurrency(router, 1)) {
+		t.Error("Router with routes by attribute must be reported")
+	}
+}
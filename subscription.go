@@ -1,5 +1,7 @@
 package pusu
 
+import "context"
+
 type Subscription interface {
 	// Returns the name of topic
 	Topic() string
@@ -12,3 +14,31 @@ type Subscription interface {
 	// If function return a non-nil error type, adapter try again for later attempt until gets a successful response.
 	Handle(m *Message) error
 }
+
+// Subscriptions which are able to wait for capacity (rate limits, concurrency limits etc.) instead of rejecting
+// messages implement BlockingHandler.
+type BlockingHandler interface {
+	// Handles the pub/sub message, waiting for capacity until context is done.
+	HandleBlocking(ctx context.Context, m *Message) error
+}
+
+// Handles message with given subscription. If subscription implements BlockingHandler, it waits for capacity
+// instead of rejecting the message. Pull based adapters must handle messages through this function.
+func HandleBlocking(ctx context.Context, subscription Subscription, m *Message) error {
+	if blocking, ok := subscription.(BlockingHandler); ok {
+		return blocking.HandleBlocking(ctx, m)
+	}
+
+	return subscription.Handle(m)
+}
+
+// Returns subscription wrapped by given subscription wrapper (LimitConcurrency, LimitRate etc.).
+// Returns nil if given subscription does not wrap another one.
+func Unwrap(subscription Subscription) Subscription {
+	wrapper, ok := subscription.(interface{ Unwrap() Subscription })
+	if !ok {
+		return nil
+	}
+
+	return wrapper.Unwrap()
+}
@@ -1,6 +1,7 @@
 package pusu
 
 import (
+	"context"
 	"errors"
 	"testing"
 )
@@ -27,6 +28,15 @@ func TestNewSubscription(t *testing.T) {
 	}
 }
 
+func TestHandleBlocking(t *testing.T) {
+	// Subscription which does not implement BlockingHandler must be handled directly
+	err := HandleBlocking(context.Background(), new(failureSubscription), new(Message))
+
+	if err == nil || err.Error() != "test error" {
+		t.Errorf("Subscriber error:\nExpected error message:\n%s \nActual:\n%v", "test error", err)
+	}
+}
+
 type failureSubscription struct {
 }
 
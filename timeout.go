@@ -0,0 +1,106 @@
+package pusu
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Subscription wrapper which gives up handling of a message after a timeout
+type timeoutHandler struct {
+	Subscription
+	timeout time.Duration
+}
+
+// Wraps subscription so that handling of a message is given up after timeout with ErrHandlerTimeout.
+// Context of message is cancelled on timeout, so handlers should watch it to stop their work.
+// Timeout set with WithTimeout overrides default handler timeout of adapters. Non-positive timeout means no timeout.
+// Handler keeps running after timeout until it returns, so wrappers outside of it (e.g. LimitConcurrency) don't
+// account for it anymore. Wrap timeouts outside of concurrency limits, see LimitConcurrency.
+func WithTimeout(subscription Subscription, timeout time.Duration) Subscription {
+	if timeout <= 0 {
+		return subscription
+	}
+
+	t := new(timeoutHandler)
+	t.Subscription = subscription
+	t.timeout = timeout
+
+	return t
+}
+
+// Handles message, giving up after timeout
+func (t *timeoutHandler) Handle(m *Message) error {
+	return handleWithTimeout(t.Subscription.Handle, m, t.timeout)
+}
+
+// Handles message waiting for capacity of wrapped subscription, giving up after timeout. Waiting counts to timeout.
+func (t *timeoutHandler) HandleBlocking(ctx context.Context, m *Message) error {
+	return handleWithTimeout(func(m *Message) error {
+		return HandleBlocking(m.Context(), t.Subscription, m)
+	}, m.WithContext(ctx), t.timeout)
+}
+
+// Returns timeout of handler
+func (t *timeoutHandler) HandlerTimeout() time.Duration {
+	return t.timeout
+}
+
+// Returns wrapped subscription
+func (t *timeoutHandler) Unwrap() Subscription {
+	return t.Subscription
+}
+
+// Returns handler timeout of given subscription set with WithTimeout, looking through subscription wrappers.
+// Reports false if subscription has no handler timeout.
+func HandlerTimeoutOf(subscription Subscription) (time.Duration, bool) {
+	for ; subscription != nil; subscription = Unwrap(subscription) {
+		if handler, ok := subscription.(*timeoutHandler); ok {
+			return handler.HandlerTimeout(), true
+		}
+	}
+
+	return 0, false
+}
+
+// Handles message with given subscription, giving up after timeout with ErrHandlerTimeout.
+// Adapters use it to enforce their default timeout, e.g. ack deadline of cloud vendor. Timeout set on subscription
+// with WithTimeout overrides given default timeout. Non-positive timeout means no timeout.
+func HandleWithTimeout(subscription Subscription, m *Message, timeout time.Duration) error {
+	if _, ok := HandlerTimeoutOf(subscription); ok {
+		// Timeout is enforced by subscription wrapper itself
+		return subscription.Handle(m)
+	}
+
+	return handleWithTimeout(subscription.Handle, m, timeout)
+}
+
+// Runs handler in a separate goroutine and waits for its result until timeout or cancellation of message context.
+// Handler keeps running after timeout, but context of message is cancelled.
+func handleWithTimeout(handle func(m *Message) error, m *Message, timeout time.Duration) error {
+	if timeout <= 0 {
+		return handle(m)
+	}
+
+	ctx, cancel := context.WithTimeout(m.Context(), timeout)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- handle(m.WithContext(ctx))
+	}()
+
+	select {
+	case err := <-result:
+		// Handler may return error of its context before it is noticed here
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == context.DeadlineExceeded {
+			return ErrHandlerTimeout
+		}
+		return err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return ErrHandlerTimeout
+		}
+		return ctx.Err()
+	}
+}
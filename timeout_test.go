@@ -0,0 +1,95 @@
+package pusu
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	// Create a subscription which blocks until its message context is done
+	subscription := new(contextSubscription)
+	limited := WithTimeout(subscription, 10*time.Millisecond)
+
+	// Handling must be given up with timeout error
+	if err := limited.Handle(new(Message)); err != ErrHandlerTimeout {
+		t.Errorf("Handle error:\nExpected: %v\nActual: %v", ErrHandlerTimeout, err)
+	}
+
+	// Declared timeout must be found through subscription wrappers
+	timeout, ok := HandlerTimeoutOf(LimitConcurrency(limited, 10))
+	if !ok || timeout != 10*time.Millisecond {
+		t.Errorf("Handler timeout:\nExpected: %s\nActual: %s", 10*time.Millisecond, timeout)
+	}
+}
+
+func TestWithTimeoutHandleBlocking(t *testing.T) {
+	// Waiting for capacity of wrapped subscription must count to timeout
+	limited := WithTimeout(LimitRate(new(countingSubscription), 1.0/60, 1), 10*time.Millisecond)
+
+	if err := HandleBlocking(context.Background(), limited, new(Message)); err != nil {
+		t.Errorf("First message must be handled. Actual error: %s", err)
+	}
+	if err := HandleBlocking(context.Background(), limited, new(Message)); err != ErrHandlerTimeout {
+		t.Errorf("Handle error:\nExpected: %v\nActual: %v", ErrHandlerTimeout, err)
+	}
+}
+
+func TestWithTimeoutNoTimeout(t *testing.T) {
+	// Non-positive timeout must return subscription as is
+	subscription := new(failureSubscription)
+
+	if WithTimeout(subscription, 0) != Subscription(subscription) {
+		t.Error("Subscription must not be wrapped when timeout is not positive")
+	}
+	if _, ok := HandlerTimeoutOf(subscription); ok {
+		t.Error("Subscription without timeout must not report handler timeout")
+	}
+}
+
+func TestHandleWithTimeout(t *testing.T) {
+	// Default timeout must be enforced on subscriptions without their own timeout
+	if err := HandleWithTimeout(new(contextSubscription), new(Message), 10*time.Millisecond); err != ErrHandlerTimeout {
+		t.Errorf("Handle error:\nExpected: %v\nActual: %v", ErrHandlerTimeout, err)
+	}
+
+	// Timeout of subscription must override default timeout
+	subscription := WithTimeout(new(contextSubscription), 10*time.Millisecond)
+	start := time.Now()
+	if err := HandleWithTimeout(subscription, new(Message), time.Minute); err != ErrHandlerTimeout {
+		t.Errorf("Handle error:\nExpected: %v\nActual: %v", ErrHandlerTimeout, err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Timeout of subscription must override default timeout")
+	}
+
+	// Cancellation of message context must not be reported as timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := HandleWithTimeout(new(contextSubscription), new(Message).WithContext(ctx), time.Minute); err != context.Canceled {
+		t.Errorf("Handle error:\nExpected: %v\nActual: %v", context.Canceled, err)
+	}
+
+	// Handler result must be returned when it completes in time
+	err := HandleWithTimeout(new(failureSubscription), new(Message), time.Minute)
+	if err == nil || err.Error() != "test error" {
+		t.Errorf("Subscriber error:\nExpected error message:\n%s \nActual:\n%v", "test error", err)
+	}
+}
+
+// A subscription which blocks until context of message is done
+type contextSubscription struct {
+}
+
+func (c *contextSubscription) Topic() string {
+	return "test"
+}
+
+func (c *contextSubscription) Name() string {
+	return "testing"
+}
+
+func (c *contextSubscription) Handle(m *Message) error {
+	<-m.Context().Done()
+	return m.Context().Err()
+}